@@ -0,0 +1,247 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/hambosto/sweetbyte/internal/archive"
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/recipient"
+	"github.com/hambosto/sweetbyte/internal/reporter"
+	"github.com/hambosto/sweetbyte/internal/storage"
+	"github.com/hambosto/sweetbyte/internal/types"
+	"github.com/hambosto/sweetbyte/internal/ui/display"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createExtractCommand() *cobra.Command {
+	var (
+		directory    string
+		password     []byte
+		keyfilePath  string
+		identityPath string
+		paths        []string
+		force        bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "extract ARCHIVE",
+		Short: "Extract an encrypted " + config.ArchiveExtension + " archive",
+		Long:  "Decrypts an archive made with encrypt --archive and recreates its directories, files and symbolic links with their permissions and modification times. Entries that would land outside the destination are refused. With --path only the named entries, and everything below those that are directories, are extracted, and only the parts of the archive holding them are decrypted. Archives have no table of contents, so finding the entries still decrypts the chunk holding every tar header; for an archive of many small files that is most of the archive.",
+		Example: `  sweetbyte extract projects.swb
+  sweetbyte extract projects.swb -C /restore -p "$BACKUP_PASSWORD"
+  sweetbyte extract projects.swb --path projects/docs/report.pdf
+  sweetbyte extract payroll.swb --identity alice.key --force`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeArchive,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(paths) > 0 && (processor.IsStdio(args[0]) || storage.IsRemote(args[0])) {
+				return errors.Newf(errors.CodeInvalidInput, "--path", "extracting single entries needs a local archive it can seek in")
+			}
+			opts, err := archiveOptions(keyfilePath, identityPath)
+			if err != nil {
+				return err
+			}
+			if len(password) == 0 && needsPassword(opts) {
+				if password, err = c.promptDecryptionPassword(); err != nil {
+					return fmt.Errorf("failed to get password: %w", err)
+				}
+			}
+
+			opts.Reporter = display.NewReporter(nil)
+			var entries []archive.Entry
+			if err := runCancelable(func(ctx context.Context) error {
+				if len(paths) > 0 {
+					entries, err = archive.ExtractPaths(ctx, args[0], directory, password, paths, force, opts.WithTuning(config.LoadTuning()))
+					return err
+				}
+				entries, err = archive.Extract(ctx, args[0], directory, password, force, opts.WithTuning(config.LoadTuning()))
+				return err
+			}); err != nil {
+				return err
+			}
+			display.ShowInfo(fmt.Sprintf("Extracted %d entries into %s", len(entries), directory))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&directory, "directory", "C", ".", "Directory to extract into")
+	cmd.Flags().VarP(c.passwordFlag(&password), "password", "p", "Decryption password (prompts if not provided)")
+	cmd.Flags().StringVarP(&keyfilePath, "keyfile", "k", "", "Keyfile used when the archive was encrypted")
+	cmd.Flags().StringVar(&identityPath, "identity", "", "Private key for archives encrypted with --recipient")
+	cmd.Flags().StringArrayVar(&paths, "path", nil, "Only extract this entry, or this directory and everything in it, as listed by list (repeatable); every tar header is still read to find it")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Replace files that already exist")
+	return cmd
+}
+
+func (c *CLI) createListCommand() *cobra.Command {
+	var (
+		password     []byte
+		keyfilePath  string
+		identityPath string
+		format       string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list ARCHIVE",
+		Short: "List the entries of an encrypted " + config.ArchiveExtension + " archive",
+		Long:  "Decrypts an archive in memory and prints the path, permissions, size and modification time of every entry. Nothing is written to disk, and the whole archive is authenticated.",
+		Example: `  sweetbyte list projects.swb
+  sweetbyte list projects.swb -p "$BACKUP_PASSWORD" --format json`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeArchive,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "text" && format != "json" {
+				return errors.Newf(errors.CodeInvalidInput, "list", "unsupported format %q", format)
+			}
+
+			opts, err := archiveOptions(keyfilePath, identityPath)
+			if err != nil {
+				return err
+			}
+			if len(password) == 0 && needsPassword(opts) {
+				if password, err = c.promptDecryptionPassword(); err != nil {
+					return fmt.Errorf("failed to get password: %w", err)
+				}
+			}
+
+			opts.Reporter = reporter.Nop()
+			entries, err := archive.List(cmd.Context(), args[0], password, opts.WithTuning(config.LoadTuning()))
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if format == "json" {
+				encoder := json.NewEncoder(out)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(entries)
+			}
+			for _, entry := range entries {
+				printArchiveEntry(out, entry)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().VarP(c.passwordFlag(&password), "password", "p", "Decryption password (prompts if not provided)")
+	cmd.Flags().StringVarP(&keyfilePath, "keyfile", "k", "", "Keyfile used when the archive was encrypted")
+	cmd.Flags().StringVar(&identityPath, "identity", "", "Private key for archives encrypted with --recipient")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+	return cmd
+}
+
+func (c *CLI) runArchive(root, outputFile string, password []byte, force, dictionary bool, opts processor.Options) error {
+	if outputFile == "" {
+		outputFile = archive.OutputPath(root)
+	}
+	if !processor.IsStdio(outputFile) {
+		if err := validateOutput(outputFile, force); err != nil {
+			return err
+		}
+	}
+
+	if len(password) == 0 && needsPassword(opts) {
+		if processor.IsStdio(outputFile) {
+			return errors.New(errors.CodeInvalidInput, "--password", ErrStdioPassword)
+		}
+		var err error
+		if password, err = c.promptEncryptionPassword(); err != nil {
+			return fmt.Errorf("failed to get password: %w", err)
+		}
+	}
+
+	opts.Reporter = display.NewQuietReporter()
+	if !processor.IsStdio(outputFile) {
+		opts.Reporter = display.NewReporter(nil)
+	}
+	var entries []archive.Entry
+	if err := runCancelable(func(ctx context.Context) error {
+		var err error
+		if dictionary {
+			if opts.Dictionary, err = archive.TrainDictionary(ctx, root); err != nil {
+				return err
+			}
+			if opts.Dictionary == nil {
+				display.ShowWarning("Too few similar small files to train a dictionary; archiving without one")
+			}
+		}
+		entries, err = archive.Create(ctx, root, outputFile, password, opts.WithTuning(config.LoadTuning()))
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if !processor.IsStdio(outputFile) {
+		display.ShowSuccessInfo(types.ModeEncrypt, outputFile)
+		display.ShowInfo(fmt.Sprintf("Archived %d entries from %s", len(entries), root))
+	}
+	return nil
+}
+
+// runUntar restores the archive at inputFile, which may be stdin or a URL,
+// under directory.
+func (c *CLI) runUntar(inputFile, directory string, password []byte, force bool, opts processor.Options) error {
+	if !processor.IsStdio(inputFile) && !storage.IsRemote(inputFile) {
+		if err := file.ValidatePath(inputFile, true); err != nil {
+			return fmt.Errorf("input file validation failed: %w", err)
+		}
+	}
+	if directory == "" {
+		directory = "."
+	}
+	if processor.IsStdio(directory) || storage.IsRemote(directory) {
+		return errors.Newf(errors.CodeInvalidInput, "--untar", "-o must name a directory to extract into")
+	}
+
+	if len(password) == 0 && needsPassword(opts) {
+		if processor.IsStdio(inputFile) {
+			return errors.New(errors.CodeInvalidInput, "--password", ErrStdioPassword)
+		}
+		var err error
+		if password, err = c.promptDecryptionPassword(); err != nil {
+			return fmt.Errorf("failed to get password: %w", err)
+		}
+	}
+
+	opts.Reporter = display.NewReporter(nil)
+	var entries []archive.Entry
+	if err := runCancelable(func(ctx context.Context) error {
+		var err error
+		entries, err = archive.Extract(ctx, inputFile, directory, password, force, opts.WithTuning(config.LoadTuning()))
+		return err
+	}); err != nil {
+		return err
+	}
+	display.ShowInfo(fmt.Sprintf("Extracted %d entries into %s", len(entries), directory))
+	return nil
+}
+
+func archiveOptions(keyfilePath, identityPath string) (processor.Options, error) {
+	var (
+		opts processor.Options
+		err  error
+	)
+	if opts.Keyfile, err = loadKeyfile(keyfilePath); err != nil {
+		return opts, err
+	}
+	if identityPath != "" {
+		if opts.Identity, err = recipient.ReadIdentity(identityPath); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+func printArchiveEntry(w io.Writer, entry archive.Entry) {
+	fmt.Fprintf(w, "%s %12d %s %s", entry.Mode, entry.Size, entry.ModTime.Format("2006-01-02 15:04"), entry.Path)
+	if entry.Link != "" {
+		fmt.Fprintf(w, " -> %s", entry.Link)
+	}
+	fmt.Fprintln(w)
+}
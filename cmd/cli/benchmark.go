@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/hambosto/sweetbyte/internal/benchmark"
+	"github.com/hambosto/sweetbyte/internal/cipher"
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/sysinfo"
+	"github.com/hambosto/sweetbyte/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createBenchmarkCommand() *cobra.Command {
+	var (
+		chunkSweep bool
+		levelSweep bool
+		save       bool
+		sizeMB     int
+		suiteName  string
+	)
+
+	cmd := &cobra.Command{
+		Use:     "benchmark",
+		Aliases: []string{"bench"},
+		Short:   "Measure encryption throughput on this machine",
+		Long:    "Prints the detected CPU features and the cipher suite --cipher auto selects, then encrypts and decrypts a synthetic in-memory workload and reports throughput for both. With --chunk-sweep it tries several chunk sizes and concurrency levels and recommends the fastest combination; --level-sweep adds every compression level to show what compression costs.",
+		Example: `  sweetbyte bench
+  sweetbyte bench --cipher xchacha20 --level-sweep
+  sweetbyte benchmark --chunk-sweep --save`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if sizeMB <= 0 {
+				return fmt.Errorf("--size must be positive, got %d", sizeMB)
+			}
+			if save && !chunkSweep {
+				return fmt.Errorf("--save requires --chunk-sweep")
+			}
+			suite, err := cipher.ParseSuite(suiteName)
+			if err != nil {
+				return errors.New(errors.CodeInvalidInput, "--cipher", err)
+			}
+
+			sweep := benchmark.NewSweep(int64(sizeMB) * 1024 * 1024)
+			sweep.Suite = suite
+			if !chunkSweep {
+				sweep.ChunkSizes = sweep.ChunkSizes[:1]
+				sweep.Concurrency = sweep.Concurrency[len(sweep.Concurrency)-1:]
+			}
+			if levelSweep {
+				sweep.Levels = benchmark.AllLevels
+			}
+			return runBenchmark(cmd.Context(), cmd.OutOrStdout(), sweep, chunkSweep && save)
+		},
+	}
+
+	cmd.Flags().BoolVar(&chunkSweep, "chunk-sweep", false, "Try several chunk sizes and concurrency levels")
+	cmd.Flags().BoolVar(&levelSweep, "level-sweep", false, "Also try every compression level: none, fast (used for files), default and best")
+	cmd.Flags().StringVar(&suiteName, "cipher", cipher.SuiteCascade.String(), "Cipher suite to measure: cascade, aes-gcm, xchacha20 or auto")
+	cmd.Flags().BoolVar(&save, "save", false, "Store the recommended settings in the config file")
+	cmd.Flags().IntVar(&sizeMB, "size", benchmark.DefaultWorkloadSize/(1024*1024), "Workload size in MB")
+
+	return cmd
+}
+
+func runBenchmark(ctx context.Context, w io.Writer, sweep benchmark.Sweep, save bool) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	printCPUReport(w, sysinfo.Collect())
+	fmt.Fprintf(w, "Cipher suite:  %s\n\n", sweep.Suite.Description())
+
+	size := sweep.WorkloadSize
+	fmt.Fprintf(w, "%-12s %-8s %-12s %-8s %-14s %-14s %-22s %s\n", "CHUNK SIZE", "LEVEL", "CONCURRENCY", "OUTPUT", "ENCRYPT", "DECRYPT", "ALLOC/CHUNK", "ALLOCS/CHUNK")
+	results, err := sweep.Run(ctx, func(r benchmark.Result) {
+		fmt.Fprintf(w, "%-12s %-8s %-12d %-8s %-14s %-14s %-22s %.1f / %.1f\n",
+			utils.FormatBytes(int64(r.ChunkSize)), r.Level, r.Concurrency,
+			fmt.Sprintf("%.0f%%", float64(r.Output)*100/float64(size)),
+			utils.FormatBytes(int64(r.EncryptThroughput(size)))+"/s",
+			utils.FormatBytes(int64(r.DecryptThroughput(size)))+"/s",
+			utils.FormatBytes(r.EncryptAlloc)+" / "+utils.FormatBytes(r.DecryptAlloc),
+			r.EncryptAllocs, r.DecryptAllocs)
+	})
+	if err != nil {
+		return err
+	}
+
+	best, ok := benchmark.Best(results)
+	if !ok || len(sweep.ChunkSizes) == 1 && len(sweep.Concurrency) == 1 {
+		return nil
+	}
+
+	fmt.Fprintf(w, "\nRecommended: chunk size %s, concurrency %d\n", utils.FormatBytes(int64(best.ChunkSize)), best.Concurrency)
+	if !save {
+		return nil
+	}
+
+	if err := updateSettings(func(s *config.Settings) error {
+		s.Tuning = config.Tuning{ChunkSize: best.ChunkSize, Concurrency: best.Concurrency}
+		return nil
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "Saved to config.")
+	return nil
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createBookmarkCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bookmark",
+		Short: "Manage directory bookmarks for interactive mode",
+		Long:  "Saved bookmarks appear in the interactive location picker so you can jump straight to your favorite folders.",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:     "add NAME [PATH]",
+		Short:   "Bookmark a directory (default: current directory)",
+		Example: `  sweetbyte bookmark add Vault ~/Documents/vault`,
+		Args:    cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := "."
+			if len(args) == 2 {
+				path = args[1]
+			}
+			return updateSettings(func(s *config.Settings) error {
+				return s.AddBookmark(args[0], path)
+			})
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "remove NAME",
+		Short: "Remove a bookmark",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return updateSettings(func(s *config.Settings) error {
+				if !s.RemoveBookmark(args[0]) {
+					return fmt.Errorf("bookmark not found: %s", args[0])
+				}
+				return nil
+			})
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List bookmarks",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			settings, err := config.LoadSettings()
+			if err != nil {
+				return err
+			}
+			for _, bookmark := range settings.Bookmarks {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\n", bookmark.Name, bookmark.Path)
+			}
+			return nil
+		},
+	})
+
+	return cmd
+}
+
+func updateSettings(update func(*config.Settings) error) error {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return err
+	}
+	if err := update(settings); err != nil {
+		return err
+	}
+	return settings.Save()
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/hambosto/sweetbyte/internal/checksum"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createCheckCommand() *cobra.Command {
+	var (
+		dbPath string
+		format string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "check [FILE...]",
+		Short: "Detect bit rot or tampering of encrypted files against the checksum database",
+		Long:  "Hashes encrypted files and compares them with the checksums recorded by encrypt --record. No password is needed. Without arguments every recorded file is checked.",
+		Example: `  sweetbyte check
+  sweetbyte check --db /mnt/backup/checksums.json
+  sweetbyte check photos.tar.swx --format json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "text" && format != "json" {
+				return errors.Newf(errors.CodeInvalidInput, "check", "unsupported format %q", format)
+			}
+
+			db, err := checksum.Load(dbPath)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			var report func(checksum.Result)
+			if format == "text" {
+				report = func(r checksum.Result) { printCheckResult(out, r) }
+			}
+
+			summary, err := db.Check(cmd.Context(), args, report)
+			if err != nil {
+				return err
+			}
+
+			if format == "json" {
+				encoder := json.NewEncoder(out)
+				encoder.SetIndent("", "  ")
+				if err := encoder.Encode(summary); err != nil {
+					return err
+				}
+			} else {
+				fmt.Fprintf(out, "\n%d files checked against %s, %d failed\n", summary.Checked, db.Path(), summary.Failed)
+			}
+
+			if !summary.OK() {
+				return errors.Newf(errors.CodeCorrupt, "check", "%d of %d files do not match the checksum database", summary.Failed, summary.Checked)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dbPath, "db", "", "Checksum database (default: checksums.json next to the config file)")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+	return cmd
+}
+
+func recordChecksums(dbPath string) (func(string, []byte) error, error) {
+	db, err := checksum.Load(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	return func(destPath string, plaintextHash []byte) error {
+		record, err := checksum.NewRecord(destPath, plaintextHash)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		db.Put(record)
+		return db.Save()
+	}, nil
+}
+
+func printCheckResult(w io.Writer, r checksum.Result) {
+	switch r.Status {
+	case checksum.StatusOK:
+		fmt.Fprintf(w, "OK       %s\n", r.Path)
+	case checksum.StatusMissing:
+		fmt.Fprintf(w, "MISSING  %s\n", r.Path)
+	case checksum.StatusReplaced:
+		fmt.Fprintf(w, "REPLACED %s: a different encrypted file is at this path\n", r.Path)
+	case checksum.StatusUnknown:
+		fmt.Fprintf(w, "UNKNOWN  %s: %s\n", r.Path, r.Error)
+	default:
+		if r.Error != "" {
+			fmt.Fprintf(w, "MODIFIED %s: %s\n", r.Path, r.Error)
+		} else {
+			fmt.Fprintf(w, "MODIFIED %s: ciphertext hash differs from the one recorded %s\n", r.Path, r.Record.Recorded.Format("2006-01-02"))
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/ui/display"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createCompareCommand() *cobra.Command {
+	var (
+		password    []byte
+		keyfilePath string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "compare PLAINTEXT [ENCRYPTED]",
+		Short: "Check that a plaintext file matches an encrypted file",
+		Long:  "Stream-decrypts the encrypted file (default: PLAINTEXT + " + config.FileExtension + ") and compares its SHA-256 with the plaintext. Nothing is written to disk.",
+		Example: `  sweetbyte compare document.txt
+  sweetbyte compare document.txt backup/document.txt.swx`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			plainPath, encryptedPath := args[0], args[0]+config.FileExtension
+			if len(args) == 2 {
+				encryptedPath = args[1]
+			}
+
+			var (
+				opts = processor.Options{Reporter: display.NewReporter(nil)}
+				err  error
+			)
+			if opts.Keyfile, err = loadKeyfile(keyfilePath); err != nil {
+				return err
+			}
+			if len(password) == 0 {
+				if password, err = c.promptDecryptionPassword(); err != nil {
+					return fmt.Errorf("failed to get password: %w", err)
+				}
+			}
+
+			match, err := processor.Compare(cmd.Context(), plainPath, encryptedPath, password, opts.WithTuning(config.LoadTuning()))
+			if err != nil {
+				return err
+			}
+			if !match {
+				fmt.Fprintf(cmd.OutOrStdout(), "MISMATCH %s != %s\n", plainPath, encryptedPath)
+				return errors.New(errors.CodeCorrupt, "compare", processor.ErrMismatch).WithPath(encryptedPath)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "MATCH    %s == %s\n", plainPath, encryptedPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().VarP(c.passwordFlag(&password), "password", "p", "Decryption password (prompts if not provided)")
+	cmd.Flags().StringVarP(&keyfilePath, "keyfile", "k", "", "Keyfile used when the file was encrypted")
+	return cmd
+}
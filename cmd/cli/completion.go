@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"strings"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createCompletionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion bash|zsh|fish|powershell",
+		Short: "Generate a shell completion script",
+		Long:  "Prints a completion script for commands, flags and encrypted files to stdout. Load it from your shell's startup file, or install it where your shell looks for completions.",
+		Example: `  source <(sweetbyte completion bash)
+  sweetbyte completion bash > /etc/bash_completion.d/sweetbyte
+  sweetbyte completion zsh > "${fpath[1]}/_sweetbyte"
+  sweetbyte completion fish > ~/.config/fish/completions/sweetbyte.fish
+  sweetbyte completion powershell | Out-String | Invoke-Expression`,
+		ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+		Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, out := cmd.Root(), cmd.OutOrStdout()
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletionV2(out, true)
+			case "zsh":
+				return root.GenZshCompletion(out)
+			case "fish":
+				return root.GenFishCompletion(out, true)
+			default:
+				return root.GenPowerShellCompletionWithDesc(out)
+			}
+		},
+	}
+}
+
+func completeArchive(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return []string{strings.TrimPrefix(config.ArchiveExtension, ".")}, cobra.ShellCompDirectiveFilterFileExt
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createDaemonCommand() *cobra.Command {
+	var (
+		schedule string
+		paths    []string
+		scrubNow bool
+		verbose  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Run in the background and scrub vault directories on a schedule",
+		Long: `Runs until interrupted and periodically scrubs the configured directories for damaged files.
+
+The schedule and directories come from the "scrub" section of the config file
+and can be overridden with flags. Schedules use the five cron fields
+(minute hour day month weekday) or @hourly, @daily, @weekly and @monthly.
+Results are logged to stderr, optionally written as Prometheus metrics to
+scrub.metrics_file, and scrub.notify_command is run when damage is found.`,
+		Example: `  sweetbyte daemon --path ~/vault --schedule "0 3 * * 0"
+  sweetbyte daemon --scrub-now`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			settings, err := config.LoadSettings()
+			if err != nil {
+				return err
+			}
+
+			scrubSettings := settings.Scrub
+			if schedule != "" {
+				scrubSettings.Schedule = schedule
+			}
+			if len(paths) > 0 {
+				scrubSettings.Paths = paths
+			}
+
+			level := slog.LevelInfo
+			if verbose {
+				level = slog.LevelDebug
+			}
+			logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+			d, err := daemon.New(scrubSettings, logger)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return d.Run(ctx, scrubNow)
+		},
+	}
+
+	cmd.Flags().StringVar(&schedule, "schedule", "", "Cron schedule for scrubs (default: scrub.schedule or "+config.DefaultScrubSchedule+")")
+	cmd.Flags().StringSliceVar(&paths, "path", nil, "Directory to scrub (repeatable, default: scrub.paths)")
+	cmd.Flags().BoolVar(&scrubNow, "scrub-now", false, "Run a scrub immediately on start")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Log every checked file")
+
+	return cmd
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/stream"
+	"github.com/hambosto/sweetbyte/internal/sysinfo"
+	"github.com/hambosto/sweetbyte/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createEnvCommand() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "env",
+		Short: "Print a report of the runtime environment for bug reports",
+		Long:  "Prints CPU features, the crypto backends they select, GOMAXPROCS, memory, the default KDF profile and tuning, config file locations and the supported file format versions.",
+		Example: `  sweetbyte env
+  sweetbyte env --format json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "text" && format != "json" {
+				return errors.Newf(errors.CodeInvalidInput, "env", "unsupported format %q", format)
+			}
+
+			report := sysinfo.Collect()
+			if format == "json" {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(report)
+			}
+			printEnvReport(cmd.OutOrStdout(), report)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+	return cmd
+}
+
+func printEnvReport(w io.Writer, r sysinfo.Report) {
+	fmt.Fprintf(w, "Version:       %s (%s, %s/%s)\n", r.Version, r.GoVersion, r.OS, r.Arch)
+	printCPUReport(w, r)
+	fmt.Fprintf(w, "Argon2:        %s\n", r.Backends.Argon2)
+	if r.Memory != nil {
+		fmt.Fprintf(w, "Memory:        %s available of %s\n", utils.FormatBytes(int64(r.Memory.Available)), utils.FormatBytes(int64(r.Memory.Total)))
+	} else {
+		fmt.Fprintln(w, "Memory:        unknown")
+	}
+	fmt.Fprintf(w, "KDF profile:   %s (Argon2id t=%d, m=%s, p=%d)\n", r.KDF.Profile, r.KDF.Time, utils.FormatBytes(int64(r.KDF.Memory)*1024), r.KDF.Threads)
+	if r.ChunkSize > 0 {
+		fmt.Fprintf(w, "Chunk size:    %s\n", utils.FormatBytes(int64(r.ChunkSize)))
+	} else {
+		fmt.Fprintf(w, "Chunk size:    auto (%s to %s by file size)\n", utils.FormatBytes(stream.MinChunkSize), utils.FormatBytes(stream.MaxAutoChunkSize))
+	}
+	fmt.Fprintf(w, "Concurrency:   %d\n", r.Concurrency)
+	if r.Tuning.MaxMemory > 0 {
+		fmt.Fprintf(w, "Max memory:    %s\n", utils.FormatBytes(r.Tuning.MaxMemory))
+	}
+	if r.Tuning.MaxOutstanding > 0 {
+		fmt.Fprintf(w, "Max in flight: %d chunks\n", r.Tuning.MaxOutstanding)
+	}
+	if r.Tuning.MaxWorkers > 0 {
+		fmt.Fprintf(w, "Max workers:   %d shared by all files\n", r.Tuning.MaxWorkers)
+	}
+	if r.Tuning.RateLimit > 0 {
+		fmt.Fprintf(w, "Rate limit:    %s/s read and written\n", utils.FormatBytes(r.Tuning.RateLimit))
+	}
+
+	switch {
+	case r.Config.SettingsError != "":
+		fmt.Fprintf(w, "Config file:   unavailable (%s)\n", r.Config.SettingsError)
+	case r.Config.SettingsExists:
+		fmt.Fprintf(w, "Config file:   %s\n", r.Config.SettingsFile)
+	default:
+		fmt.Fprintf(w, "Config file:   %s (not created yet)\n", r.Config.SettingsFile)
+	}
+	tmpfs := ""
+	if r.Config.RequireTmpfs {
+		tmpfs = " (tmpfs required)"
+	}
+	fmt.Fprintf(w, "Temp dir:      %s%s\n", r.Config.TempDir, tmpfs)
+	fmt.Fprintf(w, "File format:   versions %d-%d, revision %d\n", r.Formats.MinVersion, r.Formats.CurrentVersion, r.Formats.Revision)
+}
+
+func printCPUReport(w io.Writer, r sysinfo.Report) {
+	features := "none detected"
+	if len(r.CPUFeatures) > 0 {
+		features = strings.Join(r.CPUFeatures, " ")
+	}
+
+	fmt.Fprintf(w, "CPUs:          %d (GOMAXPROCS %d)\n", r.CPUs, r.GOMAXPROCS)
+	fmt.Fprintf(w, "CPU features:  %s\n", features)
+	fmt.Fprintf(w, "AES-GCM:       %s\n", r.Backends.AES)
+	fmt.Fprintf(w, "ChaCha20:      %s\n", r.Backends.ChaCha20)
+	fmt.Fprintf(w, "Auto cipher:   %s\n", r.Backends.AutoCipher)
+}
@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/escrow"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/secret"
+	"github.com/hambosto/sweetbyte/internal/types"
+	"github.com/hambosto/sweetbyte/internal/ui/display"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createEscrowCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "escrow",
+		Short: "Escrow data keys to an organizational recovery key",
+		Long:  "Wrap a file's data key to a recovery public key (X25519) so the holder of the matching private key can decrypt the file if the password is lost.",
+	}
+
+	cmd.AddCommand(c.createEscrowKeygenCommand())
+	cmd.AddCommand(c.createEscrowExportCommand())
+	cmd.AddCommand(c.createEscrowImportCommand())
+	return cmd
+}
+
+func (c *CLI) createEscrowKeygenCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:     "keygen PREFIX",
+		Short:   "Generate a recovery key pair (PREFIX.pub and PREFIX.key)",
+		Example: `  sweetbyte escrow keygen org-recovery`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, err := escrow.GenerateKeyPair()
+			if err != nil {
+				return err
+			}
+
+			publicPath, privatePath, err := escrow.WriteKeyPair(args[0], key)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Public key:  %s\nPrivate key: %s (keep offline)\n", publicPath, privatePath)
+			return nil
+		},
+	}
+}
+
+func (c *CLI) createEscrowExportCommand() *cobra.Command {
+	var (
+		recipient   string
+		output      string
+		password    []byte
+		keyfilePath string
+	)
+
+	cmd := &cobra.Command{
+		Use:     "export FILE",
+		Short:   "Write an escrow blob wrapping FILE's data key to a recovery key",
+		Example: `  sweetbyte escrow export --recipient org-recovery.pub report.pdf.swx`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			recipientKey, err := escrow.ReadPublicKey(recipient)
+			if err != nil {
+				return err
+			}
+
+			var opts processor.Options
+			if opts.Keyfile, err = loadKeyfile(keyfilePath); err != nil {
+				return err
+			}
+			if len(password) == 0 {
+				if password, err = c.promptDecryptionPassword(); err != nil {
+					return fmt.Errorf("failed to get password: %w", err)
+				}
+			}
+
+			dataKey, fileID, err := processor.UnlockDataKey(args[0], password, opts)
+			if err != nil {
+				return err
+			}
+			defer secret.Wipe(dataKey)
+
+			blob, err := escrow.Seal(recipientKey, fileID, dataKey)
+			if err != nil {
+				return err
+			}
+
+			if output == "" {
+				output = args[0] + escrow.FileExtension
+			}
+			if err := blob.Write(output); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Escrow blob written to %s\n", output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&recipient, "recipient", "r", "", "Recovery public key (required)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Escrow blob path (default: FILE + "+escrow.FileExtension+")")
+	cmd.Flags().VarP(c.passwordFlag(&password), "password", "p", "File password (prompts if not provided)")
+	cmd.Flags().StringVarP(&keyfilePath, "keyfile", "k", "", "Keyfile used when the file was encrypted")
+	if err := cmd.MarkFlagRequired("recipient"); err != nil {
+		panic(fmt.Sprintf("failed to mark recipient flag as required: %v", err))
+	}
+
+	return cmd
+}
+
+func (c *CLI) createEscrowImportCommand() *cobra.Command {
+	var (
+		keyPath  string
+		blobPath string
+		output   string
+		force    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:     "import FILE",
+		Short:   "Decrypt FILE with an escrow blob and the recovery private key",
+		Example: `  sweetbyte escrow import --key org-recovery.key report.pdf.swx`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inputFile := args[0]
+			if blobPath == "" {
+				blobPath = inputFile + escrow.FileExtension
+			}
+
+			recoveryKey, err := escrow.ReadPrivateKey(keyPath)
+			if err != nil {
+				return err
+			}
+			blob, err := escrow.ReadBlob(blobPath)
+			if err != nil {
+				return err
+			}
+
+			fileID, err := processor.FileID(inputFile)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(fileID, blob.FileID) {
+				return errors.New(errors.CodeInvalidInput, "escrow import", escrow.ErrWrongFile).WithPath(blobPath)
+			}
+
+			dataKey, err := blob.Open(recoveryKey)
+			if err != nil {
+				return err
+			}
+
+			if output == "" {
+				if output, err = decryptOutputPath(inputFile); err != nil {
+					return err
+				}
+			}
+			if err := validateOutput(output, force); err != nil {
+				return err
+			}
+
+			opts := processor.Options{DataKey: dataKey, Reporter: display.NewReporter(nil), Repaired: display.ShowRepairReport}.WithTuning(config.LoadTuning())
+			if err := runCancelable(func(ctx context.Context) error {
+				return processor.Decryption(ctx, inputFile, output, nil, opts)
+			}); err != nil {
+				return err
+			}
+			display.ShowSuccessInfo(types.ModeDecrypt, output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&keyPath, "key", "", "Recovery private key (required)")
+	cmd.Flags().StringVar(&blobPath, "blob", "", "Escrow blob (default: FILE + "+escrow.FileExtension+")")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output file (default: removes "+config.FileExtension+" extension)")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite the output file if it exists")
+	if err := cmd.MarkFlagRequired("key"); err != nil {
+		panic(fmt.Sprintf("failed to mark key flag as required: %v", err))
+	}
+
+	return cmd
+}
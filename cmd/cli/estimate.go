@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/types"
+	"github.com/hambosto/sweetbyte/internal/ui/display"
+)
+
+func (c *CLI) runEstimate(inputs []string, recursive bool, opts processor.Options) error {
+	var (
+		entries []file.TreeEntry
+		err     error
+	)
+	switch {
+	case isBatch(inputs):
+		entries, err = file.MapFiles(inputs, "", types.ModeEncrypt)
+	case recursive:
+		entries, err = file.MapTree(inputs[0], "", types.ModeEncrypt, 0)
+	default:
+		if err := file.ValidatePath(inputs[0], true); err != nil {
+			return err
+		}
+		estimate, err := processor.EstimateEncryption(inputs[0], opts)
+		if err != nil {
+			return err
+		}
+		display.ShowEstimateDetails(estimate, 1)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var (
+		total processor.Estimate
+		files int
+	)
+	for _, entry := range entries {
+		if entry.Size == 0 {
+			continue
+		}
+		estimate, err := processor.EstimateEncryption(entry.Input, opts)
+		if err != nil {
+			return err
+		}
+		total.Add(estimate)
+		files++
+	}
+	if files == 0 {
+		return errors.New(errors.CodeNotFound, "", fmt.Errorf("%w for %s operation", file.ErrNoEligibleFiles, types.ModeEncrypt))
+	}
+
+	display.ShowEstimateDetails(total, files)
+	return nil
+}
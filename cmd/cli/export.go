@@ -0,0 +1,285 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hambosto/sweetbyte/internal/age"
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/recipient"
+	"github.com/hambosto/sweetbyte/internal/types"
+	"github.com/hambosto/sweetbyte/internal/ui/display"
+	"github.com/hambosto/sweetbyte/internal/ui/prompt"
+	"github.com/hambosto/sweetbyte/internal/ui/term"
+	"github.com/spf13/cobra"
+)
+
+const formatAge = "age"
+
+func (c *CLI) createExportCommand() *cobra.Command {
+	var (
+		output       string
+		password     []byte
+		keyfilePath  string
+		identityPath string
+		format       string
+		recipients   []string
+		passphrase   bool
+		force        bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export [flags] FILE",
+		Short: "Re-encrypt FILE into the age format for people without sweetbyte",
+		Long:  "Decrypts FILE and encrypts its contents again as an age v1 file, chunk by chunk, so the plaintext never reaches the disk. The result opens with age or rage. Recipients are age1... public keys, age recipients files or sweetbyte public keys; --passphrase uses an age passphrase instead.",
+		Example: `  sweetbyte export report.pdf.swx --recipient age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p
+  sweetbyte export report.pdf.swx --recipient alice.pub --recipient team.txt -o report.pdf.age
+  sweetbyte export report.pdf.swx --passphrase
+  sweetbyte export report.pdf.swx -p "$PASSWORD" --passphrase --password-file age-passphrase.txt
+  sweetbyte export report.pdf.swx -o - --recipient age1... | age -d -i key.txt > report.pdf`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inputFile := args[0]
+			if err := checkExportFormat(format); err != nil {
+				return err
+			}
+			if passphrase == (len(recipients) > 0) {
+				return errors.Newf(errors.CodeInvalidInput, "--recipient", "give either --recipient or --passphrase")
+			}
+
+			var (
+				opts    = processor.Options{Reporter: display.NewReporter(nil)}
+				targets []age.Recipient
+				err     error
+			)
+			if targets, err = readAgeRecipients(recipients); err != nil {
+				return err
+			}
+			if opts.Keyfile, err = loadKeyfile(keyfilePath); err != nil {
+				return err
+			}
+			if identityPath != "" {
+				if opts.Identity, err = recipient.ReadIdentity(identityPath); err != nil {
+					return err
+				}
+			}
+
+			if output == "" {
+				if processor.IsStdio(inputFile) {
+					output = processor.StdioPath
+				} else if output, err = decryptOutputPath(inputFile); err != nil {
+					return err
+				} else {
+					output += age.Extension
+				}
+			}
+			if !processor.IsStdio(output) {
+				if err := validateOutput(output, force); err != nil {
+					return err
+				}
+			}
+
+			if len(password) == 0 && needsPassword(opts) {
+				if password, err = c.promptDecryptionPassword(); err != nil {
+					return fmt.Errorf("failed to get password: %w", err)
+				}
+			}
+			if passphrase {
+				secret := c.password.Bytes()
+				if len(secret) == 0 {
+					display.ShowInfo("Choose the age passphrase")
+					if secret, err = c.askEncryptionPassword(); err != nil {
+						return fmt.Errorf("failed to get age passphrase: %w", err)
+					}
+				}
+				targets = []age.Recipient{age.NewScryptRecipient(string(secret))}
+			}
+
+			if err := runCancelable(func(ctx context.Context) error {
+				return processor.ExportAge(ctx, inputFile, output, password, opts.WithTuning(config.LoadTuning()), targets)
+			}); err != nil {
+				return err
+			}
+			if !processor.IsStdio(output) {
+				display.ShowSuccessInfo(types.ModeEncrypt, output)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output file, or - for stdout (default: removes "+config.FileExtension+" and adds "+age.Extension+")")
+	cmd.Flags().VarP(c.passwordFlag(&password), "password", "p", "Password of FILE (prompts if not provided)")
+	cmd.Flags().StringVarP(&keyfilePath, "keyfile", "k", "", "Keyfile used when FILE was encrypted")
+	cmd.Flags().StringVar(&identityPath, "identity", "", "Private key for files encrypted with --recipient")
+	cmd.Flags().StringVar(&format, "format", formatAge, "Output format (only age is supported)")
+	cmd.Flags().StringArrayVar(&recipients, "recipient", nil, "age1... public key, age recipients file or sweetbyte public key to encrypt to (repeatable)")
+	cmd.Flags().BoolVar(&passphrase, "passphrase", false, "Encrypt with an age passphrase instead of public keys, taken from --password-file or $"+config.PasswordEnv+" if set (prompts otherwise)")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite the output file if it exists")
+	return cmd
+}
+
+func (c *CLI) createImportCommand() *cobra.Command {
+	var (
+		output        string
+		password      []byte
+		keyfilePath   string
+		identityPaths []string
+		format        string
+		passphrase    bool
+		force         bool
+		opts          processor.Options
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import [flags] FILE",
+		Short: "Re-encrypt an age file into a sweetbyte file",
+		Long:  "Decrypts an age v1 file with an age identity file, a sweetbyte identity or an age passphrase, and encrypts its contents into a sweetbyte file in one pass. age does not record the plaintext size, so the result is written like a stream from a pipe.",
+		Example: `  sweetbyte import report.pdf.age --identity key.txt
+  sweetbyte import report.pdf.age --identity alice.key -o report.pdf.swx
+  sweetbyte import notes.age --passphrase -p "$PASSWORD"`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inputFile := args[0]
+			if err := checkExportFormat(format); err != nil {
+				return err
+			}
+			if passphrase == (len(identityPaths) > 0) {
+				return errors.Newf(errors.CodeInvalidInput, "--identity", "give either --identity or --passphrase")
+			}
+			if _, err := derive.ProfileParams(opts.KDFProfile); err != nil {
+				return errors.New(errors.CodeInvalidInput, "--kdf-profile", err)
+			}
+
+			identities, err := readAgeIdentities(identityPaths)
+			if err != nil {
+				return err
+			}
+			if opts.Keyfile, err = loadKeyfile(keyfilePath); err != nil {
+				return err
+			}
+
+			if output == "" {
+				if processor.IsStdio(inputFile) {
+					output = processor.StdioPath
+				} else {
+					output = strings.TrimSuffix(inputFile, age.Extension) + config.FileExtension
+				}
+			}
+			if !processor.IsStdio(output) {
+				if err := validateOutput(output, force); err != nil {
+					return err
+				}
+			}
+
+			if passphrase {
+				secret := c.password.Bytes()
+				if len(secret) == 0 {
+					if !term.IsInteractive() {
+						return errors.New(errors.CodeInvalidInput, "--passphrase", ErrNoTerminal)
+					}
+					display.ShowInfo("Enter the age passphrase")
+					if secret, err = c.keep(prompt.GetDecryptionPassword()); err != nil {
+						return fmt.Errorf("failed to get age passphrase: %w", err)
+					}
+				}
+				identities = []age.Identity{age.NewScryptIdentity(string(secret))}
+			}
+			if len(password) == 0 {
+				if password, err = c.promptEncryptionPassword(); err != nil {
+					return fmt.Errorf("failed to get password: %w", err)
+				}
+			}
+
+			opts.Reporter = display.NewReporter(nil)
+			if err := runCancelable(func(ctx context.Context) error {
+				return processor.ImportAge(ctx, inputFile, output, password, opts.WithTuning(config.LoadTuning()), identities)
+			}); err != nil {
+				return err
+			}
+			if !processor.IsStdio(output) {
+				display.ShowSuccessInfo(types.ModeEncrypt, output)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output file, or - for stdout (default: removes "+age.Extension+" and adds "+config.FileExtension+")")
+	cmd.Flags().VarP(c.passwordFlag(&password), "password", "p", "Password for the new sweetbyte file (prompts if not provided)")
+	cmd.Flags().StringVarP(&keyfilePath, "keyfile", "k", "", "Keyfile to combine with the password")
+	cmd.Flags().StringArrayVar(&identityPaths, "identity", nil, "age identity file (as written by age-keygen) or sweetbyte identity (repeatable)")
+	cmd.Flags().StringVar(&format, "format", formatAge, "Input format (only age is supported)")
+	cmd.Flags().BoolVar(&passphrase, "passphrase", false, "The age file is encrypted with a passphrase, taken from --password-file or $"+config.PasswordEnv+" if set (prompts otherwise)")
+	cmd.Flags().StringVar(&opts.KDFProfile, "kdf-profile", derive.ProfileDefault, "Argon2id hardness preset: "+strings.Join(derive.ProfileNames(), ", "))
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite the output file if it exists")
+	return cmd
+}
+
+func checkExportFormat(format string) error {
+	if format != formatAge {
+		return errors.Newf(errors.CodeUnsupported, "--format", "unsupported format %q (only age is supported)", format)
+	}
+	return nil
+}
+
+// readAgeRecipients accepts age1... keys, files of them, and sweetbyte
+// public keys, which are X25519 keys like age's.
+func readAgeRecipients(values []string) ([]age.Recipient, error) {
+	var recipients []age.Recipient
+	for _, value := range values {
+		if strings.HasPrefix(value, "age1") {
+			r, err := age.ParseX25519Recipient(value)
+			if err != nil {
+				return nil, errors.New(errors.CodeInvalidInput, "--recipient", err)
+			}
+			recipients = append(recipients, r)
+			continue
+		}
+
+		if key, err := recipient.ReadPublicKey(value); err == nil {
+			recipients = append(recipients, age.NewX25519Recipient(key))
+			continue
+		}
+		parsed, err := readKeyFile(value, age.ParseRecipients)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, parsed...)
+	}
+	return recipients, nil
+}
+
+func readAgeIdentities(paths []string) ([]age.Identity, error) {
+	var identities []age.Identity
+	for _, path := range paths {
+		if key, err := recipient.ReadIdentity(path); err == nil {
+			identities = append(identities, age.NewX25519Identity(key))
+			continue
+		}
+		parsed, err := readKeyFile(path, age.ParseIdentities)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, parsed...)
+	}
+	return identities, nil
+}
+
+func readKeyFile[T any](path string, parse func(io.Reader) ([]T, error)) ([]T, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.New(errors.CodeNotFound, "open", err).WithPath(path)
+	}
+	defer f.Close()
+
+	keys, err := parse(f)
+	if err != nil {
+		return nil, errors.New(errors.CodeInvalidInput, "read key", err).WithPath(path)
+	}
+	return keys, nil
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hambosto/sweetbyte/internal/inventory"
+	"github.com/hambosto/sweetbyte/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createInspectCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:     "inspect FILE",
+		Short:   "Show header details of an encrypted file without decrypting it",
+		Example: `  sweetbyte inspect document.txt.swx`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entry, err := inventory.Inspect(args[0])
+			if err != nil {
+				return err
+			}
+			printEntry(cmd.OutOrStdout(), entry)
+			return nil
+		},
+	}
+}
+
+func printEntry(w io.Writer, entry inventory.Entry) {
+	fmt.Fprintf(w, "Path:          %s\n", entry.Path)
+	fmt.Fprintf(w, "Version:       %d\n", entry.Version)
+	if entry.Revision > 0 {
+		fmt.Fprintf(w, "Revision:      %d\n", entry.Revision)
+	}
+	if entry.Streamed {
+		fmt.Fprintln(w, "Original size: unknown (streamed)")
+	} else {
+		fmt.Fprintf(w, "Original size: %s (%d bytes)\n", utils.FormatBytes(entry.OriginalSize), entry.OriginalSize)
+	}
+	if !entry.Created.IsZero() {
+		fmt.Fprintf(w, "Created:       %s\n", entry.Created.Format(time.RFC3339))
+	}
+	if entry.Recipient {
+		fmt.Fprintln(w, "Key:           public key (decrypt with --identity)")
+	} else {
+		fmt.Fprintf(w, "Profile:       %s\n", entry.Profile)
+	}
+	if entry.Token != "" {
+		fmt.Fprintf(w, "Token:         %s (needed with the password)\n", entry.Token)
+	}
+	fmt.Fprintf(w, "Cipher:        %s\n", entry.Cipher)
+	if entry.Deterministic {
+		fmt.Fprintln(w, "Deterministic: yes (identical chunks share ciphertext across files)")
+	}
+	if entry.ContentType != "" {
+		fmt.Fprintf(w, "Content type:  %s\n", entry.ContentType)
+	}
+	if entry.Owner != "" {
+		fmt.Fprintf(w, "Owner:         %s\n", entry.Owner)
+	}
+	if entry.HiddenName {
+		fmt.Fprintln(w, "Name:          hidden (restored on decryption)")
+	} else if entry.Name != "" {
+		fmt.Fprintf(w, "Name:          %s\n", entry.Name)
+	}
+	if entry.NoECC {
+		fmt.Fprintln(w, "Parity:        none (encrypted with --no-ecc)")
+	}
+	if entry.ChunkSize > 0 {
+		fmt.Fprintf(w, "Chunk size:    %s\n", utils.FormatBytes(int64(entry.ChunkSize)))
+	}
+	if entry.Seekable {
+		fmt.Fprintln(w, "Chunk index:   yes (parts can be read without decrypting from the start)")
+	}
+	if entry.Dictionary {
+		fmt.Fprintln(w, "Compression:   zstd with a trained dictionary (stored encrypted in the header)")
+	}
+	if entry.Padding > 0 {
+		fmt.Fprintf(w, "Padding:       %s of random data after the trailer\n", utils.FormatBytes(entry.Padding))
+	}
+	if len(entry.Tags) > 0 {
+		fmt.Fprintf(w, "Tags:          %s\n", strings.Join(entry.Tags, ", "))
+	}
+	if entry.Comment != "" {
+		fmt.Fprintf(w, "Comment:       %s\n", entry.Comment)
+	}
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hambosto/sweetbyte/internal/inventory"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createInventoryCommand() *cobra.Command {
+	var (
+		format     string
+		outputFile string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "inventory [DIR]",
+		Short: "List encrypted files and their header details without decrypting",
+		Long:  "Recursively scans a directory for sweetbyte files (detected by their header, not their extension) and reports path, original size, created date, version, profile and tags as CSV or JSON.",
+		Example: `  sweetbyte inventory ~/vault
+  sweetbyte inventory ~/vault --format json -o vault.json`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := "."
+			if len(args) == 1 {
+				root = args[0]
+			}
+			return runInventory(cmd.OutOrStdout(), root, outputFile, inventory.Format(format))
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", string(inventory.FormatCSV), "Output format: csv or json")
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Write the inventory to a file instead of stdout")
+
+	return cmd
+}
+
+func runInventory(stdout io.Writer, root, outputFile string, format inventory.Format) error {
+	entries, err := inventory.Scan(root)
+	if err != nil {
+		return err
+	}
+
+	if outputFile == "" {
+		return inventory.Write(stdout, entries, format)
+	}
+
+	f, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", outputFile, err)
+	}
+	if err := inventory.Write(f, entries, format); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
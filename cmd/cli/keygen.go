@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/keyfile"
+	"github.com/hambosto/sweetbyte/internal/recipient"
+	"github.com/hambosto/sweetbyte/internal/ui/display"
+	"github.com/hambosto/sweetbyte/internal/ui/prompt"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createKeygenCommand() *cobra.Command {
+	var (
+		protect  bool
+		identity bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "keygen FILE",
+		Short: "Generate a random keyfile",
+		Long:  "Generate a random keyfile. With --protect the key is wrapped under its own passphrase (Argon2id + XChaCha20-Poly1305), so a stolen keyfile alone is not enough to use it. With --identity, generate an X25519 key pair instead: files encrypted with --recipient FILE.pub can only be decrypted with --identity FILE.key.",
+		Example: `  sweetbyte keygen ~/.sweetbyte/vault.key
+  sweetbyte keygen --protect ~/.sweetbyte/vault.key
+  sweetbyte keygen --identity ~/.sweetbyte/alice`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if identity {
+				if protect {
+					return errors.Newf(errors.CodeInvalidInput, "--protect", "cannot be combined with --identity")
+				}
+				return writeIdentity(cmd.OutOrStdout(), args[0])
+			}
+
+			key, err := keyfile.Generate()
+			if err != nil {
+				return err
+			}
+
+			var passphrase string
+			if protect {
+				if passphrase, err = prompt.GetKeyfilePassphrase(); err != nil {
+					return err
+				}
+			}
+
+			if err := keyfile.Write(args[0], key, passphrase); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Keyfile written to %s\n", args[0])
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&protect, "protect", false, "Encrypt the keyfile under a passphrase")
+	cmd.Flags().BoolVar(&identity, "identity", false, "Generate a public key (FILE.pub) and identity (FILE.key) for --recipient and --identity")
+	return cmd
+}
+
+func writeIdentity(w io.Writer, prefix string) error {
+	key, err := recipient.Generate()
+	if err != nil {
+		return err
+	}
+
+	publicPath, identityPath, err := recipient.Write(prefix, key)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Public key: %s (share with senders)\nIdentity:   %s (keep private)\n", publicPath, identityPath)
+	return nil
+}
+
+func loadKeyfile(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return keyfile.Load(path, func() (string, error) {
+		return prompt.GetKeyfileUnlockPassphrase(path)
+	})
+}
+
+// loadDedupSalt reads the --dedup-salt file, or else the salt saved in the
+// config file, which the first deterministic run creates.
+func loadDedupSalt(path string) ([]byte, error) {
+	if path != "" {
+		salt, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.New(errors.CodeIO, "--dedup-salt", err).WithPath(path)
+		}
+		if len(salt) == 0 {
+			return nil, errors.Newf(errors.CodeInvalidInput, "--dedup-salt", "%s is empty", path)
+		}
+		return salt, nil
+	}
+
+	salt, created, err := config.LoadDedupSalt()
+	if err != nil {
+		return nil, errors.New(errors.CodeIO, "--dedup-salt", err)
+	}
+	if created {
+		display.ShowInfo("Saved a new dedup salt in the config file; use the same config, or --dedup-salt, wherever files for this backup target are encrypted")
+	}
+	return salt, nil
+}
@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/ui/display"
+	"github.com/hambosto/sweetbyte/internal/ui/prompt"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createKeyslotCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keyslot",
+		Short: "Manage the passwords and keyfiles that open a file",
+		Long:  "A file's data key can be wrapped under several passwords or keyfiles, each in its own key slot, so any one of them decrypts it. Adding or removing a slot only rewrites the header; the chunks are copied unchanged.",
+	}
+
+	cmd.AddCommand(c.createKeyslotListCommand())
+	cmd.AddCommand(c.createKeyslotAddCommand())
+	cmd.AddCommand(c.createKeyslotRemoveCommand())
+	return cmd
+}
+
+func (c *CLI) createKeyslotListCommand() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:     "list FILE",
+		Short:   "List the key slots of FILE (no password needed)",
+		Example: `  sweetbyte keyslot list secrets.db.swx`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "text" && format != "json" {
+				return errors.Newf(errors.CodeInvalidInput, "keyslot list", "unsupported format %q", format)
+			}
+
+			slots, err := processor.ListKeySlots(args[0])
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if format == "json" {
+				encoder := json.NewEncoder(out)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(slots)
+			}
+			for _, slot := range slots {
+				profile := slot.Profile
+				if profile == "" {
+					profile = fmt.Sprintf("t=%d m=%dKiB p=%d", slot.Params.Time, slot.Params.Memory, slot.Params.Threads)
+				}
+				fmt.Fprintf(out, "%d  %-18s %s\n", slot.Index, describeSlotFactors(slot), profile)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+	return cmd
+}
+
+func (c *CLI) createKeyslotAddCommand() *cobra.Command {
+	var (
+		password       []byte
+		keyfilePath    string
+		newPassword    []byte
+		newKeyfilePath string
+		requireBoth    bool
+		kdfProfile     string
+		enforce        bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "add FILE",
+		Short: "Add a password or keyfile that also opens FILE",
+		Example: `  sweetbyte keyslot add secrets.db.swx
+  sweetbyte keyslot add secrets.db.swx --new-keyfile backup.key --kdf-profile paranoid`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := derive.ProfileParams(kdfProfile); err != nil {
+				return errors.New(errors.CodeInvalidInput, "--kdf-profile", err)
+			}
+			if requireBoth && newKeyfilePath == "" {
+				return errors.New(errors.CodeInvalidInput, "--require-both", processor.ErrMissingFactor)
+			}
+			if enforce && len(newPassword) > 0 {
+				if err := prompt.ValidateEncryptionPassword(newPassword); err != nil {
+					return errors.New(errors.CodeInvalidInput, "--new-password", err)
+				}
+			}
+
+			var (
+				opts processor.Options
+				slot = processor.KeySlotOptions{RequireBoth: requireBoth, KDFProfile: kdfProfile}
+				err  error
+			)
+			if opts.Keyfile, err = loadKeyfile(keyfilePath); err != nil {
+				return err
+			}
+			if slot.Keyfile, err = loadKeyfile(newKeyfilePath); err != nil {
+				return err
+			}
+			if len(password) == 0 {
+				if password, err = c.promptDecryptionPassword(); err != nil {
+					return fmt.Errorf("failed to get password: %w", err)
+				}
+			}
+			if len(newPassword) == 0 && (newKeyfilePath == "" || requireBoth) {
+				display.ShowInfo("Choose the password for the new key slot")
+				if newPassword, err = c.askEncryptionPassword(); err != nil {
+					return fmt.Errorf("failed to get new password: %w", err)
+				}
+			}
+			slot.Password = newPassword
+
+			index, err := processor.AddKeySlot(args[0], password, opts, slot)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Added key slot %d to %s\n", index, args[0])
+			return nil
+		},
+	}
+
+	cmd.Flags().VarP(c.passwordFlag(&password), "password", "p", "A password that already opens the file (prompts if not provided)")
+	cmd.Flags().StringVarP(&keyfilePath, "keyfile", "k", "", "Keyfile that goes with that password")
+	cmd.Flags().Var(c.passwordFlag(&newPassword), "new-password", "Password for the new slot (prompts if not provided)")
+	cmd.Flags().StringVar(&newKeyfilePath, "new-keyfile", "", "Keyfile for the new slot; without --require-both it opens the file on its own")
+	cmd.Flags().BoolVar(&requireBoth, "require-both", false, "Require both the new password and the new keyfile to open the new slot")
+	cmd.Flags().StringVar(&kdfProfile, "kdf-profile", derive.ProfileDefault, "Argon2id cost profile for the new slot")
+	cmd.Flags().BoolVar(&enforce, "enforce-strength", false, "Apply the interactive password rules to a password given with --new-password")
+	return cmd
+}
+
+func (c *CLI) createKeyslotRemoveCommand() *cobra.Command {
+	var (
+		password    []byte
+		keyfilePath string
+	)
+
+	cmd := &cobra.Command{
+		Use:     "remove FILE SLOT",
+		Short:   "Remove a key slot so its password or keyfile no longer opens FILE",
+		Long:    "Removes key slot SLOT (see keyslot list). Any slot's password can be used to authorize the removal, including the one being removed. Removing slot 0 makes slot 1 the primary slot. The last slot cannot be removed. Copies of the file made before the removal still open with the old password; use rekey to change the data key itself.",
+		Example: `  sweetbyte keyslot remove secrets.db.swx 1`,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			index, err := strconv.Atoi(args[1])
+			if err != nil {
+				return errors.Newf(errors.CodeInvalidInput, "keyslot remove", "invalid slot %q", args[1])
+			}
+
+			var opts processor.Options
+			if opts.Keyfile, err = loadKeyfile(keyfilePath); err != nil {
+				return err
+			}
+			if len(password) == 0 {
+				if password, err = c.promptDecryptionPassword(); err != nil {
+					return fmt.Errorf("failed to get password: %w", err)
+				}
+			}
+
+			if err := processor.RemoveKeySlot(args[0], password, opts, index); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Removed key slot %d from %s\n", index, args[0])
+			return nil
+		},
+	}
+
+	cmd.Flags().VarP(c.passwordFlag(&password), "password", "p", "A password that opens the file (prompts if not provided)")
+	cmd.Flags().StringVarP(&keyfilePath, "keyfile", "k", "", "Keyfile that goes with that password")
+	return cmd
+}
+
+func describeSlotFactors(slot processor.KeySlotInfo) string {
+	var factors string
+	switch {
+	case slot.Password && slot.Keyfile:
+		factors = "password+keyfile"
+	case slot.Keyfile:
+		factors = "keyfile"
+	default:
+		factors = "password"
+	}
+	if slot.Token != "" {
+		name, _, _ := strings.Cut(slot.Token, ":")
+		factors += "+" + name
+	}
+	return factors
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/mount"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/recipient"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createMountCommand() *cobra.Command {
+	var (
+		password     []byte
+		keyfilePath  string
+		identityPath string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "mount FILE MOUNTPOINT",
+		Short: "Browse an encrypted file or archive read-only without decrypting it to disk",
+		Long: `Mounts FILE at MOUNTPOINT with FUSE until interrupted or unmounted.
+
+An archive shows up as its directory tree; any other file shows up as a
+single file under its original name. Chunks are decrypted when they are
+read and kept in a small cache, so nothing is written to disk. Files
+encrypted from a pipe, including archives, are read once in full at mount
+time to check their trailer.`,
+		Example: `  sweetbyte mount photos.swb ~/mnt/photos
+  sweetbyte mount ledger.xlsx.swx /mnt/ledger --keyfile ~/.config/ledger.key`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				opts processor.Options
+				err  error
+			)
+			if opts.Keyfile, err = loadKeyfile(keyfilePath); err != nil {
+				return err
+			}
+			if identityPath != "" {
+				if opts.Identity, err = recipient.ReadIdentity(identityPath); err != nil {
+					return err
+				}
+			}
+			if len(password) == 0 && needsPassword(opts) {
+				if password, err = c.promptDecryptionPassword(); err != nil {
+					return fmt.Errorf("failed to get password: %w", err)
+				}
+			}
+			opts = opts.WithTuning(config.LoadTuning())
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+			return mount.Mount(ctx, args[0], args[1], password, opts, logger)
+		},
+	}
+
+	cmd.Flags().VarP(c.passwordFlag(&password), "password", "p", "Password to unlock the file with (prompts if not provided)")
+	cmd.Flags().StringVarP(&keyfilePath, "keyfile", "k", "", "Keyfile used when the file was encrypted")
+	cmd.Flags().StringVar(&identityPath, "identity", "", "Private key for files encrypted with --recipient")
+	return cmd
+}
@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/parity"
+	"github.com/hambosto/sweetbyte/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createParityCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "parity",
+		Short: "Create and use detached Reed-Solomon parity files",
+		Long:  "Keep Reed-Solomon parity for any file, encrypted or not, in a separate " + parity.FileExtension + " file that can live on different media. Parity covers the bytes as stored, so no password is needed to create it or to repair from it.",
+	}
+
+	cmd.AddCommand(c.createParityCreateCommand())
+	cmd.AddCommand(c.createParityVerifyCommand())
+	cmd.AddCommand(c.createParityRepairCommand())
+	return cmd
+}
+
+func (c *CLI) createParityCreateCommand() *cobra.Command {
+	var (
+		output string
+		force  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "create FILE",
+		Short: "Write a parity file for FILE",
+		Example: `  sweetbyte parity create backup.tar.swb
+  sweetbyte parity create backup.tar.swb -o /mnt/usb/backup.tar.swb.par`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output == "" {
+				output = parity.DefaultPath(args[0])
+			}
+			if err := validateOutput(output, force); err != nil {
+				return err
+			}
+
+			info, err := parity.Create(cmd.Context(), args[0], output)
+			if err != nil {
+				return err
+			}
+
+			overhead := 0.0
+			if info.Size > 0 {
+				overhead = float64(info.ParitySize) / float64(info.Size) * 100
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s: %s of parity for %s (%.1f%%), %d stripes of %d+%d shards\n",
+				info.Path, utils.FormatBytes(info.ParitySize), utils.FormatBytes(info.Size), overhead, info.Stripes, parity.DataShards, parity.ParityShards)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Parity file to write (default: FILE"+parity.FileExtension+")")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite the parity file if it exists")
+	return cmd
+}
+
+func (c *CLI) createParityVerifyCommand() *cobra.Command {
+	var parityPath string
+
+	cmd := &cobra.Command{
+		Use:     "verify FILE",
+		Short:   "Check FILE against its parity file without changing anything",
+		Example: `  sweetbyte parity verify backup.tar.swb`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if parityPath == "" {
+				parityPath = parity.DefaultPath(args[0])
+			}
+
+			report, err := parity.Repair(cmd.Context(), args[0], parityPath, "")
+			if err != nil && !errors.Is(err, parity.ErrUnrepairable) {
+				return err
+			}
+
+			printParityReport(cmd.OutOrStdout(), report)
+			if err != nil {
+				return err
+			}
+			if !report.Intact() {
+				return errors.Newf(errors.CodeCorrupt, "parity verify", "%d of %d stripes are damaged; run parity repair", len(report.DamagedStripes), report.Stripes).WithPath(args[0])
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&parityPath, "parity", "", "Parity file to check against (default: FILE"+parity.FileExtension+")")
+	return cmd
+}
+
+func (c *CLI) createParityRepairCommand() *cobra.Command {
+	var (
+		parityPath string
+		output     string
+		force      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "repair FILE",
+		Short: "Rebuild the damaged parts of FILE from its parity file",
+		Long:  "Checks FILE against its parity file and, if anything is damaged or missing, rebuilds it and replaces FILE with the repaired copy (or writes it to --output). The repaired copy is checked against the hash recorded when the parity was created.",
+		Example: `  sweetbyte parity repair backup.tar.swb
+  sweetbyte parity repair backup.tar.swb --parity /mnt/usb/backup.tar.swb.par -o restored.tar.swb`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inputFile := args[0]
+			if parityPath == "" {
+				parityPath = parity.DefaultPath(inputFile)
+			}
+			if output == "" {
+				output = inputFile
+			} else if err := validateOutput(output, force); err != nil {
+				return err
+			}
+
+			check, err := parity.Repair(cmd.Context(), inputFile, parityPath, "")
+			if err == nil && check.Intact() {
+				printParityReport(cmd.OutOrStdout(), check)
+				return nil
+			}
+			if err != nil && !errors.Is(err, parity.ErrUnrepairable) {
+				return err
+			}
+
+			report, err := parity.Repair(cmd.Context(), inputFile, parityPath, output)
+			if err != nil && !errors.Is(err, parity.ErrUnrepairable) {
+				return err
+			}
+
+			printParityReport(cmd.OutOrStdout(), report)
+			if err == nil {
+				fmt.Fprintf(cmd.OutOrStdout(), "Wrote repaired copy to %s\n", output)
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&parityPath, "parity", "", "Parity file to repair from (default: FILE"+parity.FileExtension+")")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write the repaired copy here instead of replacing FILE")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite the output file if it exists")
+	return cmd
+}
+
+func printParityReport(w io.Writer, r parity.Report) {
+	switch {
+	case r.Intact():
+		fmt.Fprintf(w, "OK       %s (%d stripes, %s)\n", r.Path, r.Stripes, utils.FormatBytes(r.Size))
+	case r.UnrecoverableStripes > 0:
+		fmt.Fprintf(w, "DAMAGED  %s: %d of %d stripes damaged, %d beyond repair\n", r.Path, len(r.DamagedStripes), r.Stripes, r.UnrecoverableStripes)
+	default:
+		fmt.Fprintf(w, "DAMAGED  %s: %d of %d stripes damaged, all repairable (%d data shards rebuilt)\n", r.Path, len(r.DamagedStripes), r.Stripes, r.RepairedShards)
+	}
+	if r.Truncated {
+		fmt.Fprintln(w, "         the file is shorter than when its parity was created")
+	}
+	if r.ParityShardsDamaged > 0 {
+		fmt.Fprintf(w, "         %d parity shards are damaged; recreate the parity file after repairing\n", r.ParityShardsDamaged)
+	}
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hambosto/sweetbyte/internal/archive"
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/storage"
+	"github.com/hambosto/sweetbyte/internal/types"
+	"github.com/hambosto/sweetbyte/internal/ui/display"
+	"github.com/hambosto/sweetbyte/internal/utils"
+)
+
+// parseRange turns --range START:END, or --offset and --length, into an
+// offset and a length, which is -1 for up to the end. END is exclusive and
+// either bound may be left out.
+func parseRange(byteRange, offset, length string) (int64, int64, error) {
+	if byteRange != "" {
+		if offset != "" || length != "" {
+			return 0, 0, errors.Newf(errors.CodeInvalidInput, "--range", "cannot be combined with --offset or --length")
+		}
+		startValue, endValue, ok := strings.Cut(byteRange, ":")
+		if !ok {
+			return 0, 0, errors.Newf(errors.CodeInvalidInput, "--range", "expected START:END, such as 1MB:2MB, 4096: or :64KB")
+		}
+		start, err := parseOptionalBytes("--range", startValue, 0)
+		if err != nil {
+			return 0, 0, err
+		}
+		end, err := parseOptionalBytes("--range", endValue, -1)
+		if err != nil {
+			return 0, 0, err
+		}
+		if end < 0 {
+			return start, -1, nil
+		}
+		if end < start {
+			return 0, 0, errors.Newf(errors.CodeInvalidInput, "--range", "end %d is before start %d", end, start)
+		}
+		return start, end - start, nil
+	}
+
+	start, err := parseOptionalBytes("--offset", offset, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	n, err := parseOptionalBytes("--length", length, -1)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, n, nil
+}
+
+func parseOptionalBytes(flag, value string, fallback int64) (int64, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := utils.ParseBytes(value)
+	if err != nil {
+		return 0, errors.New(errors.CodeInvalidInput, flag, err)
+	}
+	return n, nil
+}
+
+// runPartial writes part of a decrypted file, or of one file in an archive,
+// to stdout unless an output file is given.
+func (c *CLI) runPartial(inputFile, outputFile string, password []byte, member string, offset, length int64, force bool, opts processor.Options) error {
+	if processor.IsStdio(inputFile) || storage.IsRemote(inputFile) {
+		return errors.Newf(errors.CodeInvalidInput, "--range", "partial decryption needs a local input file it can seek in")
+	}
+	if err := file.ValidatePath(inputFile, true); err != nil {
+		return fmt.Errorf("input file validation failed: %w", err)
+	}
+	if outputFile == "" {
+		outputFile = processor.StdioPath
+	}
+	if !processor.IsStdio(outputFile) {
+		if err := validateOutput(outputFile, force); err != nil {
+			return err
+		}
+	}
+
+	if len(password) == 0 && needsPassword(opts) {
+		var err error
+		if password, err = c.promptDecryptionPassword(); err != nil {
+			return fmt.Errorf("failed to get password: %w", err)
+		}
+	}
+
+	opts.Reporter = display.NewQuietReporter()
+	if !processor.IsStdio(outputFile) {
+		opts.Reporter = display.NewReporter(nil)
+	}
+	opts = opts.WithTuning(config.LoadTuning())
+	if err := runCancelable(func(ctx context.Context) error {
+		if member != "" {
+			return archive.ExtractMember(ctx, inputFile, member, outputFile, password, opts, offset, length)
+		}
+		return processor.DecryptRange(ctx, inputFile, outputFile, password, opts, offset, length)
+	}); err != nil {
+		return err
+	}
+
+	if !processor.IsStdio(outputFile) {
+		display.ShowSuccessInfo(types.ModeDecrypt, outputFile)
+	}
+	return nil
+}
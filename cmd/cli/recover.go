@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/escrow"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/secret"
+	"github.com/hambosto/sweetbyte/internal/types"
+	"github.com/hambosto/sweetbyte/internal/ui/display"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createRecoverCommand() *cobra.Command {
+	var (
+		sharePaths []string
+		output     string
+		force      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "recover FILE [SHARE...]",
+		Short: "Decrypt FILE with key shares instead of a password",
+		Long:  "Rebuilds FILE's data key from the shares written by encrypt --split-key and decrypts it. Any threshold of the shares is enough; fewer reveal nothing about the key.",
+		Example: `  sweetbyte recover ledger.xlsx.swx --share ledger.xlsx.swx.share1 --share ledger.xlsx.swx.share4 --share ledger.xlsx.swx.share5
+  sweetbyte recover ledger.xlsx.swx ledger.xlsx.swx.share* -o ledger.xlsx`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inputFile := args[0]
+			paths := append(slices.Clone(sharePaths), args[1:]...)
+			if len(paths) == 0 {
+				return errors.New(errors.CodeInvalidInput, "--share", escrow.ErrTooFewShares)
+			}
+
+			shares := make([]*escrow.Share, 0, len(paths))
+			for _, path := range paths {
+				share, err := escrow.ReadShare(path)
+				if err != nil {
+					return err
+				}
+				shares = append(shares, share)
+			}
+
+			fileID, err := processor.FileID(inputFile)
+			if err != nil {
+				return err
+			}
+			for i, share := range shares {
+				if !bytes.Equal(share.FileID, fileID) {
+					return errors.New(errors.CodeInvalidInput, "recover", escrow.ErrShareWrongFile).WithPath(paths[i])
+				}
+			}
+
+			dataKey, err := escrow.CombineShares(shares)
+			if err != nil {
+				return err
+			}
+			defer secret.Wipe(dataKey)
+
+			if output == "" {
+				if output, err = decryptOutputPath(inputFile); err != nil {
+					return err
+				}
+			}
+			if err := validateOutput(output, force); err != nil {
+				return err
+			}
+
+			opts := processor.Options{DataKey: dataKey, Reporter: display.NewReporter(nil), Repaired: display.ShowRepairReport}.WithTuning(config.LoadTuning())
+			if err := runCancelable(func(ctx context.Context) error {
+				return processor.Decryption(ctx, inputFile, output, nil, opts)
+			}); err != nil {
+				if errors.Is(err, processor.ErrAuthentication) {
+					return errors.New(errors.CodeAuthentication, "recover", escrow.ErrSharesMismatch).WithPath(inputFile)
+				}
+				return err
+			}
+			display.ShowSuccessInfo(types.ModeDecrypt, output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&sharePaths, "share", nil, "Key share file (repeatable; shares can also be given as arguments)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output file (default: removes "+config.FileExtension+" extension)")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite the output file if it exists")
+	return cmd
+}
+
+func parseSplitKey(value string) (threshold, total int, err error) {
+	if value == "" {
+		return 0, 0, nil
+	}
+
+	k, n, ok := strings.Cut(value, "/")
+	if ok {
+		threshold, err = strconv.Atoi(k)
+		if err == nil {
+			total, err = strconv.Atoi(n)
+		}
+	}
+	if !ok || err != nil || threshold < 2 || threshold > total || total > 255 {
+		return 0, 0, errors.Newf(errors.CodeInvalidInput, "--split-key", "expected K/N with 2 <= K <= N <= 255, e.g. 3/5, got %q", value)
+	}
+	return threshold, total, nil
+}
+
+// splitKeyShares writes total shares of each encrypted file's data key next
+// to it, threshold of which rebuild the key for recover.
+func splitKeyShares(threshold, total int) func(string, []byte, []byte) error {
+	return func(destPath string, fileID, dataKey []byte) error {
+		shares, err := escrow.SplitKey(fileID, dataKey, threshold, total)
+		if err != nil {
+			return err
+		}
+		for _, share := range shares {
+			path := escrow.SharePath(destPath, share.Index())
+			if err := share.Write(path); err != nil {
+				return err
+			}
+			display.ShowInfo(fmt.Sprintf("Wrote key share %d of %d to %s", share.Index(), total, path))
+		}
+		return nil
+	}
+}
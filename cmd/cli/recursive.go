@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/reporter"
+	"github.com/hambosto/sweetbyte/internal/secret"
+	"github.com/hambosto/sweetbyte/internal/stream"
+	"github.com/hambosto/sweetbyte/internal/types"
+	"github.com/hambosto/sweetbyte/internal/ui/display"
+	"golang.org/x/sync/errgroup"
+)
+
+func (c *CLI) runRecursive(mode types.ProcessorMode, root, outputRoot string, password []byte, deleteSource, force bool, jobs int, opts processor.Options) error {
+	if jobs < 1 {
+		return errors.Newf(errors.CodeInvalidInput, "--jobs", "must be at least 1")
+	}
+
+	entries, err := file.MapTree(root, outputRoot, mode, 0)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return errors.New(errors.CodeNotFound, "", fmt.Errorf("%w for %s operation", file.ErrNoEligibleFiles, mode)).WithPath(root)
+	}
+	return c.runEntries(mode, entries, password, deleteSource, force, jobs, opts)
+}
+
+func (c *CLI) runBatch(mode types.ProcessorMode, patterns []string, outputDir string, password []byte, deleteSource, force bool, jobs int, opts processor.Options) error {
+	if jobs < 1 {
+		return errors.Newf(errors.CodeInvalidInput, "--jobs", "must be at least 1")
+	}
+
+	entries, err := file.MapFiles(patterns, outputDir, mode)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return errors.New(errors.CodeNotFound, "", fmt.Errorf("%w for %s operation", file.ErrNoEligibleFiles, mode))
+	}
+	return c.runEntries(mode, entries, password, deleteSource, force, jobs, opts)
+}
+
+func (c *CLI) runEntries(mode types.ProcessorMode, entries []file.TreeEntry, password []byte, deleteSource, force bool, jobs int, opts processor.Options) error {
+	var err error
+	results := make([]display.QueueEntry, len(entries))
+	var totalSize int64
+	for i, entry := range entries {
+		if mode == types.ModeEncrypt && opts.HideName {
+			entry.Output = file.HiddenOutputPath(entry.Output)
+			entries[i] = entry
+		}
+		results[i] = display.QueueEntry{Mode: mode, Input: entry.Input, Output: entry.Output}
+		if entry.Size == 0 {
+			results[i].Skipped = true
+			continue
+		}
+		if err := validateOutput(entry.Output, force); err != nil {
+			return err
+		}
+		totalSize += entry.Size
+	}
+
+	if len(password) == 0 && needsPassword(opts) {
+		if mode == types.ModeEncrypt {
+			password, err = c.promptEncryptionPassword()
+		} else {
+			password, err = c.promptDecryptionPassword()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get password: %w", err)
+		}
+	}
+	if mode == types.ModeEncrypt && opts.Recipient == nil {
+		if opts.BatchKey, err = processor.NewBatchKey(password, opts); err != nil {
+			return err
+		}
+		defer opts.BatchKey.Destroy()
+	}
+
+	r := display.NewReporter(nil)
+	progress := r.Progress(totalSize, fmt.Sprintf("%sing %d files...", mode, len(entries)))
+	opts.Reporter = reporter.Shared(r, progress)
+	opts = opts.WithTuning(config.LoadTuning())
+	if opts.MaxWorkers < 0 {
+		return errors.Newf(errors.CodeInvalidInput, "--max-workers", "must be at least 1")
+	}
+	if opts.WorkerPool, err = stream.NewWorkerPool(opts.MaxWorkers); err != nil {
+		return errors.New(errors.CodeInvalidInput, "--max-workers", err)
+	}
+	if opts.RateLimit > 0 {
+		if opts.Throttle, err = stream.NewThrottle(opts.RateLimit); err != nil {
+			return errors.New(errors.CodeInvalidInput, "--limit-rate", err)
+		}
+	}
+
+	err = runCancelable(func(ctx context.Context) error {
+		g := new(errgroup.Group)
+		g.SetLimit(jobs)
+		for i, entry := range entries {
+			if results[i].Skipped {
+				continue
+			}
+			g.Go(func() error {
+				if ctx.Err() != nil {
+					results[i].Skipped = true
+					return nil
+				}
+				results[i].Err = processTreeEntry(ctx, mode, entry, password, deleteSource, force, opts)
+				results[i].Done = results[i].Err == nil
+				return nil
+			})
+		}
+		_ = g.Wait()
+		return ctx.Err()
+	})
+
+	display.ShowTreeSummary(results)
+	if err != nil {
+		return errors.New(errors.CodeCanceled, "", err)
+	}
+
+	var failed int
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errors.Newf(errors.CodeUnknown, "", "%d of %d files failed", failed, len(entries))
+	}
+	return nil
+}
+
+func processTreeEntry(ctx context.Context, mode types.ProcessorMode, entry file.TreeEntry, password []byte, deleteSource, force bool, opts processor.Options) error {
+	var err error
+	if mode == types.ModeDecrypt && processor.HasHiddenName(entry.Input) {
+		output, key, err := processor.RevealOutputPath(entry.Input, password, opts)
+		if err != nil {
+			return err
+		}
+		defer secret.Wipe(key)
+		entry.Output = filepath.Join(filepath.Dir(entry.Output), filepath.Base(output))
+		if err := validateOutput(entry.Output, force); err != nil {
+			return err
+		}
+		opts.DataKey = key
+	}
+
+	if mode == types.ModeEncrypt {
+		err = processor.Encryption(ctx, entry.Input, entry.Output, password, opts)
+	} else {
+		err = processor.Decryption(ctx, entry.Input, entry.Output, password, opts)
+	}
+	if err != nil || !deleteSource {
+		return err
+	}
+
+	if err := file.Remove(entry.Input); err != nil {
+		return fmt.Errorf("failed to delete source file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hambosto/sweetbyte/internal/cipher"
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/recipient"
+	"github.com/hambosto/sweetbyte/internal/ui/display"
+	"github.com/hambosto/sweetbyte/internal/ui/prompt"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createRekeyCommand() *cobra.Command {
+	var (
+		inputFile      string
+		outputFile     string
+		oldPassword    []byte
+		newPassword    []byte
+		oldKeyfilePath string
+		newKeyfilePath string
+		identityPath   string
+		recipientKey   string
+		enforce        bool
+		force          bool
+		maxMemory      string
+		token          string
+		newOpts        processor.Options
+	)
+
+	cmd := &cobra.Command{
+		Use:   "rekey [flags] [FILE]",
+		Short: "Re-encrypt a file under a new password or key",
+		Long:  "Decrypts a file and encrypts it again with a new password, keyfile or recipient in a single pass, without writing the plaintext to disk. The new file gets a fresh data key and the current header format; tags, content type, hidden name, timestamps and owner are carried over. The KDF profile, cipher suite and chunk size are kept unless given.",
+		Example: `  sweetbyte rekey -i secrets.db.swx
+  sweetbyte rekey -i secrets.db.swx --old-password "$OLD" --new-password "$NEW"
+  sweetbyte rekey -i wallet.dat.swx --kdf-profile paranoid
+  sweetbyte rekey -i footage.mkv.swx --cipher xchacha20
+  sweetbyte rekey -i payroll.csv.swx --new-keyfile vault.key --require-both
+  sweetbyte rekey -i payroll.csv.swx --recipient alice.pub -o payroll-alice.csv.swx`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if inputFile, err = resolveInput(inputFile, args); err != nil {
+				return err
+			}
+			if err := file.ValidatePath(inputFile, true); err != nil {
+				return fmt.Errorf("input file validation failed: %w", err)
+			}
+			if newOpts.KDFProfile != "" {
+				if _, err := derive.ProfileParams(newOpts.KDFProfile); err != nil {
+					return errors.New(errors.CodeInvalidInput, "--kdf-profile", err)
+				}
+			}
+			if newOpts.Cipher != "" {
+				if _, err := cipher.ParseSuite(newOpts.Cipher); err != nil {
+					return errors.New(errors.CodeInvalidInput, "--cipher", err)
+				}
+			}
+			if newOpts.RequireBoth && newKeyfilePath == "" {
+				return errors.New(errors.CodeInvalidInput, "--require-both", processor.ErrMissingFactor)
+			}
+			if recipientKey != "" && (len(newPassword) > 0 || newKeyfilePath != "" || token != "") {
+				return errors.Newf(errors.CodeInvalidInput, "--recipient", "cannot be combined with --new-password, --new-keyfile or --token")
+			}
+			if newOpts.Token, err = parseToken(token); err != nil {
+				return err
+			}
+			if enforce && len(newPassword) > 0 {
+				if err := prompt.ValidateEncryptionPassword(newPassword); err != nil {
+					return errors.New(errors.CodeInvalidInput, "--new-password", err)
+				}
+			}
+
+			if outputFile == "" {
+				outputFile = inputFile
+			} else if err := validateOutput(outputFile, force); err != nil {
+				return err
+			}
+
+			var oldOpts processor.Options
+			if oldOpts.MaxMemory, err = parseMaxMemory(maxMemory); err != nil {
+				return err
+			}
+			if oldOpts.Keyfile, err = loadKeyfile(oldKeyfilePath); err != nil {
+				return err
+			}
+			if identityPath != "" {
+				if oldOpts.Identity, err = recipient.ReadIdentity(identityPath); err != nil {
+					return err
+				}
+			}
+			if newOpts.Keyfile, err = loadKeyfile(newKeyfilePath); err != nil {
+				return err
+			}
+			if recipientKey != "" {
+				if newOpts.Recipient, err = recipient.ReadPublicKey(recipientKey); err != nil {
+					return err
+				}
+			}
+			newOpts.MaxMemory = oldOpts.MaxMemory
+
+			if len(oldPassword) == 0 && needsPassword(oldOpts) {
+				if oldPassword, err = c.promptDecryptionPassword(); err != nil {
+					return fmt.Errorf("failed to get password: %w", err)
+				}
+			}
+			if len(newPassword) == 0 && needsPassword(newOpts) {
+				display.ShowInfo("Choose the new password")
+				if newPassword, err = c.askEncryptionPassword(); err != nil {
+					return fmt.Errorf("failed to get new password: %w", err)
+				}
+			}
+
+			newOpts.Reporter = display.NewReporter(nil)
+			tuning := config.LoadTuning()
+			if err := processor.Rekey(cmd.Context(), inputFile, outputFile, oldPassword, newPassword, oldOpts.WithTuning(tuning), newOpts.WithTuning(tuning)); err != nil {
+				return err
+			}
+
+			display.ShowRekeyed(outputFile)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&inputFile, "input", "i", "", "Encrypted file to re-encrypt")
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Write the re-encrypted file here instead of replacing the input")
+	cmd.Flags().Var(c.passwordFlag(&oldPassword), "old-password", "Current password (prompts if not provided)")
+	cmd.Flags().Var(c.passwordFlag(&newPassword), "new-password", "New password (prompts if not provided)")
+	cmd.Flags().StringVar(&oldKeyfilePath, "old-keyfile", "", "Keyfile the file is currently encrypted with")
+	cmd.Flags().StringVar(&newKeyfilePath, "new-keyfile", "", "Keyfile to encrypt the file with from now on")
+	cmd.Flags().BoolVar(&newOpts.RequireBoth, "require-both", false, "Require both the new password and the new keyfile to decrypt")
+	cmd.Flags().StringVar(&identityPath, "identity", "", "Private key for files currently encrypted with --recipient")
+	cmd.Flags().StringVar(&recipientKey, "recipient", "", "Encrypt to this public key instead of a new password")
+	cmd.Flags().StringVar(&token, "token", "", "Hardware token the re-encrypted file requires (default: keep the current one; see encrypt --token)")
+	cmd.Flags().StringVar(&newOpts.KDFProfile, "kdf-profile", "", "Argon2id cost profile for the new password: light, default or paranoid (default: keep the current one)")
+	cmd.Flags().StringVar(&newOpts.Cipher, "cipher", "", "Cipher suite for the re-encrypted file: cascade, aes-gcm, xchacha20 or auto (default: keep the current one)")
+	cmd.Flags().BoolVar(&newOpts.NoECC, "no-ecc", false, "Drop the Reed-Solomon parity from the re-encrypted file")
+	cmd.Flags().BoolVar(&enforce, "enforce-strength", false, "Apply the interactive password rules to a password given with --new-password")
+	cmd.Flags().StringVar(&maxMemory, "max-memory", "", "Cap pipeline memory, e.g. 512MB (lowers prefetch, workers and chunk size to fit)")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite the output file if it exists")
+	_ = cmd.MarkFlagFilename("input", strings.TrimPrefix(config.FileExtension, "."))
+	return cmd
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"cmp"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/ui/display"
+)
+
+type repairLog struct {
+	mu      sync.Mutex
+	path    string
+	reports []processor.RepairReport
+}
+
+func newRepairLog(path string) *repairLog {
+	return &repairLog{path: path, reports: []processor.RepairReport{}}
+}
+
+func (l *repairLog) record(report processor.RepairReport) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.reports = append(l.reports, report)
+	display.ShowRepairReport(report)
+}
+
+func (l *repairLog) finish(err error) error {
+	if l.path == "" {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	data, writeErr := json.MarshalIndent(l.reports, "", "  ")
+	if writeErr == nil {
+		if writeErr = os.WriteFile(l.path, append(data, '\n'), 0o600); writeErr != nil {
+			writeErr = errors.New(errors.CodeIO, "--repair-report", writeErr).WithPath(l.path)
+		}
+	}
+	return cmp.Or(err, writeErr)
+}
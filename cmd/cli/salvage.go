@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createSalvageCommand() *cobra.Command {
+	var (
+		output      string
+		password    []byte
+		keyfilePath string
+		mapPath     string
+		skipLost    bool
+		force       bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "salvage FILE",
+		Short: "Recover what can still be decrypted from a damaged file",
+		Long:  "Decrypts every chunk that Reed-Solomon can still repair and authenticates, fills the chunks that cannot be recovered with zeros (or leaves them out with --skip-lost), and prints a map of recovered and lost byte ranges of the original file.",
+		Example: `  sweetbyte salvage damaged.tar.swx -o recovered.tar
+  sweetbyte salvage damaged.tar.swx -o recovered.tar --map recovered.json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inputFile := args[0]
+			if output == "" {
+				var err error
+				if output, err = decryptOutputPath(inputFile); err != nil {
+					return err
+				}
+			}
+			if err := validateOutput(output, force); err != nil {
+				return err
+			}
+
+			var (
+				opts processor.Options
+				err  error
+			)
+			if opts.Keyfile, err = loadKeyfile(keyfilePath); err != nil {
+				return err
+			}
+			if len(password) == 0 {
+				if password, err = c.promptDecryptionPassword(); err != nil {
+					return fmt.Errorf("failed to get password: %w", err)
+				}
+			}
+
+			report, err := processor.Salvage(cmd.Context(), inputFile, output, password, skipLost, opts.WithTuning(config.LoadTuning()))
+			if err != nil {
+				return err
+			}
+
+			printSalvageReport(cmd.OutOrStdout(), output, report)
+			if mapPath != "" {
+				if err := writeSalvageMap(mapPath, report); err != nil {
+					return err
+				}
+			}
+			if report.LostBytes > 0 {
+				return errors.New(errors.CodeCorrupt, "salvage", processor.ErrDataLost).WithPath(inputFile)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output file (default: removes "+config.FileExtension+" extension)")
+	cmd.Flags().VarP(c.passwordFlag(&password), "password", "p", "Decryption password (prompts if not provided)")
+	cmd.Flags().StringVarP(&keyfilePath, "keyfile", "k", "", "Keyfile used when the file was encrypted")
+	cmd.Flags().StringVar(&mapPath, "map", "", "Also write the recovered and lost ranges as JSON to this file")
+	cmd.Flags().BoolVar(&skipLost, "skip-lost", false, "Leave lost chunks out instead of filling them with zeros")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite the output file if it exists")
+	return cmd
+}
+
+func printSalvageReport(w io.Writer, output string, report processor.SalvageReport) {
+	for _, r := range report.Ranges {
+		status := "RECOVERED"
+		if !r.Recovered {
+			status = "LOST     "
+		}
+		fmt.Fprintf(w, "%s bytes %d-%d (%s)\n", status, r.Start, r.End-1, utils.FormatBytes(r.End-r.Start))
+	}
+
+	percent := 100.0
+	if report.OriginalSize > 0 {
+		percent = float64(report.RecoveredBytes) / float64(report.OriginalSize) * 100
+	}
+	fmt.Fprintf(w, "\nRecovered %.1f%% of %s into %s: %d chunks read, %d repaired with Reed-Solomon, %d lost\n",
+		percent, utils.FormatBytes(report.OriginalSize), output, report.Chunks, report.RepairedChunks, report.LostChunks)
+	if report.FramingLost {
+		fmt.Fprintln(w, "The chunk framing was damaged; everything after that point is reported as lost.")
+	}
+}
+
+func writeSalvageMap(path string, report processor.SalvageReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/reporter"
+	"github.com/hambosto/sweetbyte/internal/server"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createServeCommand() *cobra.Command {
+	var (
+		socket    string
+		httpAddr  string
+		tokenFile string
+		tlsCert   string
+		tlsKey    string
+		jobs      int
+		verbose   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Accept encrypt, decrypt and verify jobs from other programs",
+		Long: `Runs until interrupted and accepts jobs over an HTTP/JSON API on a Unix socket.
+
+Other programs, such as graphical front ends, submit jobs with
+POST /v1/jobs, poll GET /v1/jobs/{id} for progress and cancel them with
+POST /v1/jobs/{id}/cancel. Jobs beyond the --jobs limit wait in a queue.
+Only the user running the server can connect to the socket. Paths in jobs
+are resolved by the server, relative to its working directory.
+
+POST /encrypt and POST /decrypt stream the request body through encryption
+or decryption into the response body, with the password in the
+` + server.PasswordHeader + ` header. --http also offers them, without the job
+API, on a network address; every request there must send the API token
+from --api-token-file or $` + config.APITokenEnv + ` as a bearer token. Without
+--tls-cert and --tls-key, --http only accepts a loopback address.`,
+		Example: `  sweetbyte serve
+  sweetbyte serve --socket /run/user/1000/sweetbyte.sock --jobs 4
+  sweetbyte serve --http :8080 --api-token-file /etc/sweetbyte/token --tls-cert cert.pem --tls-key key.pem`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if socket == "" {
+				path, err := server.DefaultSocketPath()
+				if err != nil {
+					return err
+				}
+				socket = path
+			}
+
+			var token string
+			if httpAddr != "" {
+				var err error
+				if token, err = readAPIToken(tokenFile); err != nil {
+					return err
+				}
+			}
+
+			level := slog.LevelInfo
+			if verbose {
+				level = slog.LevelDebug
+			}
+			logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+			listener, err := server.ListenUnix(socket)
+			if err != nil {
+				return err
+			}
+			defer os.Remove(socket)
+
+			var public net.Listener
+			if httpAddr != "" {
+				if public, err = server.ListenTCP(httpAddr, tlsCert, tlsKey); err != nil {
+					listener.Close()
+					return err
+				}
+			}
+
+			manager := server.NewManager(jobs, func(ctx context.Context, req server.Request, r reporter.Reporter) (string, error) {
+				logger.Debug("job started", "operation", req.Operation, "input", req.Input)
+				output, err := server.Run(ctx, req, r)
+				if err != nil {
+					logger.Warn("job failed", "operation", req.Operation, "input", req.Input, "error", err)
+				} else {
+					logger.Debug("job finished", "operation", req.Operation, "input", req.Input, "output", output)
+				}
+				return output, err
+			})
+			defer manager.Close()
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			srv := server.New(manager, logger)
+			listeners := map[net.Listener]http.Handler{listener: srv.Handler()}
+			if public != nil {
+				listeners[public] = srv.PublicHandler(token)
+				logger.Info("server started", "socket", socket, "http", public.Addr().String(), "jobs", jobs)
+			} else {
+				logger.Info("server started", "socket", socket, "jobs", jobs)
+			}
+
+			done := make(chan error, len(listeners))
+			for l, handler := range listeners {
+				go func() { done <- srv.Serve(ctx, l, handler) }()
+			}
+			for range listeners {
+				if serveErr := <-done; serveErr != nil && err == nil {
+					err = serveErr
+					stop()
+				}
+			}
+			logger.Info("server stopped")
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&socket, "socket", "", "Unix socket to listen on (default: $XDG_RUNTIME_DIR/"+server.SocketName+")")
+	cmd.Flags().StringVar(&httpAddr, "http", "", "Also offer /encrypt and /decrypt on this network address, such as :8080 (loopback only without --tls-cert)")
+	cmd.Flags().StringVar(&tokenFile, "api-token-file", "", "Read the API token required by --http from the first line of this file (default: $"+config.APITokenEnv+")")
+	cmd.Flags().StringVar(&tlsCert, "tls-cert", "", "Serve --http over TLS with this PEM certificate")
+	cmd.Flags().StringVar(&tlsKey, "tls-key", "", "PEM private key for --tls-cert")
+	cmd.Flags().IntVar(&jobs, "jobs", 2, "Number of jobs and streams to run at once")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Log when each job starts")
+	_ = cmd.MarkFlagFilename("api-token-file")
+	cmd.MarkFlagsRequiredTogether("tls-cert", "tls-key")
+
+	return cmd
+}
+
+func readAPIToken(path string) (string, error) {
+	if path == "" {
+		token := os.Getenv(config.APITokenEnv)
+		_ = os.Unsetenv(config.APITokenEnv)
+		if token == "" {
+			return "", errors.New(errors.CodeInvalidInput, "--http", server.ErrNoToken)
+		}
+		return token, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", errors.New(errors.CodeIO, "read", err).WithPath(path)
+	}
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return "", errors.New(errors.CodeInvalidInput, "--api-token-file", server.ErrNoToken).WithPath(path)
+	}
+	return string(line), nil
+}
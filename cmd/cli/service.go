@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/service"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createServiceCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "service",
+		Short: "Register the daemon to start at login",
+		Long:  "Installs the background daemon as a systemd user unit on Linux, a launchd agent on macOS or a logon scheduled task on Windows, and removes or reports on it.",
+	}
+
+	cmd.AddCommand(c.createServiceInstallCommand())
+	cmd.AddCommand(c.createServiceUninstallCommand())
+	cmd.AddCommand(c.createServiceStatusCommand())
+	return cmd
+}
+
+func (c *CLI) createServiceInstallCommand() *cobra.Command {
+	var (
+		paths     []string
+		schedule  string
+		watchMode bool
+		printOnly bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "Install and start the daemon as a login service",
+		Example: `  sweetbyte service install --path ~/vault
+  sweetbyte service install --path ~/vault --schedule @daily --print
+  sweetbyte service install --watch`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			daemonArgs := []string{"daemon"}
+			if watchMode {
+				if len(paths) > 0 || schedule != "" {
+					return errors.Newf(errors.CodeInvalidInput, "--watch", "cannot be combined with --path or --schedule; watch reads its directories from watch.rules")
+				}
+				daemonArgs = []string{"watch"}
+			}
+			for _, path := range paths {
+				absPath, err := filepath.Abs(path)
+				if err != nil {
+					return fmt.Errorf("failed to resolve %s: %w", path, err)
+				}
+				daemonArgs = append(daemonArgs, "--path", absPath)
+			}
+			if schedule != "" {
+				daemonArgs = append(daemonArgs, "--schedule", schedule)
+			}
+
+			spec, err := service.NewSpec(daemonArgs...)
+			if err != nil {
+				return err
+			}
+			if printOnly {
+				fmt.Fprint(cmd.OutOrStdout(), service.Render(spec))
+				return nil
+			}
+
+			location, err := service.Install(spec)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Installed %s\n", location)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&paths, "path", nil, "Directory for the daemon to scrub (repeatable, default: scrub.paths)")
+	cmd.Flags().StringVar(&schedule, "schedule", "", "Cron schedule passed to the daemon (default: scrub.schedule)")
+	cmd.Flags().BoolVar(&watchMode, "watch", false, "Install watch (encrypting the directories in watch.rules) instead of the scrub daemon")
+	cmd.Flags().BoolVar(&printOnly, "print", false, "Print the generated service definition instead of installing it")
+	return cmd
+}
+
+func (c *CLI) createServiceUninstallCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "uninstall",
+		Short: "Stop the daemon service and remove it",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := service.Uninstall(); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "Service removed")
+			return nil
+		},
+	}
+}
+
+func (c *CLI) createServiceStatusCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show whether the daemon service is installed and running",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			status, err := service.Status()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), status)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/storage"
+	"github.com/hambosto/sweetbyte/internal/types"
+	"github.com/hambosto/sweetbyte/internal/ui/display"
+)
+
+var ErrStdioPassword = errors.Sentinel("a password must be given with --password when streaming through stdin or stdout")
+
+func resolveInput(inputFile string, args []string) (string, error) {
+	if len(args) == 0 {
+		if inputFile == "" {
+			return "", errors.Newf(errors.CodeInvalidInput, "--input", "an input file is required, or - for stdin")
+		}
+		return inputFile, nil
+	}
+	if inputFile != "" {
+		return "", errors.Newf(errors.CodeInvalidInput, "--input", "give the input either as an argument or with --input, not both")
+	}
+	return args[0], nil
+}
+
+func resolveInputs(inputFiles, args []string) ([]string, error) {
+	inputs := append(slices.Clone(inputFiles), args...)
+	if len(inputs) == 0 {
+		return nil, errors.Newf(errors.CodeInvalidInput, "--input", "an input file is required, or - for stdin")
+	}
+	if len(inputs) > 1 && slices.ContainsFunc(inputs, processor.IsStdio) {
+		return nil, errors.Newf(errors.CodeInvalidInput, "-", "stdin cannot be combined with other inputs")
+	}
+	return inputs, nil
+}
+
+func isBatch(inputs []string) bool {
+	return len(inputs) > 1 || (file.IsPattern(inputs[0]) && !storage.IsRemote(inputs[0]))
+}
+
+// isStreaming reports whether the input or output is a stream rather than
+// a local file: stdin, stdout or an object storage URL.
+func isStreaming(inputFile, outputFile string) bool {
+	return processor.IsStdio(inputFile) || processor.IsStdio(outputFile) || storage.IsRemote(inputFile) || storage.IsRemote(outputFile)
+}
+
+func (c *CLI) runStream(mode types.ProcessorMode, inputFile, outputFile string, password []byte, force bool, opts processor.Options) error {
+	if !processor.IsStdio(inputFile) && !storage.IsRemote(inputFile) {
+		if err := file.ValidatePath(inputFile, true); err != nil {
+			return fmt.Errorf("input file validation failed: %w", err)
+		}
+	}
+	if outputFile == "" {
+		outputFile = processor.StdioPath
+		if storage.IsRemote(inputFile) {
+			var err error
+			if outputFile, err = remoteOutputPath(inputFile, mode); err != nil {
+				return err
+			}
+		}
+	}
+	if !processor.IsStdio(outputFile) {
+		if err := validateOutput(outputFile, force); err != nil {
+			return err
+		}
+	}
+
+	if len(password) == 0 && needsPassword(opts) {
+		// A prompt would read from the same stdin as the data.
+		if processor.IsStdio(inputFile) || processor.IsStdio(outputFile) {
+			return errors.New(errors.CodeInvalidInput, "--password", ErrStdioPassword)
+		}
+		var err error
+		if mode == types.ModeEncrypt {
+			password, err = c.promptEncryptionPassword()
+		} else {
+			password, err = c.promptDecryptionPassword()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get password: %w", err)
+		}
+	}
+
+	opts.Reporter = display.NewQuietReporter()
+	if !processor.IsStdio(outputFile) {
+		opts.Reporter = display.NewReporter(nil)
+	}
+	if err := runCancelable(func(ctx context.Context) error {
+		return processor.Stream(ctx, mode, inputFile, outputFile, password, opts.WithTuning(config.LoadTuning()))
+	}); err != nil {
+		return err
+	}
+
+	if !processor.IsStdio(outputFile) {
+		display.ShowSuccessInfo(mode, outputFile)
+	}
+	return nil
+}
+
+// remoteOutputPath names the output for an object storage or web input: the
+// usual sibling object where the backend can write, and otherwise a file in
+// the current directory named after the URL, as curl -O does.
+func remoteOutputPath(inputFile string, mode types.ProcessorMode) (string, error) {
+	name := inputFile
+	if !storage.Writable(inputFile) {
+		name = storage.BaseName(inputFile)
+	}
+	outputFile := file.GetOutputPath(name, mode)
+	if name == "" || outputFile == name {
+		return "", fmt.Errorf("cannot determine output filename, please specify with -o flag")
+	}
+	return outputFile, nil
+}
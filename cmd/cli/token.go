@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/ui/display"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createTokenCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "token",
+		Short: "Set up hardware tokens for encrypt --token",
+		Long:  "A hardware token adds a second factor to the password: its response to a per-file challenge is mixed into the key, so the file only opens with the token present. YubiKey challenge-response needs ykchalresp (yubikey-personalization) and a slot programmed for HMAC-SHA1; FIDO2 needs the libfido2 tools and an authenticator with the hmac-secret extension.",
+	}
+
+	cmd.AddCommand(c.createTokenEnrollCommand())
+	return cmd
+}
+
+func (c *CLI) createTokenEnrollCommand() *cobra.Command {
+	var device string
+
+	cmd := &cobra.Command{
+		Use:   "enroll",
+		Short: "Create a FIDO2 credential and print its --token value",
+		Long:  "Creates a credential with the hmac-secret extension on a FIDO2 authenticator. Pass the printed value to encrypt --token; it is not secret and is recorded in each file's header. YubiKey challenge-response needs no enrollment: use --token yubikey or yubikey:1.",
+		Example: `  sweetbyte token enroll
+  sweetbyte encrypt -i wallet.dat --token "$(sweetbyte token enroll)"`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			display.ShowInfo("Touch the authenticator when it blinks")
+			token, err := derive.EnrollFIDO2(device)
+			if err != nil {
+				return errors.New(errors.CodeIO, "token enroll", err)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), token.Spec())
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&device, "device", "", "Authenticator to use, as listed by fido2-token -L (default: $"+derive.FIDO2DeviceEnv+" or the first one found)")
+	return cmd
+}
+
+func parseToken(spec string) (derive.KeyProvider, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	token, err := derive.ParseProvider(spec)
+	if err != nil {
+		return nil, errors.New(errors.CodeInvalidInput, "--token", err)
+	}
+	return token, nil
+}
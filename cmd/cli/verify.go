@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/recipient"
+	"github.com/hambosto/sweetbyte/internal/reporter"
+	"github.com/hambosto/sweetbyte/internal/scrub"
+	"github.com/spf13/cobra"
+)
+
+type verifyResult struct {
+	scrub.Report
+	Authenticated bool   `json:"authenticated"`
+	AuthError     string `json:"auth_error,omitempty"`
+}
+
+func (r verifyResult) OK() bool {
+	return r.Report.OK() && r.AuthError == ""
+}
+
+func (c *CLI) createVerifyCommand() *cobra.Command {
+	var (
+		password     []byte
+		keyfilePath  string
+		identityPath string
+		expectAfter  string
+		parityOnly   bool
+		format       string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "verify PATH...",
+		Short: "Check that encrypted files are intact and authentic without writing plaintext",
+		Long:  "Checks every encrypted file under the given paths: the Reed-Solomon parity of each chunk as scrub does, then, with the password, the header MAC and the authentication tag of every chunk. Plaintext is decrypted in memory and discarded; nothing is written. With --parity-only no password is needed, but tampering that keeps the parity consistent is not detected.",
+		Example: `  sweetbyte verify backup.tar.swx
+  sweetbyte verify ~/vault -p "$VAULT_PASSWORD" --format json
+  sweetbyte verify ledger.swx --expect-after 2026-06-01
+  sweetbyte verify ~/vault --parity-only`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "text" && format != "json" {
+				return errors.Newf(errors.CodeInvalidInput, "verify", "unsupported format %q", format)
+			}
+
+			var (
+				opts processor.Options
+				err  error
+			)
+			if !parityOnly {
+				if opts.Keyfile, err = loadKeyfile(keyfilePath); err != nil {
+					return err
+				}
+				if opts.ExpectAfter, err = parseExpectAfter(expectAfter); err != nil {
+					return err
+				}
+				if identityPath != "" {
+					if opts.Identity, err = recipient.ReadIdentity(identityPath); err != nil {
+						return err
+					}
+				}
+				if len(password) == 0 && needsPassword(opts) {
+					if password, err = c.promptDecryptionPassword(); err != nil {
+						return fmt.Errorf("failed to get password: %w", err)
+					}
+				}
+			}
+			return runVerify(cmd, args, password, parityOnly, format, opts)
+		},
+	}
+
+	cmd.Flags().VarP(c.passwordFlag(&password), "password", "p", "Password to authenticate the files with (prompts if not provided)")
+	cmd.Flags().StringVarP(&keyfilePath, "keyfile", "k", "", "Keyfile used when the files were encrypted")
+	cmd.Flags().StringVar(&identityPath, "identity", "", "Private key for files encrypted with --recipient")
+	cmd.Flags().StringVar(&expectAfter, "expect-after", "", "Fail files created before this time (RFC 3339 or YYYY-MM-DD)")
+	cmd.Flags().BoolVar(&parityOnly, "parity-only", false, "Only check Reed-Solomon parity, without a password")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+	return cmd
+}
+
+func runVerify(cmd *cobra.Command, paths []string, password []byte, parityOnly bool, format string, opts processor.Options) error {
+	out := cmd.OutOrStdout()
+	opts = opts.WithTuning(config.LoadTuning())
+	opts.Reporter = reporter.Nop()
+
+	results := []verifyResult{}
+	_, err := scrub.Paths(cmd.Context(), paths, func(r scrub.Report) {
+		result := verifyResult{Report: r}
+		if !parityOnly && r.Recoverable() {
+			if err := processor.Authenticate(cmd.Context(), r.Path, password, opts); err != nil {
+				result.AuthError = err.Error()
+			} else {
+				result.Authenticated = true
+			}
+		}
+		if format == "text" {
+			printVerifyResult(out, result)
+		}
+		results = append(results, result)
+	})
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	repairable := true
+	for _, result := range results {
+		if !result.OK() {
+			failed++
+			repairable = repairable && result.AuthError == "" && result.Recoverable()
+		}
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(out, "\n%d files verified, %d failed\n", len(results), failed)
+	}
+
+	if failed > 0 {
+		if repairable {
+			return errors.Newf(errors.CodeCorrupt, "verify", "%d of %d files failed verification; %w", failed, len(results), scrub.ErrRepairable)
+		}
+		return errors.Newf(errors.CodeCorrupt, "verify", "%d of %d files failed verification", failed, len(results))
+	}
+	return nil
+}
+
+func printVerifyResult(w io.Writer, r verifyResult) {
+	if r.AuthError != "" {
+		fmt.Fprintf(w, "FAIL     %s: %s\n", r.Path, r.AuthError)
+		return
+	}
+
+	printScrubReport(w, r.Report)
+	if r.Authenticated {
+		fmt.Fprintf(w, "         header and all chunks authenticated\n")
+	}
+}
@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/recipient"
+	"github.com/hambosto/sweetbyte/internal/watch"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) createWatchCommand() *cobra.Command {
+	var (
+		rule     config.WatchRule
+		interval string
+		debounce string
+		verbose  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "watch [DIR]",
+		Short: "Encrypt files as they appear in a directory",
+		Long: `Runs until interrupted and encrypts every new or modified file in the watched
+directories once it has stopped changing for the debounce period.
+
+Without DIR the directories come from the "watch.rules" section of the config
+file, each with its own output directory, key and delete_source setting. Hidden
+files, ` + config.FileExtension + ` files and ` + config.ArchiveExtension + ` archives are ignored, and a file is encrypted
+again only when it is newer than its output. The key is a --recipient public
+key, a keyfile, or the password from --password-file or $` + config.PasswordEnv + `;
+watch never prompts. With --delete-source the source is hashed again after
+encrypting and kept if it changed in the meantime.`,
+		Example: `  sweetbyte watch ~/inbox --recipient backup.pub --delete-source
+  sweetbyte watch ~/scans -o ~/vault --password-file ~/.sweetbyte-pass
+  sweetbyte watch --verbose`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			settings, err := config.LoadSettings()
+			if err != nil {
+				return err
+			}
+
+			watchSettings := settings.Watch
+			if len(args) == 1 {
+				rule.Path = args[0]
+				watchSettings.Rules = []config.WatchRule{rule}
+			}
+			if interval != "" {
+				watchSettings.Interval = interval
+			}
+			if debounce != "" {
+				watchSettings.Debounce = debounce
+			}
+
+			pollInterval, err := parseWatchDuration(watchSettings.Interval, config.DefaultWatchInterval, "--interval")
+			if err != nil {
+				return err
+			}
+			settle, err := parseWatchDuration(watchSettings.Debounce, config.DefaultWatchDebounce, "--debounce")
+			if err != nil {
+				return err
+			}
+
+			rules := make([]watch.Rule, 0, len(watchSettings.Rules))
+			for _, r := range watchSettings.Rules {
+				resolved, err := c.resolveWatchRule(r)
+				if err != nil {
+					return err
+				}
+				rules = append(rules, resolved)
+			}
+
+			level := slog.LevelInfo
+			if verbose {
+				level = slog.LevelDebug
+			}
+			logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+			w, err := watch.New(rules, pollInterval, settle, logger)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return w.Run(ctx)
+		},
+	}
+
+	cmd.Flags().StringVarP(&rule.Output, "output", "o", "", "Directory to write encrypted files to (default: next to the source)")
+	cmd.Flags().StringVar(&rule.Recipient, "recipient", "", "Encrypt to this public key (see keygen --identity) instead of a password")
+	cmd.Flags().StringVarP(&rule.Keyfile, "keyfile", "k", "", "Keyfile to encrypt with, alone or combined with the password")
+	cmd.Flags().BoolVar(&rule.DeleteSource, "delete-source", false, "Delete each source file once it is encrypted")
+	cmd.Flags().StringVar(&interval, "interval", "", "How often to check the directories (default: watch.interval or "+config.DefaultWatchInterval+")")
+	cmd.Flags().StringVar(&debounce, "debounce", "", "How long a file must stay unchanged before it is encrypted (default: watch.debounce or "+config.DefaultWatchDebounce+")")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Log deleted sources as well")
+
+	return cmd
+}
+
+func (c *CLI) resolveWatchRule(r config.WatchRule) (watch.Rule, error) {
+	if r.Path == "" {
+		return watch.Rule{}, errors.Newf(errors.CodeInvalidInput, "watch", "a watch rule has no path")
+	}
+	absPath, err := filepath.Abs(r.Path)
+	if err != nil {
+		return watch.Rule{}, fmt.Errorf("failed to resolve %s: %w", r.Path, err)
+	}
+
+	rule := watch.Rule{Path: absPath, Output: r.Output, DeleteSource: r.DeleteSource}
+	if r.Recipient != "" {
+		if r.Keyfile != "" || r.PasswordFile != "" {
+			return watch.Rule{}, errors.Newf(errors.CodeInvalidInput, "watch", "%s: a recipient cannot be combined with a keyfile or password file", r.Path)
+		}
+		if rule.Options.Recipient, err = recipient.ReadPublicKey(r.Recipient); err != nil {
+			return watch.Rule{}, err
+		}
+		return rule, nil
+	}
+
+	rule.Password = c.password.Bytes()
+	if r.PasswordFile != "" {
+		password, err := readPassword(r.PasswordFile)
+		if err != nil {
+			return watch.Rule{}, err
+		}
+		c.secrets = append(c.secrets, password)
+		rule.Password = password.Bytes()
+	}
+	if rule.Options.Keyfile, err = loadKeyfile(r.Keyfile); err != nil {
+		return watch.Rule{}, err
+	}
+	if len(rule.Password) == 0 && len(rule.Options.Keyfile) == 0 {
+		return watch.Rule{}, errors.Newf(errors.CodeInvalidInput, "watch", "%s: no key configured; set a recipient, keyfile or password file", r.Path)
+	}
+	return rule, nil
+}
+
+func parseWatchDuration(value, fallback, flag string) (time.Duration, error) {
+	if value == "" {
+		value = fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.New(errors.CodeInvalidInput, flag, err)
+	}
+	return d, nil
+}
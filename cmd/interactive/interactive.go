@@ -1,11 +1,17 @@
 package interactive
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
 	"github.com/hambosto/sweetbyte/internal/file"
 	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/secret"
 	"github.com/hambosto/sweetbyte/internal/types"
 	"github.com/hambosto/sweetbyte/internal/ui/display"
 	"github.com/hambosto/sweetbyte/internal/ui/prompt"
@@ -19,9 +25,17 @@ func Run() {
 	}
 	term.PrintBanner()
 
-	if err := runInteractiveLoop(); err != nil {
-		fmt.Printf("Application error: %v\n", err)
-		os.Exit(1)
+	for {
+		err := runInteractiveLoop()
+		if errors.CodeOf(err) == errors.CodeCanceled {
+			display.ShowCanceled()
+			continue
+		}
+		if err != nil {
+			display.ShowError(err)
+			os.Exit(errors.ExitCode(err))
+		}
+		return
 	}
 }
 
@@ -30,8 +44,16 @@ func runInteractiveLoop() error {
 	if err != nil {
 		return fmt.Errorf("failed to get processing mode: %w", err)
 	}
+	if operation == prompt.ModeQueue {
+		return runQueue()
+	}
+
+	root, depth, err := chooseRoot()
+	if err != nil {
+		return err
+	}
 
-	eligibleFiles, err := getEligibleFiles(operation)
+	eligibleFiles, err := getEligibleFiles(root, operation, depth)
 	if err != nil {
 		return err
 	}
@@ -55,26 +77,78 @@ func runInteractiveLoop() error {
 		return fmt.Errorf("failed to display file info: %w", err)
 	}
 
-	selectedFile, err := prompt.ChooseFile(eligibleFiles)
+	selectedFiles, err := prompt.ChooseFiles(eligibleFiles)
 	if err != nil {
-		return fmt.Errorf("failed to select file: %w", err)
+		return fmt.Errorf("failed to select files: %w", err)
+	}
+	switch {
+	case selectedFiles[0] == prompt.SelectAll:
+		return processTree(root, operation, depth)
+	case len(selectedFiles) > 1:
+		return processFiles(selectedFiles, operation)
 	}
 
-	if err := processFile(selectedFile, operation); err != nil {
-		return fmt.Errorf("failed to process file %s: %w", selectedFile, err)
+	if err := processFile(selectedFiles[0], operation); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func getEligibleFiles(operation types.ProcessorMode) ([]string, error) {
-	eligibleFiles, err := file.FindEligibleFiles(operation)
+func chooseRoot() (string, int, error) {
+	root, err := chooseLocation()
+	if err != nil {
+		return "", 0, err
+	}
+
+	depth, err := prompt.ChooseScanDepth()
+	if err != nil {
+		return "", 0, err
+	}
+	return root, depth, nil
+}
+
+func chooseLocation() (string, error) {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return "", fmt.Errorf("failed to load settings: %w", err)
+	}
+
+	location, err := prompt.ChooseLocation(settings.Bookmarks)
+	if err != nil {
+		return "", err
+	}
+	switch location {
+	case prompt.LocationBrowse:
+		return prompt.ChooseDirectory(prompt.LocationCurrent)
+	case prompt.LocationSaveBookmark:
+	default:
+		return location, nil
+	}
+
+	name, err := prompt.GetBookmarkName()
+	if err != nil {
+		return "", err
+	}
+	if err := settings.AddBookmark(name, prompt.LocationCurrent); err != nil {
+		return "", fmt.Errorf("failed to add bookmark: %w", err)
+	}
+	if err := settings.Save(); err != nil {
+		return "", fmt.Errorf("failed to save bookmark: %w", err)
+	}
+	display.ShowBookmarkSaved(name)
+
+	return prompt.LocationCurrent, nil
+}
+
+func getEligibleFiles(root string, operation types.ProcessorMode, depth int) ([]string, error) {
+	eligibleFiles, err := file.FindEligibleFiles(root, operation, depth)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find eligible files: %w", err)
 	}
 
 	if len(eligibleFiles) == 0 {
-		return nil, fmt.Errorf("no eligible files found for %s operation", operation)
+		return nil, fmt.Errorf("%w for %s operation", file.ErrNoEligibleFiles, operation)
 	}
 
 	return eligibleFiles, nil
@@ -93,6 +167,14 @@ func processFile(inputPath string, mode types.ProcessorMode) error {
 		}
 	}
 
+	if mode == types.ModeEncrypt {
+		if err := confirmEstimate([]string{inputPath}); err != nil {
+			return err
+		}
+	} else if estimate, err := processor.EstimateDecryption(inputPath); err == nil {
+		display.ShowEstimate(mode, estimate.InputSize, estimate.OutputSize)
+	}
+
 	var err error
 	switch mode {
 	case types.ModeEncrypt:
@@ -132,12 +214,11 @@ func encryptFile(srcPath, destPath string) error {
 	if err != nil {
 		return fmt.Errorf("password prompt failed: %w", err)
 	}
+	defer secret.Wipe(password)
 
-	if err := processor.Encryption(srcPath, destPath, password); err != nil {
-		return fmt.Errorf("failed to encrypt %s: %w", srcPath, err)
-	}
-
-	return nil
+	return runCancelable(func(ctx context.Context) error {
+		return processor.Encryption(ctx, srcPath, destPath, password, processor.Options{PreserveTimes: true, Reporter: display.NewReporter(nil)}.WithTuning(config.LoadTuning()))
+	})
 }
 
 func decryptFile(srcPath, destPath string) error {
@@ -145,10 +226,56 @@ func decryptFile(srcPath, destPath string) error {
 	if err != nil {
 		return fmt.Errorf("password prompt failed: %w", err)
 	}
+	defer secret.Wipe(password)
+
+	return runCancelable(func(ctx context.Context) error {
+		opts := processor.Options{PreserveTimes: true, Reporter: display.NewReporter(nil), Repaired: display.ShowRepairReport}
+		return processor.Decryption(ctx, srcPath, destPath, password, opts.WithTuning(config.LoadTuning()))
+	})
+}
+
+func confirmEstimate(paths []string) error {
+	opts := processor.Options{}.WithTuning(config.LoadTuning())
 
-	if err := processor.Decryption(srcPath, destPath, password); err != nil {
-		return fmt.Errorf("failed to decrypt %s: %w", srcPath, err)
+	var total processor.Estimate
+	for _, path := range paths {
+		estimate, err := processor.EstimateEncryption(path, opts)
+		if err != nil {
+			return nil
+		}
+		if len(paths) == 1 {
+			total = estimate
+		} else {
+			total.Add(estimate)
+		}
 	}
 
+	display.ShowEstimateDetails(total, len(paths))
+	confirm, err := prompt.ConfirmEstimate(total.OutputSize)
+	if err != nil {
+		return err
+	}
+	if !confirm {
+		return fmt.Errorf("operation canceled by user")
+	}
 	return nil
 }
+
+func runCancelable(run func(ctx context.Context) error) error {
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	ctx, cancel := context.WithCancel(signalCtx)
+	defer cancel()
+
+	display.ShowCancelHint()
+	stop := term.WatchCancelKeys(cancel)
+	err := run(ctx)
+	stop()
+
+	if signalCtx.Err() != nil {
+		err = errors.New(errors.CodeCanceled, "", signalCtx.Err())
+		display.ShowError(err)
+		os.Exit(errors.ExitCode(err))
+	}
+	return err
+}
@@ -0,0 +1,273 @@
+package interactive
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/secret"
+	"github.com/hambosto/sweetbyte/internal/stream"
+	"github.com/hambosto/sweetbyte/internal/types"
+	"github.com/hambosto/sweetbyte/internal/ui/bar"
+	"github.com/hambosto/sweetbyte/internal/ui/display"
+	"github.com/hambosto/sweetbyte/internal/ui/prompt"
+)
+
+var errJobDeclined = errors.Sentinel("job not added")
+
+type job struct {
+	mode     types.ProcessorMode
+	input    string
+	output   string
+	password []byte
+	options  prompt.JobOptions
+}
+
+func runQueue() error {
+	var jobs []job
+	defer func() { wipePasswords(jobs) }()
+	for {
+		j, err := queueJob(jobs)
+		switch {
+		case errors.Is(err, errJobDeclined):
+			display.ShowWarning("Job not added; the existing output was kept.")
+			if len(jobs) == 0 {
+				continue
+			}
+		case err != nil:
+			return err
+		default:
+			jobs = append(jobs, j)
+			display.ShowQueue(queueEntries(jobs))
+		}
+
+		action, err := prompt.ChooseQueueAction(len(jobs))
+		if err != nil {
+			return err
+		}
+		switch action {
+		case prompt.QueueRun:
+			return runJobs(jobs)
+		case prompt.QueueDiscard:
+			return nil
+		}
+	}
+}
+
+func queueJob(queued []job) (job, error) {
+	mode, err := prompt.GetJobMode()
+	if err != nil {
+		return job{}, fmt.Errorf("failed to get processing mode: %w", err)
+	}
+
+	root, depth, err := chooseRoot()
+	if err != nil {
+		return job{}, err
+	}
+
+	eligibleFiles, err := getEligibleFiles(root, mode, depth)
+	if err != nil {
+		return job{}, err
+	}
+
+	inputPath, err := prompt.ChooseFile(eligibleFiles)
+	if err != nil {
+		return job{}, fmt.Errorf("failed to select file: %w", err)
+	}
+
+	outputPath := file.GetOutputPath(inputPath, mode)
+	if err := file.ValidatePath(inputPath, true); err != nil {
+		return job{}, fmt.Errorf("source validation failed: %w", err)
+	}
+	if err := file.ValidatePath(outputPath, false); err != nil {
+		confirm, confirmErr := prompt.ConfirmFileOverwrite(outputPath)
+		if confirmErr != nil {
+			return job{}, confirmErr
+		}
+		if !confirm {
+			return job{}, errJobDeclined
+		}
+	}
+
+	options, err := prompt.GetJobOptions(mode)
+	if err != nil {
+		return job{}, err
+	}
+
+	password, err := jobPassword(mode, queued)
+	if err != nil {
+		return job{}, fmt.Errorf("password prompt failed: %w", err)
+	}
+
+	return job{mode: mode, input: inputPath, output: outputPath, password: password, options: options}, nil
+}
+
+func processTree(root string, mode types.ProcessorMode, depth int) error {
+	entries, err := file.MapTree(root, "", mode, depth)
+	if err != nil {
+		return fmt.Errorf("failed to scan directory tree: %w", err)
+	}
+	return processEntries(entries, mode)
+}
+
+func processFiles(paths []string, mode types.ProcessorMode) error {
+	entries := make([]file.TreeEntry, 0, len(paths))
+	for _, path := range paths {
+		size, err := file.Size(path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, file.TreeEntry{Input: path, Output: file.GetOutputPath(path, mode), Size: size})
+	}
+	return processEntries(entries, mode)
+}
+
+func processEntries(entries []file.TreeEntry, mode types.ProcessorMode) error {
+	options, err := prompt.GetJobOptions(mode)
+	if err != nil {
+		return err
+	}
+
+	jobs := make([]job, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Size == 0 {
+			continue
+		}
+		if err := file.ValidatePath(entry.Output, false); err != nil {
+			display.ShowWarning(fmt.Sprintf("Skipping %s: %v", entry.Input, err))
+			continue
+		}
+		jobs = append(jobs, job{mode: mode, input: entry.Input, output: entry.Output, options: options})
+	}
+	if len(jobs) == 0 {
+		return fmt.Errorf("%w for %s operation", file.ErrNoEligibleFiles, mode)
+	}
+	if mode == types.ModeEncrypt {
+		paths := make([]string, len(jobs))
+		for i, j := range jobs {
+			paths[i] = j.input
+		}
+		if err := confirmEstimate(paths); err != nil {
+			return err
+		}
+	}
+
+	password, err := jobPassword(mode, nil)
+	if err != nil {
+		return fmt.Errorf("password prompt failed: %w", err)
+	}
+	defer secret.Wipe(password)
+	for i := range jobs {
+		jobs[i].password = password
+	}
+	return runJobs(jobs)
+}
+
+func jobPassword(mode types.ProcessorMode, queued []job) ([]byte, error) {
+	for i := len(queued) - 1; i >= 0; i-- {
+		if queued[i].mode != mode {
+			continue
+		}
+		reuse, err := prompt.ConfirmReusePassword(mode)
+		if err != nil {
+			return nil, err
+		}
+		if reuse {
+			return queued[i].password, nil
+		}
+		break
+	}
+
+	if mode == types.ModeEncrypt {
+		return prompt.GetEncryptionPassword()
+	}
+	return prompt.GetDecryptionPassword()
+}
+
+// wipePasswords clears the passwords of jobs, which reused passwords share.
+func wipePasswords(jobs []job) {
+	for _, j := range jobs {
+		secret.Wipe(j.password)
+	}
+}
+
+func runJobs(jobs []job) error {
+	var totalSize int64
+	for _, j := range jobs {
+		if size, err := file.Size(j.input); err == nil {
+			totalSize += size
+		}
+	}
+
+	pool, err := stream.NewWorkerPool(config.LoadTuning().MaxWorkers)
+	if err != nil {
+		return errors.New(errors.CodeInvalidInput, "max_workers", err)
+	}
+
+	entries := queueEntries(jobs)
+	batch := bar.NewBatch(totalSize, len(jobs))
+	err = runCancelable(func(ctx context.Context) error {
+		for i, j := range jobs {
+			if ctx.Err() != nil {
+				entries[i].Skipped = true
+				continue
+			}
+
+			batch.NextFile()
+			if err := runJob(ctx, j, batch, pool); err != nil {
+				entries[i].Err = err
+				continue
+			}
+			entries[i].Done = true
+
+			if j.options.DeleteSource && !file.IsDevice(j.input) {
+				if err := file.Remove(j.input); err != nil {
+					entries[i].Err = fmt.Errorf("failed to delete source file: %w", err)
+				}
+			}
+		}
+		return ctx.Err()
+	})
+
+	display.ShowQueueSummary(entries)
+	if err != nil {
+		return errors.New(errors.CodeCanceled, "", err)
+	}
+
+	var failed int
+	for _, entry := range entries {
+		if entry.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errors.Newf(errors.CodeUnknown, "queue", "%d of %d jobs failed", failed, len(jobs))
+	}
+	return nil
+}
+
+func runJob(ctx context.Context, j job, batch *bar.Batch, pool *stream.WorkerPool) error {
+	opts := processor.Options{
+		PreserveTimes: j.options.PreserveTimes,
+		Verify:        j.options.Verify,
+		WorkerPool:    pool,
+		Reporter:      display.NewReporter(batch),
+		Repaired:      display.ShowRepairReport,
+	}.WithTuning(config.LoadTuning())
+
+	if j.mode == types.ModeEncrypt {
+		return processor.Encryption(ctx, j.input, j.output, j.password, opts)
+	}
+	return processor.Decryption(ctx, j.input, j.output, j.password, opts)
+}
+
+func queueEntries(jobs []job) []display.QueueEntry {
+	entries := make([]display.QueueEntry, len(jobs))
+	for i, j := range jobs {
+		entries[i] = display.QueueEntry{Mode: j.mode, Input: j.input, Output: j.output}
+	}
+	return entries
+}
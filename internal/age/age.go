@@ -0,0 +1,168 @@
+// Package age reads and writes files in the age v1 format
+// (age-encryption.org/v1), so that sweetbyte files can be handed to
+// recipients who only have age or rage.
+package age
+
+import (
+	"bufio"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+)
+
+const (
+	Extension = ".age"
+
+	fileKeySize = 16
+	nonceSize   = 16
+)
+
+var (
+	ErrIncorrectIdentity = errors.Sentinel("no identity matches any of the file's recipients")
+	ErrInvalidHeader     = errors.Sentinel("not an age file or corrupt age header")
+	ErrInvalidKey        = errors.Sentinel("not an age recipient or identity")
+)
+
+// Stanza is one recipient entry of the header: a type, its arguments and a
+// body that holds the wrapped file key.
+type Stanza struct {
+	Type string
+	Args []string
+	Body []byte
+}
+
+// Recipient wraps the file key for one reader.
+type Recipient interface {
+	Wrap(fileKey []byte) (*Stanza, error)
+}
+
+// Identity unwraps the file key from the stanzas meant for it. It returns
+// ErrIncorrectIdentity when none of them are.
+type Identity interface {
+	Unwrap(stanzas []*Stanza) ([]byte, error)
+}
+
+// Encrypt writes an age header for recipients to dst and returns a writer
+// that encrypts the payload. The caller must Close it to write the last chunk.
+func Encrypt(dst io.Writer, recipients ...Recipient) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no age recipients")
+	}
+
+	fileKey := make([]byte, fileKeySize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, fmt.Errorf("failed to generate file key: %w", err)
+	}
+
+	hdr := &header{}
+	for _, recipient := range recipients {
+		stanza, err := recipient.Wrap(fileKey)
+		if err != nil {
+			return nil, err
+		}
+		hdr.stanzas = append(hdr.stanzas, stanza)
+	}
+	if err := checkScrypt(hdr.stanzas); err != nil {
+		return nil, err
+	}
+
+	mac, err := headerMAC(fileKey, hdr)
+	if err != nil {
+		return nil, err
+	}
+	hdr.mac = mac
+	if err := hdr.marshal(dst); err != nil {
+		return nil, fmt.Errorf("failed to write age header: %w", err)
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	if _, err := dst.Write(nonce); err != nil {
+		return nil, fmt.Errorf("failed to write age header: %w", err)
+	}
+
+	streamKey, err := payloadKey(fileKey, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return newWriter(streamKey, dst)
+}
+
+// Decrypt parses the age header from src, unwraps the file key with the
+// first identity that matches and returns a reader of the plaintext.
+func Decrypt(src io.Reader, identities ...Identity) (io.Reader, error) {
+	input := bufio.NewReader(src)
+	hdr, err := parseHeader(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkScrypt(hdr.stanzas); err != nil {
+		return nil, err
+	}
+
+	var fileKey []byte
+	for _, identity := range identities {
+		fileKey, err = identity.Unwrap(hdr.stanzas)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrIncorrectIdentity) {
+			return nil, err
+		}
+	}
+	if fileKey == nil {
+		return nil, errors.New(errors.CodeAuthentication, "", ErrIncorrectIdentity)
+	}
+
+	mac, err := headerMAC(fileKey, hdr)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, hdr.mac) {
+		return nil, errors.Newf(errors.CodeAuthentication, "", "%w: header MAC mismatch", ErrInvalidHeader)
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(input, nonce); err != nil {
+		return nil, errors.Newf(errors.CodeCorrupt, "", "%w: missing payload nonce", ErrInvalidHeader)
+	}
+	streamKey, err := payloadKey(fileKey, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return newReader(streamKey, input)
+}
+
+// checkScrypt enforces that a passphrase stanza is the only one, as age does,
+// so a passphrase-encrypted file cannot also be opened by a public key.
+func checkScrypt(stanzas []*Stanza) error {
+	for _, stanza := range stanzas {
+		if stanza.Type == scryptType && len(stanzas) != 1 {
+			return errors.Newf(errors.CodeInvalidInput, "", "an age passphrase cannot be combined with other recipients")
+		}
+	}
+	return nil
+}
+
+func headerMAC(fileKey []byte, hdr *header) ([]byte, error) {
+	key, err := hkdf.Key(sha256.New, fileKey, nil, "header", sha256.Size)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	if err := hdr.marshalWithoutMAC(mac); err != nil {
+		return nil, err
+	}
+	return mac.Sum(nil), nil
+}
+
+func payloadKey(fileKey, nonce []byte) ([]byte, error) {
+	return hkdf.Key(sha256.New, fileKey, nonce, "payload", 32)
+}
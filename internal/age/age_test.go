@@ -0,0 +1,170 @@
+package age_test
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	ref "filippo.io/age"
+
+	"github.com/hambosto/sweetbyte/internal/age"
+)
+
+// sizes straddle the 64 KiB chunk boundary, where the last-chunk flag moves.
+var sizes = []int{0, 1, 64<<10 - 1, 64 << 10, 64<<10 + 1, 200 << 10}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func newIdentity(t *testing.T) *age.X25519Identity {
+	t.Helper()
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return age.NewX25519Identity(key)
+}
+
+func encrypt(t *testing.T, plaintext []byte, recipients ...age.Recipient) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipients...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decrypt(t *testing.T, file []byte, identities ...age.Identity) []byte {
+	t.Helper()
+	r, err := age.Decrypt(bytes.NewReader(file), identities...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plaintext
+}
+
+func refEncrypt(t *testing.T, plaintext []byte, recipients ...ref.Recipient) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := ref.Encrypt(&buf, recipients...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func refDecrypt(t *testing.T, file []byte, identities ...ref.Identity) []byte {
+	t.Helper()
+	r, err := ref.Decrypt(bytes.NewReader(file), identities...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plaintext
+}
+
+func TestX25519ToReference(t *testing.T) {
+	identity := newIdentity(t)
+	refIdentity, err := ref.ParseX25519Identity(identity.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range sizes {
+		plaintext := randomBytes(t, size)
+		file := encrypt(t, plaintext, identity.Recipient())
+		if got := refDecrypt(t, file, refIdentity); !bytes.Equal(got, plaintext) {
+			t.Errorf("size %d: age decrypted %d bytes that differ from the plaintext", size, len(got))
+		}
+	}
+}
+
+func TestX25519FromReference(t *testing.T) {
+	identity := newIdentity(t)
+	refRecipient, err := ref.ParseX25519Recipient(identity.Recipient().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range sizes {
+		plaintext := randomBytes(t, size)
+		file := refEncrypt(t, plaintext, refRecipient)
+		if got := decrypt(t, file, identity); !bytes.Equal(got, plaintext) {
+			t.Errorf("size %d: decrypted %d bytes that differ from the plaintext", size, len(got))
+		}
+	}
+}
+
+func TestScryptToReference(t *testing.T) {
+	const passphrase = "correct horse battery staple"
+	refIdentity, err := ref.NewScryptIdentity(passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := randomBytes(t, 64<<10+1)
+	file := encrypt(t, plaintext, age.NewScryptRecipient(passphrase))
+	if got := refDecrypt(t, file, refIdentity); !bytes.Equal(got, plaintext) {
+		t.Errorf("age decrypted %d bytes that differ from the plaintext", len(got))
+	}
+}
+
+func TestScryptFromReference(t *testing.T) {
+	const passphrase = "correct horse battery staple"
+	refRecipient, err := ref.NewScryptRecipient(passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refRecipient.SetWorkFactor(10)
+
+	plaintext := randomBytes(t, 64<<10+1)
+	file := refEncrypt(t, plaintext, refRecipient)
+	if got := decrypt(t, file, age.NewScryptIdentity(passphrase)); !bytes.Equal(got, plaintext) {
+		t.Errorf("decrypted %d bytes that differ from the plaintext", len(got))
+	}
+}
+
+func TestMultipleRecipientsWithReference(t *testing.T) {
+	first, second := newIdentity(t), newIdentity(t)
+	refSecond, err := ref.ParseX25519Identity(second.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := randomBytes(t, 1000)
+	file := encrypt(t, plaintext, first.Recipient(), second.Recipient())
+	if got := refDecrypt(t, file, refSecond); !bytes.Equal(got, plaintext) {
+		t.Error("age could not decrypt with the second recipient's identity")
+	}
+	if _, err := age.Decrypt(bytes.NewReader(file), newIdentity(t)); err == nil {
+		t.Error("a file decrypted with an identity it was not encrypted to")
+	}
+}
@@ -0,0 +1,153 @@
+package age
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"strings"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+)
+
+const (
+	intro       = "age-encryption.org/v1\n"
+	stanzaStart = "-> "
+	footer      = "---"
+
+	columns    = 64
+	maxStanzas = 1024
+)
+
+var b64 = base64.RawStdEncoding.Strict()
+
+type header struct {
+	stanzas []*Stanza
+	mac     []byte
+}
+
+func (h *header) marshalWithoutMAC(w io.Writer) error {
+	if _, err := io.WriteString(w, intro); err != nil {
+		return err
+	}
+	for _, stanza := range h.stanzas {
+		if err := stanza.marshal(w); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, footer)
+	return err
+}
+
+func (h *header) marshal(w io.Writer) error {
+	if err := h.marshalWithoutMAC(w); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, " "+b64.EncodeToString(h.mac)+"\n")
+	return err
+}
+
+// marshal writes the stanza with its body wrapped at 64 columns. The last
+// line is always shorter than that, and empty when the body fills whole lines.
+func (s *Stanza) marshal(w io.Writer) error {
+	line := stanzaStart + strings.Join(append([]string{s.Type}, s.Args...), " ") + "\n"
+	if _, err := io.WriteString(w, line); err != nil {
+		return err
+	}
+
+	body := b64.EncodeToString(s.Body)
+	for len(body) >= columns {
+		if _, err := io.WriteString(w, body[:columns]+"\n"); err != nil {
+			return err
+		}
+		body = body[columns:]
+	}
+	_, err := io.WriteString(w, body+"\n")
+	return err
+}
+
+func parseHeader(r *bufio.Reader) (*header, error) {
+	line, err := readLine(r)
+	if err != nil || line+"\n" != intro {
+		return nil, invalidHeader("missing age-encryption.org/v1 intro")
+	}
+
+	h := &header{}
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, invalidHeader("truncated header")
+		}
+
+		if mac, ok := strings.CutPrefix(line, footer+" "); ok {
+			if h.mac, err = b64.DecodeString(mac); err != nil || len(h.stanzas) == 0 {
+				return nil, invalidHeader("bad header footer")
+			}
+			return h, nil
+		}
+
+		args, ok := strings.CutPrefix(line, stanzaStart)
+		if !ok || len(h.stanzas) == maxStanzas {
+			return nil, invalidHeader("unexpected line %q", line)
+		}
+		fields := strings.Split(args, " ")
+		for _, field := range fields {
+			if !validArg(field) {
+				return nil, invalidHeader("bad stanza argument %q", field)
+			}
+		}
+
+		stanza := &Stanza{Type: fields[0], Args: fields[1:]}
+		if stanza.Body, err = readBody(r); err != nil {
+			return nil, err
+		}
+		h.stanzas = append(h.stanzas, stanza)
+	}
+}
+
+func readBody(r *bufio.Reader) ([]byte, error) {
+	var body []byte
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, invalidHeader("truncated stanza body")
+		}
+		if len(line) > columns {
+			return nil, invalidHeader("stanza body line too long")
+		}
+		chunk, err := b64.DecodeString(line)
+		if err != nil {
+			return nil, invalidHeader("bad stanza body")
+		}
+		body = append(body, chunk...)
+		if len(line) < columns {
+			return body, nil
+		}
+	}
+}
+
+// readLine reads one header line without its newline. Lines longer than the
+// reader's buffer are rejected rather than grown without bound.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSuffix(line, []byte("\n"))), nil
+}
+
+func validArg(arg string) bool {
+	if arg == "" {
+		return false
+	}
+	for i := range len(arg) {
+		if arg[i] < 33 || arg[i] > 126 {
+			return false
+		}
+	}
+	return true
+}
+
+func invalidHeader(format string, args ...any) error {
+	return errors.Newf(errors.CodeCorrupt, "", "%w: "+format, append([]any{ErrInvalidHeader}, args...)...)
+}
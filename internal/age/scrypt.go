@@ -0,0 +1,96 @@
+package age
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	scryptType  = "scrypt"
+	scryptLabel = "age-encryption.org/v1/scrypt"
+	scryptSalt  = 16
+
+	// DefaultWorkFactor matches age: scrypt N = 2^18, about a second.
+	DefaultWorkFactor = 18
+	maxWorkFactor     = 22
+)
+
+// ScryptRecipient encrypts to a passphrase. age requires it to be the only
+// recipient of a file.
+type ScryptRecipient struct {
+	passphrase []byte
+	workFactor int
+}
+
+func NewScryptRecipient(passphrase string) *ScryptRecipient {
+	return &ScryptRecipient{passphrase: []byte(passphrase), workFactor: DefaultWorkFactor}
+}
+
+func (r *ScryptRecipient) Wrap(fileKey []byte) (*Stanza, error) {
+	salt := make([]byte, scryptSalt)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key, err := scryptKey(r.passphrase, salt, r.workFactor)
+	if err != nil {
+		return nil, err
+	}
+	body, err := sealFileKey(key, fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Stanza{Type: scryptType, Args: []string{b64.EncodeToString(salt), strconv.Itoa(r.workFactor)}, Body: body}, nil
+}
+
+type ScryptIdentity struct {
+	passphrase []byte
+}
+
+func NewScryptIdentity(passphrase string) *ScryptIdentity {
+	return &ScryptIdentity{passphrase: []byte(passphrase)}
+}
+
+func (i *ScryptIdentity) Unwrap(stanzas []*Stanza) ([]byte, error) {
+	for _, stanza := range stanzas {
+		if stanza.Type != scryptType {
+			continue
+		}
+		if len(stanza.Args) != 2 || len(stanza.Body) != fileKeySize+chacha20poly1305.Overhead {
+			return nil, invalidHeader("bad scrypt stanza")
+		}
+		salt, err := b64.DecodeString(stanza.Args[0])
+		if err != nil || len(salt) != scryptSalt {
+			return nil, invalidHeader("bad scrypt stanza")
+		}
+		workFactor, err := strconv.Atoi(stanza.Args[1])
+		if err != nil || strconv.Itoa(workFactor) != stanza.Args[1] || workFactor <= 0 {
+			return nil, invalidHeader("bad scrypt work factor %q", stanza.Args[1])
+		}
+		if workFactor > maxWorkFactor {
+			return nil, invalidHeader("scrypt work factor %d is above the limit of %d", workFactor, maxWorkFactor)
+		}
+
+		key, err := scryptKey(i.passphrase, salt, workFactor)
+		if err != nil {
+			return nil, err
+		}
+		if fileKey, err := openFileKey(key, stanza.Body); err == nil {
+			return fileKey, nil
+		}
+	}
+	return nil, ErrIncorrectIdentity
+}
+
+func scryptKey(passphrase, salt []byte, workFactor int) ([]byte, error) {
+	labeled := append([]byte(scryptLabel), salt...)
+	key, err := scrypt.Key(passphrase, labeled, 1<<workFactor, 8, 1, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("scrypt failed: %w", err)
+	}
+	return key, nil
+}
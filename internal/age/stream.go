@@ -0,0 +1,162 @@
+package age
+
+import (
+	gocipher "crypto/cipher"
+	"fmt"
+	"io"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// The payload is split into 64 KiB chunks, each sealed with
+// ChaCha20-Poly1305 under a nonce of an 11-byte counter and a flag byte
+// that marks the last chunk.
+const (
+	chunkSize     = 64 << 10
+	encChunkSize  = chunkSize + chacha20poly1305.Overhead
+	lastChunkFlag = 0x01
+)
+
+type streamNonce [chacha20poly1305.NonceSize]byte
+
+func (n *streamNonce) next() error {
+	for i := len(n) - 2; i >= 0; i-- {
+		n[i]++
+		if n[i] != 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("age payload too large")
+}
+
+type writer struct {
+	aead  gocipher.AEAD
+	dst   io.Writer
+	nonce streamNonce
+	buf   []byte
+}
+
+func newWriter(key []byte, dst io.Writer) (*writer, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return &writer{aead: aead, dst: dst, buf: make([]byte, 0, encChunkSize)}, nil
+}
+
+// Write holds back a full chunk until more data arrives, so that Close can
+// flag the real last chunk.
+func (w *writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if len(w.buf) == chunkSize {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := min(len(p), chunkSize-len(w.buf))
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *writer) Close() error {
+	return w.flush(true)
+}
+
+func (w *writer) flush(last bool) error {
+	if last {
+		w.nonce[len(w.nonce)-1] = lastChunkFlag
+	}
+	sealed := w.aead.Seal(w.buf[:0], w.nonce[:], w.buf, nil)
+	if _, err := w.dst.Write(sealed); err != nil {
+		return err
+	}
+	w.buf = w.buf[:0]
+	if last {
+		return nil
+	}
+	return w.nonce.next()
+}
+
+type reader struct {
+	aead     gocipher.AEAD
+	src      io.Reader
+	nonce    streamNonce
+	buf      []byte
+	plainBuf []byte
+	plain    []byte
+	err      error
+}
+
+func newReader(key []byte, src io.Reader) (*reader, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return &reader{aead: aead, src: src, buf: make([]byte, encChunkSize), plainBuf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if err := r.readChunk(); err != nil {
+			r.err = err
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+// readChunk decrypts the next chunk. A full chunk is opened as a middle one
+// first and as the last one if that fails; a short one can only be the last.
+// Anything after the last chunk is an error, reported once its plaintext has
+// been read, as age does.
+func (r *reader) readChunk() error {
+	n, err := io.ReadFull(r.src, r.buf)
+	last := false
+	switch {
+	case err == io.EOF:
+		return errors.Newf(errors.CodeCorrupt, "", "age payload is truncated")
+	case err == io.ErrUnexpectedEOF:
+		if n < chacha20poly1305.Overhead || n == chacha20poly1305.Overhead && r.nonce != (streamNonce{}) {
+			return errors.Newf(errors.CodeCorrupt, "", "age payload is truncated")
+		}
+		last = true
+		r.nonce[len(r.nonce)-1] = lastChunkFlag
+	case err != nil:
+		return errors.New(errors.CodeIO, "read", err)
+	}
+
+	plain, err := r.aead.Open(r.plainBuf[:0], r.nonce[:], r.buf[:n], nil)
+	if err != nil && !last {
+		last = true
+		r.nonce[len(r.nonce)-1] = lastChunkFlag
+		plain, err = r.aead.Open(r.plainBuf[:0], r.nonce[:], r.buf[:n], nil)
+	}
+	if err != nil {
+		return errors.Newf(errors.CodeAuthentication, "", "age payload chunk failed authentication (corrupt or truncated)")
+	}
+	r.plain = plain
+	if !last {
+		return r.nonce.next()
+	}
+
+	var extra [1]byte
+	switch n, err := io.ReadFull(r.src, extra[:]); {
+	case n > 0:
+		r.err = errors.Newf(errors.CodeCorrupt, "", "age payload is followed by trailing data")
+	case err == io.EOF:
+		r.err = io.EOF
+	default:
+		r.err = errors.New(errors.CodeIO, "read", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package age_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	agetest "c2sp.org/CCTV/age"
+
+	"github.com/hambosto/sweetbyte/internal/age"
+	"github.com/hambosto/sweetbyte/internal/errors"
+)
+
+// vector is one file of the age testkit: a textual header of expectations
+// followed by an age file.
+type vector struct {
+	expect      string
+	payload     string
+	identities  []string
+	passphrases []string
+	armored     bool
+	file        []byte
+}
+
+func TestTestkit(t *testing.T) {
+	names, err := fs.Glob(agetest.Vectors, "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) == 0 {
+		t.Fatal("no test vectors")
+	}
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			data, err := fs.ReadFile(agetest.Vectors, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testVector(t, parseVector(t, data))
+		})
+	}
+}
+
+func parseVector(t *testing.T, data []byte) vector {
+	t.Helper()
+
+	var v vector
+	compressed := false
+	for {
+		line, rest, ok := bytes.Cut(data, []byte("\n"))
+		if !ok {
+			t.Fatal("vector has no blank line after its header")
+		}
+		data = rest
+		if len(line) == 0 {
+			break
+		}
+
+		key, value, _ := strings.Cut(string(line), ": ")
+		switch key {
+		case "expect":
+			v.expect = value
+		case "payload":
+			v.payload = value
+		case "identity":
+			v.identities = append(v.identities, value)
+		case "passphrase":
+			v.passphrases = append(v.passphrases, value)
+		case "armored":
+			v.armored = value == "yes"
+		case "compressed":
+			compressed = value == "zlib"
+		}
+	}
+
+	v.file = data
+	if compressed {
+		r, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.file, err = io.ReadAll(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return v
+}
+
+func testVector(t *testing.T, v vector) {
+	if v.armored || v.expect == "armor failure" {
+		t.Skip("ASCII armor is not supported")
+	}
+
+	var identities []age.Identity
+	for _, s := range v.identities {
+		if !strings.HasPrefix(s, "AGE-SECRET-KEY-1") {
+			t.Skip("only X25519 identities are supported")
+		}
+		identity, err := age.ParseX25519Identity(s)
+		if err != nil {
+			t.Fatalf("parsing identity %q: %v", s, err)
+		}
+		identities = append(identities, identity)
+	}
+	for _, passphrase := range v.passphrases {
+		identities = append(identities, age.NewScryptIdentity(passphrase))
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(v.file), identities...)
+	switch v.expect {
+	case "header failure":
+		if err == nil {
+			t.Fatal("expected a header failure, the header was accepted")
+		}
+		if errors.Is(err, age.ErrIncorrectIdentity) {
+			t.Fatalf("expected a header failure, got %v", err)
+		}
+		return
+	case "no match":
+		if !errors.Is(err, age.ErrIncorrectIdentity) {
+			t.Fatalf("expected no identity to match, got %v", err)
+		}
+		return
+	case "HMAC failure":
+		if !errors.Is(err, age.ErrInvalidHeader) {
+			t.Fatalf("expected a header MAC failure, got %v", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("expected the header to be accepted, got %v", err)
+	}
+
+	plaintext, err := io.ReadAll(r)
+	switch v.expect {
+	case "success":
+		if err != nil {
+			t.Fatalf("expected the payload to decrypt, got %v", err)
+		}
+	case "payload failure":
+		if err == nil {
+			t.Fatal("expected a payload failure, the payload decrypted")
+		}
+	default:
+		t.Fatalf("unknown expectation %q", v.expect)
+	}
+
+	sum := sha256.Sum256(plaintext)
+	if got := hex.EncodeToString(sum[:]); got != v.payload {
+		t.Errorf("released plaintext hashes to %s, want %s", got, v.payload)
+	}
+}
@@ -0,0 +1,195 @@
+package age
+
+import (
+	"bufio"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	x25519Type  = "X25519"
+	x25519Label = "age-encryption.org/v1/X25519"
+
+	recipientPrefix = "age"
+	identityPrefix  = "AGE-SECRET-KEY-"
+)
+
+// X25519Recipient is an age1... public key. sweetbyte public keys are X25519
+// too, so the same key can be used with either tool.
+type X25519Recipient struct {
+	key *ecdh.PublicKey
+}
+
+func NewX25519Recipient(key *ecdh.PublicKey) *X25519Recipient {
+	return &X25519Recipient{key: key}
+}
+
+func ParseX25519Recipient(s string) (*X25519Recipient, error) {
+	hrp, data, err := bech32Decode(s)
+	if err != nil || hrp != recipientPrefix {
+		return nil, errors.Newf(errors.CodeInvalidInput, "", "%w: %q", ErrInvalidKey, s)
+	}
+	key, err := ecdh.X25519().NewPublicKey(data)
+	if err != nil {
+		return nil, errors.Newf(errors.CodeInvalidInput, "", "%w: %q", ErrInvalidKey, s)
+	}
+	return &X25519Recipient{key: key}, nil
+}
+
+func (r *X25519Recipient) String() string {
+	s, _ := bech32Encode(recipientPrefix, r.key.Bytes())
+	return s
+}
+
+func (r *X25519Recipient) Wrap(fileKey []byte) (*Stanza, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	share := ephemeral.PublicKey().Bytes()
+
+	wrapKey, err := x25519WrapKey(ephemeral, r.key, share, r.key.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	body, err := sealFileKey(wrapKey, fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Stanza{Type: x25519Type, Args: []string{b64.EncodeToString(share)}, Body: body}, nil
+}
+
+// X25519Identity is an AGE-SECRET-KEY-1... private key.
+type X25519Identity struct {
+	key *ecdh.PrivateKey
+}
+
+func NewX25519Identity(key *ecdh.PrivateKey) *X25519Identity {
+	return &X25519Identity{key: key}
+}
+
+func ParseX25519Identity(s string) (*X25519Identity, error) {
+	hrp, data, err := bech32Decode(s)
+	if err != nil || hrp != strings.ToLower(identityPrefix) {
+		return nil, errors.New(errors.CodeInvalidInput, "", ErrInvalidKey)
+	}
+	key, err := ecdh.X25519().NewPrivateKey(data)
+	if err != nil {
+		return nil, errors.New(errors.CodeInvalidInput, "", ErrInvalidKey)
+	}
+	return &X25519Identity{key: key}, nil
+}
+
+func (i *X25519Identity) Recipient() *X25519Recipient {
+	return &X25519Recipient{key: i.key.PublicKey()}
+}
+
+func (i *X25519Identity) String() string {
+	s, _ := bech32Encode(identityPrefix, i.key.Bytes())
+	return strings.ToUpper(s)
+}
+
+func (i *X25519Identity) Unwrap(stanzas []*Stanza) ([]byte, error) {
+	for _, stanza := range stanzas {
+		if stanza.Type != x25519Type {
+			continue
+		}
+		if len(stanza.Args) != 1 || len(stanza.Body) != fileKeySize+chacha20poly1305.Overhead {
+			return nil, invalidHeader("bad X25519 stanza")
+		}
+		share, err := b64.DecodeString(stanza.Args[0])
+		if err != nil {
+			return nil, invalidHeader("bad X25519 stanza")
+		}
+		ephemeral, err := ecdh.X25519().NewPublicKey(share)
+		if err != nil {
+			return nil, invalidHeader("bad X25519 stanza")
+		}
+
+		wrapKey, err := x25519WrapKey(i.key, ephemeral, share, i.key.PublicKey().Bytes())
+		if err != nil {
+			return nil, invalidHeader("bad X25519 stanza")
+		}
+		if fileKey, err := openFileKey(wrapKey, stanza.Body); err == nil {
+			return fileKey, nil
+		}
+	}
+	return nil, ErrIncorrectIdentity
+}
+
+func x25519WrapKey(private *ecdh.PrivateKey, public *ecdh.PublicKey, share, recipient []byte) ([]byte, error) {
+	shared, err := private.ECDH(public)
+	if err != nil {
+		return nil, fmt.Errorf("key agreement failed: %w", err)
+	}
+	salt := append(append([]byte(nil), share...), recipient...)
+	return hkdf.Key(sha256.New, shared, salt, x25519Label, chacha20poly1305.KeySize)
+}
+
+// ParseRecipients reads age1... keys, one per line, as in an age recipients
+// file. Blank lines and lines starting with # are skipped.
+func ParseRecipients(r io.Reader) ([]Recipient, error) {
+	var recipients []Recipient
+	err := scanKeys(r, func(line string) error {
+		recipient, err := ParseX25519Recipient(line)
+		if err == nil {
+			recipients = append(recipients, recipient)
+		}
+		return err
+	})
+	return recipients, err
+}
+
+// ParseIdentities reads an age identity file as written by age-keygen.
+func ParseIdentities(r io.Reader) ([]Identity, error) {
+	var identities []Identity
+	err := scanKeys(r, func(line string) error {
+		identity, err := ParseX25519Identity(line)
+		if err == nil {
+			identities = append(identities, identity)
+		}
+		return err
+	})
+	if err == nil && len(identities) == 0 {
+		err = errors.New(errors.CodeInvalidInput, "", ErrInvalidKey)
+	}
+	return identities, err
+}
+
+func scanKeys(r io.Reader, parse func(string) error) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := parse(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func sealFileKey(key, fileKey []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, make([]byte, chacha20poly1305.NonceSize), fileKey, nil), nil
+}
+
+func openFileKey(key, body []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), body, nil)
+}
@@ -0,0 +1,467 @@
+package archive
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+const ContentType = "application/x-tar"
+
+var (
+	ErrNotArchive = errors.Sentinel("file is not a sweetbyte archive")
+	ErrUnsafePath = errors.Sentinel("archive entry points outside the destination")
+	ErrNoMember   = errors.Sentinel("archive has no such entry")
+)
+
+type Entry struct {
+	Path    string      `json:"path"`
+	Mode    fs.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mod_time"`
+	Size    int64       `json:"size"`
+	Link    string      `json:"link,omitempty"`
+}
+
+func OutputPath(root string) string {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	return filepath.Clean(root) + config.ArchiveExtension
+}
+
+// Create packs the directory root into an encrypted archive at destPath,
+// which may be processor.StdioPath or an object storage URL.
+func Create(ctx context.Context, root, destPath string, password []byte, opts processor.Options) ([]Entry, error) {
+	info, err := file.GetFileInfo(root)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, errors.New(errors.CodeNotFound, "", file.ErrNotFound).WithPath(root)
+	}
+	if !info.IsDir() {
+		return nil, errors.Newf(errors.CodeInvalidInput, "", "%s is not a directory", root)
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, errors.New(errors.CodeInvalidInput, "", err).WithPath(root)
+	}
+	if !processor.IsStdio(destPath) && !storage.IsRemote(destPath) {
+		absDest, err := filepath.Abs(destPath)
+		if err != nil {
+			return nil, errors.New(errors.CodeInvalidInput, "", err).WithPath(destPath)
+		}
+		if rel, err := filepath.Rel(absRoot, absDest); err == nil && filepath.IsLocal(rel) {
+			return nil, errors.Newf(errors.CodeInvalidInput, "", "archive %s cannot be written inside the directory it archives", destPath)
+		}
+	}
+
+	opts.ContentType = ContentType
+	reader, writer := io.Pipe()
+
+	var entries []Entry
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var err error
+		entries, err = write(ctx, writer, absRoot, filepath.Base(absRoot))
+		writer.CloseWithError(err)
+		return err
+	})
+	g.Go(func() error {
+		err := processor.EncryptReader(ctx, reader, destPath, password, opts)
+		reader.CloseWithError(err)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func List(ctx context.Context, srcPath string, password []byte, opts processor.Options) ([]Entry, error) {
+	var entries []Entry
+	err := read(ctx, srcPath, password, opts, func(hdr *tar.Header, _ io.Reader) error {
+		entries = append(entries, entryOf(hdr))
+		return nil
+	})
+	return entries, err
+}
+
+// Member is an archive entry with the position of its content in the
+// decrypted tar stream.
+type Member struct {
+	Entry
+	Offset int64
+}
+
+// Members lists the entries of a decrypted archive, such as a
+// processor.Reader, seeking over their content instead of decrypting it.
+func Members(r io.ReaderAt, size int64) ([]Member, error) {
+	src := io.NewSectionReader(r, 0, size)
+
+	var members []Member
+	err := walk(src, func(hdr *tar.Header, _ io.Reader) error {
+		offset, err := src.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		members = append(members, Member{Entry: entryOf(hdr), Offset: offset})
+		return nil
+	})
+	return members, err
+}
+
+// ExtractMember writes length bytes of the regular file name in the archive
+// at srcPath, starting at offset, to destPath, which may be
+// processor.StdioPath. A negative length reads to the end. Only the chunks
+// holding the tar headers and that part of the file are decrypted.
+func ExtractMember(ctx context.Context, srcPath, name, destPath string, password []byte, opts processor.Options, offset, length int64) error {
+	r, err := processor.OpenReader(srcPath, password, opts)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if r.ContentType() != ContentType {
+		return errors.New(errors.CodeInvalidInput, "", ErrNotArchive).WithPath(srcPath)
+	}
+	members, err := Members(r, r.Size())
+	if err != nil {
+		return errors.New(errors.CodeUnknown, "", err).WithPath(srcPath)
+	}
+
+	want := path.Clean(strings.TrimPrefix(filepath.ToSlash(name), "/"))
+	for _, m := range members {
+		if path.Clean(m.Path) != want {
+			continue
+		}
+		if !m.Mode.IsRegular() {
+			return errors.Newf(errors.CodeInvalidInput, "", "archive entry %s is not a regular file", m.Path)
+		}
+		return processor.WriteSection(ctx, io.NewSectionReader(r, m.Offset, m.Size), m.Size, offset, length, destPath, opts)
+	}
+	return errors.New(errors.CodeNotFound, "", ErrNoMember).WithPath(name)
+}
+
+// Extract restores the archive at srcPath, which may be
+// processor.StdioPath or a URL, under destDir.
+func Extract(ctx context.Context, srcPath, destDir string, password []byte, force bool, opts processor.Options) ([]Entry, error) {
+	root, err := openDestination(destDir)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	var entries []Entry
+	err = read(ctx, srcPath, password, opts, func(hdr *tar.Header, r io.Reader) error {
+		entry := entryOf(hdr)
+		if err := extractEntry(root, entry, r, force); err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return entries, err
+	}
+	return entries, restoreDirs(root, entries)
+}
+
+// ExtractPaths restores only the entries of the archive at srcPath named by
+// paths under destDir, along with everything below those that are
+// directories. Like ExtractMember it decrypts just the chunks holding the
+// tar headers and the selected content.
+func ExtractPaths(ctx context.Context, srcPath, destDir string, password []byte, paths []string, force bool, opts processor.Options) ([]Entry, error) {
+	r, err := processor.OpenReader(srcPath, password, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	if r.ContentType() != ContentType {
+		return nil, errors.New(errors.CodeInvalidInput, "", ErrNotArchive).WithPath(srcPath)
+	}
+	members, err := Members(r, r.Size())
+	if err != nil {
+		return nil, errors.New(errors.CodeUnknown, "", err).WithPath(srcPath)
+	}
+	selected, err := selectMembers(members, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := openDestination(destDir)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	entries := make([]Entry, 0, len(selected))
+	for _, m := range selected {
+		if err := ctx.Err(); err != nil {
+			return entries, err
+		}
+		if err := extractEntry(root, m.Entry, io.NewSectionReader(r, m.Offset, m.Size), force); err != nil {
+			return entries, err
+		}
+		entries = append(entries, m.Entry)
+	}
+	return entries, restoreDirs(root, entries)
+}
+
+// selectMembers keeps the members named by paths or lying below them, in
+// archive order, and fails for a path that matches nothing.
+func selectMembers(members []Member, paths []string) ([]Member, error) {
+	wanted := make([]string, len(paths))
+	for i, name := range paths {
+		wanted[i] = path.Clean(strings.TrimPrefix(filepath.ToSlash(name), "/"))
+	}
+
+	matched := make([]bool, len(wanted))
+	var selected []Member
+	for _, m := range members {
+		name := path.Clean(m.Path)
+		keep := false
+		for i, want := range wanted {
+			if name == want || strings.HasPrefix(name, want+"/") {
+				matched[i] = true
+				keep = true
+			}
+		}
+		if keep {
+			selected = append(selected, m)
+		}
+	}
+	for i, ok := range matched {
+		if !ok {
+			return nil, errors.New(errors.CodeNotFound, "", ErrNoMember).WithPath(paths[i])
+		}
+	}
+	return selected, nil
+}
+
+func openDestination(destDir string) (*os.Root, error) {
+	if err := os.MkdirAll(destDir, 0o750); err != nil {
+		return nil, errors.New(errors.CodeIO, "mkdir", err).WithPath(destDir)
+	}
+	root, err := os.OpenRoot(destDir)
+	if err != nil {
+		return nil, errors.New(errors.CodeIO, "open", err).WithPath(destDir)
+	}
+	return root, nil
+}
+
+// restoreDirs applies the permissions and modification times of the
+// directories among entries, deepest first, once nothing more is written
+// into them.
+func restoreDirs(root *os.Root, entries []Entry) error {
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if !entry.Mode.IsDir() {
+			continue
+		}
+		name := filepath.FromSlash(strings.TrimSuffix(entry.Path, "/"))
+		if err := root.Chmod(name, entry.Mode.Perm()); err != nil {
+			return errors.New(errors.CodeIO, "chmod", err).WithPath(filepath.Join(root.Name(), name))
+		}
+		if err := root.Chtimes(name, entry.ModTime, entry.ModTime); err != nil {
+			return errors.New(errors.CodeIO, "chtimes", err).WithPath(filepath.Join(root.Name(), name))
+		}
+	}
+	return nil
+}
+
+func write(ctx context.Context, w io.Writer, root, prefix string) ([]Entry, error) {
+	var entries []Entry
+	tw := tar.NewWriter(w)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		var link string
+		switch mode := info.Mode(); {
+		case mode.IsRegular(), mode.IsDir():
+		case mode&fs.ModeSymlink != 0:
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(filepath.Join(prefix, rel))
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		hdr.Format = tar.FormatPAX
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			if err := copyFile(tw, path, hdr.Size); err != nil {
+				return err
+			}
+		}
+		entries = append(entries, entryOf(hdr))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func copyFile(w io.Writer, path string, size int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.CopyN(w, f, size); err != nil {
+		return errors.Newf(errors.CodeIO, "", "%s changed while it was archived: %v", path, err)
+	}
+	return nil
+}
+
+func read(ctx context.Context, srcPath string, password []byte, opts processor.Options, fn func(*tar.Header, io.Reader) error) error {
+	reader, writer := io.Pipe()
+
+	var decryptErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		decryptErr = processor.DecryptFile(ctx, srcPath, writer, password, opts)
+		writer.CloseWithError(decryptErr)
+	}()
+
+	err := walk(reader, fn)
+	if err == nil {
+		_, err = io.Copy(io.Discard, reader)
+	}
+	reader.CloseWithError(err)
+	<-done
+
+	if err != nil && !errors.Is(err, decryptErr) {
+		return err
+	}
+	return decryptErr
+}
+
+func walk(r io.Reader, fn func(*tar.Header, io.Reader) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		switch {
+		case err == io.EOF:
+			return nil
+		case errors.Is(err, tar.ErrHeader), errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New(errors.CodeInvalidInput, "", ErrNotArchive)
+		case err != nil:
+			return err
+		}
+
+		if err := fn(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func extractEntry(root *os.Root, entry Entry, r io.Reader, force bool) error {
+	name := filepath.FromSlash(strings.TrimSuffix(entry.Path, "/"))
+	if !filepath.IsLocal(name) {
+		return errors.New(errors.CodeCorrupt, "", ErrUnsafePath).WithPath(entry.Path)
+	}
+
+	path := filepath.Join(root.Name(), name)
+	switch mode := entry.Mode; {
+	case mode.IsDir():
+		if err := root.MkdirAll(name, 0o700); err != nil {
+			return errors.New(errors.CodeIO, "mkdir", err).WithPath(path)
+		}
+		return nil
+	case mode.IsRegular(), mode&fs.ModeSymlink != 0:
+	default:
+		return nil
+	}
+
+	if err := root.MkdirAll(filepath.Dir(name), 0o700); err != nil {
+		return errors.New(errors.CodeIO, "mkdir", err).WithPath(path)
+	}
+	if _, err := root.Lstat(name); err == nil {
+		if !force {
+			return errors.New(errors.CodeExists, "", file.ErrOutputExists).WithPath(path)
+		}
+		if err := root.Remove(name); err != nil {
+			return errors.New(errors.CodeIO, "remove", err).WithPath(path)
+		}
+	}
+
+	if entry.Mode&fs.ModeSymlink != 0 {
+		if err := root.Symlink(entry.Link, name); err != nil {
+			return errors.New(errors.CodeIO, "symlink", err).WithPath(path)
+		}
+		return nil
+	}
+
+	f, err := root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, entry.Mode.Perm())
+	if err != nil {
+		return errors.New(errors.CodeIO, "create", err).WithPath(path)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return errors.New(errors.CodeIO, "close", err).WithPath(path)
+	}
+	if err := root.Chtimes(name, entry.ModTime, entry.ModTime); err != nil {
+		return errors.New(errors.CodeIO, "chtimes", err).WithPath(path)
+	}
+	return nil
+}
+
+func entryOf(hdr *tar.Header) Entry {
+	return Entry{
+		Path:    hdr.Name,
+		Mode:    hdr.FileInfo().Mode(),
+		ModTime: hdr.ModTime,
+		Size:    hdr.Size,
+		Link:    hdr.Linkname,
+	}
+}
@@ -0,0 +1,83 @@
+package archive
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/hambosto/sweetbyte/internal/compression"
+	"github.com/hambosto/sweetbyte/internal/errors"
+)
+
+const (
+	// maxSampleFile is the largest file sampled for a dictionary; larger
+	// files give zstd enough context on their own.
+	maxSampleFile = 128 * 1024
+
+	// maxSampleTotal caps what training reads, about a hundred times the
+	// dictionary size as zstd recommends.
+	maxSampleTotal = 100 * compression.DictionarySize
+)
+
+// TrainDictionary samples the small regular files under root and trains a
+// zstd dictionary on them, to be passed to Create in
+// processor.Options.Dictionary. It returns nil when there are too few
+// small files for a dictionary to help or training finds nothing to share.
+func TrainDictionary(ctx context.Context, root string) ([]byte, error) {
+	type candidate struct {
+		path string
+		size int64
+	}
+
+	var (
+		candidates []candidate
+		total      int64
+	)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() > 0 && info.Size() <= maxSampleFile {
+			candidates = append(candidates, candidate{path: path, size: info.Size()})
+			total += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New(errors.CodeIO, "sampling files for a dictionary", err).WithPath(root)
+	}
+	if len(candidates) < compression.MinDictionarySamples {
+		return nil, nil
+	}
+
+	// Spread the samples over the whole tree rather than its first files.
+	stride := int((total + maxSampleTotal - 1) / maxSampleTotal)
+	samples := make([][]byte, 0, len(candidates)/stride+1)
+	for i := 0; i < len(candidates); i += stride {
+		data, err := os.ReadFile(candidates[i].path)
+		if err != nil {
+			return nil, errors.New(errors.CodeIO, "sampling files for a dictionary", err).WithPath(candidates[i].path)
+		}
+		if len(data) > 0 {
+			samples = append(samples, data)
+		}
+	}
+
+	dict, err := compression.TrainDictionary(samples)
+	if err != nil {
+		// The samples share too little to build a dictionary from.
+		return nil, nil
+	}
+	return dict, nil
+}
@@ -0,0 +1,225 @@
+package benchmark
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/hambosto/sweetbyte/internal/cipher"
+	"github.com/hambosto/sweetbyte/internal/compression"
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/stream"
+	"github.com/hambosto/sweetbyte/internal/stream/processing"
+	"github.com/hambosto/sweetbyte/internal/types"
+)
+
+const DefaultWorkloadSize = 64 * 1024 * 1024
+
+var DefaultChunkSizes = []int{256 * 1024, 512 * 1024, 1024 * 1024, 2 * 1024 * 1024, 4 * 1024 * 1024}
+
+var AllLevels = []compression.Level{
+	compression.LevelNoCompression,
+	compression.LevelBestSpeed,
+	compression.LevelDefaultCompression,
+	compression.LevelBestCompression,
+}
+
+type Result struct {
+	ChunkSize   int
+	Level       compression.Level
+	Concurrency int
+	Encrypt     time.Duration
+	Decrypt     time.Duration
+	Output      int64
+
+	// EncryptAlloc and DecryptAlloc are the bytes allocated on the heap
+	// per chunk, and EncryptAllocs and DecryptAllocs the number of
+	// allocations.
+	EncryptAlloc  int64
+	DecryptAlloc  int64
+	EncryptAllocs float64
+	DecryptAllocs float64
+}
+
+func (r Result) EncryptThroughput(size int64) float64 {
+	return throughput(size, r.Encrypt)
+}
+
+func (r Result) DecryptThroughput(size int64) float64 {
+	return throughput(size, r.Decrypt)
+}
+
+func throughput(size int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(size) / d.Seconds()
+}
+
+type Sweep struct {
+	WorkloadSize int64
+	ChunkSizes   []int
+	Levels       []compression.Level
+	Concurrency  []int
+	Suite        cipher.Suite
+}
+
+func NewSweep(workloadSize int64) Sweep {
+	return Sweep{
+		WorkloadSize: workloadSize,
+		ChunkSizes:   DefaultChunkSizes,
+		Levels:       []compression.Level{processing.CompressionLevel},
+		Concurrency:  DefaultConcurrencyLevels(),
+	}
+}
+
+func DefaultConcurrencyLevels() []int {
+	cpus := runtime.NumCPU()
+	levels := []int{1}
+	for n := 2; n < cpus; n *= 2 {
+		levels = append(levels, n)
+	}
+	if cpus > 1 {
+		levels = append(levels, cpus)
+	}
+	return levels
+}
+
+func (s Sweep) Run(ctx context.Context, report func(Result)) ([]Result, error) {
+	if s.WorkloadSize <= 0 {
+		return nil, fmt.Errorf("workload size must be positive, got %d", s.WorkloadSize)
+	}
+
+	key, err := derive.GetRandomBytes(derive.ArgonKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	workload := Workload(s.WorkloadSize)
+
+	var results []Result
+	for _, chunkSize := range s.ChunkSizes {
+		for _, level := range s.Levels {
+			for _, concurrency := range s.Concurrency {
+				result, err := s.run(ctx, key, workload, chunkSize, level, concurrency)
+				if err != nil {
+					return results, err
+				}
+				results = append(results, result)
+				if report != nil {
+					report(result)
+				}
+			}
+		}
+	}
+
+	return results, nil
+}
+
+func Best(results []Result) (Result, bool) {
+	results = slices.DeleteFunc(slices.Clone(results), func(r Result) bool {
+		return r.Level != processing.CompressionLevel
+	})
+	if len(results) == 0 {
+		return Result{}, false
+	}
+	return slices.MinFunc(results, func(a, b Result) int {
+		return int((a.Encrypt + a.Decrypt) - (b.Encrypt + b.Decrypt))
+	}), true
+}
+
+func Workload(size int64) []byte {
+	data := make([]byte, size)
+	rng := rand.New(rand.NewPCG(uint64(size), 0x5eedb17e))
+
+	const block = 64 * 1024
+	text := []byte("The quick brown fox jumps over the lazy dog. ")
+	for offset := int64(0); offset < size; offset += block {
+		end := min(offset+block, size)
+		if (offset/block)%2 == 0 {
+			for i := offset; i < end; i++ {
+				data[i] = byte(rng.Uint32())
+			}
+			continue
+		}
+		for i := offset; i < end; i++ {
+			data[i] = text[int(i)%len(text)]
+		}
+	}
+
+	return data
+}
+
+func (s Sweep) run(ctx context.Context, key, workload []byte, chunkSize int, level compression.Level, concurrency int) (Result, error) {
+	result := Result{ChunkSize: chunkSize, Level: level, Concurrency: concurrency}
+
+	encryption, err := s.pipeline(key, types.Encryption, concurrency)
+	if err != nil {
+		return Result{}, err
+	}
+	if err := encryption.SetChunkSize(chunkSize); err != nil {
+		return Result{}, err
+	}
+	if err := encryption.SetCompressionLevel(level); err != nil {
+		return Result{}, err
+	}
+
+	chunks := int64(len(workload)+chunkSize-1) / int64(chunkSize)
+
+	var encrypted bytes.Buffer
+	encrypted.Grow(len(workload) * 4)
+	allocated, allocations := heapAllocated()
+	start := time.Now()
+	if err := encryption.Process(ctx, bytes.NewReader(workload), &encrypted, int64(len(workload))); err != nil {
+		return Result{}, fmt.Errorf("benchmark encryption failed (chunk size %d, level %s, concurrency %d): %w", chunkSize, level, concurrency, err)
+	}
+	result.Encrypt = time.Since(start)
+	result.EncryptAlloc, result.EncryptAllocs = perChunk(allocated, allocations, chunks)
+	result.Output = int64(encrypted.Len())
+
+	decryption, err := s.pipeline(key, types.Decryption, concurrency)
+	if err != nil {
+		return Result{}, err
+	}
+
+	allocated, allocations = heapAllocated()
+	start = time.Now()
+	if err := decryption.Process(ctx, &encrypted, io.Discard, int64(len(workload))); err != nil {
+		return Result{}, fmt.Errorf("benchmark decryption failed (chunk size %d, level %s, concurrency %d): %w", chunkSize, level, concurrency, err)
+	}
+	result.Decrypt = time.Since(start)
+	result.DecryptAlloc, result.DecryptAllocs = perChunk(allocated, allocations, chunks)
+
+	return result, nil
+}
+
+// heapAllocated returns the bytes and the number of objects allocated on
+// the heap so far.
+func heapAllocated() (int64, uint64) {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return int64(stats.TotalAlloc), stats.Mallocs
+}
+
+// perChunk spreads what was allocated since heapAllocated returned
+// allocated and allocations over chunks.
+func perChunk(allocated int64, allocations uint64, chunks int64) (int64, float64) {
+	now, count := heapAllocated()
+	return (now - allocated) / chunks, float64(count-allocations) / float64(chunks)
+}
+
+func (s Sweep) pipeline(key []byte, mode types.Processing, concurrency int) (*stream.Pipeline, error) {
+	pipeline, err := stream.NewPipeline(key, mode)
+	if err != nil {
+		return nil, err
+	}
+	if err := pipeline.SetConcurrency(concurrency); err != nil {
+		return nil, err
+	}
+	pipeline.SetSuite(s.Suite)
+	return pipeline, nil
+}
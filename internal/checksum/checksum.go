@@ -0,0 +1,251 @@
+package checksum
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/tempfile"
+)
+
+const dbFile = "checksums.json"
+
+type Status string
+
+const (
+	StatusOK       Status = "ok"
+	StatusMissing  Status = "missing"
+	StatusModified Status = "modified"
+	StatusReplaced Status = "replaced"
+	StatusUnknown  Status = "unknown"
+)
+
+type Record struct {
+	Path       string    `json:"path"`
+	FileID     string    `json:"file_id"`
+	Ciphertext string    `json:"ciphertext_sha256"`
+	Plaintext  string    `json:"plaintext_sha256"`
+	Size       int64     `json:"size"`
+	Recorded   time.Time `json:"recorded"`
+}
+
+type Result struct {
+	Path   string `json:"path"`
+	Status Status `json:"status"`
+	Record Record `json:"record,omitzero"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Summary struct {
+	Checked int      `json:"checked"`
+	Failed  int      `json:"failed"`
+	Results []Result `json:"results"`
+}
+
+func (s Summary) OK() bool {
+	return s.Failed == 0
+}
+
+type DB struct {
+	Records []Record `json:"records"`
+
+	path string
+}
+
+func DefaultPath() (string, error) {
+	settingsPath, err := config.SettingsPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(settingsPath), dbFile), nil
+}
+
+func Load(path string) (*DB, error) {
+	if path == "" {
+		var err error
+		if path, err = DefaultPath(); err != nil {
+			return nil, err
+		}
+	}
+
+	db := &DB{path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checksum database: %w", err)
+	}
+	if err := json.Unmarshal(data, db); err != nil {
+		return nil, fmt.Errorf("failed to parse checksum database %s: %w", path, err)
+	}
+	return db, nil
+}
+
+func (db *DB) Path() string {
+	return db.path
+}
+
+func (db *DB) Save() error {
+	if err := os.MkdirAll(filepath.Dir(db.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(db, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checksum database: %w", err)
+	}
+
+	f, err := tempfile.CreateAtomic(db.path)
+	if err != nil {
+		return fmt.Errorf("failed to write checksum database: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Remove()
+		return fmt.Errorf("failed to write checksum database: %w", err)
+	}
+	if err := f.Commit(); err != nil {
+		return fmt.Errorf("failed to replace checksum database: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) Put(record Record) {
+	if i := db.index(record.Path); i >= 0 {
+		db.Records[i] = record
+		return
+	}
+	db.Records = append(db.Records, record)
+}
+
+func (db *DB) Lookup(path string) (Record, bool) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return Record{}, false
+	}
+	if i := db.index(absPath); i >= 0 {
+		return db.Records[i], true
+	}
+	return Record{}, false
+}
+
+func (db *DB) index(path string) int {
+	return slices.IndexFunc(db.Records, func(r Record) bool { return r.Path == path })
+}
+
+func NewRecord(path string, plaintextHash []byte) (Record, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+
+	digest, size, err := hashFile(absPath)
+	if err != nil {
+		return Record{}, err
+	}
+	fileID, err := processor.FileID(absPath)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to read file ID: %w", err)
+	}
+
+	return Record{
+		Path:       absPath,
+		FileID:     hex.EncodeToString(fileID),
+		Ciphertext: hex.EncodeToString(digest),
+		Plaintext:  hex.EncodeToString(plaintextHash),
+		Size:       size,
+		Recorded:   time.Now().UTC(),
+	}, nil
+}
+
+func (db *DB) Check(ctx context.Context, paths []string, report func(Result)) (Summary, error) {
+	records := db.Records
+	if len(paths) > 0 {
+		records = make([]Record, 0, len(paths))
+		for _, path := range paths {
+			record, ok := db.Lookup(path)
+			if !ok {
+				absPath, _ := filepath.Abs(path)
+				record = Record{Path: absPath}
+			}
+			records = append(records, record)
+		}
+	}
+
+	var summary Summary
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+
+		result := check(record)
+		summary.Checked++
+		if result.Status != StatusOK {
+			summary.Failed++
+		}
+		summary.Results = append(summary.Results, result)
+		if report != nil {
+			report(result)
+		}
+	}
+	return summary, nil
+}
+
+func check(record Record) Result {
+	result := Result{Path: record.Path, Record: record}
+	if record.Ciphertext == "" {
+		result.Status = StatusUnknown
+		result.Error = "not in the checksum database"
+		return result
+	}
+
+	digest, _, err := hashFile(record.Path)
+	if errors.CodeOf(err) == errors.CodeNotFound {
+		result.Status = StatusMissing
+		return result
+	}
+	if err != nil {
+		result.Status = StatusModified
+		result.Error = err.Error()
+		return result
+	}
+
+	expected, err := hex.DecodeString(record.Ciphertext)
+	if err == nil && bytes.Equal(digest, expected) {
+		result.Status = StatusOK
+		return result
+	}
+
+	result.Status = StatusModified
+	if fileID, err := processor.FileID(record.Path); err == nil && hex.EncodeToString(fileID) != record.FileID {
+		result.Status = StatusReplaced
+	}
+	return result
+}
+
+func hashFile(path string) ([]byte, int64, error) {
+	f, err := file.OpenFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	digest := sha256.New()
+	size, err := io.Copy(digest, f)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return digest.Sum(nil), size, nil
+}
@@ -3,14 +3,15 @@ package algorithm
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
 	"fmt"
-
-	"github.com/hambosto/sweetbyte/internal/derive"
+	"slices"
 )
 
 const (
 	AESKeySize   = 32
 	AESNonceSize = 12
+	AESTagSize   = 16
 )
 
 type AESCipher struct {
@@ -36,20 +37,72 @@ func NewAESCipher(key []byte) (*AESCipher, error) {
 }
 
 func (c *AESCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	return c.EncryptTo(nil, plaintext, nil)
+}
+
+func (c *AESCipher) EncryptTo(dst, plaintext, additionalData []byte) ([]byte, error) {
 	if len(plaintext) == 0 {
 		return nil, fmt.Errorf("plaintext cannot be empty")
 	}
 
-	nonce, err := derive.GetRandomBytes(AESNonceSize)
-	if err != nil {
+	dst = slices.Grow(dst[:0], AESNonceSize+len(plaintext)+c.aead.Overhead())
+	nonce := dst[:AESNonceSize]
+	if _, err := rand.Read(nonce); err != nil {
 		return nil, fmt.Errorf("failed to generate nonce: %w", err)
 	}
 
-	ciphertext := c.aead.Seal(nonce, nonce, plaintext, nil)
+	ciphertext := c.aead.Seal(nonce, nonce, plaintext, additionalData)
 	return ciphertext, nil
 }
 
+func (c *AESCipher) EncryptWithNonceTo(dst, nonce, plaintext, additionalData []byte) ([]byte, error) {
+	if len(plaintext) == 0 {
+		return nil, fmt.Errorf("plaintext cannot be empty")
+	}
+	if len(nonce) != AESNonceSize {
+		return nil, fmt.Errorf("nonce must be %d bytes, got %d", AESNonceSize, len(nonce))
+	}
+
+	dst = slices.Grow(dst[:0], AESNonceSize+len(plaintext)+c.aead.Overhead())
+	dst = append(dst, nonce...)
+
+	ciphertext := c.aead.Seal(dst, dst, plaintext, additionalData)
+	return ciphertext, nil
+}
+
+// EncryptInPlace seals the plaintext that follows AESNonceSize free bytes at the
+// start of buf, writing the nonce into those bytes and the ciphertext over
+// the plaintext. Spare capacity in buf for the tag avoids a copy.
+func (c *AESCipher) EncryptInPlace(buf, additionalData []byte) ([]byte, error) {
+	if len(buf) <= AESNonceSize {
+		return nil, fmt.Errorf("plaintext cannot be empty")
+	}
+
+	nonce := buf[:AESNonceSize]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return c.aead.Seal(nonce, nonce, buf[AESNonceSize:], additionalData), nil
+}
+
+func (c *AESCipher) EncryptWithNonceInPlace(buf, nonce, additionalData []byte) ([]byte, error) {
+	if len(buf) <= AESNonceSize {
+		return nil, fmt.Errorf("plaintext cannot be empty")
+	}
+	if len(nonce) != AESNonceSize {
+		return nil, fmt.Errorf("nonce must be %d bytes, got %d", AESNonceSize, len(nonce))
+	}
+
+	copy(buf, nonce)
+	return c.aead.Seal(buf[:AESNonceSize], buf[:AESNonceSize], buf[AESNonceSize:], additionalData), nil
+}
+
 func (c *AESCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	return c.DecryptTo(nil, ciphertext, nil)
+}
+
+func (c *AESCipher) DecryptTo(dst, ciphertext, additionalData []byte) ([]byte, error) {
 	if len(ciphertext) == 0 {
 		return nil, fmt.Errorf("ciphertext cannot be empty")
 	}
@@ -61,7 +114,7 @@ func (c *AESCipher) Decrypt(ciphertext []byte) ([]byte, error) {
 	nonce := ciphertext[:AESNonceSize]
 	ciphertext = ciphertext[AESNonceSize:]
 
-	plaintext, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := c.aead.Open(dst[:0], nonce, ciphertext, additionalData)
 	if err != nil {
 		return nil, fmt.Errorf("authentication failed: %w", err)
 	}
@@ -2,15 +2,17 @@ package algorithm
 
 import (
 	"crypto/cipher"
+	"crypto/rand"
 	"fmt"
+	"slices"
 
-	"github.com/hambosto/sweetbyte/internal/derive"
 	"golang.org/x/crypto/chacha20poly1305"
 )
 
 const (
 	ChaChaKeySize    = 32
 	ChaChaNonceSizeX = 24
+	ChaChaTagSize    = chacha20poly1305.Overhead
 )
 
 type ChaCha20Cipher struct {
@@ -31,20 +33,72 @@ func NewChaCha20Cipher(key []byte) (*ChaCha20Cipher, error) {
 }
 
 func (c *ChaCha20Cipher) Encrypt(plaintext []byte) ([]byte, error) {
+	return c.EncryptTo(nil, plaintext, nil)
+}
+
+func (c *ChaCha20Cipher) EncryptTo(dst, plaintext, additionalData []byte) ([]byte, error) {
 	if len(plaintext) == 0 {
 		return nil, fmt.Errorf("plaintext cannot be empty")
 	}
 
-	nonce, err := derive.GetRandomBytes(ChaChaNonceSizeX)
-	if err != nil {
+	dst = slices.Grow(dst[:0], ChaChaNonceSizeX+len(plaintext)+c.aead.Overhead())
+	nonce := dst[:ChaChaNonceSizeX]
+	if _, err := rand.Read(nonce); err != nil {
 		return nil, fmt.Errorf("failed to generate nonce: %w", err)
 	}
 
-	ciphertext := c.aead.Seal(nonce, nonce, plaintext, nil)
+	ciphertext := c.aead.Seal(nonce, nonce, plaintext, additionalData)
+	return ciphertext, nil
+}
+
+func (c *ChaCha20Cipher) EncryptWithNonceTo(dst, nonce, plaintext, additionalData []byte) ([]byte, error) {
+	if len(plaintext) == 0 {
+		return nil, fmt.Errorf("plaintext cannot be empty")
+	}
+	if len(nonce) != ChaChaNonceSizeX {
+		return nil, fmt.Errorf("nonce must be %d bytes, got %d", ChaChaNonceSizeX, len(nonce))
+	}
+
+	dst = slices.Grow(dst[:0], ChaChaNonceSizeX+len(plaintext)+c.aead.Overhead())
+	dst = append(dst, nonce...)
+
+	ciphertext := c.aead.Seal(dst, dst, plaintext, additionalData)
 	return ciphertext, nil
 }
 
+// EncryptInPlace seals the plaintext that follows ChaChaNonceSizeX free bytes at the
+// start of buf, writing the nonce into those bytes and the ciphertext over
+// the plaintext. Spare capacity in buf for the tag avoids a copy.
+func (c *ChaCha20Cipher) EncryptInPlace(buf, additionalData []byte) ([]byte, error) {
+	if len(buf) <= ChaChaNonceSizeX {
+		return nil, fmt.Errorf("plaintext cannot be empty")
+	}
+
+	nonce := buf[:ChaChaNonceSizeX]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return c.aead.Seal(nonce, nonce, buf[ChaChaNonceSizeX:], additionalData), nil
+}
+
+func (c *ChaCha20Cipher) EncryptWithNonceInPlace(buf, nonce, additionalData []byte) ([]byte, error) {
+	if len(buf) <= ChaChaNonceSizeX {
+		return nil, fmt.Errorf("plaintext cannot be empty")
+	}
+	if len(nonce) != ChaChaNonceSizeX {
+		return nil, fmt.Errorf("nonce must be %d bytes, got %d", ChaChaNonceSizeX, len(nonce))
+	}
+
+	copy(buf, nonce)
+	return c.aead.Seal(buf[:ChaChaNonceSizeX], buf[:ChaChaNonceSizeX], buf[ChaChaNonceSizeX:], additionalData), nil
+}
+
 func (c *ChaCha20Cipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	return c.DecryptTo(nil, ciphertext, nil)
+}
+
+func (c *ChaCha20Cipher) DecryptTo(dst, ciphertext, additionalData []byte) ([]byte, error) {
 	if len(ciphertext) == 0 {
 		return nil, fmt.Errorf("ciphertext cannot be empty")
 	}
@@ -56,7 +110,7 @@ func (c *ChaCha20Cipher) Decrypt(ciphertext []byte) ([]byte, error) {
 	nonce := ciphertext[:ChaChaNonceSizeX]
 	ciphertext = ciphertext[ChaChaNonceSizeX:]
 
-	plaintext, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := c.aead.Open(dst[:0], nonce, ciphertext, additionalData)
 	if err != nil {
 		return nil, fmt.Errorf("authentication failed: %w", err)
 	}
@@ -1,15 +1,23 @@
 package cipher
 
 import (
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
 	"fmt"
 
 	"github.com/hambosto/sweetbyte/internal/cipher/algorithm"
 	"github.com/hambosto/sweetbyte/internal/derive"
 )
 
+const deterministicInfo = "sweetbyte deterministic nonce"
+
 type Cipher struct {
-	aesCipher    *algorithm.AESCipher
-	chachaCipher *algorithm.ChaCha20Cipher
+	aesCipher     *algorithm.AESCipher
+	chachaCipher  *algorithm.ChaCha20Cipher
+	aesNonce      []byte
+	chachaNonce   []byte
+	deterministic bool
 }
 
 func NewCipher(key []byte) (*Cipher, error) {
@@ -27,12 +35,35 @@ func NewCipher(key []byte) (*Cipher, error) {
 		return nil, fmt.Errorf("failed to create ChaCha20 cipher: %w", err)
 	}
 
+	aesNonce, err := hkdf.Key(sha256.New, key[:32], nil, deterministicInfo, sha256.Size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive AES nonce key: %w", err)
+	}
+
+	chachaNonce, err := hkdf.Key(sha256.New, key[32:64], nil, deterministicInfo, sha256.Size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive ChaCha20 nonce key: %w", err)
+	}
+
 	return &Cipher{
 		aesCipher:    aesCipher,
 		chachaCipher: chachaCipher,
+		aesNonce:     aesNonce,
+		chachaNonce:  chachaNonce,
 	}, nil
 }
 
+func (c *Cipher) SetDeterministic(enabled bool) {
+	c.deterministic = enabled
+}
+
+func syntheticNonce(key, additionalData, plaintext []byte, size int) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(additionalData)
+	mac.Write(plaintext)
+	return mac.Sum(nil)[:size]
+}
+
 func (c *Cipher) EncryptAES(plaintext []byte) ([]byte, error) {
 	return c.aesCipher.Encrypt(plaintext)
 }
@@ -48,3 +79,43 @@ func (c *Cipher) EncryptChaCha20(plaintext []byte) ([]byte, error) {
 func (c *Cipher) DecryptChaCha20(ciphertext []byte) ([]byte, error) {
 	return c.chachaCipher.Decrypt(ciphertext)
 }
+
+func (c *Cipher) EncryptAESTo(dst, plaintext, additionalData []byte) ([]byte, error) {
+	if c.deterministic {
+		return c.aesCipher.EncryptWithNonceTo(dst, syntheticNonce(c.aesNonce, additionalData, plaintext, algorithm.AESNonceSize), plaintext, additionalData)
+	}
+	return c.aesCipher.EncryptTo(dst, plaintext, additionalData)
+}
+
+// EncryptAESInPlace is EncryptAESTo for a plaintext that follows
+// algorithm.AESNonceSize free bytes in buf, which it encrypts over.
+func (c *Cipher) EncryptAESInPlace(buf, additionalData []byte) ([]byte, error) {
+	if c.deterministic && len(buf) > algorithm.AESNonceSize {
+		return c.aesCipher.EncryptWithNonceInPlace(buf, syntheticNonce(c.aesNonce, additionalData, buf[algorithm.AESNonceSize:], algorithm.AESNonceSize), additionalData)
+	}
+	return c.aesCipher.EncryptInPlace(buf, additionalData)
+}
+
+func (c *Cipher) DecryptAESTo(dst, ciphertext, additionalData []byte) ([]byte, error) {
+	return c.aesCipher.DecryptTo(dst, ciphertext, additionalData)
+}
+
+func (c *Cipher) EncryptChaCha20To(dst, plaintext, additionalData []byte) ([]byte, error) {
+	if c.deterministic {
+		return c.chachaCipher.EncryptWithNonceTo(dst, syntheticNonce(c.chachaNonce, additionalData, plaintext, algorithm.ChaChaNonceSizeX), plaintext, additionalData)
+	}
+	return c.chachaCipher.EncryptTo(dst, plaintext, additionalData)
+}
+
+// EncryptChaCha20InPlace is EncryptChaCha20To for a plaintext that follows
+// algorithm.ChaChaNonceSizeX free bytes in buf, which it encrypts over.
+func (c *Cipher) EncryptChaCha20InPlace(buf, additionalData []byte) ([]byte, error) {
+	if c.deterministic && len(buf) > algorithm.ChaChaNonceSizeX {
+		return c.chachaCipher.EncryptWithNonceInPlace(buf, syntheticNonce(c.chachaNonce, additionalData, buf[algorithm.ChaChaNonceSizeX:], algorithm.ChaChaNonceSizeX), additionalData)
+	}
+	return c.chachaCipher.EncryptInPlace(buf, additionalData)
+}
+
+func (c *Cipher) DecryptChaCha20To(dst, ciphertext, additionalData []byte) ([]byte, error) {
+	return c.chachaCipher.DecryptTo(dst, ciphertext, additionalData)
+}
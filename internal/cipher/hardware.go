@@ -0,0 +1,30 @@
+package cipher
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/cpu"
+)
+
+var hardwareAES = detectHardwareAES()
+
+func detectHardwareAES() bool {
+	switch runtime.GOARCH {
+	case "amd64":
+		return cpu.X86.HasAES && cpu.X86.HasPCLMULQDQ
+	case "arm64":
+		return cpu.ARM64.HasAES && cpu.ARM64.HasPMULL
+	}
+	return false
+}
+
+func HardwareAES() bool {
+	return hardwareAES
+}
+
+func AutoSuite() Suite {
+	if hardwareAES {
+		return SuiteAESGCM
+	}
+	return SuiteXChaCha20
+}
@@ -0,0 +1,93 @@
+package cipher
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hambosto/sweetbyte/internal/cipher/algorithm"
+)
+
+type Suite uint8
+
+const SuiteAuto = "auto"
+
+const (
+	SuiteCascade Suite = iota
+	SuiteAESGCM
+	SuiteXChaCha20
+)
+
+var suiteNames = map[Suite]string{
+	SuiteCascade:   "cascade",
+	SuiteAESGCM:    "aes-gcm",
+	SuiteXChaCha20: "xchacha20",
+}
+
+func SuiteNames() []string {
+	return []string{suiteNames[SuiteCascade], suiteNames[SuiteAESGCM], suiteNames[SuiteXChaCha20]}
+}
+
+func ParseSuite(name string) (Suite, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "":
+		return SuiteCascade, nil
+	case SuiteAuto:
+		return AutoSuite(), nil
+	}
+	for suite, suiteName := range suiteNames {
+		if suiteName == name {
+			return suite, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown cipher suite %q (expected %s or %s)", name, strings.Join(SuiteNames(), ", "), SuiteAuto)
+}
+
+func (s Suite) Valid() bool {
+	_, ok := suiteNames[s]
+	return ok
+}
+
+func (s Suite) String() string {
+	if name, ok := suiteNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("suite(%d)", uint8(s))
+}
+
+func (s Suite) Description() string {
+	switch s {
+	case SuiteAESGCM:
+		return "AES-256-GCM"
+	case SuiteXChaCha20:
+		return "XChaCha20-Poly1305"
+	default:
+		return "AES-256-GCM + XChaCha20-Poly1305"
+	}
+}
+
+// NonceSize is the length of the nonces that lead a sealed chunk: the
+// XChaCha20 nonce, if any, followed by the AES nonce, if any.
+func (s Suite) NonceSize() int {
+	switch s {
+	case SuiteAESGCM:
+		return algorithm.AESNonceSize
+	case SuiteXChaCha20:
+		return algorithm.ChaChaNonceSizeX
+	default:
+		return algorithm.AESNonceSize + algorithm.ChaChaNonceSizeX
+	}
+}
+
+func (s Suite) Overhead() int {
+	aesOverhead := algorithm.AESNonceSize + algorithm.AESTagSize
+	chachaOverhead := algorithm.ChaChaNonceSizeX + algorithm.ChaChaTagSize
+	switch s {
+	case SuiteAESGCM:
+		return aesOverhead
+	case SuiteXChaCha20:
+		return chachaOverhead
+	default:
+		return aesOverhead + chachaOverhead
+	}
+}
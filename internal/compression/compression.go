@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"sync"
 
 	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
 )
 
 type Level int
@@ -17,8 +19,33 @@ const (
 	LevelBestCompression
 )
 
+func (l Level) String() string {
+	switch l {
+	case LevelNoCompression:
+		return "none"
+	case LevelBestSpeed:
+		return "fast"
+	case LevelDefaultCompression:
+		return "default"
+	case LevelBestCompression:
+		return "best"
+	default:
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+}
+
 type Compression struct {
 	level int
+
+	// writers and readers keep zlib state, which is costly to allocate,
+	// across chunks.
+	writers sync.Pool
+	readers sync.Pool
+
+	// encoder and decoder are set for zstd with a trained dictionary, which
+	// replaces zlib.
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
 }
 
 func NewCompression(level Level) (*Compression, error) {
@@ -40,46 +67,166 @@ func NewCompression(level Level) (*Compression, error) {
 	return &Compression{level: zlibLevel}, nil
 }
 
+// NewDictCompression compresses with zstd primed with dict, a dictionary
+// made by TrainDictionary.
+func NewDictCompression(level Level, dict []byte) (*Compression, error) {
+	var zstdLevel zstd.EncoderLevel
+
+	switch level {
+	case LevelBestSpeed:
+		zstdLevel = zstd.SpeedFastest
+	case LevelBestCompression:
+		zstdLevel = zstd.SpeedBestCompression
+	default:
+		zstdLevel = zstd.SpeedDefault
+	}
+
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstdLevel), zstd.WithEncoderDict(dict))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dictionary: %w", err)
+	}
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderDicts(dict))
+	if err != nil {
+		encoder.Close()
+		return nil, fmt.Errorf("failed to load dictionary: %w", err)
+	}
+	return &Compression{encoder: encoder, decoder: decoder}, nil
+}
+
+// Bound is the most that compressing size bytes can produce, with zlib at
+// any level or with zstd.
+func Bound(size int) int {
+	return size + size>>8 + 128
+}
+
 func (c *Compression) Compress(data []byte) ([]byte, error) {
+	return c.CompressTo(nil, data)
+}
+
+func (c *Compression) CompressTo(dst, data []byte) ([]byte, error) {
+	return c.AppendCompressed(dst[:0], data)
+}
+
+// AppendCompressed appends the compressed form of data to dst, so a caller
+// can compress straight after a header it has already written.
+func (c *Compression) AppendCompressed(dst, data []byte) ([]byte, error) {
 	if len(data) == 0 {
 		return nil, fmt.Errorf("data cannot be empty")
 	}
+	if c.encoder != nil {
+		return c.encoder.EncodeAll(data, dst), nil
+	}
 
-	var buffer bytes.Buffer
-	writer, err := zlib.NewWriterLevel(&buffer, c.level)
+	w, err := c.writer(dst)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create compressor: %w", err)
 	}
 
-	if _, err := writer.Write(data); err != nil {
+	if _, err := w.zlib.Write(data); err != nil {
 		return nil, fmt.Errorf("failed to compress data: %w", err)
 	}
 
-	if err := writer.Close(); err != nil {
+	if err := w.zlib.Close(); err != nil {
 		return nil, fmt.Errorf("failed to finalize compression: %w", err)
 	}
+	compressed := w.output
+	w.output = nil
+	c.writers.Put(w)
+
+	return compressed, nil
+}
+
+// writer is a pooled zlib writer along with the buffer it appends to.
+type writer struct {
+	zlib   *zlib.Writer
+	output []byte
+}
 
-	return buffer.Bytes(), nil
+func (w *writer) Write(p []byte) (int, error) {
+	w.output = append(w.output, p...)
+	return len(p), nil
+}
+
+func (c *Compression) writer(dst []byte) (*writer, error) {
+	if w, ok := c.writers.Get().(*writer); ok {
+		w.output = dst
+		w.zlib.Reset(w)
+		return w, nil
+	}
+	w := &writer{output: dst}
+	zw, err := zlib.NewWriterLevel(w, c.level)
+	if err != nil {
+		return nil, err
+	}
+	w.zlib = zw
+	return w, nil
 }
 
 func (c *Compression) Decompress(data []byte) ([]byte, error) {
+	return c.DecompressTo(nil, data)
+}
+
+func (c *Compression) DecompressTo(dst, data []byte) ([]byte, error) {
 	if len(data) == 0 {
 		return nil, fmt.Errorf("data cannot be empty")
 	}
+	if c.decoder != nil {
+		decompressed, err := c.decoder.DecodeAll(data, dst[:0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress data: %w", err)
+		}
+		return decompressed, nil
+	}
 
-	reader, err := zlib.NewReader(bytes.NewReader(data))
+	r, err := c.reader(data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create decompressor: %w", err)
 	}
 
-	var buffer bytes.Buffer
-	if _, err := io.Copy(&buffer, reader); err != nil {
-		return nil, fmt.Errorf("failed to decompress data: %w", err)
+	decompressed := dst[:0]
+	for {
+		if len(decompressed) == cap(decompressed) {
+			decompressed = append(decompressed, 0)[:len(decompressed)]
+		}
+		n, err := r.zlib.Read(decompressed[len(decompressed):cap(decompressed)])
+		decompressed = decompressed[:len(decompressed)+n]
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress data: %w", err)
+		}
 	}
 
-	if err := reader.Close(); err != nil {
+	if err := r.zlib.Close(); err != nil {
 		return nil, fmt.Errorf("failed to finalize decompression: %w", err)
 	}
+	r.input.Reset(nil)
+	c.readers.Put(r)
+
+	return decompressed, nil
+}
+
+// reader is a pooled zlib reader along with the input it reads from.
+type reader struct {
+	zlib  io.ReadCloser
+	input bytes.Reader
+}
 
-	return buffer.Bytes(), nil
+func (c *Compression) reader(data []byte) (*reader, error) {
+	if r, ok := c.readers.Get().(*reader); ok {
+		r.input.Reset(data)
+		if err := r.zlib.(zlib.Resetter).Reset(&r.input, nil); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+	r := &reader{}
+	r.input.Reset(data)
+	zr, err := zlib.NewReader(&r.input)
+	if err != nil {
+		return nil, err
+	}
+	r.zlib = zr
+	return r, nil
 }
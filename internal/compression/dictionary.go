@@ -0,0 +1,50 @@
+package compression
+
+import (
+	"fmt"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// DictionarySize is the most content a trained dictionary holds.
+	DictionarySize = 64 * 1024
+
+	// MinDictionarySamples is how many samples training needs to be worth
+	// it; with fewer, the dictionary mostly repeats a few files.
+	MinDictionarySamples = 8
+
+	dictionaryID = 1
+)
+
+// TrainDictionary builds a zstd dictionary from samples, such as the
+// contents of similar small files. The leading bytes of each sample, where
+// formats keep their boilerplate, become the dictionary content, and the
+// entropy tables are fitted to all of them.
+func TrainDictionary(samples [][]byte) ([]byte, error) {
+	if len(samples) < MinDictionarySamples {
+		return nil, fmt.Errorf("need at least %d samples, got %d", MinDictionarySamples, len(samples))
+	}
+
+	share := max(DictionarySize/len(samples), 256)
+	history := make([]byte, 0, DictionarySize)
+	for _, sample := range samples {
+		take := min(len(sample), share, DictionarySize-len(history))
+		history = append(history, sample[:take]...)
+		if len(history) == DictionarySize {
+			break
+		}
+	}
+
+	dict, err := zstd.BuildDict(zstd.BuildDictOptions{
+		ID:       dictionaryID,
+		Contents: samples,
+		History:  history,
+		Offsets:  [3]int{1, 4, 8},
+		Level:    zstd.SpeedFastest,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to train dictionary: %w", err)
+	}
+	return dict, nil
+}
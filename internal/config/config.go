@@ -1,9 +1,11 @@
 package config
 
 const (
-	AppName       = "SweetByte"
-	AppVersion    = "1.0"
-	FileExtension = ".swx"
+	AppName          = "SweetByte"
+	AppVersion       = "1.0"
+	FileExtension    = ".swx"
+	ArchiveExtension = ".swb"
+	IgnoreFile       = ".sweetbyteignore"
 )
 
 var ExcludedPatterns = []string{
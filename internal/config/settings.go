@@ -0,0 +1,248 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hambosto/sweetbyte/internal/tempfile"
+)
+
+const DefaultScrubSchedule = "@weekly"
+
+const (
+	DefaultWatchInterval = "2s"
+	DefaultWatchDebounce = "5s"
+)
+
+const (
+	SettingsEnv  = "SWEETBYTE_CONFIG"
+	TempDirEnv   = "SWEETBYTE_TMPDIR"
+	PasswordEnv  = "SWEETBYTE_PASSWORD"
+	APITokenEnv  = "SWEETBYTE_API_TOKEN"
+	settingsDir  = "sweetbyte"
+	settingsFile = "config.json"
+)
+
+const dedupSaltSize = 32
+
+type Bookmark struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type Tuning struct {
+	ChunkSize      int   `json:"chunk_size,omitempty"`
+	Concurrency    int   `json:"concurrency,omitempty"`
+	MaxMemory      int64 `json:"max_memory,omitempty"`
+	MaxOutstanding int   `json:"max_outstanding_chunks,omitempty"`
+	MaxWorkers     int   `json:"max_workers,omitempty"`
+	RateLimit      int64 `json:"rate_limit,omitempty"`
+}
+
+type TempSettings struct {
+	Dir          string `json:"dir,omitempty"`
+	RequireTmpfs bool   `json:"require_tmpfs,omitempty"`
+}
+
+type ScrubSettings struct {
+	Schedule      string   `json:"schedule,omitempty"`
+	Paths         []string `json:"paths,omitempty"`
+	MetricsFile   string   `json:"metrics_file,omitempty"`
+	NotifyCommand string   `json:"notify_command,omitempty"`
+}
+
+type WatchRule struct {
+	Path         string `json:"path"`
+	Output       string `json:"output,omitempty"`
+	Recipient    string `json:"recipient,omitempty"`
+	Keyfile      string `json:"keyfile,omitempty"`
+	PasswordFile string `json:"password_file,omitempty"`
+	DeleteSource bool   `json:"delete_source,omitempty"`
+}
+
+type WatchSettings struct {
+	Interval string      `json:"interval,omitempty"`
+	Debounce string      `json:"debounce,omitempty"`
+	Rules    []WatchRule `json:"rules,omitempty"`
+}
+
+// DedupSettings holds the secret salt of deterministic encryption, which
+// decides which files deduplicate against each other.
+type DedupSettings struct {
+	Salt string `json:"salt,omitempty"`
+}
+
+type Settings struct {
+	Bookmarks []Bookmark    `json:"bookmarks,omitempty"`
+	Tuning    Tuning        `json:"tuning,omitzero"`
+	Scrub     ScrubSettings `json:"scrub,omitzero"`
+	Watch     WatchSettings `json:"watch,omitzero"`
+	Temp      TempSettings  `json:"temp,omitzero"`
+	Dedup     DedupSettings `json:"dedup,omitzero"`
+
+	path string
+}
+
+func SettingsPath() (string, error) {
+	if path := os.Getenv(SettingsEnv); path != "" {
+		return filepath.Clean(path), nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(dir, settingsDir, settingsFile), nil
+}
+
+func LoadSettings() (*Settings, error) {
+	path, err := SettingsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &Settings{path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settings: %w", err)
+	}
+
+	if err := json.Unmarshal(data, settings); err != nil {
+		return nil, fmt.Errorf("failed to parse settings %s: %w", path, err)
+	}
+	return settings, nil
+}
+
+func LoadTuning() Tuning {
+	settings, err := LoadSettings()
+	if err != nil {
+		return Tuning{}
+	}
+	return settings.Tuning
+}
+
+func LoadTemp() TempSettings {
+	var temp TempSettings
+	if settings, err := LoadSettings(); err == nil {
+		temp = settings.Temp
+	}
+	if dir := os.Getenv(TempDirEnv); dir != "" {
+		temp.Dir = dir
+	}
+	return temp
+}
+
+// LoadDedupSalt returns the salt that deterministic encryption derives its
+// data key with. The first call creates a random one and saves it, so later
+// runs with the same settings produce the same ciphertext.
+func LoadDedupSalt() (salt []byte, created bool, err error) {
+	settings, err := LoadSettings()
+	if err != nil {
+		return nil, false, err
+	}
+	if settings.Dedup.Salt != "" {
+		salt, err := hex.DecodeString(settings.Dedup.Salt)
+		if err != nil || len(salt) == 0 {
+			return nil, false, fmt.Errorf("invalid dedup.salt in %s", settings.path)
+		}
+		return salt, false, nil
+	}
+
+	salt = make([]byte, dedupSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, false, fmt.Errorf("failed to generate dedup salt: %w", err)
+	}
+	settings.Dedup.Salt = hex.EncodeToString(salt)
+	if err := settings.Save(); err != nil {
+		return nil, false, err
+	}
+	return salt, true, nil
+}
+
+func (s *Settings) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode settings: %w", err)
+	}
+
+	f, err := tempfile.CreateAtomic(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to write settings: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Remove()
+		return fmt.Errorf("failed to write settings: %w", err)
+	}
+	if err := f.Commit(); err != nil {
+		return fmt.Errorf("failed to replace settings: %w", err)
+	}
+	return nil
+}
+
+func (s *Settings) Path() string {
+	return s.path
+}
+
+func (s *Settings) Bookmark(name string) (Bookmark, bool) {
+	idx := s.bookmarkIndex(name)
+	if idx < 0 {
+		return Bookmark{}, false
+	}
+	return s.Bookmarks[idx], true
+}
+
+func (s *Settings) AddBookmark(name, path string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("bookmark name cannot be empty")
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return fmt.Errorf("failed to access %s: %w", absPath, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("bookmark target is not a directory: %s", absPath)
+	}
+
+	bookmark := Bookmark{Name: name, Path: absPath}
+	if idx := s.bookmarkIndex(name); idx >= 0 {
+		s.Bookmarks[idx] = bookmark
+		return nil
+	}
+	s.Bookmarks = append(s.Bookmarks, bookmark)
+	return nil
+}
+
+func (s *Settings) RemoveBookmark(name string) bool {
+	idx := s.bookmarkIndex(name)
+	if idx < 0 {
+		return false
+	}
+	s.Bookmarks = slices.Delete(s.Bookmarks, idx, idx+1)
+	return true
+}
+
+func (s *Settings) bookmarkIndex(name string) int {
+	return slices.IndexFunc(s.Bookmarks, func(b Bookmark) bool {
+		return strings.EqualFold(b.Name, name)
+	})
+}
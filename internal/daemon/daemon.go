@@ -0,0 +1,187 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/scrub"
+	"github.com/hambosto/sweetbyte/internal/tempfile"
+)
+
+type Daemon struct {
+	settings config.ScrubSettings
+	schedule *Schedule
+	logger   *slog.Logger
+}
+
+func New(settings config.ScrubSettings, logger *slog.Logger) (*Daemon, error) {
+	if len(settings.Paths) == 0 {
+		return nil, fmt.Errorf("no scrub paths configured")
+	}
+
+	spec := settings.Schedule
+	if spec == "" {
+		spec = config.DefaultScrubSchedule
+	}
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return nil, err
+	}
+	settings.Schedule = spec
+
+	return &Daemon{settings: settings, schedule: schedule, logger: logger}, nil
+}
+
+func (d *Daemon) Run(ctx context.Context, scrubNow bool) error {
+	d.logger.Info("daemon started", "schedule", d.settings.Schedule, "paths", strings.Join(d.settings.Paths, ","))
+
+	if scrubNow {
+		d.Scrub(ctx)
+	}
+
+	for {
+		next := d.schedule.Next(time.Now())
+		if next.IsZero() {
+			return fmt.Errorf("schedule %q never fires", d.settings.Schedule)
+		}
+		d.logger.Info("next scrub scheduled", "at", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			d.logger.Info("daemon stopped")
+			return nil
+		case <-timer.C:
+			d.Scrub(ctx)
+		}
+	}
+}
+
+func (d *Daemon) Scrub(ctx context.Context) scrub.Summary {
+	d.logger.Info("scrub started")
+
+	summary, err := scrub.Paths(ctx, d.settings.Paths, func(r scrub.Report) {
+		switch {
+		case r.Err != nil:
+			d.logger.Error("scrub failed", "path", r.Path, "error", r.Err)
+		case len(r.DamagedChunks) > 0:
+			d.logger.Warn("damaged chunks found", "path", r.Path, "chunks", formatChunks(r.DamagedChunks), "repairable", r.RepairableChunks, "unrecoverable", r.UnrecoverableChunks)
+		default:
+			d.logger.Debug("file ok", "path", r.Path, "chunks", r.Chunks)
+		}
+	})
+	if err != nil {
+		d.logger.Error("scrub aborted", "error", err)
+	}
+
+	d.logger.Info("scrub finished",
+		"files", summary.Files,
+		"damaged_files", summary.DamagedFiles,
+		"damaged_chunks", summary.DamagedChunks,
+		"unrecoverable_chunks", summary.UnrecoverableChunks,
+		"duration", summary.Duration.Round(time.Millisecond),
+	)
+
+	if err := d.writeMetrics(summary, err == nil); err != nil {
+		d.logger.Error("failed to write metrics", "error", err)
+	}
+	if !summary.OK() || err != nil {
+		if err := d.notify(ctx, summary, err); err != nil {
+			d.logger.Error("failed to send notification", "error", err)
+		}
+	}
+
+	return summary
+}
+
+func (d *Daemon) writeMetrics(summary scrub.Summary, completed bool) error {
+	if d.settings.MetricsFile == "" {
+		return nil
+	}
+
+	success := 0
+	if completed && summary.OK() {
+		success = 1
+	}
+
+	var b strings.Builder
+	metric := func(name, help string, value any) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s %v\n", name, help, name, name, value)
+	}
+	metric("sweetbyte_scrub_last_run_timestamp_seconds", "Start time of the last scrub.", summary.Started.Unix())
+	metric("sweetbyte_scrub_duration_seconds", "Duration of the last scrub.", summary.Duration.Seconds())
+	metric("sweetbyte_scrub_files", "Files checked by the last scrub.", summary.Files)
+	metric("sweetbyte_scrub_damaged_files", "Files with damage found by the last scrub.", summary.DamagedFiles)
+	metric("sweetbyte_scrub_damaged_chunks", "Damaged chunks found by the last scrub.", summary.DamagedChunks)
+	metric("sweetbyte_scrub_success", "Whether the last scrub completed without finding damage.", success)
+
+	f, err := tempfile.CreateAtomic(filepath.Clean(d.settings.MetricsFile))
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		_ = f.Remove()
+		return err
+	}
+	if err := f.Chmod(0o644); err != nil {
+		_ = f.Remove()
+		return err
+	}
+	return f.Commit()
+}
+
+func (d *Daemon) notify(ctx context.Context, summary scrub.Summary, scrubErr error) error {
+	if d.settings.NotifyCommand == "" {
+		return nil
+	}
+
+	message := fmt.Sprintf("sweetbyte scrub: %d of %d files damaged (%d chunks)", summary.DamagedFiles, summary.Files, summary.DamagedChunks)
+	if scrubErr != nil {
+		message = fmt.Sprintf("sweetbyte scrub aborted: %v", scrubErr)
+	}
+
+	var damaged []string
+	for _, r := range summary.Reports {
+		if !r.OK() {
+			damaged = append(damaged, r.Path)
+		}
+	}
+
+	cmd := shellCommand(ctx, d.settings.NotifyCommand)
+	cmd.Env = append(os.Environ(),
+		"SWEETBYTE_SCRUB_MESSAGE="+message,
+		"SWEETBYTE_SCRUB_FILES="+strconv.Itoa(summary.Files),
+		"SWEETBYTE_SCRUB_DAMAGED_FILES="+strconv.Itoa(summary.DamagedFiles),
+		"SWEETBYTE_SCRUB_DAMAGED_CHUNKS="+strconv.Itoa(summary.DamagedChunks),
+		"SWEETBYTE_SCRUB_DAMAGED_PATHS="+strings.Join(damaged, string(os.PathListSeparator)),
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+func formatChunks(chunks []uint64) string {
+	parts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		parts[i] = strconv.FormatUint(chunk, 10)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,162 @@
+package daemon
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type field struct {
+	min, max int
+	bits     uint64
+	any      bool
+}
+
+type Schedule struct {
+	minute field
+	hour   field
+	dom    field
+	month  field
+	dow    field
+}
+
+var scheduleMacros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+}
+
+func ParseSchedule(spec string) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if macro, ok := scheduleMacros[strings.ToLower(spec)]; ok {
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields (minute hour day month weekday)", spec)
+	}
+
+	s := &Schedule{
+		minute: field{min: 0, max: 59},
+		hour:   field{min: 0, max: 23},
+		dom:    field{min: 1, max: 31},
+		month:  field{min: 1, max: 12},
+		dow:    field{min: 0, max: 7},
+	}
+
+	for i, f := range []*field{&s.minute, &s.hour, &s.dom, &s.month, &s.dow} {
+		if err := f.parse(fields[i]); err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+	}
+
+	if s.dow.bits&(1<<7) != 0 {
+		s.dow.bits |= 1
+	}
+
+	return s, nil
+}
+
+func (f *field) parse(expr string) error {
+	f.any = expr == "*"
+	for part := range strings.SplitSeq(expr, ",") {
+		if err := f.parsePart(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *field) parsePart(part string) error {
+	rangeExpr, stepExpr, hasStep := strings.Cut(part, "/")
+
+	step := 1
+	if hasStep {
+		n, err := strconv.Atoi(stepExpr)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid step %q", stepExpr)
+		}
+		step = n
+	}
+
+	low, high := f.min, f.max
+	if rangeExpr != "*" {
+		lowExpr, highExpr, isRange := strings.Cut(rangeExpr, "-")
+
+		var err error
+		if low, err = f.value(lowExpr); err != nil {
+			return err
+		}
+		high = low
+		if isRange {
+			if high, err = f.value(highExpr); err != nil {
+				return err
+			}
+		} else if hasStep {
+			high = f.max
+		}
+		if low > high {
+			return fmt.Errorf("invalid range %q", rangeExpr)
+		}
+	}
+
+	for v := low; v <= high; v += step {
+		f.bits |= 1 << v
+	}
+	return nil
+}
+
+func (f *field) value(expr string) (int, error) {
+	n, err := strconv.Atoi(expr)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("value %q out of range %d-%d", expr, f.min, f.max)
+	}
+	return n, nil
+}
+
+func (f *field) has(v int) bool {
+	return f.bits&(1<<v) != 0
+}
+
+func (s *Schedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case !s.month.has(int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !s.hour.has(t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !s.minute.has(t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom.has(t.Day())
+	dowMatch := s.dow.has(int(t.Weekday()))
+
+	switch {
+	case s.dom.any && s.dow.any:
+		return true
+	case s.dom.any:
+		return dowMatch
+	case s.dow.any:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
@@ -1,9 +1,12 @@
 package derive
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha512"
 	"fmt"
 	"io"
+	"strings"
 
 	"golang.org/x/crypto/argon2"
 )
@@ -16,7 +19,76 @@ const (
 	ArgonSaltLen = 32
 )
 
+const (
+	ProfileLight    = "light"
+	ProfileDefault  = "default"
+	ProfileParanoid = "paranoid"
+)
+
+const (
+	maxTime   = 16
+	minMemory = 8 * 1024
+	maxMemory = 4 * 1024 * 1024
+)
+
+type Params struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+var profiles = map[string]Params{
+	ProfileLight:    {Time: 2, Memory: 19 * 1024, Threads: 1},
+	ProfileDefault:  {Time: ArgonTime, Memory: ArgonMemory, Threads: ArgonThreads},
+	ProfileParanoid: {Time: 4, Memory: 1024 * 1024, Threads: 4},
+}
+
+func ProfileNames() []string {
+	return []string{ProfileLight, ProfileDefault, ProfileParanoid}
+}
+
+func ProfileParams(name string) (Params, error) {
+	if name == "" {
+		name = ProfileDefault
+	}
+	params, ok := profiles[name]
+	if !ok {
+		return Params{}, fmt.Errorf("unknown KDF profile %q (choose %s)", name, strings.Join(ProfileNames(), ", "))
+	}
+	return params, nil
+}
+
+func ProfileName(params Params) (string, bool) {
+	for _, name := range ProfileNames() {
+		if profiles[name] == params {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func DefaultParams() Params {
+	return profiles[ProfileDefault]
+}
+
+func (p Params) Validate() error {
+	if p.Time == 0 || p.Time > maxTime {
+		return fmt.Errorf("argon2 time %d out of range 1-%d", p.Time, maxTime)
+	}
+	if p.Memory < minMemory || p.Memory > maxMemory {
+		return fmt.Errorf("argon2 memory %d KiB out of range %d-%d KiB", p.Memory, minMemory, maxMemory)
+	}
+	if p.Threads == 0 {
+		return fmt.Errorf("argon2 threads cannot be zero")
+	}
+	return nil
+}
+
 func Hash(password, salt []byte) ([]byte, error) {
+	return HashWithParams(password, salt, DefaultParams())
+}
+
+func HashWithParams(password, salt []byte, params Params) ([]byte, error) {
 	if len(password) == 0 {
 		return nil, fmt.Errorf("password cannot be empty")
 	}
@@ -25,10 +97,24 @@ func Hash(password, salt []byte) ([]byte, error) {
 		return nil, fmt.Errorf("expected %d bytes, got %d", ArgonSaltLen, len(salt))
 	}
 
-	key := argon2.IDKey(password, salt, ArgonTime, ArgonMemory, ArgonThreads, ArgonKeyLen)
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey(password, salt, params.Time, params.Memory, params.Threads, ArgonKeyLen)
 	return key, nil
 }
 
+func CombineKeyfile(password, keyfile []byte) []byte {
+	if len(keyfile) == 0 {
+		return password
+	}
+
+	mac := hmac.New(sha512.New, keyfile)
+	mac.Write(password)
+	return mac.Sum(nil)
+}
+
 func GetRandomBytes(size int) ([]byte, error) {
 	salt := make([]byte, size)
 	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
@@ -0,0 +1,79 @@
+package derive
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// KeyProvider is a hardware token that answers a challenge with a secret
+// response. The response is mixed into the key derived from the password,
+// so the file only opens with the token present.
+type KeyProvider interface {
+	// Spec names the provider and its settings, e.g. "yubikey:2". It is
+	// recorded in the header so decryption can find the same provider.
+	Spec() string
+	Respond(challenge []byte) ([]byte, error)
+}
+
+// ProviderFactory builds a provider from the part of its spec after the
+// colon, which is empty when the spec is just the name.
+type ProviderFactory func(arg string) (KeyProvider, error)
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]ProviderFactory{
+		ProviderYubiKey: newYubiKey,
+		ProviderFIDO2:   newFIDO2,
+	}
+)
+
+func RegisterProvider(name string, factory ProviderFactory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[name] = factory
+}
+
+func ProviderNames() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func ParseProvider(spec string) (KeyProvider, error) {
+	name, arg, _ := strings.Cut(spec, ":")
+
+	providersMu.RLock()
+	factory, ok := providers[name]
+	providersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown key provider %q (choose %s)", name, strings.Join(ProviderNames(), ", "))
+	}
+	return factory(arg)
+}
+
+// ProviderChallenge is the challenge sent to the token for a file. It is
+// bound to the KDF salt, so every file gets a different response.
+func ProviderChallenge(salt []byte) []byte {
+	mac := hmac.New(sha256.New, []byte("sweetbyte key provider"))
+	mac.Write(salt)
+	return mac.Sum(nil)
+}
+
+// MixProvider combines the key derived from the password with the token's
+// response into the key that wraps the data key.
+func MixProvider(key, response []byte) []byte {
+	mac := hmac.New(sha512.New, response)
+	mac.Write(key)
+	return mac.Sum(nil)[:len(key)]
+}
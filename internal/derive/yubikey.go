@@ -0,0 +1,52 @@
+package derive
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+const (
+	ProviderYubiKey = "yubikey"
+
+	yubiKeyDefaultSlot = 2
+)
+
+// yubiKey answers with the HMAC-SHA1 challenge-response configured in one of
+// the two OTP slots of a YubiKey, through ykchalresp from yubikey-personalization.
+type yubiKey struct {
+	slot int
+}
+
+func newYubiKey(arg string) (KeyProvider, error) {
+	if arg == "" {
+		return &yubiKey{slot: yubiKeyDefaultSlot}, nil
+	}
+	slot, err := strconv.Atoi(arg)
+	if err != nil || slot < 1 || slot > 2 {
+		return nil, fmt.Errorf("yubikey slot must be 1 or 2, got %q", arg)
+	}
+	return &yubiKey{slot: slot}, nil
+}
+
+func (y *yubiKey) Spec() string {
+	return fmt.Sprintf("%s:%d", ProviderYubiKey, y.slot)
+}
+
+func (y *yubiKey) Respond(challenge []byte) ([]byte, error) {
+	cmd := exec.Command("ykchalresp", "-"+strconv.Itoa(y.slot), "-x", hex.EncodeToString(challenge))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("yubikey challenge-response failed (touch the key if it blinks): %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	response, err := hex.DecodeString(string(bytes.TrimSpace(out)))
+	if err != nil || len(response) == 0 {
+		return nil, fmt.Errorf("unexpected ykchalresp output")
+	}
+	return response, nil
+}
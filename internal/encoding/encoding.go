@@ -1,8 +1,11 @@
 package encoding
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"slices"
+	"sync"
 
 	"github.com/klauspost/reedsolomon"
 )
@@ -16,6 +19,12 @@ type Encoding struct {
 	encoder      reedsolomon.Encoder
 	dataShards   int
 	parityShards int
+
+	// shards keeps the slices that chunks are split into, so encoding and
+	// decoding a chunk does not allocate one.
+	shards sync.Pool
+	// parity keeps the buffers that Verify recomputes parity into.
+	parity sync.Pool
 }
 
 func NewEncoding(dataShards, parityShards int) (*Encoding, error) {
@@ -32,6 +41,12 @@ func NewEncoding(dataShards, parityShards int) (*Encoding, error) {
 }
 
 func (e *Encoding) Encode(data []byte) ([]byte, error) {
+	return e.EncodeTo(nil, data)
+}
+
+// EncodeTo writes data followed by its parity shards to dst. dst may be
+// data itself, which then grows in place when its capacity allows.
+func (e *Encoding) EncodeTo(dst, data []byte) ([]byte, error) {
 	if len(data) == 0 {
 		return nil, errors.New("empty input")
 	}
@@ -39,64 +54,212 @@ func (e *Encoding) Encode(data []byte) ([]byte, error) {
 	shardSize := (len(data) + e.dataShards - 1) / e.dataShards
 	totalShards := e.dataShards + e.parityShards
 
-	shards := make([][]byte, totalShards)
+	dst = slices.Grow(dst[:0], shardSize*totalShards)[:shardSize*totalShards]
+	copy(dst, data)
+	clear(dst[len(data) : shardSize*e.dataShards])
 
-	for i := 0; i < e.dataShards; i++ {
-		shards[i] = make([]byte, shardSize)
+	shards := e.pooledSplit(dst, shardSize)
+	defer e.release(shards)
+	if err := e.encoder.Encode(*shards); err != nil {
+		return nil, err
+	}
 
-		start := i * shardSize
-		end := min(start+shardSize, len(data))
+	return dst, nil
+}
 
-		if start < len(data) {
-			copy(shards[i], data[start:end])
-		}
+func (e *Encoding) Decode(encoded []byte) ([]byte, error) {
+	if len(encoded) == 0 {
+		return nil, errors.New("empty encoded data")
 	}
 
-	for i := e.dataShards; i < totalShards; i++ {
-		shards[i] = make([]byte, shardSize)
+	totalShards := e.dataShards + e.parityShards
+
+	if len(encoded)%totalShards != 0 {
+		return nil, fmt.Errorf("invalid encoded length: %d not divisible by shards (%d)", len(encoded), totalShards)
 	}
 
-	if err := e.encoder.Encode(shards); err != nil {
+	shardSize := len(encoded) / totalShards
+
+	shards := e.pooledSplit(encoded, shardSize)
+	defer e.release(shards)
+	if err := e.encoder.Reconstruct(*shards); err != nil {
 		return nil, err
 	}
 
-	result := make([]byte, 0, shardSize*totalShards)
-	for _, shard := range shards {
-		result = append(result, shard...)
+	return encoded[:e.dataShards*shardSize], nil
+}
+
+// Data returns the data shards of encoded, which hold what was encoded
+// followed by zero padding, without checking or repairing them.
+func (e *Encoding) Data(encoded []byte) []byte {
+	totalShards := e.dataShards + e.parityShards
+	return encoded[:len(encoded)/totalShards*e.dataShards]
+}
+
+func (e *Encoding) Verify(encoded []byte) (bool, error) {
+	totalShards := e.dataShards + e.parityShards
+	if len(encoded) == 0 || len(encoded)%totalShards != 0 {
+		return false, nil
+	}
+
+	shardSize := len(encoded) / totalShards
+	shards := e.pooledSplit(encoded, shardSize)
+	defer e.release(shards)
+
+	// The parity is recomputed into a pooled buffer and compared, which
+	// unlike the encoder's own Verify does not allocate for every chunk.
+	scratch, ok := e.parity.Get().(*[]byte)
+	if !ok {
+		scratch = new([]byte)
+	}
+	defer e.parity.Put(scratch)
+	*scratch = slices.Grow((*scratch)[:0], shardSize*e.parityShards)[:shardSize*e.parityShards]
+
+	parity := encoded[e.dataShards*shardSize:]
+	splitInto((*shards)[e.dataShards:], *scratch, shardSize)
+	if err := e.encoder.Encode(*shards); err != nil {
+		return false, err
 	}
+	return bytes.Equal(*scratch, parity), nil
+}
 
-	return result, nil
+func (e *Encoding) split(data []byte, shardSize int) [][]byte {
+	shards := make([][]byte, e.dataShards+e.parityShards)
+	splitInto(shards, data, shardSize)
+	return shards
 }
 
-func (e *Encoding) Decode(encoded []byte) ([]byte, error) {
-	if len(encoded) == 0 {
-		return nil, errors.New("empty encoded data")
+// pooledSplit is split into a pooled slice, which release returns.
+func (e *Encoding) pooledSplit(data []byte, shardSize int) *[][]byte {
+	shards, ok := e.shards.Get().(*[][]byte)
+	if !ok {
+		s := make([][]byte, e.dataShards+e.parityShards)
+		shards = &s
 	}
+	splitInto(*shards, data, shardSize)
+	return shards
+}
 
-	totalShards := e.dataShards + e.parityShards
+func (e *Encoding) release(shards *[][]byte) {
+	clear(*shards)
+	e.shards.Put(shards)
+}
 
-	if len(encoded)%totalShards != 0 {
-		return nil, fmt.Errorf("invalid encoded length: %d not divisible by shards (%d)", len(encoded), totalShards)
+func splitInto(shards [][]byte, data []byte, shardSize int) {
+	for i := range shards {
+		shards[i] = data[i*shardSize : (i+1)*shardSize : (i+1)*shardSize]
 	}
+}
 
-	shardSize := len(encoded) / totalShards
+type Status int
+
+const (
+	StatusIntact Status = iota
+	StatusRepairable
+	StatusUnrecoverable
+)
+
+var statusNames = map[Status]string{
+	StatusIntact:        "intact",
+	StatusRepairable:    "repairable",
+	StatusUnrecoverable: "unrecoverable",
+}
+
+func (s Status) String() string {
+	return statusNames[s]
+}
+
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+type Diagnosis struct {
+	Status        Status
+	Shards        int
+	ShardSize     int
+	DamagedShards []int
+}
 
-	shards := make([][]byte, totalShards)
-	for i := range totalShards {
-		start := i * shardSize
-		end := start + shardSize
-		shards[i] = make([]byte, shardSize)
-		copy(shards[i], encoded[start:end])
+func (e *Encoding) Diagnose(encoded []byte) Diagnosis {
+	diagnosis, _ := e.locate(encoded)
+	return diagnosis
+}
+
+func (e *Encoding) Repair(encoded []byte) Diagnosis {
+	diagnosis, shards := e.locate(encoded)
+	if diagnosis.Status == StatusRepairable {
+		for _, i := range diagnosis.DamagedShards {
+			copy(encoded[i*diagnosis.ShardSize:(i+1)*diagnosis.ShardSize], shards[i])
+		}
 	}
+	return diagnosis
+}
 
-	if err := e.encoder.Reconstruct(shards); err != nil {
-		return nil, err
+func (e *Encoding) locate(encoded []byte) (Diagnosis, [][]byte) {
+	totalShards := e.dataShards + e.parityShards
+	diagnosis := Diagnosis{Status: StatusUnrecoverable, Shards: totalShards}
+	if len(encoded) == 0 || len(encoded)%totalShards != 0 {
+		return diagnosis, nil
 	}
 
-	result := make([]byte, 0, e.dataShards*shardSize)
-	for i := 0; i < e.dataShards; i++ {
-		result = append(result, shards[i]...)
+	shardSize := len(encoded) / totalShards
+	diagnosis.ShardSize = shardSize
+
+	if ok, err := e.Verify(encoded); err == nil && ok {
+		diagnosis.Status = StatusIntact
+		return diagnosis, nil
+	}
+
+	shards := e.split(encoded, shardSize)
+
+	candidate := make([][]byte, totalShards)
+	for damaged := 1; damaged <= e.parityShards/2; damaged++ {
+		for erased := range combinations(totalShards, damaged) {
+			for i := range shards {
+				candidate[i] = shards[i]
+			}
+			for _, i := range erased {
+				candidate[i] = nil
+			}
+
+			if err := e.encoder.Reconstruct(candidate); err != nil {
+				continue
+			}
+			if ok, err := e.encoder.Verify(candidate); err == nil && ok {
+				diagnosis.Status = StatusRepairable
+				diagnosis.DamagedShards = slices.Clone(erased)
+				return diagnosis, candidate
+			}
+		}
 	}
 
-	return result, nil
+	return diagnosis, nil
+}
+
+func combinations(n, k int) func(yield func([]int) bool) {
+	return func(yield func([]int) bool) {
+		indices := make([]int, k)
+		for i := range indices {
+			indices[i] = i
+		}
+
+		for {
+			if !yield(indices) {
+				return
+			}
+
+			i := k - 1
+			for i >= 0 && indices[i] == n-k+i {
+				i--
+			}
+			if i < 0 {
+				return
+			}
+
+			indices[i]++
+			for j := i + 1; j < k; j++ {
+				indices[j] = indices[j-1] + 1
+			}
+		}
+	}
 }
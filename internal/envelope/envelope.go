@@ -0,0 +1,93 @@
+package envelope
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/hambosto/sweetbyte/internal/cipher/algorithm"
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/errors"
+)
+
+const DataKeySize = derive.ArgonKeyLen
+
+var (
+	ErrUnwrap = errors.Sentinel("failed to unwrap data key")
+	ErrOpen   = errors.Sentinel("failed to open sealed header field")
+)
+
+func NewDataKey() ([]byte, error) {
+	return derive.GetRandomBytes(DataKeySize)
+}
+
+func Wrap(kek, dek []byte) ([]byte, error) {
+	if len(dek) != DataKeySize {
+		return nil, fmt.Errorf("data key must be %d bytes, got %d", DataKeySize, len(dek))
+	}
+
+	wrapping, err := newWrapping(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := wrapping.Encrypt(dek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return wrapped, nil
+}
+
+func Unwrap(kek, wrapped []byte) ([]byte, error) {
+	wrapping, err := newWrapping(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	dek, err := wrapping.Decrypt(wrapped)
+	if err != nil || len(dek) != DataKeySize {
+		return nil, ErrUnwrap
+	}
+	return dek, nil
+}
+
+func newWrapping(kek []byte) (*algorithm.ChaCha20Cipher, error) {
+	if len(kek) < algorithm.ChaChaKeySize {
+		return nil, fmt.Errorf("key encryption key must be at least %d bytes, got %d", algorithm.ChaChaKeySize, len(kek))
+	}
+	return algorithm.NewChaCha20Cipher(kek[:algorithm.ChaChaKeySize])
+}
+
+func Seal(key []byte, info string, plaintext []byte) ([]byte, error) {
+	sealing, err := newSealing(key, info)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := sealing.Encrypt(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal %s: %w", info, err)
+	}
+	return sealed, nil
+}
+
+func Open(key []byte, info string, sealed []byte) ([]byte, error) {
+	sealing, err := newSealing(key, info)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := sealing.Decrypt(sealed)
+	if err != nil {
+		return nil, ErrOpen
+	}
+	return plaintext, nil
+}
+
+func newSealing(key []byte, info string) (*algorithm.ChaCha20Cipher, error) {
+	subkey, err := hkdf.Key(sha256.New, key, nil, info, algorithm.ChaChaKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive %s key: %w", info, err)
+	}
+	return algorithm.NewChaCha20Cipher(subkey)
+}
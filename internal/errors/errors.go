@@ -0,0 +1,268 @@
+package errors
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"strings"
+)
+
+type Code int
+
+const (
+	CodeUnknown Code = iota
+	CodeInvalidInput
+	CodeNotFound
+	CodeExists
+	CodeIO
+	CodeAuthentication
+	CodeCorrupt
+	CodeCanceled
+	CodeUnsupported
+)
+
+const (
+	NoChunk  = -1
+	NoOffset = -1
+)
+
+var codeNames = map[Code]string{
+	CodeUnknown:        "unknown",
+	CodeInvalidInput:   "invalid_input",
+	CodeNotFound:       "not_found",
+	CodeExists:         "exists",
+	CodeIO:             "io",
+	CodeAuthentication: "authentication",
+	CodeCorrupt:        "corrupt",
+	CodeCanceled:       "canceled",
+	CodeUnsupported:    "unsupported",
+}
+
+var exitCodes = map[Code]int{
+	CodeUnknown:        1,
+	CodeInvalidInput:   2,
+	CodeNotFound:       3,
+	CodeExists:         4,
+	CodeIO:             5,
+	CodeAuthentication: 6,
+	CodeCorrupt:        7,
+	CodeCanceled:       130,
+	CodeUnsupported:    8,
+}
+
+func (c Code) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return codeNames[CodeUnknown]
+}
+
+func (c Code) ExitCode() int {
+	if code, ok := exitCodes[c]; ok {
+		return code
+	}
+	return exitCodes[CodeUnknown]
+}
+
+type Error struct {
+	Code      Code
+	Op        string
+	Path      string
+	Chunk     int64
+	Offset    int64
+	Recovered int64
+	Err       error
+}
+
+func New(code Code, op string, err error) *Error {
+	return &Error{Code: code, Op: op, Chunk: NoChunk, Offset: NoOffset, Recovered: NoOffset, Err: err}
+}
+
+func Newf(code Code, op, format string, args ...any) *Error {
+	return New(code, op, fmt.Errorf(format, args...))
+}
+
+func (e *Error) WithPath(path string) *Error {
+	e.Path = path
+	return e
+}
+
+func (e *Error) WithChunk(index uint64) *Error {
+	e.Chunk = int64(index)
+	return e
+}
+
+func (e *Error) WithOffset(offset int64) *Error {
+	e.Offset = offset
+	return e
+}
+
+func (e *Error) WithRecovered(size int64) *Error {
+	e.Recovered = size
+	return e
+}
+
+func (e *Error) Error() string {
+	parts := make([]string, 0, 3)
+	if e.Op != "" {
+		parts = append(parts, e.Op)
+	}
+	if e.Path != "" {
+		parts = append(parts, e.Path)
+	}
+	if e.Chunk != NoChunk {
+		location := fmt.Sprintf("chunk %d", e.Chunk)
+		if e.Offset != NoOffset {
+			location += fmt.Sprintf(" at byte %d", e.Offset)
+		}
+		if len(parts) == 0 {
+			parts = append(parts, location)
+		} else {
+			parts = append(parts, "("+location+")")
+		}
+	}
+
+	prefix := strings.Join(parts, " ")
+	var message string
+	switch {
+	case e.Err == nil:
+		message = prefix
+	case prefix == "":
+		message = e.Err.Error()
+	default:
+		message = prefix + ": " + e.Err.Error()
+	}
+
+	if e.Recovered != NoOffset {
+		message += fmt.Sprintf("; %d bytes recovered before the failure", e.Recovered)
+	}
+	return message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newReport(e))
+}
+
+type report struct {
+	Code      string `json:"code"`
+	Op        string `json:"op,omitempty"`
+	Path      string `json:"path,omitempty"`
+	Chunk     *int64 `json:"chunk,omitempty"`
+	Offset    *int64 `json:"offset,omitempty"`
+	Recovered *int64 `json:"recovered,omitempty"`
+	Message   string `json:"message"`
+}
+
+func newReport(err error) report {
+	r := report{
+		Code:    CodeOf(err).String(),
+		Path:    PathOf(err),
+		Message: err.Error(),
+	}
+
+	var e *Error
+	if stderrors.As(err, &e) {
+		r.Op = e.Op
+	}
+	if chunk := ChunkOf(err); chunk != NoChunk {
+		r.Chunk = &chunk
+	}
+	if offset := OffsetOf(err); offset != NoOffset {
+		r.Offset = &offset
+	}
+	if recovered := RecoveredOf(err); recovered != NoOffset {
+		r.Recovered = &recovered
+	}
+	return r
+}
+
+func CodeOf(err error) Code {
+	for e := range chain(err) {
+		if e.Code != CodeUnknown {
+			return e.Code
+		}
+	}
+	return CodeUnknown
+}
+
+func PathOf(err error) string {
+	for e := range chain(err) {
+		if e.Path != "" {
+			return e.Path
+		}
+	}
+	return ""
+}
+
+func ChunkOf(err error) int64 {
+	for e := range chain(err) {
+		if e.Chunk != NoChunk {
+			return e.Chunk
+		}
+	}
+	return NoChunk
+}
+
+func OffsetOf(err error) int64 {
+	for e := range chain(err) {
+		if e.Offset != NoOffset {
+			return e.Offset
+		}
+	}
+	return NoOffset
+}
+
+func RecoveredOf(err error) int64 {
+	for e := range chain(err) {
+		if e.Recovered != NoOffset {
+			return e.Recovered
+		}
+	}
+	return NoOffset
+}
+
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	return CodeOf(err).ExitCode()
+}
+
+func JSON(err error) []byte {
+	data, marshalErr := json.Marshal(newReport(err))
+	if marshalErr != nil {
+		return []byte(fmt.Sprintf(`{"code":%q,"message":%q}`, CodeOf(err), err.Error()))
+	}
+	return data
+}
+
+func Sentinel(text string) error {
+	return stderrors.New(text)
+}
+
+func Is(err, target error) bool {
+	return stderrors.Is(err, target)
+}
+
+func As(err error, target any) bool {
+	return stderrors.As(err, target)
+}
+
+func chain(err error) func(yield func(*Error) bool) {
+	return func(yield func(*Error) bool) {
+		for err != nil {
+			var e *Error
+			if !stderrors.As(err, &e) {
+				return
+			}
+			if !yield(e) {
+				return
+			}
+			err = e.Err
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package escrow
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/hambosto/sweetbyte/internal/envelope"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+)
+
+const (
+	FileExtension = ".escrow"
+
+	publicKeyType  = "SWEETBYTE ESCROW PUBLIC KEY"
+	privateKeyType = "SWEETBYTE ESCROW PRIVATE KEY"
+	blobType       = "SWEETBYTE ESCROW"
+
+	magic        = "SWES"
+	version      = 1
+	keySize      = 32
+	fileIDSize   = 32
+	blobOverhead = len(magic) + 1 + keySize + fileIDSize
+	wrapInfo     = "sweetbyte escrow v1"
+)
+
+var (
+	ErrInvalidKey  = errors.Sentinel("not a sweetbyte escrow key")
+	ErrInvalidBlob = errors.Sentinel("not a sweetbyte escrow blob")
+	ErrWrongFile   = errors.Sentinel("escrow blob belongs to a different file")
+	ErrWrongKey    = errors.Sentinel("escrow blob was not made for this recovery key")
+)
+
+type Blob struct {
+	FileID     []byte
+	ephemeral  []byte
+	wrappedKey []byte
+}
+
+func GenerateKeyPair() (*ecdh.PrivateKey, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate recovery key: %w", err)
+	}
+	return key, nil
+}
+
+func WriteKeyPair(prefix string, key *ecdh.PrivateKey) (string, string, error) {
+	publicPath, privatePath := prefix+".pub", prefix+".key"
+	if err := file.WritePEM(privatePath, privateKeyType, key.Bytes()); err != nil {
+		return "", "", err
+	}
+	if err := file.WritePEM(publicPath, publicKeyType, key.PublicKey().Bytes()); err != nil {
+		return "", "", err
+	}
+	return publicPath, privatePath, nil
+}
+
+func ReadPublicKey(path string) (*ecdh.PublicKey, error) {
+	data, err := file.ReadPEM(path, publicKeyType, ErrInvalidKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdh.X25519().NewPublicKey(data)
+	if err != nil {
+		return nil, errors.New(errors.CodeInvalidInput, "read recovery key", ErrInvalidKey).WithPath(path)
+	}
+	return key, nil
+}
+
+func ReadPrivateKey(path string) (*ecdh.PrivateKey, error) {
+	data, err := file.ReadPEM(path, privateKeyType, ErrInvalidKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdh.X25519().NewPrivateKey(data)
+	if err != nil {
+		return nil, errors.New(errors.CodeInvalidInput, "read recovery key", ErrInvalidKey).WithPath(path)
+	}
+	return key, nil
+}
+
+func Seal(recipient *ecdh.PublicKey, fileID, dataKey []byte) (*Blob, error) {
+	if len(fileID) != fileIDSize {
+		return nil, fmt.Errorf("file ID must be %d bytes, got %d", fileIDSize, len(fileID))
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	wrappingKey, err := deriveWrappingKey(ephemeral, recipient, ephemeral.PublicKey().Bytes(), fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := envelope.Wrap(wrappingKey, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Blob{
+		FileID:     bytes.Clone(fileID),
+		ephemeral:  ephemeral.PublicKey().Bytes(),
+		wrappedKey: wrappedKey,
+	}, nil
+}
+
+func (b *Blob) Open(key *ecdh.PrivateKey) ([]byte, error) {
+	ephemeral, err := ecdh.X25519().NewPublicKey(b.ephemeral)
+	if err != nil {
+		return nil, ErrInvalidBlob
+	}
+
+	wrappingKey, err := deriveWrappingKey(key, ephemeral, b.ephemeral, b.FileID)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := envelope.Unwrap(wrappingKey, b.wrappedKey)
+	if err != nil {
+		return nil, errors.New(errors.CodeAuthentication, "open escrow blob", ErrWrongKey)
+	}
+	return dataKey, nil
+}
+
+func (b *Blob) Write(path string) error {
+	data := make([]byte, 0, blobOverhead+len(b.wrappedKey))
+	data = append(data, magic...)
+	data = append(data, version)
+	data = append(data, b.ephemeral...)
+	data = append(data, b.FileID...)
+	data = append(data, b.wrappedKey...)
+	return file.WritePEM(path, blobType, data)
+}
+
+func ReadBlob(path string) (*Blob, error) {
+	data, err := file.ReadPEM(path, blobType, ErrInvalidBlob)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) <= blobOverhead || string(data[:len(magic)]) != magic || data[len(magic)] != version {
+		return nil, errors.New(errors.CodeInvalidInput, "read escrow blob", ErrInvalidBlob).WithPath(path)
+	}
+
+	offset := len(magic) + 1
+	return &Blob{
+		ephemeral:  bytes.Clone(data[offset : offset+keySize]),
+		FileID:     bytes.Clone(data[offset+keySize : offset+keySize+fileIDSize]),
+		wrappedKey: bytes.Clone(data[blobOverhead:]),
+	}, nil
+}
+
+func deriveWrappingKey(private *ecdh.PrivateKey, public *ecdh.PublicKey, ephemeral, fileID []byte) ([]byte, error) {
+	shared, err := private.ECDH(public)
+	if err != nil {
+		return nil, fmt.Errorf("key agreement failed: %w", err)
+	}
+
+	salt := append(bytes.Clone(ephemeral), fileID...)
+	return hkdf.Key(sha256.New, shared, salt, wrapInfo, keySize)
+}
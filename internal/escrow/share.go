@@ -0,0 +1,123 @@
+package escrow
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/shamir"
+)
+
+const (
+	ShareExtension = ".share"
+
+	shareType     = "SWEETBYTE KEY SHARE"
+	shareMagic    = "SWSH"
+	shareVersion  = 1
+	shareOverhead = len(shareMagic) + 1 + 2 + fileIDSize
+)
+
+var (
+	ErrInvalidShare   = errors.Sentinel("not a sweetbyte key share")
+	ErrMixedShares    = errors.Sentinel("key shares belong to different files or splits")
+	ErrShareWrongFile = errors.Sentinel("key share belongs to a different file")
+	ErrTooFewShares   = errors.Sentinel("not enough key shares")
+	ErrSharesMismatch = errors.Sentinel("key shares do not rebuild this file's data key")
+)
+
+// Share is one Shamir share of a file's data key. Threshold of the Total
+// shares made for FileID rebuild the key.
+type Share struct {
+	FileID    []byte
+	Threshold int
+	Total     int
+	value     []byte
+}
+
+func (s *Share) Index() int {
+	return int(s.value[0])
+}
+
+func SplitKey(fileID, dataKey []byte, threshold, total int) ([]*Share, error) {
+	if len(fileID) != fileIDSize {
+		return nil, fmt.Errorf("file ID must be %d bytes, got %d", fileIDSize, len(fileID))
+	}
+
+	values, err := shamir.Split(dataKey, threshold, total)
+	if err != nil {
+		return nil, errors.New(errors.CodeInvalidInput, "split key", err)
+	}
+
+	shares := make([]*Share, len(values))
+	for i, value := range values {
+		shares[i] = &Share{FileID: bytes.Clone(fileID), Threshold: threshold, Total: total, value: value}
+	}
+	return shares, nil
+}
+
+// CombineShares rebuilds the data key. The caller must still check it against
+// the file header, since shares from the same split always combine to
+// something.
+func CombineShares(shares []*Share) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New(errors.CodeInvalidInput, "combine shares", ErrTooFewShares)
+	}
+
+	first := shares[0]
+	values := make([][]byte, len(shares))
+	for i, share := range shares {
+		if !bytes.Equal(share.FileID, first.FileID) || share.Threshold != first.Threshold || share.Total != first.Total {
+			return nil, errors.New(errors.CodeInvalidInput, "combine shares", ErrMixedShares)
+		}
+		values[i] = share.value
+	}
+	if len(shares) < first.Threshold {
+		return nil, errors.Newf(errors.CodeInvalidInput, "combine shares", "%w: have %d, need %d of %d", ErrTooFewShares, len(shares), first.Threshold, first.Total)
+	}
+
+	key, err := shamir.Combine(values)
+	if err != nil {
+		return nil, errors.New(errors.CodeInvalidInput, "combine shares", err)
+	}
+	return key, nil
+}
+
+func (s *Share) Marshal() []byte {
+	data := make([]byte, 0, shareOverhead+len(s.value))
+	data = append(data, shareMagic...)
+	data = append(data, shareVersion, byte(s.Threshold), byte(s.Total))
+	data = append(data, s.FileID...)
+	return append(data, s.value...)
+}
+
+func (s *Share) Write(path string) error {
+	return file.WritePEM(path, shareType, s.Marshal())
+}
+
+func ReadShare(path string) (*Share, error) {
+	data, err := file.ReadPEM(path, shareType, ErrInvalidShare)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) <= shareOverhead+1 || string(data[:len(shareMagic)]) != shareMagic || data[len(shareMagic)] != shareVersion {
+		return nil, errors.New(errors.CodeInvalidInput, "read key share", ErrInvalidShare).WithPath(path)
+	}
+
+	offset := len(shareMagic) + 1
+	share := &Share{
+		Threshold: int(data[offset]),
+		Total:     int(data[offset+1]),
+		FileID:    bytes.Clone(data[offset+2 : shareOverhead]),
+		value:     bytes.Clone(data[shareOverhead:]),
+	}
+	if share.Threshold < 2 || share.Threshold > share.Total || share.value[0] == 0 || share.Index() > share.Total {
+		return nil, errors.New(errors.CodeInvalidInput, "read key share", ErrInvalidShare).WithPath(path)
+	}
+	return share, nil
+}
+
+// SharePath names the file for share index of the encrypted file at path.
+func SharePath(path string, index int) string {
+	return fmt.Sprintf("%s%s%d", path, ShareExtension, index)
+}
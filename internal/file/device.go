@@ -0,0 +1,48 @@
+package file
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+)
+
+func IsDevice(path string) bool {
+	info, err := os.Stat(filepath.Clean(path))
+	return err == nil && info.Mode()&os.ModeDevice != 0
+}
+
+func Size(path string) (int64, error) {
+	cleanPath := filepath.Clean(path)
+	info, err := os.Stat(cleanPath)
+	if err != nil {
+		return 0, errors.New(ioCode(err), "stat", err).WithPath(cleanPath)
+	}
+	if info.Mode()&os.ModeDevice == 0 {
+		return info.Size(), nil
+	}
+
+	f, err := os.Open(cleanPath)
+	if err != nil {
+		return 0, errors.New(ioCode(err), "open", err).WithPath(cleanPath)
+	}
+	defer f.Close()
+
+	size, err := deviceSize(f)
+	if err != nil {
+		return 0, errors.New(errors.CodeIO, "read device size", err).WithPath(cleanPath)
+	}
+	return size, nil
+}
+
+func seekSize(f *os.File) (int64, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
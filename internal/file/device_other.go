@@ -0,0 +1,9 @@
+//go:build !linux
+
+package file
+
+import "os"
+
+func deviceSize(f *os.File) (int64, error) {
+	return seekSize(f)
+}
@@ -4,19 +4,46 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/hambosto/sweetbyte/internal/types"
 )
 
-func FindEligibleFiles(mode types.ProcessorMode) ([]string, error) {
-	var files []string
+func FindEligibleFiles(root string, mode types.ProcessorMode, maxDepth int) ([]string, error) {
+	var (
+		files []string
+		rules []ignoreRule
+	)
 
-	err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		if isEligible(path, info, mode) {
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			base := filepath.ToSlash(relPath)
+			if base == "." {
+				base = ""
+			} else if maxDepth > 0 && strings.Count(base, "/")+1 >= maxDepth {
+				return filepath.SkipDir
+			} else if len(includeRules) == 0 && matchRules(slices.Concat(rules, excludeRules), base, true, false) {
+				return filepath.SkipDir
+			}
+
+			dirRules, err := loadIgnoreFile(path, base)
+			if err != nil {
+				return err
+			}
+			rules = append(rules, dirRules...)
+			return nil
+		}
+
+		if isEligible(path, relPath, info, mode, slices.Concat(rules, excludeRules)) {
 			files = append(files, path)
 		}
 		return nil
@@ -28,15 +55,18 @@ func FindEligibleFiles(mode types.ProcessorMode) ([]string, error) {
 	return files, nil
 }
 
-func isEligible(path string, info os.FileInfo, mode types.ProcessorMode) bool {
-	if info.IsDir() || strings.HasPrefix(info.Name(), ".") || isExcluded(path) {
+func isEligible(path, relPath string, info os.FileInfo, mode types.ProcessorMode, rules []ignoreRule) bool {
+	if info.IsDir() {
+		return false
+	}
+	if !isIncluded(relPath) && (strings.HasPrefix(info.Name(), ".") || isExcluded(relPath) || isIgnored(rules, relPath)) {
 		return false
 	}
 
-	isEncrypted := isEncryptedFile(path)
+	isEncrypted := IsEncryptedFile(path)
 	switch mode {
 	case types.ModeEncrypt:
-		return !isEncrypted
+		return !isEncrypted && !IsArchive(path)
 	case types.ModeDecrypt:
 		return isEncrypted
 	default:
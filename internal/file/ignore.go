@@ -0,0 +1,182 @@
+package file
+
+import (
+	"bufio"
+	"fmt"
+	"iter"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"github.com/hambosto/sweetbyte/internal/config"
+)
+
+type ignoreRule struct {
+	base     string
+	pattern  glob.Glob
+	negate   bool
+	dirOnly  bool
+	nameOnly bool
+}
+
+var (
+	excludeRules []ignoreRule
+	includeRules []ignoreRule
+)
+
+func SetFilters(exclude, include []string) error {
+	excludes, err := parseRules(exclude)
+	if err != nil {
+		return err
+	}
+	includes, err := parseRules(include)
+	if err != nil {
+		return err
+	}
+
+	excludeRules, includeRules = excludes, includes
+	return nil
+}
+
+func parseRules(patterns []string) ([]ignoreRule, error) {
+	rules := make([]ignoreRule, 0, len(patterns))
+	for _, pattern := range patterns {
+		rule, ok, err := parseIgnoreRule("", pattern)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+func parseIgnoreRule(base, line string) (ignoreRule, bool, error) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false, nil
+	}
+
+	rule := ignoreRule{base: base}
+	switch {
+	case strings.HasPrefix(line, "!"):
+		rule.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\`):
+		line = line[1:]
+	}
+
+	if trimmed, ok := strings.CutSuffix(line, "/"); ok {
+		rule.dirOnly = true
+		line = trimmed
+	}
+	switch {
+	case strings.HasPrefix(line, "/"):
+		line = line[1:]
+	case strings.HasPrefix(line, "**/") && !strings.Contains(line[3:], "/"):
+		rule.nameOnly = true
+		line = line[3:]
+	case !strings.Contains(line, "/"):
+		rule.nameOnly = true
+	}
+	if line == "" {
+		return ignoreRule{}, false, nil
+	}
+
+	pattern, err := glob.Compile(line, '/')
+	if err != nil {
+		return ignoreRule{}, false, fmt.Errorf("invalid pattern %q: %w", line, err)
+	}
+	rule.pattern = pattern
+	return rule, true, nil
+}
+
+func loadIgnoreFile(dir, base string) ([]ignoreRule, error) {
+	f, err := os.Open(filepath.Join(dir, config.IgnoreFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		rule, ok, err := parseIgnoreRule(base, scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", filepath.Join(dir, config.IgnoreFile), line, err)
+		}
+		if ok {
+			rules = append(rules, rule)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filepath.Join(dir, config.IgnoreFile), err)
+	}
+	return rules, nil
+}
+
+func (r ignoreRule) match(relPath string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		rest, ok := strings.CutPrefix(relPath, r.base+"/")
+		if !ok {
+			return false
+		}
+		relPath = rest
+	}
+	if r.nameOnly {
+		return r.pattern.Match(path.Base(relPath))
+	}
+	return r.pattern.Match(relPath)
+}
+
+func matchRules(rules []ignoreRule, relPath string, isDir, excluded bool) bool {
+	for _, rule := range rules {
+		if rule.match(relPath, isDir) {
+			excluded = !rule.negate
+		}
+	}
+	return excluded
+}
+
+func isIgnored(rules []ignoreRule, relPath string) bool {
+	slashPath := filepath.ToSlash(relPath)
+	for dir := range parentDirs(slashPath) {
+		if matchRules(rules, dir, true, false) {
+			return true
+		}
+	}
+	return matchRules(rules, slashPath, false, false)
+}
+
+func isIncluded(relPath string) bool {
+	if len(includeRules) == 0 {
+		return false
+	}
+
+	slashPath := filepath.ToSlash(relPath)
+	for dir := range parentDirs(slashPath) {
+		if matchRules(includeRules, dir, true, false) {
+			return true
+		}
+	}
+	return matchRules(includeRules, slashPath, false, false)
+}
+
+func parentDirs(slashPath string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for i, c := range slashPath {
+			if c == '/' && !yield(slashPath[:i]) {
+				return
+			}
+		}
+	}
+}
@@ -1,12 +1,14 @@
 package file
 
 import (
+	"crypto/rand"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
 	"github.com/hambosto/sweetbyte/internal/types"
 )
 
@@ -31,10 +33,15 @@ func CreateFile(path string) (*os.File, error) {
 	cleanPath := filepath.Clean(path)
 
 	if err := ensureParentDir(cleanPath); err != nil {
-		return nil, err
+		return nil, errors.New(errors.CodeIO, "create parent directory", err).WithPath(cleanPath)
 	}
 
-	return os.Create(cleanPath)
+	f, err := os.Create(cleanPath)
+	if err != nil {
+		return nil, errors.New(errors.CodeIO, "create", err).WithPath(cleanPath)
+	}
+
+	return f, nil
 }
 
 func OpenFile(path string) (*os.File, error) {
@@ -42,7 +49,7 @@ func OpenFile(path string) (*os.File, error) {
 
 	f, err := os.Open(cleanPath)
 	if err != nil {
-		return nil, fmt.Errorf("open failed: %w", err)
+		return nil, errors.New(ioCode(err), "open", err).WithPath(cleanPath)
 	}
 
 	return f, nil
@@ -55,22 +62,40 @@ func GetFileInfo(path string) (os.FileInfo, error) {
 		if os.IsNotExist(err) {
 			return nil, nil
 		}
-		return nil, fmt.Errorf("stat failed: %w", err)
+		return nil, errors.New(ioCode(err), "stat", err).WithPath(cleanPath)
 	}
 	return stat, nil
 }
 
+func ioCode(err error) errors.Code {
+	if os.IsNotExist(err) {
+		return errors.CodeNotFound
+	}
+	return errors.CodeIO
+}
+
 func GetOutputPath(inputPath string, mode types.ProcessorMode) string {
 	switch mode {
 	case types.ModeEncrypt:
+		if IsDevice(inputPath) {
+			return filepath.Base(inputPath) + config.FileExtension
+		}
 		return inputPath + config.FileExtension
 	case types.ModeDecrypt:
-		return strings.TrimSuffix(inputPath, config.FileExtension)
+		return trimExtension(inputPath, config.FileExtension)
 	default:
 		return inputPath
 	}
 }
 
+func HiddenOutputPath(inputPath string) string {
+	dir := filepath.Dir(inputPath)
+	if IsDevice(inputPath) {
+		dir = "."
+	}
+	return filepath.Join(dir, strings.ToLower(rand.Text()[:12])+config.FileExtension)
+}
+
 func GetFileInfoList(files []string) ([]FileInfo, error) {
 	infos := make([]FileInfo, 0, len(files))
 
@@ -86,7 +111,7 @@ func GetFileInfoList(files []string) ([]FileInfo, error) {
 		info := FileInfo{
 			Path:        filePath,
 			Size:        stat.Size(),
-			IsEncrypted: isEncryptedFile(filePath),
+			IsEncrypted: IsEncryptedFile(filePath),
 			IsSelected:  true,
 		}
 		infos = append(infos, info)
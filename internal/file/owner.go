@@ -0,0 +1,54 @@
+package file
+
+import (
+	"fmt"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+type Owner struct {
+	UID   uint32
+	GID   uint32
+	User  string
+	Group string
+}
+
+func GetOwner(path string) (Owner, bool, error) {
+	uid, gid, ok, err := statOwner(filepath.Clean(path))
+	if err != nil || !ok {
+		return Owner{}, ok, err
+	}
+
+	owner := Owner{UID: uid, GID: gid}
+	if u, err := user.LookupId(strconv.FormatUint(uint64(uid), 10)); err == nil {
+		owner.User = u.Username
+	}
+	if g, err := user.LookupGroupId(strconv.FormatUint(uint64(gid), 10)); err == nil {
+		owner.Group = g.Name
+	}
+	return owner, true, nil
+}
+
+func RestoreOwner(path string, owner Owner) error {
+	uid, gid := int(owner.UID), int(owner.GID)
+	if owner.User != "" {
+		if u, err := user.Lookup(owner.User); err == nil {
+			if id, err := strconv.Atoi(u.Uid); err == nil {
+				uid = id
+			}
+		}
+	}
+	if owner.Group != "" {
+		if g, err := user.LookupGroup(owner.Group); err == nil {
+			if id, err := strconv.Atoi(g.Gid); err == nil {
+				gid = id
+			}
+		}
+	}
+
+	if err := chown(filepath.Clean(path), uid, gid); err != nil {
+		return fmt.Errorf("failed to restore ownership: %w", err)
+	}
+	return nil
+}
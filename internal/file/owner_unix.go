@@ -0,0 +1,34 @@
+//go:build unix
+
+package file
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+func statOwner(path string) (uint32, uint32, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("stat failed: %w", err)
+	}
+
+	uid, gid, ok := InfoOwner(info)
+	return uid, gid, ok, nil
+}
+
+// InfoOwner returns the user and group IDs that own the file described by
+// info, and false where the platform does not have them.
+func InfoOwner(info fs.FileInfo) (uint32, uint32, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint32(stat.Uid), uint32(stat.Gid), true
+}
+
+func chown(path string, uid, gid int) error {
+	return os.Chown(path, uid, gid)
+}
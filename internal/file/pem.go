@@ -0,0 +1,44 @@
+package file
+
+import (
+	"encoding/pem"
+	"os"
+	"path/filepath"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/tempfile"
+)
+
+func WritePEM(path, blockType string, data []byte) error {
+	cleanPath := filepath.Clean(path)
+	if _, err := os.Lstat(cleanPath); err == nil {
+		return errors.Newf(errors.CodeExists, "write "+blockType, "file already exists").WithPath(cleanPath)
+	}
+
+	f, err := tempfile.CreateAtomic(cleanPath)
+	if err != nil {
+		return err
+	}
+	if err := pem.Encode(f, &pem.Block{Type: blockType, Bytes: data}); err != nil {
+		_ = f.Remove()
+		return errors.New(errors.CodeIO, "write "+blockType, err).WithPath(cleanPath)
+	}
+	return f.Commit()
+}
+
+func ReadPEM(path, blockType string, invalid error) ([]byte, error) {
+	cleanPath := filepath.Clean(path)
+	data, err := os.ReadFile(cleanPath)
+	if os.IsNotExist(err) {
+		return nil, errors.New(errors.CodeNotFound, "read "+blockType, err).WithPath(cleanPath)
+	}
+	if err != nil {
+		return nil, errors.New(errors.CodeIO, "read "+blockType, err).WithPath(cleanPath)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, errors.New(errors.CodeInvalidInput, "read "+blockType, invalid).WithPath(cleanPath)
+	}
+	return block.Bytes, nil
+}
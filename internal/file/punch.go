@@ -0,0 +1,29 @@
+package file
+
+import (
+	"os"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+)
+
+var ErrPunchUnsupported = errors.Sentinel("freeing space inside a file is not supported on this platform or filesystem")
+
+// punchProbeSize covers a block on any common filesystem, so the probe
+// frees one instead of only zeroing part of it.
+const punchProbeSize = 64 * 1024
+
+// ProbePunch checks that PunchHole works on the filesystem holding dir by
+// punching a hole in a scratch file there, before anything relies on it.
+func ProbePunch(dir string) error {
+	f, err := os.CreateTemp(dir, ".sweetbyte-punch-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := f.Write(make([]byte, punchProbeSize)); err != nil {
+		return err
+	}
+	return PunchHole(f, 0, punchProbeSize)
+}
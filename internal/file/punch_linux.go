@@ -0,0 +1,18 @@
+package file
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func PunchHole(f *os.File, offset, length int64) error {
+	if length <= 0 {
+		return nil
+	}
+	err := unix.Fallocate(int(f.Fd()), unix.FALLOC_FL_PUNCH_HOLE|unix.FALLOC_FL_KEEP_SIZE, offset, length)
+	if err == unix.EOPNOTSUPP || err == unix.ENOSYS {
+		return ErrPunchUnsupported
+	}
+	return err
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package file
+
+import "os"
+
+func PunchHole(f *os.File, offset, length int64) error {
+	return ErrPunchUnsupported
+}
@@ -0,0 +1,31 @@
+package file
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+const sniffLen = 512
+
+func SniffContentType(path string) (string, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+
+	contentType := http.DetectContentType(buf[:n])
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType, nil
+	}
+	return contentType, nil
+}
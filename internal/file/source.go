@@ -0,0 +1,102 @@
+package file
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+)
+
+const (
+	directAlignment  = 4096
+	directBufferSize = 1024 * 1024
+	dropCacheEvery   = 8 * 1024 * 1024
+)
+
+type Source struct {
+	*os.File
+	reader    io.Reader
+	dropCache bool
+	offset    int64
+	dropped   int64
+}
+
+func OpenSource(path string, directIO bool) (*Source, error) {
+	cleanPath := filepath.Clean(path)
+
+	if !directIO {
+		f, err := os.Open(cleanPath)
+		if err != nil {
+			return nil, errors.New(ioCode(err), "open", err).WithPath(cleanPath)
+		}
+		adviseSequential(f)
+		return &Source{File: f, reader: f}, nil
+	}
+
+	f, direct, err := openDirect(cleanPath)
+	if err != nil {
+		return nil, errors.New(ioCode(err), "open", err).WithPath(cleanPath)
+	}
+	adviseSequential(f)
+
+	source := &Source{File: f, reader: f, dropCache: true}
+	if direct {
+		source.reader = newAlignedReader(f)
+	}
+	return source, nil
+}
+
+func (s *Source) Offset() int64 {
+	return s.offset
+}
+
+func (s *Source) Read(p []byte) (int, error) {
+	n, err := s.reader.Read(p)
+	s.offset += int64(n)
+
+	if s.dropCache && (s.offset-s.dropped >= dropCacheEvery || err == io.EOF) {
+		dropCache(s.File, s.dropped, s.offset-s.dropped)
+		s.dropped = s.offset
+	}
+	return n, err
+}
+
+type alignedReader struct {
+	f      *os.File
+	buffer []byte
+	start  int
+	end    int
+	err    error
+}
+
+func newAlignedReader(f *os.File) *alignedReader {
+	raw := make([]byte, directBufferSize+directAlignment)
+	shift := 0
+	if rem := int(uintptr(unsafe.Pointer(&raw[0])) & (directAlignment - 1)); rem != 0 {
+		shift = directAlignment - rem
+	}
+	return &alignedReader{f: f, buffer: raw[shift : shift+directBufferSize]}
+}
+
+func (r *alignedReader) Read(p []byte) (int, error) {
+	if r.start == r.end {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		n, err := io.ReadFull(r.f, r.buffer)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		r.start, r.end, r.err = 0, n, err
+		if n == 0 {
+			return 0, r.err
+		}
+	}
+
+	n := copy(p, r.buffer[r.start:r.end])
+	r.start += n
+	return n, nil
+}
@@ -0,0 +1,20 @@
+package file
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func openDirect(path string) (*os.File, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	_, _ = unix.FcntlInt(f.Fd(), unix.F_NOCACHE, 1)
+	return f, false, nil
+}
+
+func adviseSequential(*os.File) {}
+
+func dropCache(*os.File, int64, int64) {}
@@ -0,0 +1,29 @@
+package file
+
+import (
+	"os"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"golang.org/x/sys/unix"
+)
+
+func openDirect(path string) (*os.File, bool, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|unix.O_DIRECT, 0)
+	if err == nil {
+		return f, true, nil
+	}
+	if !errors.Is(err, unix.EINVAL) {
+		return nil, false, err
+	}
+
+	f, err = os.Open(path)
+	return f, false, err
+}
+
+func adviseSequential(f *os.File) {
+	_ = unix.Fadvise(int(f.Fd()), 0, 0, unix.FADV_SEQUENTIAL)
+}
+
+func dropCache(f *os.File, offset, length int64) {
+	_ = unix.Fadvise(int(f.Fd()), offset, length, unix.FADV_DONTNEED)
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package file
+
+import "os"
+
+func openDirect(path string) (*os.File, bool, error) {
+	f, err := os.Open(path)
+	return f, false, err
+}
+
+func adviseSequential(*os.File) {}
+
+func dropCache(*os.File, int64, int64) {}
@@ -0,0 +1,46 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type Times struct {
+	Modified time.Time
+	Accessed time.Time
+	Created  time.Time
+}
+
+func GetTimes(path string) (Times, error) {
+	info, err := os.Stat(filepath.Clean(path))
+	if err != nil {
+		return Times{}, fmt.Errorf("stat failed: %w", err)
+	}
+
+	times := statTimes(info)
+	times.Modified = info.ModTime()
+	return times, nil
+}
+
+func RestoreTimes(path string, times Times) error {
+	cleanPath := filepath.Clean(path)
+
+	accessed := times.Accessed
+	if accessed.IsZero() {
+		accessed = times.Modified
+	}
+
+	if err := os.Chtimes(cleanPath, accessed, times.Modified); err != nil {
+		return fmt.Errorf("failed to restore timestamps: %w", err)
+	}
+
+	if !times.Created.IsZero() {
+		if err := setCreationTime(cleanPath, times.Created); err != nil {
+			return fmt.Errorf("failed to restore creation time: %w", err)
+		}
+	}
+
+	return nil
+}
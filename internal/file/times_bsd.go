@@ -0,0 +1,24 @@
+//go:build darwin || freebsd
+
+package file
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+func statTimes(info os.FileInfo) Times {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return Times{}
+	}
+	return Times{
+		Accessed: time.Unix(stat.Atimespec.Unix()),
+		Created:  time.Unix(stat.Birthtimespec.Unix()),
+	}
+}
+
+func setCreationTime(string, time.Time) error {
+	return nil
+}
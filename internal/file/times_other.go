@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package file
+
+import (
+	"os"
+	"time"
+)
+
+func statTimes(os.FileInfo) Times {
+	return Times{}
+}
+
+func setCreationTime(string, time.Time) error {
+	return nil
+}
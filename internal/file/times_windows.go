@@ -0,0 +1,42 @@
+package file
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+func statTimes(info os.FileInfo) Times {
+	attrs, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return Times{}
+	}
+	return Times{
+		Accessed: time.Unix(0, attrs.LastAccessTime.Nanoseconds()),
+		Created:  time.Unix(0, attrs.CreationTime.Nanoseconds()),
+	}
+}
+
+func setCreationTime(path string, created time.Time) error {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+
+	handle, err := syscall.CreateFile(
+		pathPtr,
+		syscall.FILE_WRITE_ATTRIBUTES,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE,
+		nil,
+		syscall.OPEN_EXISTING,
+		syscall.FILE_FLAG_BACKUP_SEMANTICS,
+		0,
+	)
+	if err != nil {
+		return err
+	}
+	defer syscall.CloseHandle(handle)
+
+	ft := syscall.NsecToFiletime(created.UnixNano())
+	return syscall.SetFileTime(handle, &ft, nil, nil)
+}
@@ -0,0 +1,124 @@
+package file
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/types"
+)
+
+type TreeEntry struct {
+	Input  string
+	Output string
+	Size   int64
+}
+
+func MapTree(root, outputRoot string, mode types.ProcessorMode, maxDepth int) ([]TreeEntry, error) {
+	info, err := GetFileInfo(root)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, errors.New(errors.CodeNotFound, "", ErrNotFound).WithPath(root)
+	}
+	if !info.IsDir() {
+		return nil, errors.Newf(errors.CodeInvalidInput, "", "%s is not a directory", root)
+	}
+
+	paths, err := FindEligibleFiles(root, mode, maxDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]TreeEntry, 0, len(paths))
+	for _, path := range paths {
+		output := GetOutputPath(path, mode)
+		if outputRoot != "" {
+			relPath, err := filepath.Rel(root, path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+			}
+			output = GetOutputPath(filepath.Join(outputRoot, relPath), mode)
+		}
+		if output == path {
+			continue
+		}
+
+		size, err := Size(path)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, TreeEntry{Input: path, Output: output, Size: size})
+	}
+	return entries, nil
+}
+
+func IsPattern(path string) bool {
+	if !strings.ContainsAny(path, "*?[") {
+		return false
+	}
+	info, err := GetFileInfo(path)
+	return err == nil && info == nil
+}
+
+func MapFiles(patterns []string, outputDir string, mode types.ProcessorMode) ([]TreeEntry, error) {
+	var (
+		entries []TreeEntry
+		seen    = make(map[string]bool)
+		outputs = make(map[string]string)
+	)
+	for _, pattern := range patterns {
+		paths := []string{pattern}
+		isGlob := IsPattern(pattern)
+		if isGlob {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, errors.Newf(errors.CodeInvalidInput, "", "invalid pattern %q: %v", pattern, err)
+			}
+			if len(matches) == 0 {
+				return nil, errors.New(errors.CodeNotFound, "", ErrNoEligibleFiles).WithPath(pattern)
+			}
+			paths = matches
+		}
+
+		for _, path := range paths {
+			path = filepath.Clean(path)
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+
+			info, err := GetFileInfo(path)
+			if err != nil {
+				return nil, err
+			}
+			if info == nil {
+				return nil, errors.New(errors.CodeNotFound, "", ErrNotFound).WithPath(path)
+			}
+			if isGlob && (info.IsDir() || IsArchive(path) || IsEncryptedFile(path) != (mode == types.ModeDecrypt)) {
+				continue
+			}
+			if info.IsDir() {
+				return nil, errors.Newf(errors.CodeInvalidInput, "", "%s is a directory (use --recursive)", path)
+			}
+
+			output := GetOutputPath(path, mode)
+			if outputDir != "" {
+				output = GetOutputPath(filepath.Join(outputDir, filepath.Base(path)), mode)
+			}
+			if other, ok := outputs[output]; ok {
+				return nil, errors.Newf(errors.CodeInvalidInput, "", "%s and %s would both be written to %s", other, path, output)
+			}
+			outputs[output] = path
+
+			size, err := Size(path)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, TreeEntry{Input: path, Output: output, Size: size})
+		}
+	}
+	return entries, nil
+}
@@ -4,17 +4,32 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 
 	"github.com/gobwas/glob"
 	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+)
+
+var (
+	ErrNotFound        = errors.Sentinel("file not found")
+	ErrIsDirectory     = errors.Sentinel("path is directory")
+	ErrEmptyFile       = errors.Sentinel("file is empty")
+	ErrOutputExists    = errors.Sentinel("output exists")
+	ErrNoEligibleFiles = errors.Sentinel("no eligible files found")
 )
 
 var (
 	exclusionGlobs         []glob.Glob
 	exclusionGlobsCompiled bool
+	caseInsensitiveExt     = runtime.GOOS == "windows" || runtime.GOOS == "darwin"
 )
 
+func SetCaseInsensitiveExt(enabled bool) {
+	caseInsensitiveExt = enabled
+}
+
 func isExcluded(path string) bool {
 	cleanPath := filepath.Clean(path)
 	globs := getCompiledExclusionGlobs()
@@ -44,8 +59,31 @@ func getCompiledExclusionGlobs() []glob.Glob {
 	return exclusionGlobs
 }
 
-func isEncryptedFile(path string) bool {
-	return strings.HasSuffix(path, config.FileExtension)
+func IsEncryptedFile(path string) bool {
+	return hasExtension(path, config.FileExtension)
+}
+
+func IsArchive(path string) bool {
+	return hasExtension(path, config.ArchiveExtension)
+}
+
+func hasExtension(path, ext string) bool {
+	if len(path) < len(ext) {
+		return false
+	}
+
+	suffix := path[len(path)-len(ext):]
+	if caseInsensitiveExt {
+		return strings.EqualFold(suffix, ext)
+	}
+	return suffix == ext
+}
+
+func trimExtension(path, ext string) string {
+	if !hasExtension(path, ext) {
+		return path
+	}
+	return path[:len(path)-len(ext)]
 }
 
 func ValidatePath(path string, mustExist bool) error {
@@ -59,14 +97,22 @@ func ValidatePath(path string, mustExist bool) error {
 	if mustExist {
 		switch {
 		case info == nil:
-			return fmt.Errorf("file not found: %s", cleanPath)
+			return errors.New(errors.CodeNotFound, "", ErrNotFound).WithPath(cleanPath)
 		case info.IsDir():
-			return fmt.Errorf("path is directory: %s", cleanPath)
+			return errors.New(errors.CodeInvalidInput, "", ErrIsDirectory).WithPath(cleanPath)
+		case info.Mode()&os.ModeDevice != 0:
+			size, err := Size(cleanPath)
+			if err != nil {
+				return err
+			}
+			if size == 0 {
+				return errors.New(errors.CodeInvalidInput, "", ErrEmptyFile).WithPath(cleanPath)
+			}
 		case info.Size() == 0:
-			return fmt.Errorf("file is empty: %s", cleanPath)
+			return errors.New(errors.CodeInvalidInput, "", ErrEmptyFile).WithPath(cleanPath)
 		}
 	} else if info != nil {
-		return fmt.Errorf("output exists: %s", cleanPath)
+		return errors.New(errors.CodeExists, "", ErrOutputExists).WithPath(cleanPath)
 	}
 
 	return nil
@@ -7,6 +7,13 @@ import (
 	"github.com/hambosto/sweetbyte/internal/utils"
 )
 
+const maxSectionSize = 64 * 1024
+
+var eagerSections = map[SectionType]bool{
+	SectionMagic:      true,
+	SectionHeaderData: true,
+}
+
 type Deserializer struct {
 	header  *Header
 	encoder *SectionEncoder
@@ -24,6 +31,14 @@ func NewDeserializer(header *Header) (*Deserializer, error) {
 }
 
 func (d *Deserializer) Unmarshal(r io.Reader) error {
+	return d.unmarshal(r, false)
+}
+
+func (d *Deserializer) UnmarshalLazy(r io.Reader) error {
+	return d.unmarshal(r, true)
+}
+
+func (d *Deserializer) unmarshal(r io.Reader, lazy bool) error {
 	lengthSizes, err := d.readLengthSizes(r)
 	if err != nil {
 		return fmt.Errorf("failed to read length sizes: %w", err)
@@ -34,12 +49,14 @@ func (d *Deserializer) Unmarshal(r io.Reader) error {
 		return fmt.Errorf("failed to read section lengths: %w", err)
 	}
 
-	decodedSections, err := d.readAndDecodeData(r, sectionLengths)
+	decodedSections, encodedSections, err := d.readAndDecodeData(r, sectionLengths, lazy)
 	if err != nil {
 		return fmt.Errorf("failed to read and decode data: %w", err)
 	}
 
 	d.header.decodedSections = decodedSections
+	d.header.encodedSections = encodedSections
+	d.header.encoder = d.encoder
 	magic, ok := d.header.decodedSections[SectionMagic]
 	if !ok || len(magic) < MagicSize {
 		return fmt.Errorf("invalid or missing magic section")
@@ -56,6 +73,12 @@ func (d *Deserializer) Unmarshal(r io.Reader) error {
 		return fmt.Errorf("failed to deserialize header: %w", err)
 	}
 
+	if d.header.HasMetadata() {
+		if err := d.readMetadata(r); err != nil {
+			return fmt.Errorf("failed to read metadata: %w", err)
+		}
+	}
+
 	if err := d.header.Validate(); err != nil {
 		return fmt.Errorf("header validation failed: %w", err)
 	}
@@ -69,12 +92,19 @@ func (d *Deserializer) readLengthSizes(r io.Reader) (map[SectionType]uint32, err
 		return nil, fmt.Errorf("failed to read lengths header: %w", err)
 	}
 
-	return map[SectionType]uint32{
+	lengthSizes := map[SectionType]uint32{
 		SectionMagic:      utils.FromBytes[uint32](lengthsHeader[0:4]),
 		SectionSalt:       utils.FromBytes[uint32](lengthsHeader[4:8]),
 		SectionHeaderData: utils.FromBytes[uint32](lengthsHeader[8:12]),
 		SectionMAC:        utils.FromBytes[uint32](lengthsHeader[12:16]),
-	}, nil
+	}
+	for sectionType, size := range lengthSizes {
+		if size == 0 || size > maxSectionSize {
+			return nil, fmt.Errorf("invalid length size for %s: %d", sectionType, size)
+		}
+	}
+
+	return lengthSizes, nil
 }
 
 func (d *Deserializer) readAndDecodeLengths(r io.Reader, lengthSizes map[SectionType]uint32) (map[SectionType]uint32, error) {
@@ -91,30 +121,84 @@ func (d *Deserializer) readAndDecodeLengths(r io.Reader, lengthSizes map[Section
 		if err != nil {
 			return nil, fmt.Errorf("failed to decode length for %s: %w", sectionType, err)
 		}
+		if length == 0 || length > maxSectionSize {
+			return nil, fmt.Errorf("invalid length for %s: %d", sectionType, length)
+		}
 		sectionLengths[sectionType] = length
 	}
 
 	return sectionLengths, nil
 }
 
-func (d *Deserializer) readAndDecodeData(r io.Reader, sectionLengths map[SectionType]uint32) (map[SectionType][]byte, error) {
+func (d *Deserializer) readAndDecodeData(r io.Reader, sectionLengths map[SectionType]uint32, lazy bool) (map[SectionType][]byte, map[SectionType][]byte, error) {
 	decodedSections := make(map[SectionType][]byte)
+	encodedSections := make(map[SectionType][]byte)
 
 	for _, sectionType := range SectionOrder {
 		encodedData := make([]byte, sectionLengths[sectionType])
 		if _, err := io.ReadFull(r, encodedData); err != nil {
-			return nil, fmt.Errorf("failed to read encoded %s: %w", sectionType, err)
+			return nil, nil, fmt.Errorf("failed to read encoded %s: %w", sectionType, err)
+		}
+
+		if lazy && !eagerSections[sectionType] {
+			encodedSections[sectionType] = encodedData
+			continue
 		}
 
 		section := &EncodedSection{Data: encodedData, Length: sectionLengths[sectionType]}
 		decoded, err := d.encoder.DecodeSection(section)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode %s: %w", sectionType, err)
+			return nil, nil, fmt.Errorf("failed to decode %s: %w", sectionType, err)
 		}
 		decodedSections[sectionType] = decoded
 	}
 
-	return decodedSections, nil
+	return decodedSections, encodedSections, nil
+}
+
+func (d *Deserializer) readMetadata(r io.Reader) error {
+	var sizeBuffer [4]byte
+	if _, err := io.ReadFull(r, sizeBuffer[:]); err != nil {
+		return fmt.Errorf("failed to read metadata length size: %w", err)
+	}
+
+	lengthSize := utils.FromBytes[uint32](sizeBuffer[:])
+	if lengthSize == 0 || lengthSize > maxMetadataSize {
+		return fmt.Errorf("invalid metadata length size: %d", lengthSize)
+	}
+
+	encodedLength := make([]byte, lengthSize)
+	if _, err := io.ReadFull(r, encodedLength); err != nil {
+		return fmt.Errorf("failed to read encoded length for %s: %w", SectionMetadata, err)
+	}
+
+	length, err := d.encoder.DecodeLengthPrefix(&EncodedSection{Data: encodedLength, Length: lengthSize})
+	if err != nil {
+		return fmt.Errorf("failed to decode length for %s: %w", SectionMetadata, err)
+	}
+	if length == 0 || length > maxMetadataSize {
+		return fmt.Errorf("invalid metadata size: %d", length)
+	}
+
+	encodedData := make([]byte, length)
+	if _, err := io.ReadFull(r, encodedData); err != nil {
+		return fmt.Errorf("failed to read encoded %s: %w", SectionMetadata, err)
+	}
+
+	decoded, err := d.encoder.DecodeSection(&EncodedSection{Data: encodedData, Length: length})
+	if err != nil {
+		return fmt.Errorf("failed to decode %s: %w", SectionMetadata, err)
+	}
+
+	metadata := NewMetadata()
+	consumed, err := metadata.unmarshal(decoded)
+	if err != nil {
+		return err
+	}
+
+	d.header.Metadata = metadata
+	d.header.decodedSections[SectionMetadata] = decoded[:consumed]
+	return nil
 }
 
 func (d *Deserializer) deserialize(h *Header, data []byte) error {
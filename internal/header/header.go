@@ -1,11 +1,15 @@
 package header
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io"
+	"strings"
+	"time"
 
 	"github.com/ccoveille/go-safecast/v2"
 	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/errors"
 )
 
 const (
@@ -13,21 +17,42 @@ const (
 	MagicSize      = 4
 	MACSize        = 32
 	HeaderDataSize = 14
-	CurrentVersion = 0x0001
+	CurrentVersion = 0x0004
 	FlagProtected  = 1 << 0
+	FlagNoECC      = 1 << 1
+	kdfParamsSize  = 9
+	parametersSize = 4
+	ownerIDsSize   = 9
+	keySlotSize    = 1 + kdfParamsSize + derive.ArgonSaltLen + 2
+)
+
+const (
+	VersionLegacy    = 0x0001
+	VersionMetadata  = 0x0002
+	VersionFramedMAC = 0x0003
+	VersionCritical  = 0x0004
+)
+
+var (
+	ErrNewerFormat   = errors.Sentinel("file needs a newer version of sweetbyte")
+	ErrHeaderDamaged = errors.Sentinel("header is damaged beyond what its parity can repair")
 )
 
 type Header struct {
 	Version         uint16
 	Flags           uint32
 	OriginalSize    uint64
+	Metadata        *Metadata
 	decodedSections map[SectionType][]byte
+	encodedSections map[SectionType][]byte
+	encoder         *SectionEncoder
 }
 
 func NewHeader() (*Header, error) {
 	return &Header{
 		Version:      CurrentVersion,
 		OriginalSize: 0,
+		Metadata:     NewMetadata(),
 	}, nil
 }
 
@@ -51,17 +76,422 @@ func (h *Header) SetProtected(protected bool) {
 	}
 }
 
+func (h *Header) HasECC() bool {
+	return h.Flags&FlagNoECC == 0
+}
+
+func (h *Header) SetECC(enabled bool) {
+	if enabled {
+		h.Flags &^= FlagNoECC
+	} else {
+		h.Flags |= FlagNoECC
+	}
+}
+
+func (h *Header) HasMetadata() bool {
+	return h.Version >= VersionMetadata
+}
+
+func (h *Header) SetTime(tag MetadataTag, t time.Time) {
+	h.Metadata.SetUint64(tag, uint64(t.UnixNano()))
+}
+
+func (h *Header) Time(tag MetadataTag) (time.Time, bool) {
+	nanos, ok := h.Metadata.Uint64(tag)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(nanos)), true
+}
+
+func (h *Header) SetChunkSize(size int) {
+	h.Metadata.SetUint64(TagChunkSize, uint64(size))
+}
+
+func (h *Header) ChunkSize() (int, bool) {
+	size, ok := h.Metadata.Uint64(TagChunkSize)
+	if !ok {
+		return 0, false
+	}
+
+	chunkSize, err := safecast.Convert[int](size)
+	if err != nil {
+		return 0, false
+	}
+	return chunkSize, true
+}
+
+func (h *Header) SetRequiredFactors(factors Factor) {
+	if factors == 0 {
+		h.Metadata.Delete(TagFactors)
+		return
+	}
+	h.Metadata.SetUint64(TagFactors, uint64(factors))
+}
+
+func (h *Header) RequiredFactors() Factor {
+	factors, _ := h.Metadata.Uint64(TagFactors)
+	return Factor(factors)
+}
+
+func (h *Header) SetRevision(revision uint64) {
+	h.Metadata.SetUint64(TagRevision, revision)
+}
+
+func (h *Header) Revision() uint64 {
+	revision, _ := h.Metadata.Uint64(TagRevision)
+	return revision
+}
+
+func (h *Header) SetStreamed() {
+	h.Metadata.SetUint64(TagStreamed, 1)
+}
+
+func (h *Header) Streamed() bool {
+	streamed, _ := h.Metadata.Uint64(TagStreamed)
+	return streamed != 0
+}
+
+func (h *Header) SetTrailer(enabled bool) {
+	if !enabled {
+		h.Metadata.Delete(TagTrailer)
+		return
+	}
+	h.Metadata.SetUint64(TagTrailer, 1)
+}
+
+func (h *Header) HasTrailer() bool {
+	trailer, _ := h.Metadata.Uint64(TagTrailer)
+	return trailer != 0
+}
+
+func (h *Header) SetSequence(enabled bool) {
+	if !enabled {
+		h.Metadata.Delete(TagSequence)
+		return
+	}
+	h.Metadata.SetUint64(TagSequence, 1)
+}
+
+func (h *Header) HasSequence() bool {
+	sequence, _ := h.Metadata.Uint64(TagSequence)
+	return sequence != 0
+}
+
+// SetChunkIndex records that the trailer is followed by a table of chunk
+// offsets, which lets readers start at any chunk.
+func (h *Header) SetChunkIndex(enabled bool) {
+	if !enabled {
+		h.Metadata.Delete(TagChunkIndex)
+		return
+	}
+	h.Metadata.SetUint64(TagChunkIndex, 1)
+}
+
+func (h *Header) HasChunkIndex() bool {
+	index, _ := h.Metadata.Uint64(TagChunkIndex)
+	return index != 0
+}
+
+// SetDictionary stores the sealed zstd dictionary that chunks are
+// compressed with instead of zlib.
+func (h *Header) SetDictionary(sealed []byte) {
+	if len(sealed) == 0 {
+		h.Metadata.Delete(TagDictionary)
+		return
+	}
+	h.Metadata.SetBytes(TagDictionary, sealed)
+}
+
+func (h *Header) Dictionary() ([]byte, bool) {
+	return h.Metadata.Bytes(TagDictionary)
+}
+
+func (h *Header) SetPadding(size int64) {
+	if size <= 0 {
+		h.Metadata.Delete(TagPadding)
+		return
+	}
+	h.Metadata.SetUint64(TagPadding, uint64(size))
+}
+
+func (h *Header) Padding() int64 {
+	size, _ := h.Metadata.Uint64(TagPadding)
+	padding, err := safecast.Convert[int64](size)
+	if err != nil {
+		return 0
+	}
+	return padding
+}
+
+func (h *Header) SetKeyProvider(spec string) {
+	if spec == "" {
+		h.Metadata.Delete(TagKeyProvider)
+		return
+	}
+	h.Metadata.SetString(TagKeyProvider, spec)
+}
+
+// KeyProvider returns the spec of the hardware token whose response is mixed
+// into the primary key slot, if any.
+func (h *Header) KeyProvider() (string, bool) {
+	return h.Metadata.String(TagKeyProvider)
+}
+
+func (h *Header) SetDeterministic() {
+	h.Metadata.SetUint64(TagDeterministic, 1)
+}
+
+func (h *Header) Deterministic() bool {
+	deterministic, _ := h.Metadata.Uint64(TagDeterministic)
+	return deterministic != 0
+}
+
+func (h *Header) SetCipherSuite(suite uint64) {
+	if suite == 0 {
+		h.Metadata.Delete(TagCipherSuite)
+		return
+	}
+	h.Metadata.SetUint64(TagCipherSuite, suite)
+}
+
+func (h *Header) CipherSuite() uint64 {
+	suite, _ := h.Metadata.Uint64(TagCipherSuite)
+	return suite
+}
+
+func (h *Header) SetContentType(contentType string) {
+	h.Metadata.SetString(TagContentType, contentType)
+}
+
+func (h *Header) ContentType() string {
+	contentType, _ := h.Metadata.String(TagContentType)
+	return contentType
+}
+
+func (h *Header) SetWrappedKey(wrapped []byte) {
+	h.Metadata.SetBytes(TagWrappedKey, wrapped)
+}
+
+func (h *Header) WrappedKey() ([]byte, bool) {
+	return h.Metadata.Bytes(TagWrappedKey)
+}
+
+func (h *Header) SetRecipientKey(stanza []byte) {
+	h.Metadata.SetBytes(TagRecipient, stanza)
+}
+
+func (h *Header) RecipientKey() ([]byte, bool) {
+	return h.Metadata.Bytes(TagRecipient)
+}
+
+func (h *Header) SetSealedName(sealed []byte) {
+	h.Metadata.SetBytes(TagSealedName, sealed)
+}
+
+func (h *Header) SealedName() ([]byte, bool) {
+	return h.Metadata.Bytes(TagSealedName)
+}
+
+func (h *Header) SetComment(comment string) {
+	if comment == "" {
+		h.Metadata.Delete(TagComment)
+		return
+	}
+	h.Metadata.SetString(TagComment, comment)
+}
+
+func (h *Header) Comment() string {
+	comment, _ := h.Metadata.String(TagComment)
+	return comment
+}
+
+func (h *Header) SetName(name string) {
+	h.Metadata.SetString(TagName, name)
+}
+
+func (h *Header) Name() (string, bool) {
+	return h.Metadata.String(TagName)
+}
+
+func (h *Header) SetKeySlots(slots []KeySlot) {
+	if len(slots) == 0 {
+		h.Metadata.Delete(TagKeySlots)
+		return
+	}
+
+	var value []byte
+	for _, slot := range slots {
+		value = append(value, byte(slot.Factors))
+		value = binary.BigEndian.AppendUint32(value, slot.Params.Time)
+		value = binary.BigEndian.AppendUint32(value, slot.Params.Memory)
+		value = append(value, slot.Params.Threads)
+		value = append(value, slot.Salt...)
+		value = binary.BigEndian.AppendUint16(value, uint16(len(slot.Wrapped)))
+		value = append(value, slot.Wrapped...)
+	}
+	h.Metadata.SetBytes(TagKeySlots, value)
+}
+
+func (h *Header) KeySlots() ([]KeySlot, error) {
+	value, ok := h.Metadata.Bytes(TagKeySlots)
+	if !ok {
+		return nil, nil
+	}
+
+	var slots []KeySlot
+	for len(value) > 0 {
+		if len(value) < keySlotSize {
+			return nil, fmt.Errorf("invalid key slot length: %d", len(value))
+		}
+		slot := KeySlot{
+			Factors: Factor(value[0]),
+			Params: derive.Params{
+				Time:    binary.BigEndian.Uint32(value[1:5]),
+				Memory:  binary.BigEndian.Uint32(value[5:9]),
+				Threads: value[9],
+			},
+			Salt: value[1+kdfParamsSize : 1+kdfParamsSize+derive.ArgonSaltLen],
+		}
+		wrappedLen := int(binary.BigEndian.Uint16(value[keySlotSize-2 : keySlotSize]))
+		if len(value) < keySlotSize+wrappedLen {
+			return nil, fmt.Errorf("invalid key slot length: %d", len(value))
+		}
+		if err := slot.Params.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid key slot KDF parameters: %w", err)
+		}
+		slot.Wrapped = value[keySlotSize : keySlotSize+wrappedLen]
+		slots = append(slots, slot)
+		value = value[keySlotSize+wrappedLen:]
+	}
+	return slots, nil
+}
+
+func (h *Header) SetKDFSalt(salt []byte) {
+	h.Metadata.SetBytes(TagKDFSalt, salt)
+}
+
+func (h *Header) KDFSalt() ([]byte, bool) {
+	return h.Metadata.Bytes(TagKDFSalt)
+}
+
+func (h *Header) SetOwner(owner Owner) {
+	user := owner.User[:min(len(owner.User), 255)]
+	value := make([]byte, ownerIDsSize, ownerIDsSize+len(user)+len(owner.Group))
+	binary.BigEndian.PutUint32(value[0:4], owner.UID)
+	binary.BigEndian.PutUint32(value[4:8], owner.GID)
+	value[8] = byte(len(user))
+	value = append(value, user...)
+	value = append(value, owner.Group...)
+	h.Metadata.SetBytes(TagOwner, value)
+}
+
+func (h *Header) Owner() (Owner, bool, error) {
+	value, ok := h.Metadata.Bytes(TagOwner)
+	if !ok {
+		return Owner{}, false, nil
+	}
+	if len(value) < ownerIDsSize || len(value) < ownerIDsSize+int(value[8]) {
+		return Owner{}, false, fmt.Errorf("invalid owner length: %d", len(value))
+	}
+
+	userEnd := ownerIDsSize + int(value[8])
+	return Owner{
+		UID:   binary.BigEndian.Uint32(value[0:4]),
+		GID:   binary.BigEndian.Uint32(value[4:8]),
+		User:  string(value[ownerIDsSize:userEnd]),
+		Group: string(value[userEnd:]),
+	}, true, nil
+}
+
+func (h *Header) SetLabels(labels []string) {
+	if len(labels) == 0 {
+		h.Metadata.Delete(TagLabels)
+		return
+	}
+	h.Metadata.SetString(TagLabels, strings.Join(labels, ","))
+}
+
+func (h *Header) Labels() []string {
+	value, ok := h.Metadata.String(TagLabels)
+	if !ok || value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+func (h *Header) Profile() string {
+	if profile, ok := h.Metadata.String(TagProfile); ok && profile != "" {
+		return profile
+	}
+	return DefaultProfile
+}
+
+func (h *Header) SetKDF(profile string, params derive.Params) {
+	h.Metadata.SetString(TagProfile, profile)
+
+	value := make([]byte, kdfParamsSize)
+	binary.BigEndian.PutUint32(value[0:4], params.Time)
+	binary.BigEndian.PutUint32(value[4:8], params.Memory)
+	value[8] = params.Threads
+	h.Metadata.SetBytes(TagKDFParams, value)
+}
+
+func (h *Header) KDFParams() (derive.Params, error) {
+	value, ok := h.Metadata.Bytes(TagKDFParams)
+	if !ok {
+		return derive.DefaultParams(), nil
+	}
+	if len(value) != kdfParamsSize {
+		return derive.Params{}, fmt.Errorf("invalid KDF parameters length: %d", len(value))
+	}
+
+	params := derive.Params{
+		Time:    binary.BigEndian.Uint32(value[0:4]),
+		Memory:  binary.BigEndian.Uint32(value[4:8]),
+		Threads: value[8],
+	}
+	if err := params.Validate(); err != nil {
+		return derive.Params{}, fmt.Errorf("invalid KDF parameters: %w", err)
+	}
+	return params, nil
+}
+
+func (h *Header) SetParameters(params Parameters) {
+	h.Metadata.SetBytes(TagParameters, []byte{params.DataShards, params.ParityShards, params.Compression, params.Level})
+}
+
+func (h *Header) Parameters() (Parameters, bool, error) {
+	value, ok := h.Metadata.Bytes(TagParameters)
+	if !ok {
+		return Parameters{}, false, nil
+	}
+	if len(value) != parametersSize {
+		return Parameters{}, false, fmt.Errorf("invalid processing parameters length: %d", len(value))
+	}
+	return Parameters{DataShards: value[0], ParityShards: value[1], Compression: value[2], Level: value[3]}, true, nil
+}
+
 func (h *Header) Validate() error {
 	if h.Version > CurrentVersion {
-		return fmt.Errorf("unsupported version: %d (current: %d)", h.Version, CurrentVersion)
+		return fmt.Errorf("%w: format version %d (this release reads up to %d)", ErrNewerFormat, h.Version, CurrentVersion)
+	}
+	for _, tag := range h.Metadata.Tags() {
+		if tag.Critical() && !criticalTags[tag] {
+			return fmt.Errorf("%w: unknown required header section %#04x", ErrNewerFormat, uint16(tag))
+		}
 	}
-	if h.OriginalSize == 0 {
+	if h.Version == VersionMetadata {
+		return fmt.Errorf("unsupported version: %d", h.Version)
+	}
+	if h.OriginalSize == 0 && !h.Streamed() {
 		return fmt.Errorf("original size cannot be zero")
 	}
 	return nil
 }
 
 func (h *Header) Marshal(salt, key []byte) ([]byte, error) {
+	h.Version = h.requiredVersion()
 	marshaler, err := NewSerializer(h)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create serializer: %w", err)
@@ -74,7 +504,72 @@ func (h *Header) Unmarshal(r io.Reader) error {
 	if err != nil {
 		return fmt.Errorf("failed to create deserializer: %w", err)
 	}
-	return unmarshaler.Unmarshal(r)
+	if err := unmarshaler.Unmarshal(r); err != nil {
+		return readError(err)
+	}
+	return nil
+}
+
+func (h *Header) UnmarshalLazy(r io.Reader) error {
+	unmarshaler, err := NewDeserializer(h)
+	if err != nil {
+		return fmt.Errorf("failed to create deserializer: %w", err)
+	}
+	if err := unmarshaler.UnmarshalLazy(r); err != nil {
+		return readError(err)
+	}
+	return nil
+}
+
+func readError(err error) error {
+	if errors.Is(err, ErrNewerFormat) {
+		return errors.New(errors.CodeUnsupported, "read header", err)
+	}
+	return errors.New(errors.CodeCorrupt, "read header", err)
+}
+
+func (h *Header) requiredVersion() uint16 {
+	for _, tag := range h.Metadata.Tags() {
+		if tag.Critical() {
+			return VersionCritical
+		}
+	}
+	return VersionFramedMAC
+}
+
+func (h *Header) RepairedShards() int {
+	if h.encoder == nil {
+		return 0
+	}
+	return h.encoder.repaired
+}
+
+func (h *Header) Reencode() ([]byte, error) {
+	sections := make(map[SectionType][]byte, len(SectionOrder))
+	for _, st := range SectionOrder {
+		data, err := h.rawSection(st)
+		if err != nil {
+			return nil, err
+		}
+		sections[st] = data
+	}
+
+	var metadata []byte
+	if h.HasMetadata() {
+		var err error
+		if metadata, err = h.rawSection(SectionMetadata); err != nil {
+			return nil, err
+		}
+	}
+	if h.encoder.unrecoverable > 0 {
+		return nil, ErrHeaderDamaged
+	}
+
+	serializer, err := NewSerializer(h)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create serializer: %w", err)
+	}
+	return serializer.Reencode(sections, metadata)
 }
 
 func (h *Header) Salt() ([]byte, error) {
@@ -110,28 +605,57 @@ func (h *Header) Verify(key []byte) error {
 		return err
 	}
 
-	return VerifyMAC(
-		key,
-		expectedMAC,
-		magic,
-		salt,
-		headerData,
-	)
+	var metadata []byte
+	if h.HasMetadata() {
+		if metadata, err = h.rawSection(SectionMetadata); err != nil {
+			return err
+		}
+	}
+
+	inputs, err := macInputs(h.Version, magic, salt, headerData, metadata)
+	if err != nil {
+		return err
+	}
+
+	if err := VerifyMAC(key, expectedMAC, inputs...); err != nil {
+		return errors.New(errors.CodeAuthentication, "verify header", err)
+	}
+	return nil
 }
 
 func (h *Header) section(st SectionType, minLen int) ([]byte, error) {
+	data, err := h.rawSection(st)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < minLen {
+		return nil, fmt.Errorf("section too short")
+	}
+
+	return data[:minLen], nil
+}
+
+func (h *Header) rawSection(st SectionType) ([]byte, error) {
 	if h.decodedSections == nil {
 		return nil, fmt.Errorf("header not unmarshalled yet")
 	}
 
-	data, ok := h.decodedSections[st]
-	if !ok || data == nil {
+	if data, ok := h.decodedSections[st]; ok && data != nil {
+		return data, nil
+	}
+
+	encoded, ok := h.encodedSections[st]
+	if !ok || encoded == nil {
 		return nil, fmt.Errorf("required section missing or nil")
 	}
 
-	if len(data) < minLen {
-		return nil, fmt.Errorf("section too short")
+	data, err := h.encoder.DecodeSection(&EncodedSection{Data: encoded, Length: safecast.MustConvert[uint32](len(encoded))})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", st, err)
 	}
 
-	return data[:minLen], nil
+	h.decodedSections[st] = data
+	delete(h.encodedSections, st)
+	return data, nil
 }
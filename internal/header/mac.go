@@ -3,9 +3,34 @@ package header
 import (
 	"crypto/hmac"
 	"crypto/sha256"
+	"encoding/binary"
 	"fmt"
+
+	"github.com/ccoveille/go-safecast/v2"
+	"github.com/hambosto/sweetbyte/internal/errors"
 )
 
+const macDomain = "sweetbyte header"
+
+var ErrMACMismatch = errors.Sentinel("MAC verification failed")
+
+func macInputs(version uint16, magic, salt, headerData, metadata []byte) ([][]byte, error) {
+	if version == VersionLegacy {
+		return [][]byte{magic, salt, headerData, metadata}, nil
+	}
+
+	framed := []byte(macDomain)
+	for _, part := range [][]byte{magic, salt, headerData, metadata} {
+		length, err := safecast.Convert[uint32](len(part))
+		if err != nil {
+			return nil, fmt.Errorf("MAC input too large: %w", err)
+		}
+		framed = binary.BigEndian.AppendUint32(framed, length)
+		framed = append(framed, part...)
+	}
+	return [][]byte{framed}, nil
+}
+
 func ComputeMAC(key []byte, parts ...[]byte) ([]byte, error) {
 	if len(key) == 0 {
 		return nil, fmt.Errorf("key cannot be empty")
@@ -31,7 +56,7 @@ func VerifyMAC(key, expectedMAC []byte, parts ...[]byte) error {
 	}
 
 	if !hmac.Equal(expectedMAC, computedMAC) {
-		return fmt.Errorf("MAC verification failed")
+		return errors.New(errors.CodeAuthentication, "", ErrMACMismatch)
 	}
 	return nil
 }
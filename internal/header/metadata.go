@@ -0,0 +1,229 @@
+package header
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/ccoveille/go-safecast/v2"
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/utils"
+)
+
+type MetadataTag uint16
+
+const (
+	TagModTime MetadataTag = iota + 1
+	TagAccessTime
+	TagBirthTime
+	TagCreated
+	TagLabels
+	TagProfile
+	TagChunkSize
+	TagFactors
+	TagKDFParams
+	TagParameters
+	TagRevision
+	TagContentType
+	TagWrappedKey
+	TagOwner
+	TagStreamed
+	TagRecipient
+	TagKDFSalt
+	TagSealedName
+	TagComment
+	TagName
+	TagDeterministic
+	TagKeySlots
+)
+
+const TagCritical MetadataTag = 0x8000
+
+const (
+	TagCipherSuite MetadataTag = TagCritical | iota + 1
+	TagTrailer
+	TagSequence
+	TagPadding
+	TagKeyProvider
+	TagChunkIndex
+	TagDictionary
+)
+
+var criticalTags = map[MetadataTag]bool{
+	TagCipherSuite: true,
+	TagTrailer:     true,
+	TagSequence:    true,
+	TagPadding:     true,
+	TagKeyProvider: true,
+	TagChunkIndex:  true,
+	TagDictionary:  true,
+}
+
+func (t MetadataTag) Critical() bool {
+	return t&TagCritical != 0
+}
+
+const (
+	CompressionZlib = 1
+	CompressionZstd = 2
+	FormatRevision  = 2
+
+	RevisionChunkFlags = 2
+)
+
+type Parameters struct {
+	DataShards   uint8
+	ParityShards uint8
+	Compression  uint8
+	Level        uint8
+}
+
+type Owner struct {
+	UID   uint32
+	GID   uint32
+	User  string
+	Group string
+}
+
+type Factor uint64
+
+const (
+	FactorPassword Factor = 1 << iota
+	FactorKeyfile
+)
+
+func (f Factor) Has(factor Factor) bool {
+	return f&factor == factor
+}
+
+// KeySlot is an extra copy of the data key, wrapped under a key derived from
+// another password or keyfile. The primary slot lives in TagWrappedKey.
+type KeySlot struct {
+	Factors Factor
+	Params  derive.Params
+	Salt    []byte
+	Wrapped []byte
+}
+
+const DefaultProfile = derive.ProfileDefault
+
+const (
+	metadataCountSize = 2
+	metadataEntrySize = 6
+	maxMetadataValue  = 1 << 20
+	maxMetadataSize   = 16 << 20
+
+	MaxCommentSize = 4096
+)
+
+type Metadata struct {
+	entries map[MetadataTag][]byte
+}
+
+func NewMetadata() *Metadata {
+	return &Metadata{entries: make(map[MetadataTag][]byte)}
+}
+
+func (m *Metadata) Has(tag MetadataTag) bool {
+	_, ok := m.entries[tag]
+	return ok
+}
+
+func (m *Metadata) Delete(tag MetadataTag) {
+	delete(m.entries, tag)
+}
+
+func (m *Metadata) Tags() []MetadataTag {
+	return slices.Sorted(maps.Keys(m.entries))
+}
+
+func (m *Metadata) SetBytes(tag MetadataTag, value []byte) {
+	m.entries[tag] = append([]byte(nil), value...)
+}
+
+func (m *Metadata) Bytes(tag MetadataTag) ([]byte, bool) {
+	value, ok := m.entries[tag]
+	return value, ok
+}
+
+func (m *Metadata) SetUint64(tag MetadataTag, value uint64) {
+	m.entries[tag] = utils.ToBytes[uint64](value)
+}
+
+func (m *Metadata) Uint64(tag MetadataTag) (uint64, bool) {
+	value, ok := m.entries[tag]
+	if !ok || len(value) != 8 {
+		return 0, false
+	}
+	return utils.FromBytes[uint64](value), true
+}
+
+func (m *Metadata) SetString(tag MetadataTag, value string) {
+	m.entries[tag] = []byte(value)
+}
+
+func (m *Metadata) String(tag MetadataTag) (string, bool) {
+	value, ok := m.entries[tag]
+	return string(value), ok
+}
+
+func (m *Metadata) Marshal() ([]byte, error) {
+	tags := m.Tags()
+
+	size := metadataCountSize
+	for _, tag := range tags {
+		size += metadataEntrySize + len(m.entries[tag])
+	}
+
+	data := make([]byte, 0, size)
+	data = append(data, utils.ToBytes[uint16](len(tags))...)
+	for _, tag := range tags {
+		value := m.entries[tag]
+		if len(value) > maxMetadataValue {
+			return nil, fmt.Errorf("metadata value for tag %d too large: %d bytes", tag, len(value))
+		}
+		data = append(data, utils.ToBytes[uint16](uint16(tag))...)
+		data = append(data, utils.ToBytes[uint32](len(value))...)
+		data = append(data, value...)
+	}
+
+	return data, nil
+}
+
+func (m *Metadata) Unmarshal(data []byte) error {
+	_, err := m.unmarshal(data)
+	return err
+}
+
+func (m *Metadata) unmarshal(data []byte) (int, error) {
+	if len(data) < metadataCountSize {
+		return 0, fmt.Errorf("metadata too short")
+	}
+
+	count := utils.FromBytes[uint16](data[:metadataCountSize])
+	offset := metadataCountSize
+	entries := make(map[MetadataTag][]byte, count)
+
+	for i := range count {
+		if len(data)-offset < metadataEntrySize {
+			return 0, fmt.Errorf("truncated metadata entry %d", i)
+		}
+
+		tag := MetadataTag(utils.FromBytes[uint16](data[offset : offset+2]))
+		length := safecast.MustConvert[int](utils.FromBytes[uint32](data[offset+2 : offset+6]))
+		offset += metadataEntrySize
+
+		if length > maxMetadataValue || len(data)-offset < length {
+			return 0, fmt.Errorf("invalid metadata length for tag %d", tag)
+		}
+		if _, exists := entries[tag]; exists {
+			return 0, fmt.Errorf("duplicate metadata tag %d", tag)
+		}
+
+		entries[tag] = append([]byte(nil), data[offset:offset+length]...)
+		offset += length
+	}
+
+	m.entries = entries
+	return offset, nil
+}
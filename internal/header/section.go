@@ -16,6 +16,7 @@ const (
 	SectionSalt       SectionType = "salt"
 	SectionHeaderData SectionType = "header_data"
 	SectionMAC        SectionType = "mac"
+	SectionMetadata   SectionType = "metadata"
 )
 
 var SectionOrder = []SectionType{SectionMagic, SectionSalt, SectionHeaderData, SectionMAC}
@@ -26,7 +27,9 @@ type EncodedSection struct {
 }
 
 type SectionEncoder struct {
-	encoder *encoding.Encoding
+	encoder       *encoding.Encoding
+	repaired      int
+	unrecoverable int
 }
 
 func NewSectionEncoder() (*SectionEncoder, error) {
@@ -59,6 +62,15 @@ func (se *SectionEncoder) DecodeSection(section *EncodedSection) ([]byte, error)
 		return nil, fmt.Errorf("invalid encoded section")
 	}
 
+	if ok, err := se.encoder.Verify(section.Data); err == nil && !ok {
+		diagnosis := se.encoder.Repair(section.Data)
+		if diagnosis.Status == encoding.StatusRepairable {
+			se.repaired += len(diagnosis.DamagedShards)
+		} else {
+			se.unrecoverable++
+		}
+	}
+
 	decoded, err := se.encoder.Decode(section.Data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode data: %w", err)
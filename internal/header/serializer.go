@@ -1,6 +1,7 @@
 package header
 
 import (
+	"encoding/binary"
 	"fmt"
 
 	"github.com/hambosto/sweetbyte/internal/derive"
@@ -31,7 +32,20 @@ func (s *Serializer) Marshal(salt, key []byte) ([]byte, error) {
 	magic := utils.ToBytes[uint32](MagicBytes)
 	headerData := s.serialize(s.header)
 
-	mac, err := ComputeMAC(key, magic, salt, headerData)
+	var metadata []byte
+	if s.header.HasMetadata() {
+		var err error
+		if metadata, err = s.header.Metadata.Marshal(); err != nil {
+			return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+	}
+
+	inputs, err := macInputs(s.header.Version, magic, salt, headerData, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	mac, err := ComputeMAC(key, inputs...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compute MAC: %w", err)
 	}
@@ -46,8 +60,49 @@ func (s *Serializer) Marshal(salt, key []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	lengthsHeader := s.buildLengthsHeader(lengthSections)
-	return s.assembleEncodedHeader(lengthsHeader, lengthSections, sections), nil
+	var metadataSection, metadataLength *EncodedSection
+	if metadata != nil {
+		if metadataSection, metadataLength, err = s.encodeMetadata(metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.assemble(lengthSections, sections, metadataLength, metadataSection)
+}
+
+func (s *Serializer) Reencode(sections map[SectionType][]byte, metadata []byte) ([]byte, error) {
+	encoded, err := s.encodeSections(sections[SectionMagic], sections[SectionSalt], sections[SectionHeaderData], sections[SectionMAC])
+	if err != nil {
+		return nil, err
+	}
+
+	lengthSections, err := s.encodeLengthPrefixes(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadataSection, metadataLength *EncodedSection
+	if metadata != nil {
+		if metadataSection, metadataLength, err = s.encodeMetadata(metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.assemble(lengthSections, encoded, metadataLength, metadataSection)
+}
+
+func (s *Serializer) encodeMetadata(metadata []byte) (*EncodedSection, *EncodedSection, error) {
+	section, err := s.encoder.EncodeSection(metadata)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	lengthSection, err := s.encoder.EncodeLengthPrefix(section.Length)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode length for %s: %w", SectionMetadata, err)
+	}
+
+	return section, lengthSection, nil
 }
 
 func (s *Serializer) validateInputs(salt, key []byte) error {
@@ -96,49 +151,54 @@ func (s *Serializer) encodeLengthPrefixes(sections map[SectionType]*EncodedSecti
 	return lengthSections, nil
 }
 
-func (s *Serializer) buildLengthsHeader(lengthSections map[SectionType]*EncodedSection) []byte {
-	lengthsHeader := make([]byte, 0, 16)
-	for _, sectionType := range SectionOrder {
-		sec, ok := lengthSections[sectionType]
-		if !ok || sec == nil {
-			panic(fmt.Sprintf("missing encoded length section for %s", sectionType))
-		}
-		lengthsHeader = append(lengthsHeader, utils.ToBytes[uint32](sec.Length)...)
-	}
-	return lengthsHeader
-}
-
-func (s *Serializer) assembleEncodedHeader(
-	lengthsHeader []byte,
+func (s *Serializer) assemble(
 	lengthSections map[SectionType]*EncodedSection,
 	sections map[SectionType]*EncodedSection,
-) []byte {
-	var result []byte
-
-	result = append(result, lengthsHeader...)
+	metadataLength *EncodedSection,
+	metadataSection *EncodedSection,
+) ([]byte, error) {
+	size := 4 * len(SectionOrder)
 	for _, sectionType := range SectionOrder {
-		sec, ok := lengthSections[sectionType]
+		lengthSec, ok := lengthSections[sectionType]
+		if !ok || lengthSec == nil || lengthSec.Data == nil {
+			return nil, fmt.Errorf("missing encoded length prefix for %s", sectionType)
+		}
+		sec, ok := sections[sectionType]
 		if !ok || sec == nil || sec.Data == nil {
-			panic(fmt.Sprintf("missing encoded length prefix for %s", sectionType))
+			return nil, fmt.Errorf("missing encoded section for %s", sectionType)
+		}
+		size += len(lengthSec.Data) + len(sec.Data)
+	}
+	if metadataSection != nil {
+		if metadataLength == nil || metadataLength.Data == nil || metadataSection.Data == nil {
+			return nil, fmt.Errorf("missing encoded %s", SectionMetadata)
 		}
-		result = append(result, sec.Data...)
+		size += 4 + len(metadataLength.Data) + len(metadataSection.Data)
 	}
 
+	result := make([]byte, 0, size)
 	for _, sectionType := range SectionOrder {
-		sec, ok := sections[sectionType]
-		if !ok || sec == nil || sec.Data == nil {
-			panic(fmt.Sprintf("missing encoded section for %s", sectionType))
-		}
-		result = append(result, sec.Data...)
+		result = binary.BigEndian.AppendUint32(result, lengthSections[sectionType].Length)
+	}
+	for _, sectionType := range SectionOrder {
+		result = append(result, lengthSections[sectionType].Data...)
+	}
+	for _, sectionType := range SectionOrder {
+		result = append(result, sections[sectionType].Data...)
+	}
+	if metadataSection != nil {
+		result = binary.BigEndian.AppendUint32(result, metadataLength.Length)
+		result = append(result, metadataLength.Data...)
+		result = append(result, metadataSection.Data...)
 	}
 
-	return result
+	return result, nil
 }
 
 func (s *Serializer) serialize(h *Header) []byte {
-	data := make([]byte, 0, HeaderDataSize)
-	data = append(data, utils.ToBytes[uint16](h.Version)...)
-	data = append(data, utils.ToBytes[uint32](h.Flags)...)
-	data = append(data, utils.ToBytes[uint64](h.OriginalSize)...)
+	data := make([]byte, HeaderDataSize)
+	binary.BigEndian.PutUint16(data[0:2], h.Version)
+	binary.BigEndian.PutUint32(data[2:6], h.Flags)
+	binary.BigEndian.PutUint64(data[6:14], h.OriginalSize)
 	return data
 }
@@ -0,0 +1,135 @@
+package hidden
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+
+	"github.com/hambosto/sweetbyte/internal/cipher/algorithm"
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/envelope"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/secret"
+	"golang.org/x/crypto/chacha20"
+)
+
+const (
+	// Alignment is the granularity of the padding, so its size says little
+	// about whether it holds anything.
+	Alignment = 1024 * 1024
+
+	// SlotSize is the space taken at the start of the padding by the sealed
+	// key slot: the KDF salt followed by the sealed stream key, nonce and size.
+	SlotSize = derive.ArgonSaltLen + algorithm.ChaChaNonceSizeX + slotDataSize + algorithm.ChaChaTagSize
+
+	slotInfo     = "sweetbyte hidden slot"
+	slotDataSize = chacha20.KeySize + chacha20.NonceSizeX + 8
+)
+
+var (
+	ErrNotFound = errors.Sentinel("no hidden file opens with this password")
+	ErrTooLarge = errors.Sentinel("hidden file does not fit in the padding")
+)
+
+// KeyFunc derives the key that seals the slot from the hidden password and
+// the salt stored in the slot.
+type KeyFunc func(salt []byte) (*secret.Buffer, error)
+
+// Size returns the padding needed for at least minimum bytes and, when
+// payload is positive, a hidden file of that size, rounded up to Alignment.
+func Size(minimum, payload int64) int64 {
+	size := max(minimum, 0)
+	if payload > 0 {
+		size = max(size, SlotSize+payload)
+	}
+	return (size + Alignment - 1) / Alignment * Alignment
+}
+
+// Fill writes size bytes of randomness, which is what padding without a
+// hidden file looks like.
+func Fill(w io.Writer, size int64) error {
+	if _, err := io.CopyN(w, rand.Reader, size); err != nil {
+		return errors.New(errors.CodeIO, "write padding", err)
+	}
+	return nil
+}
+
+// Write fills size bytes of padding with a sealed slot, the payload encrypted
+// with XChaCha20 under the key in the slot, and randomness for the rest. The
+// result cannot be told apart from Fill without the hidden password.
+func Write(w io.Writer, size int64, payload io.Reader, payloadSize int64, deriveKey KeyFunc) error {
+	if payloadSize < 0 || SlotSize+payloadSize > size {
+		return errors.Newf(errors.CodeInvalidInput, "", "%w: needs %d bytes, padding is %d", ErrTooLarge, SlotSize+payloadSize, size)
+	}
+
+	salt, err := derive.GetRandomBytes(derive.ArgonSaltLen)
+	if err != nil {
+		return err
+	}
+	slot := make([]byte, slotDataSize)
+	defer secret.Wipe(slot)
+	if _, err := rand.Read(slot[:chacha20.KeySize+chacha20.NonceSizeX]); err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint64(slot[chacha20.KeySize+chacha20.NonceSizeX:], uint64(payloadSize))
+
+	kek, err := deriveKey(salt)
+	if err != nil {
+		return err
+	}
+	sealed, err := envelope.Seal(kek.Bytes(), slotInfo, slot)
+	kek.Destroy()
+	if err != nil {
+		return err
+	}
+
+	stream, err := chacha20.NewUnauthenticatedCipher(slot[:chacha20.KeySize], slot[chacha20.KeySize:chacha20.KeySize+chacha20.NonceSizeX])
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(append(salt, sealed...)); err != nil {
+		return errors.New(errors.CodeIO, "write padding", err)
+	}
+	if _, err := io.CopyN(cipher.StreamWriter{S: stream, W: w}, payload, payloadSize); err != nil {
+		return errors.New(errors.CodeIO, "write hidden file", err)
+	}
+	return Fill(w, size-SlotSize-payloadSize)
+}
+
+// Open reads the slot at the start of size bytes of padding and, if it opens
+// with the key from deriveKey, returns a reader for the hidden payload.
+func Open(r io.Reader, size int64, deriveKey KeyFunc) (io.Reader, int64, error) {
+	if size < SlotSize {
+		return nil, 0, errors.New(errors.CodeAuthentication, "", ErrNotFound)
+	}
+
+	header := make([]byte, SlotSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, 0, errors.New(errors.CodeIO, "read padding", err)
+	}
+
+	kek, err := deriveKey(bytes.Clone(header[:derive.ArgonSaltLen]))
+	if err != nil {
+		return nil, 0, err
+	}
+	slot, err := envelope.Open(kek.Bytes(), slotInfo, header[derive.ArgonSaltLen:])
+	kek.Destroy()
+	if err != nil || len(slot) != slotDataSize {
+		return nil, 0, errors.New(errors.CodeAuthentication, "", ErrNotFound)
+	}
+	defer secret.Wipe(slot)
+
+	payloadSize := binary.BigEndian.Uint64(slot[chacha20.KeySize+chacha20.NonceSizeX:])
+	if payloadSize > uint64(size-SlotSize) {
+		return nil, 0, errors.Newf(errors.CodeCorrupt, "", "hidden file claims %d bytes, padding holds %d", payloadSize, size-SlotSize)
+	}
+
+	stream, err := chacha20.NewUnauthenticatedCipher(slot[:chacha20.KeySize], slot[chacha20.KeySize:chacha20.KeySize+chacha20.NonceSizeX])
+	if err != nil {
+		return nil, 0, err
+	}
+	return cipher.StreamReader{S: stream, R: io.LimitReader(r, int64(payloadSize))}, int64(payloadSize), nil
+}
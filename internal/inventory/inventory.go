@@ -0,0 +1,189 @@
+package inventory
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hambosto/sweetbyte/internal/cipher"
+	"github.com/hambosto/sweetbyte/internal/header"
+)
+
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatJSON Format = "json"
+)
+
+type Entry struct {
+	Path          string    `json:"path"`
+	OriginalSize  int64     `json:"original_size"`
+	Created       time.Time `json:"created,omitzero"`
+	Version       uint16    `json:"version"`
+	Revision      uint64    `json:"revision,omitempty"`
+	Streamed      bool      `json:"streamed,omitempty"`
+	Recipient     bool      `json:"recipient,omitempty"`
+	Token         string    `json:"token,omitempty"`
+	NoECC         bool      `json:"no_ecc,omitempty"`
+	Deterministic bool      `json:"deterministic,omitempty"`
+	HiddenName    bool      `json:"hidden_name,omitempty"`
+	Name          string    `json:"name,omitempty"`
+	Comment       string    `json:"comment,omitempty"`
+	Profile       string    `json:"profile"`
+	Cipher        string    `json:"cipher"`
+	Tags          []string  `json:"tags,omitempty"`
+	ChunkSize     int       `json:"chunk_size,omitempty"`
+	Padding       int64     `json:"padding,omitempty"`
+	Seekable      bool      `json:"seekable,omitempty"`
+	Dictionary    bool      `json:"dictionary,omitempty"`
+	ContentType   string    `json:"content_type,omitempty"`
+	Owner         string    `json:"owner,omitempty"`
+}
+
+func Scan(root string) ([]Entry, error) {
+	var entries []Entry
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		if entry, err := Inspect(path); err == nil {
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+
+	return entries, nil
+}
+
+func Inspect(path string) (Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	fileHeader, err := header.NewHeader()
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to create header: %w", err)
+	}
+	if err := fileHeader.UnmarshalLazy(f); err != nil {
+		return Entry{}, err
+	}
+
+	created, _ := fileHeader.Time(header.TagCreated)
+	chunkSize, _ := fileHeader.ChunkSize()
+	_, recipient := fileHeader.RecipientKey()
+	_, hiddenName := fileHeader.SealedName()
+	_, dictionary := fileHeader.Dictionary()
+	name, _ := fileHeader.Name()
+	token, _ := fileHeader.KeyProvider()
+	return Entry{
+		Path:          path,
+		OriginalSize:  fileHeader.GetOriginalSize(),
+		Created:       created,
+		Version:       fileHeader.Version,
+		Revision:      fileHeader.Revision(),
+		Streamed:      fileHeader.Streamed(),
+		Recipient:     recipient,
+		Token:         token,
+		NoECC:         !fileHeader.HasECC(),
+		Deterministic: fileHeader.Deterministic(),
+		HiddenName:    hiddenName,
+		Name:          name,
+		Comment:       fileHeader.Comment(),
+		Profile:       fileHeader.Profile(),
+		Cipher:        cipher.Suite(fileHeader.CipherSuite()).String(),
+		Tags:          fileHeader.Labels(),
+		ChunkSize:     chunkSize,
+		Padding:       fileHeader.Padding(),
+		Seekable:      fileHeader.HasChunkIndex(),
+		Dictionary:    dictionary,
+		ContentType:   fileHeader.ContentType(),
+		Owner:         formatOwner(fileHeader),
+	}, nil
+}
+
+func formatOwner(h *header.Header) string {
+	owner, ok, err := h.Owner()
+	if err != nil || !ok {
+		return ""
+	}
+
+	user, group := owner.User, owner.Group
+	if user == "" {
+		user = strconv.FormatUint(uint64(owner.UID), 10)
+	}
+	if group == "" {
+		group = strconv.FormatUint(uint64(owner.GID), 10)
+	}
+	return fmt.Sprintf("%s:%s (%d:%d)", user, group, owner.UID, owner.GID)
+}
+
+func Write(w io.Writer, entries []Entry, format Format) error {
+	switch format {
+	case FormatCSV:
+		return writeCSV(w, entries)
+	case FormatJSON:
+		return writeJSON(w, entries)
+	default:
+		return fmt.Errorf("unsupported inventory format: %s", format)
+	}
+}
+
+func writeCSV(w io.Writer, entries []Entry) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"path", "original_size", "created", "version", "profile", "tags", "cipher"}); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		var created string
+		if !entry.Created.IsZero() {
+			created = entry.Created.UTC().Format(time.RFC3339)
+		}
+
+		record := []string{
+			entry.Path,
+			strconv.FormatInt(entry.OriginalSize, 10),
+			created,
+			strconv.Itoa(int(entry.Version)),
+			entry.Profile,
+			strings.Join(entry.Tags, ";"),
+			entry.Cipher,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeJSON(w io.Writer, entries []Entry) error {
+	if entries == nil {
+		entries = []Entry{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
@@ -0,0 +1,201 @@
+package keyfile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	"github.com/hambosto/sweetbyte/internal/cipher/algorithm"
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/secret"
+	"github.com/hambosto/sweetbyte/internal/tempfile"
+)
+
+const (
+	KeySize = 64
+	magic   = "SWKF"
+	Version = 1
+
+	flagEncrypted = 1 << 0
+	prefixSize    = len(magic) + 2
+	maxFileSize   = 4096
+)
+
+var (
+	ErrInvalidKeyfile   = errors.Sentinel("not a sweetbyte keyfile")
+	ErrWrongPassphrase  = errors.Sentinel("wrong keyfile passphrase")
+	ErrPassphraseNeeded = errors.Sentinel("keyfile is passphrase-protected")
+)
+
+type PassphraseFunc func() (string, error)
+
+type Keyfile struct {
+	Path      string
+	encrypted bool
+	salt      []byte
+	payload   []byte
+}
+
+func Generate() ([]byte, error) {
+	return derive.GetRandomBytes(KeySize)
+}
+
+func Write(path string, key []byte, passphrase string) error {
+	cleanPath := filepath.Clean(path)
+	if len(key) != KeySize {
+		return errors.Newf(errors.CodeInvalidInput, "write keyfile", "key must be %d bytes, got %d", KeySize, len(key)).WithPath(cleanPath)
+	}
+	if _, err := os.Lstat(cleanPath); err == nil {
+		return errors.Newf(errors.CodeExists, "write keyfile", "file already exists").WithPath(cleanPath)
+	}
+
+	data, err := encode(key, passphrase)
+	if err != nil {
+		return errors.New(errors.CodeUnknown, "write keyfile", err).WithPath(cleanPath)
+	}
+
+	f, err := tempfile.CreateAtomic(cleanPath)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Remove()
+		return errors.New(errors.CodeIO, "write keyfile", err).WithPath(cleanPath)
+	}
+	return f.Commit()
+}
+
+func Read(path string) (*Keyfile, error) {
+	cleanPath := filepath.Clean(path)
+	info, err := os.Stat(cleanPath)
+	if os.IsNotExist(err) {
+		return nil, errors.New(errors.CodeNotFound, "read keyfile", err).WithPath(cleanPath)
+	}
+	if err != nil {
+		return nil, errors.New(errors.CodeIO, "read keyfile", err).WithPath(cleanPath)
+	}
+	if info.Size() > maxFileSize {
+		return nil, errors.New(errors.CodeInvalidInput, "read keyfile", ErrInvalidKeyfile).WithPath(cleanPath)
+	}
+
+	data, err := os.ReadFile(cleanPath)
+	if err != nil {
+		return nil, errors.New(errors.CodeIO, "read keyfile", err).WithPath(cleanPath)
+	}
+
+	kf, err := decode(data)
+	if err != nil {
+		return nil, errors.New(errors.CodeInvalidInput, "read keyfile", err).WithPath(cleanPath)
+	}
+	kf.Path = cleanPath
+	return kf, nil
+}
+
+func Load(path string, passphrase PassphraseFunc) ([]byte, error) {
+	kf, err := Read(path)
+	if err != nil {
+		return nil, err
+	}
+	if !kf.Encrypted() {
+		return kf.Unlock("")
+	}
+	if passphrase == nil {
+		return nil, errors.New(errors.CodeAuthentication, "unlock keyfile", ErrPassphraseNeeded).WithPath(kf.Path)
+	}
+
+	secret, err := passphrase()
+	if err != nil {
+		return nil, err
+	}
+	return kf.Unlock(secret)
+}
+
+func (k *Keyfile) Encrypted() bool {
+	return k.encrypted
+}
+
+func (k *Keyfile) Unlock(passphrase string) ([]byte, error) {
+	if !k.encrypted {
+		return bytes.Clone(k.payload), nil
+	}
+	if passphrase == "" {
+		return nil, errors.New(errors.CodeAuthentication, "unlock keyfile", ErrPassphraseNeeded).WithPath(k.Path)
+	}
+
+	wrapping, err := wrappingCipher(passphrase, k.salt)
+	if err != nil {
+		return nil, errors.New(errors.CodeUnknown, "unlock keyfile", err).WithPath(k.Path)
+	}
+
+	key, err := wrapping.Decrypt(k.payload)
+	if err != nil || len(key) != KeySize {
+		return nil, errors.New(errors.CodeAuthentication, "unlock keyfile", ErrWrongPassphrase).WithPath(k.Path)
+	}
+	return key, nil
+}
+
+func encode(key []byte, passphrase string) ([]byte, error) {
+	data := append([]byte(magic), Version, 0)
+	if passphrase == "" {
+		return append(data, key...), nil
+	}
+
+	salt, err := derive.GetRandomBytes(derive.ArgonSaltLen)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapping, err := wrappingCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := wrapping.Encrypt(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data[prefixSize-1] = flagEncrypted
+	data = append(data, salt...)
+	return append(data, wrapped...), nil
+}
+
+func decode(data []byte) (*Keyfile, error) {
+	if len(data) < prefixSize || string(data[:len(magic)]) != magic {
+		return nil, ErrInvalidKeyfile
+	}
+	if data[len(magic)] != Version {
+		return nil, errors.Newf(errors.CodeUnsupported, "", "unsupported keyfile version %d", data[len(magic)])
+	}
+
+	flags := data[prefixSize-1]
+	body := data[prefixSize:]
+	if flags&flagEncrypted == 0 {
+		if len(body) != KeySize {
+			return nil, ErrInvalidKeyfile
+		}
+		return &Keyfile{payload: bytes.Clone(body)}, nil
+	}
+
+	if len(body) <= derive.ArgonSaltLen {
+		return nil, ErrInvalidKeyfile
+	}
+	return &Keyfile{
+		encrypted: true,
+		salt:      bytes.Clone(body[:derive.ArgonSaltLen]),
+		payload:   bytes.Clone(body[derive.ArgonSaltLen:]),
+	}, nil
+}
+
+func wrappingCipher(passphrase string, salt []byte) (*algorithm.ChaCha20Cipher, error) {
+	secretPassphrase := secret.FromString(passphrase)
+	defer secretPassphrase.Destroy()
+
+	key, err := derive.Hash(secretPassphrase.Bytes(), salt)
+	if err != nil {
+		return nil, err
+	}
+	defer secret.Wipe(key)
+	return algorithm.NewChaCha20Cipher(key[:algorithm.ChaChaKeySize])
+}
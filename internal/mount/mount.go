@@ -0,0 +1,57 @@
+// Package mount exposes the decrypted content of an encrypted file or
+// archive as a read-only file system, decrypting chunks as they are read.
+package mount
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hambosto/sweetbyte/internal/archive"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/processor"
+)
+
+var ErrUnsupported = errors.Sentinel("mounting is only supported on Linux and macOS")
+
+// node is a file, directory or symbolic link in the mounted tree. Paths are
+// slash-separated and relative to the mount point.
+type node struct {
+	path    string
+	mode    fs.FileMode
+	modTime time.Time
+	size    int64
+	link    string
+	data    io.ReaderAt
+}
+
+// nodes lists what r exposes: the members of an archive, or else a single
+// file under its stored name.
+func nodes(r *processor.Reader) ([]node, error) {
+	if r.ContentType() != archive.ContentType {
+		return []node{{path: r.Name(), mode: 0o444, modTime: r.ModTime(), size: r.Size(), data: r}}, nil
+	}
+
+	members, err := archive.Members(r, r.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]node, 0, len(members))
+	for _, m := range members {
+		name := strings.TrimSuffix(m.Path, "/")
+		if name == "" || path.Clean(name) != name || !fs.ValidPath(name) {
+			return nil, errors.New(errors.CodeCorrupt, "", archive.ErrUnsafePath).WithPath(m.Path)
+		}
+
+		n := node{path: name, mode: m.Mode &^ 0o222, modTime: m.ModTime, link: m.Link}
+		if m.Mode.IsRegular() {
+			n.size = m.Size
+			n.data = io.NewSectionReader(r, m.Offset, m.Size)
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package mount
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/processor"
+)
+
+func Mount(ctx context.Context, srcPath, mountpoint string, password []byte, opts processor.Options, logger *slog.Logger) error {
+	return errors.New(errors.CodeUnsupported, "mount", ErrUnsupported)
+}
@@ -0,0 +1,168 @@
+//go:build linux || darwin
+
+package mount
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	gofs "github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/processor"
+)
+
+// cacheTimeout is how long the kernel may cache names and attributes; the
+// content never changes while mounted.
+const cacheTimeout = time.Hour
+
+// Mount serves the decrypted content of srcPath at mountpoint until ctx is
+// canceled or the file system is unmounted from outside.
+func Mount(ctx context.Context, srcPath, mountpoint string, password []byte, opts processor.Options, logger *slog.Logger) error {
+	r, err := processor.OpenReader(srcPath, password, opts)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	list, err := nodes(r)
+	if err != nil {
+		return errors.New(errors.CodeUnknown, "mount", err).WithPath(srcPath)
+	}
+
+	fsName := srcPath
+	if abs, err := filepath.Abs(srcPath); err == nil {
+		fsName = abs
+	}
+	timeout := cacheTimeout
+	root := &dirNode{modTime: r.ModTime()}
+	server, err := gofs.Mount(mountpoint, root, &gofs.Options{
+		MountOptions: fuse.MountOptions{
+			FsName:      fsName,
+			Name:        "sweetbyte",
+			Options:     []string{"ro"},
+			DirectMount: true,
+		},
+		EntryTimeout: &timeout,
+		AttrTimeout:  &timeout,
+		UID:          uint32(os.Getuid()),
+		GID:          uint32(os.Getgid()),
+		OnAdd: func(ctx context.Context) {
+			build(ctx, root, list, logger)
+		},
+	})
+	if err != nil {
+		return errors.New(errors.CodeIO, "mount", err).WithPath(mountpoint)
+	}
+	logger.Info("mounted", "source", srcPath, "mountpoint", mountpoint, "entries", len(list))
+
+	done := make(chan struct{})
+	go func() {
+		server.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if err := server.Unmount(); err != nil {
+			return errors.Newf(errors.CodeIO, "unmount", "%w (close the files open under %s and try again)", err, mountpoint)
+		}
+		<-done
+	}
+	logger.Info("unmounted", "mountpoint", mountpoint)
+	return nil
+}
+
+// build adds list to the tree under root, creating directories that the
+// archive implies but does not list.
+func build(ctx context.Context, root *dirNode, list []node, logger *slog.Logger) {
+	dirs := map[string]*gofs.Inode{".": &root.Inode}
+
+	var dir func(name string) *gofs.Inode
+	dir = func(name string) *gofs.Inode {
+		if inode, ok := dirs[name]; ok {
+			return inode
+		}
+		parent := dir(path.Dir(name))
+		inode := parent.NewPersistentInode(ctx, &dirNode{modTime: root.modTime}, gofs.StableAttr{Mode: fuse.S_IFDIR})
+		parent.AddChild(path.Base(name), inode, true)
+		dirs[name] = inode
+		return inode
+	}
+
+	for _, n := range list {
+		switch {
+		case n.mode.IsDir():
+			inode := dir(n.path)
+			if d, ok := inode.Operations().(*dirNode); ok {
+				d.modTime = n.modTime
+			}
+		case n.link != "":
+			parent := dir(path.Dir(n.path))
+			link := &gofs.MemSymlink{Data: []byte(n.link)}
+			link.Attr.Size = uint64(len(n.link))
+			link.Attr.SetTimes(nil, &n.modTime, nil)
+			parent.AddChild(path.Base(n.path), parent.NewPersistentInode(ctx, link, gofs.StableAttr{Mode: fuse.S_IFLNK}), true)
+		default:
+			parent := dir(path.Dir(n.path))
+			file := &fileNode{node: n, logger: logger}
+			parent.AddChild(path.Base(n.path), parent.NewPersistentInode(ctx, file, gofs.StableAttr{Mode: fuse.S_IFREG}), true)
+		}
+	}
+}
+
+type dirNode struct {
+	gofs.Inode
+	modTime time.Time
+}
+
+var _ gofs.NodeGetattrer = (*dirNode)(nil)
+
+func (d *dirNode) Getattr(_ context.Context, _ gofs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = fuse.S_IFDIR | 0o555
+	out.SetTimes(nil, &d.modTime, nil)
+	return gofs.OK
+}
+
+type fileNode struct {
+	gofs.Inode
+	node
+	logger *slog.Logger
+}
+
+var (
+	_ gofs.NodeGetattrer = (*fileNode)(nil)
+	_ gofs.NodeOpener    = (*fileNode)(nil)
+	_ gofs.NodeReader    = (*fileNode)(nil)
+)
+
+func (f *fileNode) Getattr(_ context.Context, _ gofs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = fuse.S_IFREG | uint32(f.mode.Perm())
+	out.Size = uint64(f.size)
+	out.SetTimes(nil, &f.modTime, nil)
+	return gofs.OK
+}
+
+func (f *fileNode) Open(_ context.Context, flags uint32) (gofs.FileHandle, uint32, syscall.Errno) {
+	if flags&(syscall.O_WRONLY|syscall.O_RDWR|syscall.O_TRUNC|syscall.O_APPEND) != 0 {
+		return nil, 0, syscall.EROFS
+	}
+	return nil, fuse.FOPEN_KEEP_CACHE, gofs.OK
+}
+
+func (f *fileNode) Read(_ context.Context, _ gofs.FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	n, err := f.data.ReadAt(dest, off)
+	if err != nil && err != io.EOF {
+		f.logger.Error("read failed", "path", f.path, "offset", off, "error", err)
+		return nil, syscall.EIO
+	}
+	return fuse.ReadResultData(dest[:n]), gofs.OK
+}
@@ -0,0 +1,139 @@
+package netclient
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultTimeout = 30 * time.Second
+
+var (
+	proxyMu  sync.RWMutex
+	proxyURL *url.URL
+)
+
+func ParseProxy(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q (use http, https or socks5)", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy %q has no host", raw)
+	}
+	return u, nil
+}
+
+func SetProxy(raw string) error {
+	var u *url.URL
+	if raw != "" {
+		var err error
+		if u, err = ParseProxy(raw); err != nil {
+			return err
+		}
+	}
+
+	proxyMu.Lock()
+	defer proxyMu.Unlock()
+	proxyURL = u
+	return nil
+}
+
+func Proxy(req *http.Request) (*url.URL, error) {
+	proxyMu.RLock()
+	u := proxyURL
+	proxyMu.RUnlock()
+
+	if u == nil {
+		return http.ProxyFromEnvironment(req)
+	}
+	if bypassProxy(req.URL, noProxy()) {
+		return nil, nil
+	}
+	return u, nil
+}
+
+func New() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = Proxy
+	return &http.Client{Transport: transport, Timeout: DefaultTimeout}
+}
+
+// NewStreaming returns a client for transfers that may outlast
+// DefaultTimeout: only the wait for the response headers is bounded.
+func NewStreaming() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = Proxy
+	transport.ResponseHeaderTimeout = DefaultTimeout
+	return &http.Client{Transport: transport}
+}
+
+func noProxy() string {
+	if v := os.Getenv("NO_PROXY"); v != "" {
+		return v
+	}
+	return os.Getenv("no_proxy")
+}
+
+func bypassProxy(target *url.URL, patterns string) bool {
+	host := strings.ToLower(target.Hostname())
+	port := target.Port()
+	if host == "localhost" || isLoopback(host) {
+		return true
+	}
+
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if pattern == "*" {
+			return true
+		}
+
+		if _, network, err := net.ParseCIDR(pattern); err == nil {
+			if ip := net.ParseIP(host); ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		if h, p, err := net.SplitHostPort(pattern); err == nil {
+			if p != port {
+				continue
+			}
+			pattern = h
+		}
+
+		pattern = strings.TrimPrefix(pattern, "*")
+		if strings.HasPrefix(pattern, ".") {
+			if strings.HasSuffix(host, pattern) || host == pattern[1:] {
+				return true
+			}
+			continue
+		}
+		if host == pattern || strings.HasSuffix(host, "."+pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func isLoopback(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
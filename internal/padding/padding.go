@@ -1,8 +1,8 @@
 package padding
 
 import (
-	"bytes"
 	"fmt"
+	"slices"
 )
 
 const (
@@ -27,8 +27,11 @@ func (p *Padding) Pad(data []byte) ([]byte, error) {
 	}
 
 	paddingLen := p.blockSize - (len(data) % p.blockSize)
-	padding := bytes.Repeat([]byte{byte(paddingLen & 0xff)}, paddingLen)
-	return append(data, padding...), nil
+	data = slices.Grow(data, paddingLen)
+	for range paddingLen {
+		data = append(data, byte(paddingLen&0xff))
+	}
+	return data, nil
 }
 
 func (p *Padding) Unpad(data []byte) ([]byte, error) {
@@ -0,0 +1,371 @@
+package parity
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/tempfile"
+	"github.com/klauspost/reedsolomon"
+)
+
+const (
+	FileExtension = ".par"
+
+	DataShards   = 16
+	ParityShards = 4
+	MaxShardSize = 64 * 1024
+
+	magic         = "SWPR"
+	version       = 1
+	minShardSize  = 64
+	headerSize    = len(magic) + 1 + 1 + 1 + 4 + 8 + sha256.Size
+	headerMACSize = sha256.Size
+)
+
+var (
+	ErrInvalidFile   = errors.Sentinel("not a sweetbyte parity file")
+	ErrUnrepairable  = errors.Sentinel("some stripes are damaged beyond what parity can repair")
+	ErrHashMismatch  = errors.Sentinel("repaired file does not match the hash recorded in the parity file")
+	ErrParityDamaged = errors.Sentinel("parity file header is damaged")
+)
+
+type Info struct {
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	ShardSize  int    `json:"shard_size"`
+	Stripes    int64  `json:"stripes"`
+	ParitySize int64  `json:"parity_size"`
+}
+
+type Report struct {
+	Path                 string  `json:"path"`
+	Size                 int64   `json:"size"`
+	Stripes              int64   `json:"stripes"`
+	Truncated            bool    `json:"truncated,omitempty"`
+	DamagedStripes       []int64 `json:"damaged_stripes,omitempty"`
+	RepairedShards       int     `json:"repaired_shards"`
+	ParityShardsDamaged  int     `json:"parity_shards_damaged"`
+	UnrecoverableStripes int     `json:"unrecoverable_stripes"`
+}
+
+func (r Report) Intact() bool {
+	return !r.Truncated && len(r.DamagedStripes) == 0
+}
+
+func DefaultPath(path string) string {
+	return path + FileExtension
+}
+
+type layout struct {
+	dataShards   int
+	parityShards int
+	shardSize    int
+	size         int64
+	digest       []byte
+}
+
+func newLayout(size int64) layout {
+	shardSize := int64(MaxShardSize)
+	if perShard := (size + DataShards - 1) / DataShards; perShard < shardSize {
+		shardSize = max(minShardSize, (perShard+minShardSize-1)/minShardSize*minShardSize)
+	}
+	return layout{dataShards: DataShards, parityShards: ParityShards, shardSize: int(shardSize), size: size}
+}
+
+func (l layout) stripeData() int64 {
+	return int64(l.dataShards) * int64(l.shardSize)
+}
+
+func (l layout) stripes() int64 {
+	return (l.size + l.stripeData() - 1) / l.stripeData()
+}
+
+func (l layout) stripeRecord() int64 {
+	return int64(l.dataShards+l.parityShards)*sha256.Size + int64(l.parityShards)*int64(l.shardSize)
+}
+
+func (l layout) marshal() []byte {
+	data := make([]byte, 0, headerSize+headerMACSize)
+	data = append(data, magic...)
+	data = append(data, version, byte(l.dataShards), byte(l.parityShards))
+	data = binary.BigEndian.AppendUint32(data, uint32(l.shardSize))
+	data = binary.BigEndian.AppendUint64(data, uint64(l.size))
+	data = append(data, l.digest...)
+	sum := sha256.Sum256(data)
+	return append(data, sum[:]...)
+}
+
+func readLayout(r io.Reader) (layout, error) {
+	data := make([]byte, headerSize+headerMACSize)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return layout{}, ErrInvalidFile
+	}
+	if string(data[:len(magic)]) != magic {
+		return layout{}, ErrInvalidFile
+	}
+	if sum := sha256.Sum256(data[:headerSize]); !bytes.Equal(sum[:], data[headerSize:]) {
+		return layout{}, ErrParityDamaged
+	}
+	if data[len(magic)] != version {
+		return layout{}, ErrInvalidFile
+	}
+
+	offset := len(magic) + 1
+	l := layout{
+		dataShards:   int(data[offset]),
+		parityShards: int(data[offset+1]),
+		shardSize:    int(binary.BigEndian.Uint32(data[offset+2:])),
+		size:         int64(binary.BigEndian.Uint64(data[offset+6:])),
+		digest:       bytes.Clone(data[offset+14 : headerSize]),
+	}
+	if l.dataShards == 0 || l.parityShards == 0 || l.dataShards+l.parityShards > 256 || l.shardSize < minShardSize || l.shardSize > MaxShardSize || l.size < 0 {
+		return layout{}, ErrParityDamaged
+	}
+	return l, nil
+}
+
+func Create(ctx context.Context, srcPath, parityPath string) (info Info, err error) {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return info, errors.New(errors.CodeIO, "open", err).WithPath(srcPath)
+	}
+	defer src.Close()
+
+	stat, err := src.Stat()
+	if err != nil {
+		return info, errors.New(errors.CodeIO, "stat", err).WithPath(srcPath)
+	}
+	l := newLayout(stat.Size())
+
+	encoder, err := reedsolomon.New(l.dataShards, l.parityShards)
+	if err != nil {
+		return info, err
+	}
+
+	dest, err := tempfile.CreateAtomic(parityPath)
+	if err != nil {
+		return info, err
+	}
+	defer func() {
+		if err != nil {
+			_ = dest.Abort()
+		}
+	}()
+	if _, err := dest.Write(make([]byte, headerSize+headerMACSize)); err != nil {
+		return info, errors.New(errors.CodeIO, "write", err).WithPath(dest.Path())
+	}
+
+	digest := sha256.New()
+	shards := newShards(l)
+	record := make([]byte, 0, l.stripeRecord())
+	for stripe := range l.stripes() {
+		if err := ctx.Err(); err != nil {
+			return info, err
+		}
+
+		if _, err := readStripe(io.TeeReader(src, digest), shards[:l.dataShards], l.size-stripe*l.stripeData()); err != nil {
+			return info, errors.New(errors.CodeIO, "read", err).WithPath(srcPath)
+		}
+		if err := encoder.Encode(shards); err != nil {
+			return info, err
+		}
+
+		record = record[:0]
+		for _, shard := range shards {
+			sum := sha256.Sum256(shard)
+			record = append(record, sum[:]...)
+		}
+		for _, shard := range shards[l.dataShards:] {
+			record = append(record, shard...)
+		}
+		if _, err := dest.Write(record); err != nil {
+			return info, errors.New(errors.CodeIO, "write", err).WithPath(dest.Path())
+		}
+	}
+
+	l.digest = digest.Sum(nil)
+	if _, err := dest.WriteAt(l.marshal(), 0); err != nil {
+		return info, errors.New(errors.CodeIO, "write", err).WithPath(dest.Path())
+	}
+	if err := dest.Commit(); err != nil {
+		return info, err
+	}
+
+	return Info{
+		Path:       parityPath,
+		Size:       l.size,
+		ShardSize:  l.shardSize,
+		Stripes:    l.stripes(),
+		ParitySize: int64(headerSize+headerMACSize) + l.stripes()*l.stripeRecord(),
+	}, nil
+}
+
+func Repair(ctx context.Context, srcPath, parityPath, destPath string) (report Report, err error) {
+	report.Path = srcPath
+
+	par, err := os.Open(parityPath)
+	if err != nil {
+		return report, errors.New(errors.CodeIO, "open", err).WithPath(parityPath)
+	}
+	defer par.Close()
+
+	l, err := readLayout(par)
+	if err != nil {
+		return report, errors.New(errors.CodeCorrupt, "read parity", err).WithPath(parityPath)
+	}
+	report.Size = l.size
+	report.Stripes = l.stripes()
+
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return report, errors.New(errors.CodeIO, "open", err).WithPath(srcPath)
+	}
+	defer src.Close()
+
+	var (
+		dest   *tempfile.File
+		output io.Writer = io.Discard
+	)
+	if destPath != "" {
+		if dest, err = tempfile.CreateAtomic(destPath); err != nil {
+			return report, err
+		}
+		defer func() {
+			if err != nil {
+				_ = dest.Abort()
+			}
+		}()
+		if stat, statErr := src.Stat(); statErr == nil {
+			if err := dest.Chmod(stat.Mode().Perm()); err != nil {
+				return report, errors.New(errors.CodeIO, "chmod", err).WithPath(dest.Path())
+			}
+		}
+		output = dest
+	}
+
+	if err := repairStripes(ctx, src, par, output, l, &report); err != nil {
+		return report, err
+	}
+
+	if dest != nil {
+		if err := dest.Commit(); err != nil {
+			return report, err
+		}
+	}
+	if report.UnrecoverableStripes > 0 {
+		return report, errors.New(errors.CodeCorrupt, "parity repair", ErrUnrepairable).WithPath(srcPath)
+	}
+	return report, nil
+}
+
+func repairStripes(ctx context.Context, src, par io.Reader, output io.Writer, l layout, report *Report) error {
+	encoder, err := reedsolomon.New(l.dataShards, l.parityShards)
+	if err != nil {
+		return err
+	}
+
+	var (
+		digest  = sha256.New()
+		shards  = newShards(l)
+		present = make([][]byte, len(shards))
+		sums    = make([]byte, (l.dataShards+l.parityShards)*sha256.Size)
+	)
+	for stripe := range l.stripes() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		remaining := l.size - stripe*l.stripeData()
+		n, err := readStripe(src, shards[:l.dataShards], remaining)
+		if err != nil {
+			return errors.New(errors.CodeIO, "read", err).WithPath(report.Path)
+		}
+		if n < min(remaining, l.stripeData()) {
+			report.Truncated = true
+		}
+		if _, err := io.ReadFull(par, sums); err != nil {
+			return errors.New(errors.CodeCorrupt, "read parity", err).WithChunk(uint64(stripe))
+		}
+		for _, shard := range shards[l.dataShards:] {
+			if _, err := io.ReadFull(par, shard); err != nil {
+				return errors.New(errors.CodeCorrupt, "read parity", err).WithChunk(uint64(stripe))
+			}
+		}
+
+		damaged, damagedData := 0, 0
+		for i, shard := range shards {
+			present[i] = shard
+			if sum := sha256.Sum256(shard); !bytes.Equal(sum[:], sums[i*sha256.Size:(i+1)*sha256.Size]) {
+				present[i] = shard[:0]
+				damaged++
+				if i < l.dataShards {
+					damagedData++
+				} else {
+					report.ParityShardsDamaged++
+				}
+			}
+		}
+
+		if damaged > 0 {
+			report.DamagedStripes = append(report.DamagedStripes, stripe)
+			if damaged > l.parityShards || encoder.ReconstructData(present) != nil {
+				report.UnrecoverableStripes++
+			} else {
+				report.RepairedShards += damagedData
+			}
+		}
+
+		if err := writeStripe(io.MultiWriter(output, digest), shards[:l.dataShards], min(remaining, l.stripeData())); err != nil {
+			return errors.New(errors.CodeIO, "write", err)
+		}
+	}
+
+	if report.UnrecoverableStripes == 0 && !bytes.Equal(digest.Sum(nil), l.digest) {
+		return errors.New(errors.CodeCorrupt, "parity repair", ErrHashMismatch).WithPath(report.Path)
+	}
+	return nil
+}
+
+func newShards(l layout) [][]byte {
+	buffer := make([]byte, (l.dataShards+l.parityShards)*l.shardSize)
+	shards := make([][]byte, l.dataShards+l.parityShards)
+	for i := range shards {
+		shards[i] = buffer[i*l.shardSize : (i+1)*l.shardSize : (i+1)*l.shardSize]
+	}
+	return shards
+}
+
+func readStripe(r io.Reader, shards [][]byte, remaining int64) (int64, error) {
+	var read int64
+	for _, shard := range shards {
+		want := min(max(remaining-read, 0), int64(len(shard)))
+		n, err := io.ReadFull(r, shard[:want])
+		clear(shard[n:])
+		read += int64(n)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			remaining = read
+		} else if err != nil {
+			return read, err
+		}
+	}
+	return read, nil
+}
+
+func writeStripe(w io.Writer, shards [][]byte, n int64) error {
+	for _, shard := range shards {
+		if n <= 0 {
+			return nil
+		}
+		part := shard[:min(n, int64(len(shard)))]
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+		n -= int64(len(part))
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+)
+
+func Authenticate(ctx context.Context, path string, password []byte, opts Options) (err error) {
+	defer wrapError("authenticate", path, &err)
+
+	srcFile, err := file.OpenSource(path, opts.DirectIO)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close()
+
+	fileHeader, key, err := openHeader(srcFile, password, opts)
+	if err != nil {
+		return err
+	}
+	defer releaseKey(key, opts)
+
+	if _, err := decryptDigest(ctx, srcFile, fileHeader, key, opts, "Authenticating..."); err != nil {
+		return errors.New(errors.CodeCorrupt, "", err)
+	}
+	return nil
+}
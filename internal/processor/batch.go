@@ -0,0 +1,49 @@
+package processor
+
+import (
+	"fmt"
+
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/secret"
+)
+
+type BatchKey struct {
+	salt    []byte
+	kek     *secret.Buffer
+	profile string
+	params  derive.Params
+}
+
+func NewBatchKey(password []byte, opts Options) (*BatchKey, error) {
+	if opts.RequireBoth && (len(password) == 0 || len(opts.Keyfile) == 0) {
+		return nil, errors.New(errors.CodeInvalidInput, "", ErrMissingFactor)
+	}
+
+	profile, params, err := resolveKDF(opts.KDFProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := derive.GetRandomBytes(derive.ArgonSaltLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	kek, err := deriveKey(password, opts.Keyfile, salt, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	if opts.Token != nil {
+		if kek, err = applyToken(kek, opts.Token, salt); err != nil {
+			return nil, err
+		}
+	}
+	return &BatchKey{salt: salt, kek: kek, profile: profile, params: params}, nil
+}
+
+func (k *BatchKey) Destroy() {
+	if k != nil {
+		k.kek.Destroy()
+	}
+}
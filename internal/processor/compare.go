@@ -0,0 +1,110 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/header"
+	"github.com/hambosto/sweetbyte/internal/types"
+)
+
+var ErrMismatch = errors.Sentinel("plaintext does not match the encrypted file")
+
+func Compare(ctx context.Context, plainPath, encryptedPath string, password []byte, opts Options) (match bool, err error) {
+	defer wrapError("compare", encryptedPath, &err)
+
+	encrypted, err := file.OpenSource(encryptedPath, opts.DirectIO)
+	if err != nil {
+		return false, fmt.Errorf("failed to open encrypted file: %w", err)
+	}
+	defer encrypted.Close()
+
+	fileHeader, err := header.NewHeader()
+	if err != nil {
+		return false, fmt.Errorf("failed to create header: %w", err)
+	}
+	if err := fileHeader.Unmarshal(encrypted); err != nil {
+		return false, fmt.Errorf("failed to unmarshal header: %w", err)
+	}
+
+	key, err := unlockWith(fileHeader, password, opts)
+	if err != nil {
+		return false, err
+	}
+	defer releaseKey(key, opts)
+
+	plainSize, err := file.Size(plainPath)
+	if err != nil {
+		return false, err
+	}
+	if !fileHeader.Streamed() && plainSize != fileHeader.GetOriginalSize() {
+		return false, nil
+	}
+
+	expected, err := hashFile(plainPath, opts.DirectIO)
+	if err != nil {
+		return false, err
+	}
+
+	actual, err := decryptDigest(ctx, encrypted, fileHeader, key, opts, "Comparing...")
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(actual, expected), nil
+}
+
+func decryptDigest(ctx context.Context, r io.Reader, fileHeader *header.Header, key []byte, opts Options, description string) ([]byte, error) {
+	pipeline, err := newPipeline(key, types.Decryption, Options{Concurrency: opts.Concurrency, MaxOutstanding: opts.MaxOutstanding, Reporter: opts.Reporter})
+	if err != nil {
+		return nil, err
+	}
+	pipeline.SetChunkFlags(fileHeader.Revision() >= header.RevisionChunkFlags)
+	pipeline.SetECC(fileHeader.HasECC())
+	pipeline.SetSuite(fileSuite(fileHeader))
+	if err := setDictionary(pipeline, fileHeader, key); err != nil {
+		return nil, err
+	}
+	if err := setTrailer(pipeline, fileHeader, key); err != nil {
+		return nil, err
+	}
+	if err := setSequence(pipeline, fileHeader); err != nil {
+		return nil, err
+	}
+	if err := setChunkSize(pipeline, fileHeader); err != nil {
+		return nil, err
+	}
+	if err := limitMemory(pipeline, opts.MaxMemory); err != nil {
+		return nil, err
+	}
+	pipeline.SetDescription(description)
+
+	size, err := decryptedSize(fileHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.New()
+	if err := pipeline.Process(ctx, r, digest, size); err != nil {
+		return nil, err
+	}
+	return digest.Sum(nil), nil
+}
+
+func hashFile(path string, directIO bool) ([]byte, error) {
+	f, err := file.OpenSource(path, directIO)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	digest := sha256.New()
+	if _, err := io.Copy(digest, f); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return digest.Sum(nil), nil
+}
@@ -0,0 +1,64 @@
+package processor
+
+import (
+	"fmt"
+
+	"github.com/hambosto/sweetbyte/internal/compression"
+	"github.com/hambosto/sweetbyte/internal/envelope"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/header"
+	"github.com/hambosto/sweetbyte/internal/stream"
+)
+
+const dictionaryInfo = "sweetbyte compression dictionary v1"
+
+// sealDictionary stores dict in the header, encrypted under the data key
+// since it is made of plaintext. It is compressed first, as the header is
+// stored with heavy parity.
+func sealDictionary(h *header.Header, key, dict []byte) error {
+	compressor, err := compression.NewCompression(compression.LevelBestCompression)
+	if err != nil {
+		return err
+	}
+	packed, err := compressor.Compress(dict)
+	if err != nil {
+		return fmt.Errorf("failed to compress dictionary: %w", err)
+	}
+	sealed, err := envelope.Seal(key, dictionaryInfo, packed)
+	if err != nil {
+		return err
+	}
+	h.SetDictionary(sealed)
+	return nil
+}
+
+func openDictionary(h *header.Header, key []byte) ([]byte, bool, error) {
+	sealed, ok := h.Dictionary()
+	if !ok {
+		return nil, false, nil
+	}
+	packed, err := envelope.Open(key, dictionaryInfo, sealed)
+	if err != nil {
+		return nil, false, errors.New(errors.CodeCorrupt, "opening compression dictionary", err)
+	}
+	compressor, err := compression.NewCompression(compression.LevelBestCompression)
+	if err != nil {
+		return nil, false, err
+	}
+	dict, err := compressor.Decompress(packed)
+	if err != nil {
+		return nil, false, errors.New(errors.CodeCorrupt, "opening compression dictionary", err)
+	}
+	return dict, true, nil
+}
+
+func setDictionary(pipeline *stream.Pipeline, h *header.Header, key []byte) error {
+	dict, ok, err := openDictionary(h, key)
+	if err != nil || !ok {
+		return err
+	}
+	if err := pipeline.SetDictionary(dict); err != nil {
+		return errors.New(errors.CodeCorrupt, "", err)
+	}
+	return nil
+}
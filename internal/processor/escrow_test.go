@@ -0,0 +1,117 @@
+package processor_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/escrow"
+	"github.com/hambosto/sweetbyte/internal/processor"
+)
+
+// recoveryKeys writes a new recovery key pair and reads both halves back.
+func recoveryKeys(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := escrow.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPath, privatePath, err := escrow.WriteKeyPair(filepath.Join(t.TempDir(), "recovery"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return publicPath, privatePath
+}
+
+// exportEscrow seals the data key of the file at path to the recovery key
+// at publicPath, the way escrow export does, and returns the blob's path.
+func exportEscrow(t *testing.T, path, publicPath string) string {
+	t.Helper()
+	recipient, err := escrow.ReadPublicKey(publicPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataKey, fileID, err := processor.UnlockDataKey(path, password, options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, err := escrow.Seal(recipient, fileID, dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := blob.Write(path + escrow.FileExtension); err != nil {
+		t.Fatal(err)
+	}
+	return path + escrow.FileExtension
+}
+
+// importEscrow decrypts the file at path with the blob at blobPath and the
+// recovery key at privatePath, the way escrow import does.
+func importEscrow(t *testing.T, path, blobPath, privatePath string) ([]byte, error) {
+	t.Helper()
+	key, err := escrow.ReadPrivateKey(privatePath)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := escrow.ReadBlob(blobPath)
+	if err != nil {
+		return nil, err
+	}
+	fileID, err := processor.FileID(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fileID, blob.FileID) {
+		return nil, escrow.ErrWrongFile
+	}
+	dataKey, err := blob.Open(key)
+	if err != nil {
+		return nil, err
+	}
+
+	dest := filepath.Join(t.TempDir(), "recovered")
+	opts := options()
+	opts.DataKey = dataKey
+	if err := processor.Decryption(context.Background(), path, dest, nil, opts); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(dest)
+}
+
+func TestEscrowRoundTrip(t *testing.T) {
+	data := plaintext(chunkSize+17, 28)
+	path := writeFile(t, "plain.swx", encrypt(t, data, password, options()))
+	publicPath, privatePath := recoveryKeys(t)
+
+	recovered, err := importEscrow(t, path, exportEscrow(t, path, publicPath), privatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(recovered, data) {
+		t.Error("recovered file differs from the plaintext")
+	}
+}
+
+func TestEscrowFailures(t *testing.T) {
+	path := writeFile(t, "plain.swx", encrypt(t, plaintext(100, 29), password, options()))
+	other := writeFile(t, "other.swx", encrypt(t, plaintext(100, 30), password, options()))
+	publicPath, privatePath := recoveryKeys(t)
+	_, otherPrivatePath := recoveryKeys(t)
+	blobPath := exportEscrow(t, path, publicPath)
+
+	if _, err := importEscrow(t, path, blobPath, otherPrivatePath); !errors.Is(err, escrow.ErrWrongKey) {
+		t.Errorf("importing with another recovery key: %v, want %v", err, escrow.ErrWrongKey)
+	}
+	if _, err := importEscrow(t, other, blobPath, privatePath); !errors.Is(err, escrow.ErrWrongFile) {
+		t.Errorf("importing another file's blob: %v, want %v", err, escrow.ErrWrongFile)
+	}
+	if _, err := importEscrow(t, path, publicPath, privatePath); !errors.Is(err, escrow.ErrInvalidBlob) {
+		t.Errorf("importing a key as a blob: %v, want %v", err, escrow.ErrInvalidBlob)
+	}
+	if _, err := importEscrow(t, path, blobPath, publicPath); !errors.Is(err, escrow.ErrInvalidKey) {
+		t.Errorf("importing with a public key: %v, want %v", err, escrow.ErrInvalidKey)
+	}
+}
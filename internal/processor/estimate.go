@@ -0,0 +1,189 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hambosto/sweetbyte/internal/cipher"
+	"github.com/hambosto/sweetbyte/internal/compression"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/header"
+	"github.com/hambosto/sweetbyte/internal/stream"
+	"github.com/hambosto/sweetbyte/internal/stream/chunk"
+	"github.com/hambosto/sweetbyte/internal/stream/processing"
+	"github.com/hambosto/sweetbyte/internal/types"
+)
+
+const (
+	estimateSampleSize = 1024 * 1024
+	estimateSamples    = 4
+	estimateHeaderSize = 1280
+	framePrefixSize    = 4
+)
+
+type Estimate struct {
+	InputSize   int64
+	OutputSize  int64
+	DataSize    int64
+	PaddingSize int64
+	CipherSize  int64
+	ParitySize  int64
+	FramingSize int64
+	Chunks      int64
+	ChunkSize   int
+	Suite       cipher.Suite
+	ECC         bool
+}
+
+func (e Estimate) CompressionRatio() float64 {
+	if e.InputSize == 0 {
+		return 1
+	}
+	return float64(e.DataSize) / float64(e.InputSize)
+}
+
+func (e *Estimate) Add(other Estimate) {
+	e.InputSize += other.InputSize
+	e.OutputSize += other.OutputSize
+	e.DataSize += other.DataSize
+	e.PaddingSize += other.PaddingSize
+	e.CipherSize += other.CipherSize
+	e.ParitySize += other.ParitySize
+	e.FramingSize += other.FramingSize
+	e.Chunks += other.Chunks
+	e.Suite = other.Suite
+	e.ECC = other.ECC
+}
+
+func EstimateOutputSize(srcPath string, mode types.ProcessorMode, opts Options) (Estimate, error) {
+	switch mode {
+	case types.ModeEncrypt:
+		return EstimateEncryption(srcPath, opts)
+	case types.ModeDecrypt:
+		return EstimateDecryption(srcPath)
+	default:
+		return Estimate{}, fmt.Errorf("unknown processing mode: %v", mode)
+	}
+}
+
+func EstimateEncryption(srcPath string, opts Options) (Estimate, error) {
+	size, err := file.Size(srcPath)
+	if err != nil {
+		return Estimate{}, err
+	}
+	suite, err := cipher.ParseSuite(opts.Cipher)
+	if err != nil {
+		return Estimate{}, errors.New(errors.CodeInvalidInput, "--cipher", err)
+	}
+
+	chunkSize := opts.ChunkSize
+	switch {
+	case chunkSize > 0:
+	case opts.Deterministic:
+		chunkSize = stream.DefaultChunkSize
+	default:
+		chunkSize = stream.AutoChunkSize(size)
+	}
+
+	ratio, err := sampleCompressionRatio(srcPath, size, chunkSize)
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	estimate := Estimate{
+		InputSize:   size,
+		FramingSize: estimateHeaderSize + chunk.TrailerSize,
+		ChunkSize:   chunkSize,
+		Suite:       suite,
+		ECC:         !opts.NoECC,
+	}
+	estimate.addChunks(size/int64(chunkSize), chunkSize, ratio)
+	estimate.addChunks(1, int(size%int64(chunkSize)), ratio)
+	if opts.Seekable {
+		estimate.FramingSize += chunk.IndexSize(uint64(estimate.Chunks))
+	}
+	estimate.OutputSize = estimate.DataSize + estimate.PaddingSize + estimate.CipherSize + estimate.ParitySize + estimate.FramingSize
+	return estimate, nil
+}
+
+func (e *Estimate) addChunks(count int64, length int, ratio float64) {
+	if count == 0 || length == 0 {
+		return
+	}
+
+	compressed := min(int(float64(length)*ratio+0.5), length)
+	layout := processing.EncryptedLayout(compressed, e.Suite, e.ECC)
+	e.Chunks += count
+	e.DataSize += count * int64(compressed)
+	e.PaddingSize += count * int64(layout.Padded-compressed)
+	e.CipherSize += count * int64(layout.Sealed-layout.Padded)
+	e.ParitySize += count * int64(layout.Encoded-layout.Sealed)
+	e.FramingSize += count * framePrefixSize
+}
+
+func EstimateDecryption(srcPath string) (Estimate, error) {
+	inputSize, err := file.Size(srcPath)
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	srcFile, err := file.OpenFile(srcPath)
+	if err != nil {
+		return Estimate{}, err
+	}
+	defer srcFile.Close()
+
+	fileHeader, err := header.NewHeader()
+	if err != nil {
+		return Estimate{}, fmt.Errorf("failed to create header: %w", err)
+	}
+
+	if err := fileHeader.UnmarshalLazy(srcFile); err != nil {
+		return Estimate{}, err
+	}
+
+	return Estimate{InputSize: inputSize, OutputSize: fileHeader.GetOriginalSize()}, nil
+}
+
+func sampleCompressionRatio(srcPath string, size int64, chunkSize int) (float64, error) {
+	if size == 0 {
+		return 1, nil
+	}
+
+	srcFile, err := file.OpenFile(srcPath)
+	if err != nil {
+		return 0, err
+	}
+	defer srcFile.Close()
+
+	compressor, err := compression.NewCompression(processing.CompressionLevel)
+	if err != nil {
+		return 0, fmt.Errorf("compressor initialization: %w", err)
+	}
+
+	sampleSize := min(size, int64(chunkSize), estimateSampleSize)
+	samples := min(int64(estimateSamples), (size+sampleSize-1)/sampleSize)
+	sample := make([]byte, sampleSize)
+
+	var raw, compressed int64
+	for i := range samples {
+		offset := (size - sampleSize) * i / max(samples-1, 1)
+		n, err := srcFile.ReadAt(sample, offset)
+		if err != nil && err != io.EOF {
+			return 0, fmt.Errorf("failed to read sample: %w", err)
+		}
+
+		packed, err := compressor.Compress(sample[:n])
+		if err != nil {
+			return 0, fmt.Errorf("failed to compress sample: %w", err)
+		}
+		raw += int64(n)
+		compressed += int64(min(len(packed), n))
+	}
+	if raw == 0 {
+		return 1, nil
+	}
+
+	return float64(compressed) / float64(raw), nil
+}
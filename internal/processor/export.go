@@ -0,0 +1,88 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hambosto/sweetbyte/internal/age"
+	"github.com/hambosto/sweetbyte/internal/storage"
+	"github.com/hambosto/sweetbyte/internal/types"
+)
+
+// ExportAge decrypts the sweetbyte file at srcPath and encrypts the plaintext
+// to recipients in the age format, one chunk at a time, so the plaintext
+// never reaches the disk. Either path may be StdioPath or an object storage
+// URL.
+func ExportAge(ctx context.Context, srcPath, destPath string, password []byte, opts Options, recipients []age.Recipient) (err error) {
+	defer wrapError("export", srcPath, &err)
+
+	src, _, err := openStream(ctx, srcPath, opts.DirectIO)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if IsStdio(destPath) {
+		return exportAge(ctx, src, os.Stdout, password, opts, recipients)
+	}
+	if storage.IsRemote(destPath) {
+		return writeObject(ctx, destPath, func(w io.Writer) error {
+			return exportAge(ctx, src, w, password, opts, recipients)
+		})
+	}
+
+	output, err := createOutput(destPath, opts.Mode)
+	if err != nil {
+		return err
+	}
+	defer closeOutput(output, opts.KeepPartial, &err)
+
+	if err := exportAge(ctx, src, output, password, opts, recipients); err != nil {
+		return err
+	}
+	if err := output.Sync(); err != nil {
+		return fmt.Errorf("failed to sync output: %w", err)
+	}
+	return output.Commit()
+}
+
+func exportAge(ctx context.Context, src io.Reader, dst io.Writer, password []byte, opts Options, recipients []age.Recipient) error {
+	plaintext, err := age.Encrypt(dst, recipients...)
+	if err != nil {
+		return err
+	}
+	if err := decryptStream(ctx, src, plaintext, password, opts); err != nil {
+		return err
+	}
+	if err := plaintext.Close(); err != nil {
+		return fmt.Errorf("failed to finish age payload: %w", err)
+	}
+	return nil
+}
+
+// ImportAge decrypts the age file at srcPath with identities and encrypts
+// the plaintext into a sweetbyte file with password and opts. Either path
+// may be StdioPath or an object storage URL.
+func ImportAge(ctx context.Context, srcPath, destPath string, password []byte, opts Options, identities []age.Identity) (err error) {
+	defer wrapError("import", srcPath, &err)
+
+	src, _, err := openStream(ctx, srcPath, opts.DirectIO)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	plaintext, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return err
+	}
+
+	// age does not record the plaintext size, so the file is written as
+	// streamed, like input from a pipe.
+	if IsStdio(destPath) {
+		return streamTo(ctx, types.ModeEncrypt, plaintext, -1, os.Stdout, password, opts)
+	}
+	return streamToFile(ctx, types.ModeEncrypt, plaintext, -1, destPath, password, opts)
+}
@@ -0,0 +1,195 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/header"
+	"github.com/hambosto/sweetbyte/internal/hidden"
+	"github.com/hambosto/sweetbyte/internal/reporter"
+	"github.com/hambosto/sweetbyte/internal/secret"
+	"github.com/hambosto/sweetbyte/internal/tempfile"
+	"github.com/hambosto/sweetbyte/internal/types"
+)
+
+// padding is the random region written after the trailer. When it carries a
+// hidden file, that file is encrypted on its own with the hidden password
+// first and then sealed into the padding.
+type padding struct {
+	size     int64
+	payload  *tempfile.File
+	length   int64
+	password []byte
+	keyfile  []byte
+	params   derive.Params
+}
+
+func checkPadding(password []byte, opts Options) error {
+	if opts.Padding < 0 {
+		return errors.Newf(errors.CodeInvalidInput, "", "padding cannot be negative")
+	}
+	if opts.Padding > 0 && opts.Deterministic {
+		return errors.Newf(errors.CodeInvalidInput, "", "padding cannot be combined with deterministic encryption")
+	}
+	if opts.HiddenPath == "" {
+		return nil
+	}
+	if opts.Recipient != nil || opts.BatchKey != nil || opts.Deterministic {
+		return errors.Newf(errors.CodeInvalidInput, "", "a hidden file needs a password of its own and cannot be combined with a recipient, a batch key or deterministic encryption")
+	}
+	if len(opts.HiddenPassword) == 0 || bytes.Equal(opts.HiddenPassword, password) {
+		return errors.Newf(errors.CodeInvalidInput, "", "the hidden file needs a password that differs from the decoy password")
+	}
+	return nil
+}
+
+// preparePadding sizes the padding and, for a hidden file, encrypts it to a
+// temporary file. The caller must call release when done.
+func preparePadding(ctx context.Context, password []byte, opts Options) (*padding, error) {
+	if err := checkPadding(password, opts); err != nil {
+		return nil, err
+	}
+	if opts.HiddenPath == "" {
+		return &padding{size: hidden.Size(opts.Padding, 0)}, nil
+	}
+
+	_, params, err := resolveKDF(opts.KDFProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := file.OpenSource(opts.HiddenPath, opts.DirectIO)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open hidden file: %w", err)
+	}
+	defer src.Close()
+
+	size, err := file.Size(opts.HiddenPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hidden file size: %w", err)
+	}
+
+	payload, err := tempfile.Create("sweetbyte-hidden-*")
+	if err != nil {
+		return nil, err
+	}
+
+	inner := Options{
+		KDFProfile: opts.KDFProfile,
+		Cipher:     opts.Cipher,
+		Keyfile:    opts.Keyfile,
+		NoECC:      opts.NoECC,
+		Reporter:   reporter.Nop(),
+	}
+	if err := encryptStream(ctx, src, size, payload, opts.HiddenPassword, inner); err != nil {
+		_ = payload.Remove()
+		return nil, fmt.Errorf("failed to encrypt hidden file: %w", err)
+	}
+
+	length, err := payload.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = payload.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = payload.Remove()
+		return nil, errors.New(errors.CodeIO, "seek", err).WithPath(payload.Path())
+	}
+
+	return &padding{
+		size:     hidden.Size(opts.Padding, length),
+		payload:  payload,
+		length:   length,
+		password: opts.HiddenPassword,
+		keyfile:  opts.Keyfile,
+		params:   params,
+	}, nil
+}
+
+func (p *padding) write(w io.Writer) error {
+	if p.size == 0 {
+		return nil
+	}
+	if p.payload == nil {
+		return hidden.Fill(w, p.size)
+	}
+	return hidden.Write(w, p.size, p.payload, p.length, func(salt []byte) (*secret.Buffer, error) {
+		return deriveKey(p.password, p.keyfile, salt, p.params)
+	})
+}
+
+func (p *padding) release() {
+	if p.payload != nil {
+		_ = p.payload.Remove()
+	}
+}
+
+// copyPadding carries the padding of srcPath over unchanged, so a hidden file
+// survives a change of the decoy password.
+func copyPadding(srcPath string, dst io.Writer, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+
+	src, err := file.OpenFile(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer src.Close()
+
+	if _, err := src.Seek(-size, io.SeekEnd); err != nil {
+		return errors.New(errors.CodeIO, "seek", err).WithPath(srcPath)
+	}
+	if _, err := io.CopyN(dst, src, size); err != nil {
+		return fmt.Errorf("failed to copy padding: %w", err)
+	}
+	return nil
+}
+
+// decryptHidden looks for a hidden file in the padding of srcPath that opens
+// with password. It returns hidden.ErrNotFound when there is none, which is
+// indistinguishable from a wrong password.
+func decryptHidden(ctx context.Context, srcPath, destPath string, password []byte, opts Options) error {
+	srcFile, err := file.OpenFile(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close()
+
+	fileHeader, err := header.NewHeader()
+	if err != nil {
+		return fmt.Errorf("failed to create header: %w", err)
+	}
+	if err := fileHeader.Unmarshal(srcFile); err != nil {
+		return fmt.Errorf("failed to unmarshal header: %w", err)
+	}
+
+	size := fileHeader.Padding()
+	if _, ok := fileHeader.RecipientKey(); ok || size == 0 || len(password) == 0 {
+		return errors.New(errors.CodeAuthentication, "", hidden.ErrNotFound)
+	}
+	params, err := fileHeader.KDFParams()
+	if err != nil {
+		return errors.New(errors.CodeCorrupt, "", err)
+	}
+
+	if _, err := srcFile.Seek(-size, io.SeekEnd); err != nil {
+		return errors.New(errors.CodeIO, "seek", err)
+	}
+	payload, _, err := hidden.Open(srcFile, size, func(salt []byte) (*secret.Buffer, error) {
+		return deriveKey(password, opts.Keyfile, salt, params)
+	})
+	if err != nil {
+		return err
+	}
+
+	inner := opts
+	inner.DataKey = nil
+	inner.PreserveTimes = false
+	inner.PreserveOwner = false
+	return streamToFile(ctx, types.ModeDecrypt, payload, -1, destPath, password, inner)
+}
@@ -0,0 +1,113 @@
+package processor_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/hidden"
+	"github.com/hambosto/sweetbyte/internal/processor"
+)
+
+var hiddenPassword = []byte("a different horse entirely")
+
+// encryptHidden encrypts decoy under password with secret hidden in the
+// padding under hiddenPassword.
+func encryptHidden(t *testing.T, decoy, secret []byte) []byte {
+	t.Helper()
+	opts := options()
+	opts.HiddenPath = writeFile(t, "hidden", secret)
+	opts.HiddenPassword = hiddenPassword
+	return encrypt(t, decoy, password, opts)
+}
+
+func TestHiddenRoundTrip(t *testing.T) {
+	decoy, secret := plaintext(chunkSize+17, 12), plaintext(2*chunkSize+5, 13)
+	encrypted := encryptHidden(t, decoy, secret)
+
+	decrypted, err := decrypt(t, encrypted, options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, decoy) {
+		t.Error("the decoy password did not give the decoy file")
+	}
+
+	decrypted, err = decryptAs(t, encrypted, hiddenPassword, options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, secret) {
+		t.Error("the hidden password did not give the hidden file")
+	}
+
+	if _, err := decryptAs(t, encrypted, []byte("another password"), options()); !errors.Is(err, processor.ErrAuthentication) {
+		t.Fatalf("decrypting with the wrong password: %v, want ErrAuthentication", err)
+	}
+}
+
+// TestHiddenLooksLikePadding checks that a file with a hidden file is the
+// same size as one with plain random padding.
+func TestHiddenLooksLikePadding(t *testing.T) {
+	decoy := plaintext(chunkSize+17, 14)
+	opts := options()
+	opts.Padding = 1
+	padded := encrypt(t, decoy, password, opts)
+
+	if withHidden := encryptHidden(t, decoy, plaintext(1000, 15)); len(withHidden) != len(padded) {
+		t.Errorf("a hidden file makes the output %d bytes, plain padding %d", len(withHidden), len(padded))
+	}
+}
+
+func TestHiddenDamagedSlot(t *testing.T) {
+	decoy := plaintext(chunkSize+17, 16)
+	encrypted := encryptHidden(t, decoy, plaintext(1000, 17))
+	encrypted[len(encrypted)-hidden.Alignment+hidden.SlotSize/2] ^= 0x01
+
+	if _, err := decryptAs(t, encrypted, hiddenPassword, options()); !errors.Is(err, processor.ErrAuthentication) {
+		t.Fatalf("decrypting a damaged hidden file: %v, want ErrAuthentication", err)
+	}
+	decrypted, err := decrypt(t, encrypted, options())
+	if err != nil {
+		t.Fatalf("the decoy no longer decrypts: %v", err)
+	}
+	if !bytes.Equal(decrypted, decoy) {
+		t.Error("decrypted decoy differs from the plaintext")
+	}
+}
+
+func TestHiddenSurvivesRekey(t *testing.T) {
+	secret := plaintext(1000, 18)
+	src := writeFile(t, "plain.swx", encryptHidden(t, plaintext(chunkSize+17, 19), secret))
+	dest := filepath.Join(filepath.Dir(src), "rekeyed.swx")
+	if err := processor.Rekey(context.Background(), src, dest, password, []byte("a new decoy password"), options(), options()); err != nil {
+		t.Fatal(err)
+	}
+
+	rekeyed, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := decryptAs(t, rekeyed, hiddenPassword, options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, secret) {
+		t.Error("the hidden file changed when the decoy password did")
+	}
+}
+
+func TestHiddenPasswordMustDiffer(t *testing.T) {
+	opts := options()
+	opts.HiddenPath = writeFile(t, "hidden", plaintext(1000, 20))
+	opts.HiddenPassword = password
+	src := writeFile(t, "plain", plaintext(100, 21))
+
+	err := processor.Encryption(context.Background(), src, src+".swx", password, opts)
+	if errors.CodeOf(err) != errors.CodeInvalidInput {
+		t.Fatalf("encrypting with the same password twice: %v (%s), want %s", err, errors.CodeOf(err), errors.CodeInvalidInput)
+	}
+}
@@ -0,0 +1,39 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+// SetPunch replaces how in-place encryption checks for and frees space in
+// the source until the test ends. A nil function keeps the real one.
+func SetPunch(t testing.TB, probe func(dir string) error, punch func(f *os.File, offset, length int64) error) {
+	savedProbe, savedPunch := probePunch, punchHole
+	t.Cleanup(func() { probePunch, punchHole = savedProbe, savedPunch })
+	if probe != nil {
+		probePunch = probe
+	}
+	if punch != nil {
+		punchHole = punch
+	}
+}
+
+// SetInPlaceWindow makes in-place encryption work size bytes at a time until
+// the test ends.
+func SetInPlaceWindow(t testing.TB, size int) {
+	saved := inPlaceWindow
+	t.Cleanup(func() { inPlaceWindow = saved })
+	inPlaceWindow = size
+}
+
+// FailVerify makes the verification of encrypted output fail until the test
+// ends.
+func FailVerify(t testing.TB) {
+	saved := verifyEncrypted
+	t.Cleanup(func() { verifyEncrypted = saved })
+	verifyEncrypted = func(context.Context, string, int64, []byte, []byte, Options) error {
+		return errors.New("verification failed")
+	}
+}
@@ -0,0 +1,312 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/header"
+	"github.com/hambosto/sweetbyte/internal/reporter"
+	"github.com/hambosto/sweetbyte/internal/secret"
+	"github.com/hambosto/sweetbyte/internal/stream"
+	"github.com/hambosto/sweetbyte/internal/tempfile"
+	"github.com/hambosto/sweetbyte/internal/types"
+	"github.com/hambosto/sweetbyte/internal/utils"
+)
+
+// inPlaceWindow is how much of the source is encrypted, synced and freed
+// at a time. It and the functions that free the source are replaced in
+// tests.
+var (
+	inPlaceWindow = 64 * 1024 * 1024
+	probePunch    = file.ProbePunch
+	punchHole     = file.PunchHole
+)
+
+type inPlaceJournal struct {
+	Source     string `json:"source"`
+	SourceSize int64  `json:"source_size"`
+	Consumed   int64  `json:"consumed"`
+	Written    int64  `json:"written"`
+	Done       bool   `json:"done"`
+
+	path string
+}
+
+func JournalPath(destPath string) string {
+	return destPath + ".journal"
+}
+
+func HasJournal(destPath string) bool {
+	_, err := os.Stat(JournalPath(destPath))
+	return err == nil
+}
+
+func EncryptInPlace(ctx context.Context, srcPath, destPath string, password []byte, opts Options) (err error) {
+	defer wrapError("encrypt", srcPath, &err)
+
+	absSource, err := filepath.Abs(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve source path: %w", err)
+	}
+
+	journal, resuming, err := loadJournal(destPath)
+	if err != nil {
+		return err
+	}
+	if resuming {
+		if journal.Source != absSource {
+			return errors.Newf(errors.CodeInvalidInput, "", "journal %s belongs to %s", journal.path, journal.Source)
+		}
+		if journal.Done {
+			return finishInPlace(srcPath, journal)
+		}
+	}
+
+	srcFile, err := os.OpenFile(srcPath, os.O_RDWR, 0)
+	if err != nil {
+		return errors.New(errors.CodeIO, "open", err).WithPath(srcPath)
+	}
+	defer srcFile.Close()
+
+	if err := probePunch(filepath.Dir(srcPath)); err != nil {
+		if errors.Is(err, file.ErrPunchUnsupported) {
+			return errors.New(errors.CodeInvalidInput, "", err)
+		}
+		return errors.New(errors.CodeIO, "free source blocks", err).WithPath(srcPath)
+	}
+
+	var (
+		destFile *os.File
+		pipeline *stream.Pipeline
+	)
+	if resuming {
+		destFile, pipeline, err = resumeInPlace(srcFile, destPath, password, journal, opts)
+	} else {
+		destFile, pipeline, journal, err = startInPlace(srcPath, absSource, destPath, password, opts)
+	}
+	if err != nil {
+		return err
+	}
+	defer destFile.Close()
+
+	r := reporter.OrNop(opts.Reporter)
+	progress := r.Progress(journal.SourceSize, "Encrypting in place...")
+	if err := progress.Add(journal.Consumed); err != nil {
+		return err
+	}
+	pipeline.SetReporter(reporter.Shared(r, progress))
+
+	window := max(inPlaceWindow/pipeline.ChunkSize()*pipeline.ChunkSize(), pipeline.ChunkSize())
+	for journal.Consumed < journal.SourceSize {
+		start := journal.Consumed
+		length := min(int64(window), journal.SourceSize-start)
+
+		if err := encryptWindow(ctx, pipeline, srcFile, destFile, start, length, journal); err != nil {
+			if start == 0 && !resuming {
+				abortInPlace(destFile, destPath, journal)
+			}
+			return fmt.Errorf("stopped after %s of %s, run the same command again to resume: %w",
+				utils.FormatBytes(start), utils.FormatBytes(journal.SourceSize), err)
+		}
+	}
+
+	journal.Done = true
+	if err := journal.save(); err != nil {
+		return err
+	}
+	return finishInPlace(srcPath, journal)
+}
+
+func startInPlace(srcPath, absSource, destPath string, password []byte, opts Options) (*os.File, *stream.Pipeline, *inPlaceJournal, error) {
+	if opts.Padding != 0 || opts.HiddenPath != "" || opts.Seekable {
+		return nil, nil, nil, errors.Newf(errors.CodeInvalidInput, "", "padding, hidden files and chunk indexes cannot be used when encrypting in place")
+	}
+
+	originalSize, err := file.Size(srcPath)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get file size: %w", err)
+	}
+
+	key, pipeline, headerBytes, err := prepareEncryption(srcPath, originalSize, password, opts, func(h *header.Header, _ []byte) error {
+		h.SetTrailer(false)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	secret.Wipe(key)
+	if err := pipeline.SetTrailer(nil); err != nil {
+		return nil, nil, nil, err
+	}
+
+	mode := opts.Mode
+	if mode == 0 {
+		mode = 0o600
+	}
+	destFile, err := os.OpenFile(destPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return nil, nil, nil, errors.New(errors.CodeIO, "create", err).WithPath(destPath)
+	}
+
+	journal := &inPlaceJournal{Source: absSource, SourceSize: originalSize, path: JournalPath(destPath)}
+	if _, err := destFile.Write(headerBytes); err == nil {
+		err = destFile.Sync()
+	}
+	if err == nil {
+		err = tempfile.SyncDir(filepath.Dir(destPath))
+	}
+	if err == nil {
+		journal.Written = int64(len(headerBytes))
+		err = journal.save()
+	}
+	if err != nil {
+		abortInPlace(destFile, destPath, journal)
+		return nil, nil, nil, fmt.Errorf("failed to write header: %w", err)
+	}
+	return destFile, pipeline, journal, nil
+}
+
+func resumeInPlace(srcFile *os.File, destPath string, password []byte, journal *inPlaceJournal, opts Options) (*os.File, *stream.Pipeline, error) {
+	info, err := srcFile.Stat()
+	if err != nil {
+		return nil, nil, errors.New(errors.CodeIO, "stat", err).WithPath(srcFile.Name())
+	}
+	if info.Size() != journal.SourceSize {
+		return nil, nil, errors.Newf(errors.CodeInvalidInput, "", "source is %d bytes but the journal recorded %d; it changed since the interrupted run", info.Size(), journal.SourceSize)
+	}
+
+	destFile, err := os.OpenFile(destPath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, errors.New(errors.CodeIO, "open", err).WithPath(destPath)
+	}
+
+	pipeline, err := resumePipeline(destFile, password, opts)
+	if err == nil {
+		err = destFile.Truncate(journal.Written)
+	}
+	if err == nil {
+		_, err = destFile.Seek(journal.Written, io.SeekStart)
+	}
+	if err != nil {
+		_ = destFile.Close()
+		return nil, nil, err
+	}
+	return destFile, pipeline, nil
+}
+
+func resumePipeline(destFile *os.File, password []byte, opts Options) (*stream.Pipeline, error) {
+	fileHeader, err := header.NewHeader()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create header: %w", err)
+	}
+	if err := fileHeader.Unmarshal(destFile); err != nil {
+		return nil, errors.New(errors.CodeCorrupt, "", err)
+	}
+
+	key, err := unlockWith(fileHeader, password, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseKey(key, opts)
+
+	chunkSize, ok := fileHeader.ChunkSize()
+	if !ok {
+		return nil, errors.Newf(errors.CodeCorrupt, "", "header does not record the chunk size")
+	}
+	pipeline, err := newPipeline(key, types.Encryption, Options{ChunkSize: chunkSize, Concurrency: opts.Concurrency, MaxOutstanding: opts.MaxOutstanding, RateLimit: opts.RateLimit, Throttle: opts.Throttle, Reporter: opts.Reporter})
+	if err != nil {
+		return nil, err
+	}
+	pipeline.SetECC(fileHeader.HasECC())
+	pipeline.SetSuite(fileSuite(fileHeader))
+	pipeline.SetDeterministic(fileHeader.Deterministic())
+	if err := setSequence(pipeline, fileHeader); err != nil {
+		return nil, err
+	}
+	return pipeline, nil
+}
+
+func encryptWindow(ctx context.Context, pipeline *stream.Pipeline, srcFile, destFile *os.File, start, length int64, journal *inPlaceJournal) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	pipeline.SetFirstChunk(uint64(start / int64(pipeline.ChunkSize())))
+	if err := pipeline.Process(ctx, io.NewSectionReader(srcFile, start, length), destFile, length); err != nil {
+		return err
+	}
+	if err := destFile.Sync(); err != nil {
+		return errors.New(errors.CodeIO, "sync", err).WithPath(destFile.Name())
+	}
+
+	written, err := destFile.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	journal.Consumed = start + length
+	journal.Written = written
+	if err := journal.save(); err != nil {
+		return err
+	}
+
+	if err := punchHole(srcFile, start, length); err != nil {
+		return errors.New(errors.CodeIO, "free source blocks", err).WithPath(srcFile.Name())
+	}
+	return nil
+}
+
+func abortInPlace(destFile *os.File, destPath string, journal *inPlaceJournal) {
+	_ = destFile.Close()
+	_ = os.Remove(destPath)
+	_ = os.Remove(journal.path)
+}
+
+func finishInPlace(srcPath string, journal *inPlaceJournal) error {
+	if err := os.Remove(srcPath); err != nil && !os.IsNotExist(err) {
+		return errors.New(errors.CodeIO, "remove source", err).WithPath(srcPath)
+	}
+	if err := os.Remove(journal.path); err != nil && !os.IsNotExist(err) {
+		return errors.New(errors.CodeIO, "remove journal", err).WithPath(journal.path)
+	}
+	return nil
+}
+
+func loadJournal(destPath string) (*inPlaceJournal, bool, error) {
+	journal := &inPlaceJournal{path: JournalPath(destPath)}
+	data, err := os.ReadFile(journal.path)
+	if os.IsNotExist(err) {
+		return journal, false, nil
+	}
+	if err != nil {
+		return nil, false, errors.New(errors.CodeIO, "read journal", err).WithPath(journal.path)
+	}
+	if err := json.Unmarshal(data, journal); err != nil {
+		return nil, false, errors.New(errors.CodeCorrupt, "parse journal", err).WithPath(journal.path)
+	}
+	return journal, true, nil
+}
+
+func (j *inPlaceJournal) save() error {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+
+	f, err := tempfile.CreateAtomic(j.path)
+	if err != nil {
+		return errors.New(errors.CodeIO, "write journal", err).WithPath(j.path)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Remove()
+		return errors.New(errors.CodeIO, "write journal", err).WithPath(j.path)
+	}
+	if err := f.Commit(); err != nil {
+		return errors.New(errors.CodeIO, "write journal", err).WithPath(j.path)
+	}
+	return nil
+}
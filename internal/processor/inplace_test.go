@@ -0,0 +1,190 @@
+package processor_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/stream/chunk"
+)
+
+// encryptInPlace encrypts data in place and returns the encrypted file,
+// checking that the source and the journal are gone.
+func encryptInPlace(t *testing.T, data []byte, opts processor.Options) []byte {
+	t.Helper()
+	src := writeFile(t, "plain", data)
+	dest := src + ".swx"
+	if err := processor.EncryptInPlace(context.Background(), src, dest, password, opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Error("the source is still there")
+	}
+	if processor.HasJournal(dest) {
+		t.Error("the journal is still there")
+	}
+	encrypted, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encrypted
+}
+
+func TestInPlaceRoundTrip(t *testing.T) {
+	data := plaintext(3*chunkSize+17, 6)
+	decrypted, err := decrypt(t, encryptInPlace(t, data, options()), options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, data) {
+		t.Error("decrypted file differs from the plaintext")
+	}
+}
+
+// TestInPlaceTruncation checks that files encrypted in place, which have no
+// trailer, fail to decrypt when cut short at a chunk boundary.
+func TestInPlaceTruncation(t *testing.T) {
+	for _, deterministic := range []bool{false, true} {
+		t.Run(fmt.Sprintf("deterministic=%t", deterministic), func(t *testing.T) {
+			opts := options()
+			opts.Deterministic, opts.DedupSalt = deterministic, dedupSalt
+			encrypted := encryptInPlace(t, plaintext(3*chunkSize+17, 7), opts)
+			last := spansBefore(t, encrypted, len(encrypted), 1)[0]
+
+			_, err := decrypt(t, encrypted[:last[0]-4], options())
+			if !errors.Is(err, chunk.ErrMissingChunks) {
+				t.Fatalf("decrypting: %v, want %v", err, chunk.ErrMissingChunks)
+			}
+		})
+	}
+}
+
+// TestInPlacePunchUnsupported checks that in-place encryption is refused
+// before anything is written when the filesystem cannot free blocks, so
+// the file can be encrypted normally instead.
+func TestInPlacePunchUnsupported(t *testing.T) {
+	processor.SetPunch(t, func(string) error { return file.ErrPunchUnsupported }, nil)
+	data := plaintext(chunkSize+17, 8)
+	src := writeFile(t, "plain", data)
+	dest := src + ".swx"
+
+	err := processor.EncryptInPlace(context.Background(), src, dest, password, options())
+	if !errors.Is(err, file.ErrPunchUnsupported) || errors.CodeOf(err) != errors.CodeInvalidInput {
+		t.Fatalf("encrypting: %v (%s), want %v", err, errors.CodeOf(err), file.ErrPunchUnsupported)
+	}
+	if source, err := os.ReadFile(src); err != nil || !bytes.Equal(source, data) {
+		t.Error("the source changed")
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Error("an output was written")
+	}
+	if processor.HasJournal(dest) {
+		t.Error("a journal was written")
+	}
+}
+
+// TestInPlaceResume interrupts in-place encryption after a window and
+// checks that running it again finishes the file, also when the output
+// holds part of a window written after the journal, and that a
+// deterministic file resumes with the chunks it would have had.
+func TestInPlaceResume(t *testing.T) {
+	processor.SetInPlaceWindow(t, 2*chunkSize)
+	errInterrupted := errors.Sentinel("interrupted")
+	data := plaintext(5*chunkSize+17, 9)
+
+	for _, tc := range []struct{ partial, deterministic bool }{{false, false}, {true, false}, {false, true}} {
+		t.Run(fmt.Sprintf("partial=%t,deterministic=%t", tc.partial, tc.deterministic), func(t *testing.T) {
+			opts := options()
+			if tc.deterministic {
+				opts.Deterministic, opts.DedupSalt = true, dedupSalt
+			}
+			windows := 0
+			processor.SetPunch(t, nil, func(f *os.File, offset, length int64) error {
+				if windows++; windows == 2 {
+					return errInterrupted
+				}
+				return file.PunchHole(f, offset, length)
+			})
+			src := writeFile(t, "plain", data)
+			dest := src + ".swx"
+
+			if err := processor.EncryptInPlace(context.Background(), src, dest, password, opts); !errors.Is(err, errInterrupted) {
+				t.Fatalf("interrupted run: %v, want %v", err, errInterrupted)
+			}
+			if !processor.HasJournal(dest) {
+				t.Fatal("the interrupted run left no journal")
+			}
+			if tc.partial {
+				out, err := os.OpenFile(dest, os.O_WRONLY|os.O_APPEND, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = out.Write(plaintext(chunkSize/2, 10))
+				if closeErr := out.Close(); err == nil {
+					err = closeErr
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := processor.EncryptInPlace(context.Background(), src, dest, password, opts); err != nil {
+				t.Fatalf("resuming: %v", err)
+			}
+			if _, err := os.Stat(src); !os.IsNotExist(err) {
+				t.Error("the source is still there")
+			}
+			if processor.HasJournal(dest) {
+				t.Error("the journal is still there")
+			}
+			encrypted, err := os.ReadFile(dest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decrypted, err := decrypt(t, encrypted, options())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decrypted, data) {
+				t.Error("decrypted file differs from the plaintext")
+			}
+			if tc.deterministic {
+				uninterrupted := encryptInPlace(t, data, opts)
+				want := spansBefore(t, uninterrupted, len(uninterrupted), 6)
+				for i, span := range spansBefore(t, encrypted, len(encrypted), 6) {
+					if !bytes.Equal(encrypted[span[0]:span[1]], uninterrupted[want[i][0]:want[i][1]]) {
+						t.Errorf("chunk %d differs from an uninterrupted run", i)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestInPlaceFirstWindowFails checks that a failure before any window is
+// done removes the output and the journal, leaving the source as it was.
+func TestInPlaceFirstWindowFails(t *testing.T) {
+	errInterrupted := errors.Sentinel("interrupted")
+	processor.SetPunch(t, nil, func(*os.File, int64, int64) error { return errInterrupted })
+	data := plaintext(chunkSize+17, 11)
+	src := writeFile(t, "plain", data)
+	dest := src + ".swx"
+
+	if err := processor.EncryptInPlace(context.Background(), src, dest, password, options()); !errors.Is(err, errInterrupted) {
+		t.Fatalf("encrypting: %v, want %v", err, errInterrupted)
+	}
+	if source, err := os.ReadFile(src); err != nil || !bytes.Equal(source, data) {
+		t.Error("the source changed")
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Error("the output is still there")
+	}
+	if processor.HasJournal(dest) {
+		t.Error("the journal is still there")
+	}
+}
@@ -0,0 +1,245 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/envelope"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/header"
+	"github.com/hambosto/sweetbyte/internal/secret"
+)
+
+var (
+	ErrNoKeySlot   = errors.Sentinel("no such key slot")
+	ErrLastKeySlot = errors.Sentinel("cannot remove the only key slot")
+	ErrNotWrapped  = errors.Sentinel("file has no wrapped data key; run rekey to upgrade it first")
+)
+
+// KeySlotInfo describes one key slot. Slot 0 is the primary slot that older
+// releases read; the others are only tried when it does not open.
+type KeySlotInfo struct {
+	Index    int           `json:"index"`
+	Profile  string        `json:"profile,omitempty"`
+	Params   derive.Params `json:"params"`
+	Password bool          `json:"password"`
+	Keyfile  bool          `json:"keyfile"`
+	Token    string        `json:"token,omitempty"`
+}
+
+// KeySlotOptions selects the password, keyfile and KDF profile of a new slot.
+type KeySlotOptions struct {
+	Password    []byte
+	Keyfile     []byte
+	RequireBoth bool
+	KDFProfile  string
+}
+
+func ListKeySlots(path string) (slots []KeySlotInfo, err error) {
+	defer wrapError("keyslot list", path, &err)
+
+	srcFile, err := file.OpenFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close()
+
+	fileHeader, err := header.NewHeader()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create header: %w", err)
+	}
+	if err := fileHeader.Unmarshal(srcFile); err != nil {
+		return nil, err
+	}
+	if _, ok := fileHeader.WrappedKey(); !ok {
+		return nil, errors.New(errors.CodeUnsupported, "", ErrNotWrapped)
+	}
+
+	params, err := fileHeader.KDFParams()
+	if err != nil {
+		return nil, errors.New(errors.CodeCorrupt, "", err)
+	}
+	extra, err := fileHeader.KeySlots()
+	if err != nil {
+		return nil, errors.New(errors.CodeCorrupt, "", err)
+	}
+
+	slots = append(slots, slotInfo(0, fileHeader.RequiredFactors(), params))
+	slots[0].Token, _ = fileHeader.KeyProvider()
+	for i, slot := range extra {
+		slots = append(slots, slotInfo(i+1, slot.Factors, slot.Params))
+	}
+	return slots, nil
+}
+
+func slotInfo(index int, factors header.Factor, params derive.Params) KeySlotInfo {
+	profile, _ := derive.ProfileName(params)
+	return KeySlotInfo{
+		Index:    index,
+		Profile:  profile,
+		Params:   params,
+		Password: factors == 0 || factors.Has(header.FactorPassword),
+		Keyfile:  factors.Has(header.FactorKeyfile),
+	}
+}
+
+// AddKeySlot unlocks path with any existing slot and adds a slot for the
+// password and keyfile in slot. It returns the index of the new slot.
+func AddKeySlot(path string, password []byte, opts Options, slot KeySlotOptions) (index int, err error) {
+	defer wrapError("keyslot add", path, &err)
+
+	if len(slot.Password) == 0 && len(slot.Keyfile) == 0 {
+		return 0, errors.Newf(errors.CodeInvalidInput, "", "a key slot needs a password or a keyfile")
+	}
+	if slot.RequireBoth && (len(slot.Password) == 0 || len(slot.Keyfile) == 0) {
+		return 0, errors.New(errors.CodeInvalidInput, "", ErrMissingFactor)
+	}
+	_, params, err := resolveKDF(slot.KDFProfile)
+	if err != nil {
+		return 0, err
+	}
+
+	err = rewriteKeySlots(path, password, opts, func(h *header.Header, slots []header.KeySlot, key []byte) ([]header.KeySlot, error) {
+		salt, err := derive.GetRandomBytes(derive.ArgonSaltLen)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+		kek, err := deriveKey(slot.Password, slot.Keyfile, salt, params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive key: %w", err)
+		}
+		defer kek.Destroy()
+
+		wrapped, err := envelope.Wrap(kek.Bytes(), key)
+		if err != nil {
+			return nil, err
+		}
+		index = len(slots) + 1
+		return append(slots, header.KeySlot{
+			Factors: requiredFactors(Options{Keyfile: slot.Keyfile, RequireBoth: slot.RequireBoth}),
+			Params:  params,
+			Salt:    salt,
+			Wrapped: wrapped,
+		}), nil
+	})
+	return index, err
+}
+
+// RemoveKeySlot unlocks path with any slot and removes slot index. Removing
+// slot 0 promotes slot 1 to primary.
+func RemoveKeySlot(path string, password []byte, opts Options, index int) (err error) {
+	defer wrapError("keyslot remove", path, &err)
+
+	return rewriteKeySlots(path, password, opts, func(h *header.Header, slots []header.KeySlot, _ []byte) ([]header.KeySlot, error) {
+		switch {
+		case index < 0 || index > len(slots):
+			return nil, errors.Newf(errors.CodeNotFound, "", "%w: %d (the file has %d)", ErrNoKeySlot, index, len(slots)+1)
+		case len(slots) == 0:
+			return nil, errors.New(errors.CodeInvalidInput, "", ErrLastKeySlot)
+		case index > 0:
+			return slices.Delete(slots, index-1, index), nil
+		}
+
+		primary := slots[0]
+		profile, ok := derive.ProfileName(primary.Params)
+		if !ok {
+			profile = header.DefaultProfile
+		}
+		h.SetKDF(profile, primary.Params)
+		h.SetKDFSalt(primary.Salt)
+		h.SetWrappedKey(primary.Wrapped)
+		h.SetRequiredFactors(primary.Factors)
+		h.SetKeyProvider("")
+		return slots[1:], nil
+	})
+}
+
+func rewriteKeySlots(path string, password []byte, opts Options, update func(h *header.Header, slots []header.KeySlot, key []byte) ([]header.KeySlot, error)) (err error) {
+	srcFile, err := file.OpenSource(path, false)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close()
+
+	fileHeader, key, err := openHeader(srcFile, password, opts)
+	if err != nil {
+		return err
+	}
+	defer releaseKey(key, opts)
+
+	if _, ok := fileHeader.WrappedKey(); !ok {
+		return errors.New(errors.CodeUnsupported, "", ErrNotWrapped)
+	}
+	if fileHeader.Deterministic() {
+		return errors.Newf(errors.CodeUnsupported, "", "deterministic files derive their data key from the password and cannot have key slots")
+	}
+
+	slots, err := fileHeader.KeySlots()
+	if err != nil {
+		return errors.New(errors.CodeCorrupt, "", err)
+	}
+	if slots, err = update(fileHeader, slots, key); err != nil {
+		return err
+	}
+	fileHeader.SetKeySlots(slots)
+
+	salt, err := fileHeader.Salt()
+	if err != nil {
+		return fmt.Errorf("failed to get salt from header: %w", err)
+	}
+	headerBytes, err := fileHeader.Marshal(salt, key)
+	if err != nil {
+		return fmt.Errorf("failed to marshal header: %w", err)
+	}
+
+	mode := opts.Mode
+	if info, err := file.GetFileInfo(path); err == nil && info != nil && mode == 0 {
+		mode = info.Mode().Perm()
+	}
+	destFile, err := createOutput(path, mode)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer closeOutput(destFile, false, &err)
+
+	if _, err := destFile.Write(headerBytes); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	if _, err := io.Copy(destFile, srcFile); err != nil {
+		return fmt.Errorf("failed to copy chunks: %w", err)
+	}
+	if err := destFile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync output: %w", err)
+	}
+	return destFile.Commit()
+}
+
+// unlockSlots tries every extra key slot in turn. It returns fallback when
+// none of them opens with password and keyfile.
+func unlockSlots(h *header.Header, slots []header.KeySlot, password, keyfile []byte, fallback error) ([]byte, error) {
+	for _, slot := range slots {
+		if checkFactors(slot.Factors, password, keyfile) != nil {
+			continue
+		}
+
+		kek, err := deriveKey(password, keyfile, slot.Salt, slot.Params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive key: %w", err)
+		}
+		key, err := envelope.Unwrap(kek.Bytes(), slot.Wrapped)
+		kek.Destroy()
+		if err != nil {
+			continue
+		}
+
+		if err := h.Verify(key); err != nil {
+			secret.Wipe(key)
+			return nil, fmt.Errorf("decryption failed: %w: %w", ErrAuthentication, err)
+		}
+		return key, nil
+	}
+	return nil, fallback
+}
@@ -0,0 +1,92 @@
+package processor_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/processor"
+)
+
+var backupPassword = []byte("the backup password")
+
+// checkOpens checks that the file at path decrypts to data with each of
+// passwords.
+func checkOpens(t *testing.T, path string, data []byte, passwords ...[]byte) {
+	t.Helper()
+	encrypted, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, password := range passwords {
+		decrypted, err := decryptAs(t, encrypted, password, options())
+		if err != nil {
+			t.Fatalf("decrypting with %q: %v", password, err)
+		}
+		if !bytes.Equal(decrypted, data) {
+			t.Errorf("decrypting with %q differs from the plaintext", password)
+		}
+	}
+}
+
+func TestKeySlots(t *testing.T) {
+	data := plaintext(chunkSize+17, 22)
+	path := writeFile(t, "plain.swx", encrypt(t, data, password, options()))
+	backup := processor.KeySlotOptions{Password: backupPassword, KDFProfile: derive.ProfileLight}
+
+	index, err := processor.AddKeySlot(path, password, options(), backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index != 1 {
+		t.Errorf("the new slot is %d, want 1", index)
+	}
+	if slots, err := processor.ListKeySlots(path); err != nil || len(slots) != 2 {
+		t.Fatalf("listing slots: %d, %v, want 2", len(slots), err)
+	}
+	checkOpens(t, path, data, password, backupPassword)
+
+	// Removing the primary slot with the backup promotes the backup.
+	if err := processor.RemoveKeySlot(path, backupPassword, options(), 0); err != nil {
+		t.Fatal(err)
+	}
+	checkOpens(t, path, data, backupPassword)
+	encrypted, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decrypt(t, encrypted, options()); !errors.Is(err, processor.ErrAuthentication) {
+		t.Fatalf("decrypting with the removed password: %v, want ErrAuthentication", err)
+	}
+}
+
+func TestKeySlotFailures(t *testing.T) {
+	path := writeFile(t, "plain.swx", encrypt(t, plaintext(100, 23), password, options()))
+	backup := processor.KeySlotOptions{Password: backupPassword, KDFProfile: derive.ProfileLight}
+
+	if _, err := processor.AddKeySlot(path, []byte("another password"), options(), backup); !errors.Is(err, processor.ErrAuthentication) {
+		t.Errorf("adding a slot with the wrong password: %v, want ErrAuthentication", err)
+	}
+	if _, err := processor.AddKeySlot(path, password, options(), processor.KeySlotOptions{}); errors.CodeOf(err) != errors.CodeInvalidInput {
+		t.Errorf("adding a slot without a password or keyfile: %v, want %s", err, errors.CodeInvalidInput)
+	}
+	if err := processor.RemoveKeySlot(path, password, options(), 0); !errors.Is(err, processor.ErrLastKeySlot) {
+		t.Errorf("removing the only slot: %v, want %v", err, processor.ErrLastKeySlot)
+	}
+	if err := processor.RemoveKeySlot(path, password, options(), 3); !errors.Is(err, processor.ErrNoKeySlot) {
+		t.Errorf("removing a missing slot: %v, want %v", err, processor.ErrNoKeySlot)
+	}
+
+	opts := options()
+	opts.Deterministic, opts.DedupSalt = true, dedupSalt
+	src := writeFile(t, "deterministic", plaintext(100, 24))
+	if err := processor.Encryption(context.Background(), src, src+".swx", password, opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := processor.AddKeySlot(src+".swx", password, options(), backup); errors.CodeOf(err) != errors.CodeUnsupported {
+		t.Errorf("adding a slot to a deterministic file: %v, want %s", err, errors.CodeUnsupported)
+	}
+}
@@ -0,0 +1,118 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/hambosto/sweetbyte/internal/envelope"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/header"
+)
+
+const nameInfo = "sweetbyte file name v1"
+
+var ErrInvalidName = errors.Sentinel("hidden file name is not a plain file name")
+
+func HasHiddenName(path string) bool {
+	fileHeader, err := peekHeader(path)
+	if err != nil {
+		return false
+	}
+	_, ok := fileHeader.SealedName()
+	return ok
+}
+
+func StoredOutputPath(path string) (string, bool) {
+	fileHeader, err := peekHeader(path)
+	if err != nil {
+		return "", false
+	}
+	name, ok := fileHeader.Name()
+	if !ok || !validName(name) {
+		return "", false
+	}
+
+	outputPath := filepath.Join(filepath.Dir(path), name)
+	if outputPath == filepath.Clean(path) {
+		return "", false
+	}
+	return outputPath, true
+}
+
+func peekHeader(path string) (*header.Header, error) {
+	srcFile, err := file.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer srcFile.Close()
+
+	fileHeader, err := header.NewHeader()
+	if err != nil {
+		return nil, err
+	}
+	if err := fileHeader.UnmarshalLazy(srcFile); err != nil {
+		return nil, err
+	}
+	return fileHeader, nil
+}
+
+func RevealOutputPath(path string, password []byte, opts Options) (outputPath string, key []byte, err error) {
+	defer wrapError("decrypt", path, &err)
+
+	srcFile, err := file.OpenFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close()
+
+	fileHeader, err := header.NewHeader()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create header: %w", err)
+	}
+	if err := fileHeader.Unmarshal(srcFile); err != nil {
+		return "", nil, fmt.Errorf("failed to unmarshal header: %w", err)
+	}
+
+	if key, err = unlockWith(fileHeader, password, opts); err != nil {
+		return "", nil, err
+	}
+
+	name, err := openName(fileHeader, key)
+	if err != nil {
+		releaseKey(key, opts)
+		return "", nil, err
+	}
+	return filepath.Join(filepath.Dir(path), name), key, nil
+}
+
+func sealName(h *header.Header, key []byte, srcPath string) error {
+	sealed, err := envelope.Seal(key, nameInfo, []byte(filepath.Base(srcPath)))
+	if err != nil {
+		return err
+	}
+	h.SetSealedName(sealed)
+	return nil
+}
+
+func openName(h *header.Header, key []byte) (string, error) {
+	sealed, ok := h.SealedName()
+	if !ok {
+		return "", errors.Newf(errors.CodeInvalidInput, "", "file does not have a hidden name")
+	}
+
+	plaintext, err := envelope.Open(key, nameInfo, sealed)
+	if err != nil {
+		return "", errors.New(errors.CodeCorrupt, "", err)
+	}
+
+	name := string(plaintext)
+	if !validName(name) {
+		return "", errors.New(errors.CodeCorrupt, "", ErrInvalidName)
+	}
+	return name, nil
+}
+
+func validName(name string) bool {
+	return name == filepath.Base(name) && filepath.IsLocal(name)
+}
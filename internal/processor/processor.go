@@ -1,126 +1,1057 @@
 package processor
 
 import (
+	"bytes"
 	"context"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/sha256"
 	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/hambosto/sweetbyte/internal/cipher"
+	"github.com/hambosto/sweetbyte/internal/config"
 	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/encoding"
+	"github.com/hambosto/sweetbyte/internal/envelope"
+	"github.com/hambosto/sweetbyte/internal/errors"
 	"github.com/hambosto/sweetbyte/internal/file"
 	"github.com/hambosto/sweetbyte/internal/header"
+	"github.com/hambosto/sweetbyte/internal/hidden"
+	"github.com/hambosto/sweetbyte/internal/recipient"
+	"github.com/hambosto/sweetbyte/internal/reporter"
+	"github.com/hambosto/sweetbyte/internal/secret"
 	"github.com/hambosto/sweetbyte/internal/stream"
+	"github.com/hambosto/sweetbyte/internal/stream/processing"
+	"github.com/hambosto/sweetbyte/internal/sysinfo"
+	"github.com/hambosto/sweetbyte/internal/tempfile"
 	"github.com/hambosto/sweetbyte/internal/types"
 )
 
-func Encryption(srcPath, destPath, password string) error {
-	srcFile, err := file.OpenFile(srcPath)
+var (
+	ErrAuthentication = errors.Sentinel("incorrect password or corrupt file")
+	ErrMissingFactor  = errors.Sentinel("file requires both a password and a keyfile")
+	ErrNeedsIdentity  = errors.Sentinel("file is encrypted to a public key")
+	ErrNeedsKeyfile   = errors.Sentinel("file was encrypted with a keyfile")
+	ErrRollback       = errors.Sentinel("file is older than expected")
+	ErrSourceChanged  = errors.Sentinel("source changed while it was being encrypted")
+)
+
+type Options struct {
+	PreserveTimes  bool
+	PreserveOwner  bool
+	KeepPartial    bool
+	Labels         []string
+	Comment        string
+	ContentType    string
+	HideName       bool
+	NoECC          bool
+	ChunkSize      int
+	Concurrency    int
+	MaxMemory      int64
+	MaxOutstanding int
+	MaxWorkers     int
+	WorkerPool     *stream.WorkerPool
+	RateLimit      int64
+	Throttle       *stream.Throttle
+	DirectIO       bool
+	Verify         bool
+	Paranoid       bool
+	Keyfile        []byte
+	RequireBoth    bool
+	Token          derive.KeyProvider
+	KDFProfile     string
+	Cipher         string
+	Deterministic  bool
+	DedupSalt      []byte
+	Seekable       bool
+	Dictionary     []byte
+	Padding        int64
+	HiddenPath     string
+	HiddenPassword []byte
+	ExpectAfter    time.Time
+	Reporter       reporter.Reporter
+	DataKey        []byte
+	BatchKey       *BatchKey
+	Recipient      *ecdh.PublicKey
+	Identity       *ecdh.PrivateKey
+	Mode           os.FileMode
+	Record         func(destPath string, plaintextHash []byte) error
+	KeyShares      func(destPath string, fileID, dataKey []byte) error
+	Repaired       func(report RepairReport)
+}
+
+func (o Options) WithTuning(tuning config.Tuning) Options {
+	if o.ChunkSize == 0 {
+		o.ChunkSize = tuning.ChunkSize
+	}
+	if o.Concurrency == 0 {
+		o.Concurrency = tuning.Concurrency
+	}
+	if o.MaxMemory == 0 {
+		o.MaxMemory = tuning.MaxMemory
+	}
+	if o.MaxOutstanding == 0 {
+		o.MaxOutstanding = tuning.MaxOutstanding
+	}
+	if o.MaxWorkers == 0 {
+		o.MaxWorkers = tuning.MaxWorkers
+	}
+	if o.RateLimit == 0 {
+		o.RateLimit = tuning.RateLimit
+	}
+	return o
+}
+
+func Encryption(ctx context.Context, srcPath, destPath string, password []byte, opts Options) (err error) {
+	defer wrapError("encrypt", srcPath, &err)
+
+	srcFile, err := file.OpenSource(srcPath, opts.DirectIO)
 	if err != nil {
 		return fmt.Errorf("failed to open source file: %w", err)
 	}
+	defer srcFile.Close()
 
-	destFile, err := file.CreateFile(destPath)
+	destFile, err := createOutput(destPath, opts.Mode)
 	if err != nil {
 		return fmt.Errorf("failed to create destination file: %w", err)
 	}
+	defer closeOutput(destFile, opts.KeepPartial, &err)
+
+	originalSize, err := file.Size(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to get file size: %w", err)
+	}
+
+	pad, err := preparePadding(ctx, password, opts)
+	if err != nil {
+		return err
+	}
+	defer pad.release()
+	opts.Padding = pad.size
+
+	key, pipeline, headerBytes, err := prepareEncryption(srcPath, originalSize, password, opts, nil)
+	if err != nil {
+		return err
+	}
+	defer secret.Wipe(key)
+
+	if _, err := destFile.Write(headerBytes); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
 
-	srcInfo, err := file.GetFileInfo(srcPath)
+	var input io.Reader = srcFile
+	sourceHash := sha256.New()
+	if opts.Verify || opts.Paranoid || opts.Record != nil {
+		input = io.TeeReader(srcFile, sourceHash)
+	}
+
+	if err := pipeline.Process(ctx, input, destFile, originalSize); err != nil {
+		return fmt.Errorf("failed to process file: %w", err)
+	}
+
+	if opts.Paranoid {
+		if err := checkSourceUnchanged(srcPath, sourceHash.Sum(nil), opts.DirectIO); err != nil {
+			return err
+		}
+	}
+
+	if err := pad.write(destFile); err != nil {
+		return fmt.Errorf("failed to write padding: %w", err)
+	}
+
+	written, err := destFile.Seek(0, io.SeekCurrent)
 	if err != nil {
-		return fmt.Errorf("failed to get file info: %w", err)
+		return fmt.Errorf("failed to get output size: %w", err)
+	}
+
+	if opts.Verify {
+		if err := verifyEncrypted(ctx, destFile.Path(), written, key, sourceHash.Sum(nil), opts); err != nil {
+			return err
+		}
+	}
+
+	if err := destFile.Commit(); err != nil {
+		return fmt.Errorf("failed to finalize destination file: %w", err)
+	}
+
+	if opts.Record != nil {
+		if err := opts.Record(destPath, sourceHash.Sum(nil)); err != nil {
+			return fmt.Errorf("failed to record checksums: %w", err)
+		}
+	}
+
+	if opts.KeyShares != nil {
+		fileID, err := FileID(destPath)
+		if err != nil {
+			return fmt.Errorf("failed to read file ID: %w", err)
+		}
+		if err := opts.KeyShares(destPath, fileID, key); err != nil {
+			return fmt.Errorf("failed to split data key: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func prepareEncryption(srcPath string, originalSize int64, password []byte, opts Options, inherit func(*header.Header, []byte) error) (key []byte, pipeline *stream.Pipeline, headerBytes []byte, err error) {
+	if opts.RequireBoth && (len(password) == 0 || len(opts.Keyfile) == 0) {
+		return nil, nil, nil, errors.New(errors.CodeInvalidInput, "", ErrMissingFactor)
+	}
+	if len(opts.Comment) > header.MaxCommentSize {
+		return nil, nil, nil, errors.Newf(errors.CodeInvalidInput, "", "comment is %d bytes, the limit is %d", len(opts.Comment), header.MaxCommentSize)
+	}
+
+	if opts.Token != nil && opts.Recipient != nil {
+		return nil, nil, nil, errors.Newf(errors.CodeInvalidInput, "", "a hardware token cannot be combined with a recipient")
+	}
+	if opts.Deterministic && (opts.Recipient != nil || len(password) == 0 && len(opts.Keyfile) == 0) {
+		return nil, nil, nil, errors.Newf(errors.CodeInvalidInput, "", "deterministic encryption needs a password or keyfile")
+	}
+	if opts.Deterministic && len(opts.DedupSalt) == 0 {
+		return nil, nil, nil, errors.Newf(errors.CodeInvalidInput, "", "deterministic encryption needs a dedup salt")
+	}
+
+	suite, err := cipher.ParseSuite(opts.Cipher)
+	if err != nil {
+		return nil, nil, nil, errors.New(errors.CodeInvalidInput, "", err)
+	}
+
+	kdfProfile, kdfParams, err := resolveKDF(opts.KDFProfile)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
 	salt, err := derive.GetRandomBytes(derive.ArgonSaltLen)
 	if err != nil {
-		return fmt.Errorf("failed to generate salt: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to generate salt: %w", err)
 	}
 
-	key, err := derive.Hash([]byte(password), salt)
+	if opts.Deterministic {
+		key, err = deterministicKey(password, opts.Keyfile, opts.DedupSalt, kdfParams)
+	} else {
+		key, err = envelope.NewDataKey()
+	}
 	if err != nil {
-		return fmt.Errorf("failed to derive key: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to generate data key: %w", err)
 	}
+	defer func() {
+		if err != nil {
+			secret.Wipe(key)
+		}
+	}()
 
-	originalSize := srcInfo.Size()
-	if originalSize <= 0 {
-		return fmt.Errorf("cannot encrypt a file with zero or negative size")
+	var wrappedKey, stanza []byte
+	switch {
+	case opts.Recipient != nil:
+		if stanza, err = recipient.Wrap(opts.Recipient, salt, key); err != nil {
+			return nil, nil, nil, err
+		}
+	case opts.BatchKey != nil:
+		kdfProfile, kdfParams = opts.BatchKey.profile, opts.BatchKey.params
+		if wrappedKey, err = envelope.Wrap(opts.BatchKey.kek.Bytes(), key); err != nil {
+			return nil, nil, nil, err
+		}
+	default:
+		kek, err := deriveKey(password, opts.Keyfile, salt, kdfParams)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to derive key: %w", err)
+		}
+		if opts.Token != nil {
+			if kek, err = applyToken(kek, opts.Token, salt); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		wrappedKey, err = envelope.Wrap(kek.Bytes(), key)
+		kek.Destroy()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	if originalSize == 0 {
+		return nil, nil, nil, errors.Newf(errors.CodeInvalidInput, "", "cannot encrypt a file with zero size")
 	}
 
+	if pipeline, err = newPipeline(key, types.Encryption, opts); err != nil {
+		return nil, nil, nil, err
+	}
+	if opts.ChunkSize == 0 && !opts.Deterministic {
+		if err := autoChunkSize(pipeline, originalSize); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	pipeline.SetECC(!opts.NoECC)
+	pipeline.SetSuite(suite)
+	pipeline.SetDeterministic(opts.Deterministic)
+	if len(opts.Dictionary) > 0 {
+		if err := pipeline.SetDictionary(opts.Dictionary); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if err := pipeline.SetTrailer(key); err != nil {
+		return nil, nil, nil, err
+	}
+	if opts.Seekable {
+		if err := pipeline.SetIndex(key); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if err := limitMemory(pipeline, opts.MaxMemory); err != nil {
+		return nil, nil, nil, err
+	}
 	fileHeader, err := header.NewHeader()
 	if err != nil {
-		return fmt.Errorf("failed to create header: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to create header: %w", err)
+	}
+	if originalSize < 0 {
+		fileHeader.SetStreamed()
+	} else {
+		fileHeader.SetOriginalSize(uint64(originalSize))
 	}
-	fileHeader.SetOriginalSize(uint64(originalSize))
 	fileHeader.SetProtected(true)
+	fileHeader.SetTime(header.TagCreated, time.Now())
+	fileHeader.SetRevision(header.FormatRevision)
+	if opts.ContentType != "" {
+		fileHeader.SetContentType(opts.ContentType)
+	} else if srcPath != "" {
+		if contentType, err := file.SniffContentType(srcPath); err == nil {
+			fileHeader.SetContentType(contentType)
+		}
+	}
+	fileHeader.SetLabels(opts.Labels)
+	fileHeader.SetComment(opts.Comment)
+	if opts.HideName && srcPath != "" {
+		if err := sealName(fileHeader, key, srcPath); err != nil {
+			return nil, nil, nil, err
+		}
+	} else if srcPath != "" && !file.IsDevice(srcPath) {
+		fileHeader.SetName(filepath.Base(srcPath))
+	}
+	fileHeader.SetChunkSize(pipeline.ChunkSize())
+	if stanza != nil {
+		fileHeader.SetRecipientKey(stanza)
+	} else {
+		fileHeader.SetKDF(kdfProfile, kdfParams)
+		fileHeader.SetWrappedKey(wrappedKey)
+	}
+	if opts.BatchKey != nil && stanza == nil {
+		fileHeader.SetKDFSalt(opts.BatchKey.salt)
+	}
+	if opts.Token != nil && stanza == nil {
+		fileHeader.SetKeyProvider(opts.Token.Spec())
+	}
+	fileHeader.SetECC(!opts.NoECC)
+	fileHeader.SetCipherSuite(uint64(suite))
+	fileHeader.SetTrailer(true)
+	fileHeader.SetSequence(true)
+	fileHeader.SetChunkIndex(opts.Seekable)
+	fileHeader.SetPadding(opts.Padding)
+	if opts.Deterministic {
+		fileHeader.SetDeterministic()
+	}
+	if len(opts.Dictionary) > 0 {
+		if err := sealDictionary(fileHeader, key, opts.Dictionary); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	fileHeader.SetParameters(processingParameters(!opts.NoECC, len(opts.Dictionary) > 0))
+	fileHeader.SetRequiredFactors(requiredFactors(opts))
 
-	headerBytes, err := fileHeader.Marshal(salt, key)
+	if opts.PreserveTimes && srcPath != "" {
+		times, err := file.GetTimes(srcPath)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to read timestamps: %w", err)
+		}
+		storeTimes(fileHeader, times)
+	}
+
+	if opts.PreserveOwner && srcPath != "" {
+		owner, ok, err := file.GetOwner(srcPath)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to read ownership: %w", err)
+		}
+		if ok {
+			fileHeader.SetOwner(header.Owner(owner))
+		}
+	}
+
+	if inherit != nil {
+		if err := inherit(fileHeader, key); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	var count uint64
+	if countsChunks(fileHeader) {
+		count = chunkCount(originalSize, pipeline.ChunkSize())
+	}
+	pipeline.SetSequence(sequenceID(salt, opts.Deterministic), count)
+
+	if headerBytes, err = fileHeader.Marshal(salt, key); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to marshal header: %w", err)
+	}
+
+	return key, pipeline, headerBytes, nil
+}
+
+func Decryption(ctx context.Context, srcPath, destPath string, password []byte, opts Options) (err error) {
+	defer wrapError("decrypt", srcPath, &err)
+
+	srcFile, err := file.OpenSource(srcPath, opts.DirectIO)
 	if err != nil {
-		return fmt.Errorf("failed to marshal header: %w", err)
+		return fmt.Errorf("failed to open source file: %w", err)
 	}
+	defer srcFile.Close()
 
-	if _, err := destFile.Write(headerBytes); err != nil {
-		return fmt.Errorf("failed to write header: %w", err)
+	fileHeader, key, err := openHeader(srcFile, password, opts)
+	if err != nil {
+		if errors.Is(err, ErrAuthentication) && len(opts.DataKey) == 0 {
+			if hiddenErr := decryptHidden(ctx, srcPath, destPath, password, opts); !errors.Is(hiddenErr, hidden.ErrNotFound) {
+				return hiddenErr
+			}
+		}
+		return err
+	}
+	defer releaseKey(key, opts)
+
+	destFile, err := createOutput(destPath, opts.Mode)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %w", err)
 	}
+	defer closeOutput(destFile, opts.KeepPartial, &err)
 
-	pipeline, err := stream.NewPipeline(key, types.Encryption)
+	pipeline, err := newPipeline(key, types.Decryption, Options{Concurrency: opts.Concurrency, MaxOutstanding: opts.MaxOutstanding, WorkerPool: opts.WorkerPool, RateLimit: opts.RateLimit, Throttle: opts.Throttle, Reporter: opts.Reporter})
 	if err != nil {
-		return fmt.Errorf("failed to create stream pipeline: %w", err)
+		return err
 	}
+	pipeline.SetChunkFlags(fileHeader.Revision() >= header.RevisionChunkFlags)
+	pipeline.SetECC(fileHeader.HasECC())
+	pipeline.SetSuite(fileSuite(fileHeader))
+	if err := setDictionary(pipeline, fileHeader, key); err != nil {
+		return err
+	}
+	if err := setTrailer(pipeline, fileHeader, key); err != nil {
+		return err
+	}
+	if err := setSequence(pipeline, fileHeader); err != nil {
+		return err
+	}
+	pipeline.SetBaseOffset(srcFile.Offset())
 
-	if err := pipeline.Process(context.Background(), srcFile, destFile, originalSize); err != nil {
+	if chunkSize, ok := fileHeader.ChunkSize(); ok {
+		if err := pipeline.EnablePositionalWrites(chunkSize); err != nil {
+			return errors.New(errors.CodeCorrupt, "", err)
+		}
+	}
+	if err := limitMemory(pipeline, opts.MaxMemory); err != nil {
+		return err
+	}
+
+	originalSize, err := decryptedSize(fileHeader)
+	if err != nil {
+		return err
+	}
+
+	err = pipeline.Process(ctx, srcFile, destFile, originalSize)
+	reportRepairs(pipeline, srcPath, opts)
+	if err != nil {
 		return fmt.Errorf("failed to process file: %w", err)
 	}
 
+	if err := destFile.Commit(); err != nil {
+		return fmt.Errorf("failed to finalize destination file: %w", err)
+	}
+
+	checkContentType(fileHeader.ContentType(), destPath, reporter.OrNop(opts.Reporter))
+
+	if opts.PreserveOwner && !file.IsDevice(destPath) {
+		owner, ok, err := fileHeader.Owner()
+		if err != nil {
+			return errors.New(errors.CodeCorrupt, "", err)
+		}
+		if ok {
+			if err := file.RestoreOwner(destPath, file.Owner(owner)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if opts.PreserveTimes && !file.IsDevice(destPath) {
+		if times, ok := loadTimes(fileHeader); ok {
+			if err := file.RestoreTimes(destPath, times); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
-func Decryption(srcPath, destPath, password string) error {
-	srcFile, err := file.OpenFile(srcPath)
+func openHeader(r io.Reader, password []byte, opts Options) (_ *header.Header, key []byte, err error) {
+	fileHeader, err := header.NewHeader()
 	if err != nil {
-		return fmt.Errorf("failed to open source file: %w", err)
+		return nil, nil, fmt.Errorf("failed to create header: %w", err)
+	}
+
+	if err := fileHeader.Unmarshal(r); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal header: %w", err)
+	}
+
+	if key, err = unlockWith(fileHeader, password, opts); err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			releaseKey(key, opts)
+		}
+	}()
+
+	if !fileHeader.IsProtected() {
+		return nil, nil, errors.Newf(errors.CodeUnsupported, "", "file is not protected")
+	}
+
+	if err := checkParameters(fileHeader); err != nil {
+		return nil, nil, err
 	}
 
+	if err := checkFreshness(fileHeader, opts.ExpectAfter); err != nil {
+		return nil, nil, err
+	}
+	return fileHeader, key, nil
+}
+
+func decryptedSize(h *header.Header) (int64, error) {
+	if h.Streamed() {
+		return -1, nil
+	}
+
+	originalSize := h.GetOriginalSize()
+	if originalSize <= 0 {
+		return 0, errors.Newf(errors.CodeCorrupt, "", "cannot decrypt a file with zero or negative size")
+	}
+	return originalSize, nil
+}
+
+// verifyEncrypted decrypts the output of Encryption before it replaces the
+// destination. It is replaced in tests.
+var verifyEncrypted = verifyOutput
+
+func verifyOutput(ctx context.Context, path string, size int64, key, expected []byte, opts Options) error {
+	f, err := file.OpenSource(path, opts.DirectIO)
+	if err != nil {
+		return fmt.Errorf("failed to reopen output for verification: %w", err)
+	}
+	defer f.Close()
+
 	fileHeader, err := header.NewHeader()
 	if err != nil {
 		return fmt.Errorf("failed to create header: %w", err)
 	}
+	if err := fileHeader.Unmarshal(f); err != nil {
+		return errors.New(errors.CodeCorrupt, "verify", err)
+	}
+	if err := fileHeader.Verify(key); err != nil {
+		return errors.New(errors.CodeCorrupt, "verify", err)
+	}
 
+	actual, err := decryptDigest(ctx, io.LimitReader(f, size-f.Offset()), fileHeader, key, opts, "Verifying...")
+	if err != nil {
+		return errors.New(errors.CodeCorrupt, "verify", err)
+	}
+
+	if !bytes.Equal(actual, expected) {
+		return errors.Newf(errors.CodeCorrupt, "verify", "decrypted output does not match the source")
+	}
+	return nil
+}
+
+func UnlockDataKey(path string, password []byte, opts Options) (key, fileID []byte, err error) {
+	defer wrapError("unlock", path, &err)
+
+	srcFile, err := file.OpenFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close()
+
+	fileHeader, err := header.NewHeader()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create header: %w", err)
+	}
 	if err := fileHeader.Unmarshal(srcFile); err != nil {
-		return fmt.Errorf("failed to unmarshal header: %w", err)
+		return nil, nil, fmt.Errorf("failed to unmarshal header: %w", err)
+	}
+
+	if key, err = unlockWith(fileHeader, password, opts); err != nil {
+		return nil, nil, err
+	}
+	if fileID, err = fileHeader.Salt(); err != nil {
+		releaseKey(key, opts)
+		return nil, nil, fmt.Errorf("failed to get salt from header: %w", err)
 	}
+	return key, fileID, nil
+}
 
-	salt, err := fileHeader.Salt()
+func FileID(path string) ([]byte, error) {
+	srcFile, err := file.OpenFile(path)
 	if err != nil {
-		return fmt.Errorf("failed to get salt from header: %w", err)
+		return nil, err
 	}
+	defer srcFile.Close()
 
-	key, err := derive.Hash([]byte(password), salt)
+	fileHeader, err := header.NewHeader()
 	if err != nil {
-		return fmt.Errorf("failed to derive key: %w", err)
+		return nil, fmt.Errorf("failed to create header: %w", err)
+	}
+	if err := fileHeader.UnmarshalLazy(srcFile); err != nil {
+		return nil, err
 	}
+	return fileHeader.Salt()
+}
 
-	if err := fileHeader.Verify(key); err != nil {
-		return fmt.Errorf("decryption failed: incorrect password or corrupt file: %w", err)
+func checkSourceUnchanged(path string, expected []byte, directIO bool) error {
+	actual, err := hashFile(path, directIO)
+	if err != nil {
+		return fmt.Errorf("failed to re-read source: %w", err)
+	}
+	if !bytes.Equal(actual, expected) {
+		return errors.New(errors.CodeIO, "", ErrSourceChanged)
 	}
+	return nil
+}
 
-	if !fileHeader.IsProtected() {
-		return fmt.Errorf("file is not protected")
+func unlockWith(h *header.Header, password []byte, opts Options) ([]byte, error) {
+	if len(opts.DataKey) > 0 {
+		if err := h.Verify(opts.DataKey); err != nil {
+			return nil, fmt.Errorf("decryption failed: %w: %w", ErrAuthentication, err)
+		}
+		return opts.DataKey, nil
 	}
 
-	destFile, err := file.CreateFile(destPath)
+	if stanza, ok := h.RecipientKey(); ok {
+		return unlockRecipient(h, stanza, opts.Identity)
+	}
+
+	slots, err := h.KeySlots()
 	if err != nil {
-		return fmt.Errorf("failed to create destination file: %w", err)
+		return nil, errors.New(errors.CodeCorrupt, "", err)
+	}
+	if err := checkFactors(h.RequiredFactors(), password, opts.Keyfile); err != nil {
+		if len(slots) == 0 {
+			return nil, err
+		}
+		return unlockSlots(h, slots, password, opts.Keyfile, err)
 	}
 
-	pipeline, err := stream.NewPipeline(key, types.Decryption)
+	key, err := unlock(h, password, opts.Keyfile)
+	if err != nil && len(slots) > 0 && (errors.Is(err, ErrAuthentication) || errors.Is(err, ErrTokenFailed)) {
+		return unlockSlots(h, slots, password, opts.Keyfile, err)
+	}
+	return key, err
+}
+
+func unlock(h *header.Header, password, keyfile []byte) ([]byte, error) {
+	salt, err := h.Salt()
 	if err != nil {
-		return fmt.Errorf("failed to create stream pipeline: %w", err)
+		return nil, fmt.Errorf("failed to get salt from header: %w", err)
+	}
+	if kdfSalt, ok := h.KDFSalt(); ok {
+		salt = kdfSalt
 	}
 
-	originalSize := fileHeader.GetOriginalSize()
-	if originalSize <= 0 {
-		return fmt.Errorf("cannot decrypt a file with zero or negative size")
+	kdfParams, err := h.KDFParams()
+	if err != nil {
+		return nil, errors.New(errors.CodeCorrupt, "", err)
 	}
 
-	if err := pipeline.Process(context.Background(), srcFile, destFile, originalSize); err != nil {
-		return fmt.Errorf("failed to process file: %w", err)
+	token, err := headerToken(h)
+	if err != nil {
+		return nil, err
+	}
+
+	kek, err := deriveKey(password, keyfile, salt, kdfParams)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	if token != nil {
+		if kek, err = applyToken(kek, token, salt); err != nil {
+			return nil, err
+		}
+	}
+
+	key := kek.Bytes()
+	if wrappedKey, ok := h.WrappedKey(); ok {
+		defer kek.Destroy()
+		if key, err = envelope.Unwrap(kek.Bytes(), wrappedKey); err != nil {
+			return nil, errors.Newf(errors.CodeAuthentication, "", "decryption failed: %w: %w", ErrAuthentication, err)
+		}
+	}
+
+	if err := h.Verify(key); err != nil {
+		secret.Wipe(key)
+		return nil, fmt.Errorf("decryption failed: %w: %w", ErrAuthentication, err)
+	}
+	return key, nil
+}
+
+func unlockRecipient(h *header.Header, stanza []byte, identity *ecdh.PrivateKey) ([]byte, error) {
+	if identity == nil {
+		return nil, errors.Newf(errors.CodeAuthentication, "", "%w: use --identity with the matching private key", ErrNeedsIdentity)
+	}
+
+	salt, err := h.Salt()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get salt from header: %w", err)
+	}
+
+	key, err := recipient.Unwrap(identity, salt, stanza)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Verify(key); err != nil {
+		secret.Wipe(key)
+		return nil, fmt.Errorf("decryption failed: %w: %w", ErrAuthentication, err)
+	}
+	return key, nil
+}
+
+func resolveKDF(profile string) (string, derive.Params, error) {
+	if profile == "" {
+		profile = derive.ProfileDefault
+	}
+	params, err := derive.ProfileParams(profile)
+	if err != nil {
+		return "", derive.Params{}, errors.New(errors.CodeInvalidInput, "", err)
+	}
+	return profile, params, nil
+}
+
+func deriveKey(password, keyfile, salt []byte, params derive.Params) (*secret.Buffer, error) {
+	input := derive.CombineKeyfile(password, keyfile)
+	if len(keyfile) > 0 {
+		defer secret.Wipe(input)
+	}
+
+	key, err := derive.HashWithParams(input, salt, params)
+	if err != nil {
+		return nil, err
+	}
+	return secret.FromBytes(key), nil
+}
+
+// deterministicKey salts the password with dedupSalt, so only files that
+// share it deduplicate and no dictionary covers every deterministic file.
+func deterministicKey(password, keyfile, dedupSalt []byte, params derive.Params) ([]byte, error) {
+	mac := hmac.New(sha256.New, dedupSalt)
+	mac.Write([]byte("sweetbyte deterministic data key"))
+	key, err := deriveKey(password, keyfile, mac.Sum(nil)[:derive.ArgonSaltLen], params)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Destroy()
+	return bytes.Clone(key.Bytes()), nil
+}
+
+func releaseKey(key []byte, opts Options) {
+	if len(opts.DataKey) == 0 {
+		secret.Wipe(key)
+	}
+}
+
+func requiredFactors(opts Options) header.Factor {
+	switch {
+	case opts.Recipient != nil:
+		return 0
+	case opts.RequireBoth:
+		return header.FactorPassword | header.FactorKeyfile
+	case len(opts.Keyfile) > 0:
+		return header.FactorKeyfile
+	default:
+		return 0
+	}
+}
+
+func checkFactors(required header.Factor, password, keyfile []byte) error {
+	if required.Has(header.FactorPassword) && len(password) == 0 {
+		return errors.Newf(errors.CodeAuthentication, "", "%w: password is missing", ErrMissingFactor)
+	}
+	if required.Has(header.FactorKeyfile) && len(keyfile) == 0 {
+		if required.Has(header.FactorPassword) {
+			return errors.Newf(errors.CodeAuthentication, "", "%w: keyfile is missing (use --keyfile)", ErrMissingFactor)
+		}
+		return errors.Newf(errors.CodeAuthentication, "", "%w: use --keyfile", ErrNeedsKeyfile)
+	}
+	return nil
+}
+
+func processingParameters(ecc, dictionary bool) header.Parameters {
+	params := header.Parameters{
+		Compression: header.CompressionZlib,
+		Level:       uint8(processing.CompressionLevel),
+	}
+	if dictionary {
+		params.Compression = header.CompressionZstd
+	}
+	if ecc {
+		params.DataShards = encoding.DataShards
+		params.ParityShards = encoding.ParityShards
+	}
+	return params
+}
+
+func checkParameters(h *header.Header) error {
+	params, ok, err := h.Parameters()
+	if err != nil {
+		return errors.New(errors.CodeCorrupt, "", err)
+	}
+	_, dictionary := h.Dictionary()
+	if ok && params != processingParameters(h.HasECC(), dictionary) {
+		return errors.Newf(errors.CodeUnsupported, "", "unsupported processing parameters: %d+%d shards, compression %d level %d",
+			params.DataShards, params.ParityShards, params.Compression, params.Level)
+	}
+	if suite := fileSuite(h); uint64(suite) != h.CipherSuite() || !suite.Valid() {
+		return errors.Newf(errors.CodeUnsupported, "", "unsupported cipher suite %d", h.CipherSuite())
+	}
+	return nil
+}
+
+func setTrailer(pipeline *stream.Pipeline, h *header.Header, key []byte) error {
+	if !h.HasTrailer() {
+		return nil
+	}
+	pipeline.SetPadding(h.Padding())
+	if h.HasChunkIndex() {
+		if err := pipeline.SetIndex(key); err != nil {
+			return err
+		}
+	}
+	return pipeline.SetTrailer(key)
+}
+
+// setChunkSize sizes decryption for the chunks the file was written with,
+// so a size prefix larger than any of them is rejected before it is read.
+func setChunkSize(pipeline *stream.Pipeline, h *header.Header) error {
+	chunkSize, ok := h.ChunkSize()
+	if !ok {
+		chunkSize = stream.DefaultChunkSize
+	}
+	if err := pipeline.SetChunkSize(chunkSize); err != nil {
+		return errors.New(errors.CodeCorrupt, "", err)
+	}
+	return nil
+}
+
+func setSequence(pipeline *stream.Pipeline, h *header.Header) error {
+	fileID, count, err := fileSequence(h)
+	if err != nil {
+		return err
+	}
+	pipeline.SetSequence(fileID, count)
+	return nil
+}
+
+func fileSequence(h *header.Header) ([]byte, uint64, error) {
+	if !h.HasSequence() {
+		return nil, 0, nil
+	}
+
+	salt, err := h.Salt()
+	if err != nil {
+		return nil, 0, errors.New(errors.CodeCorrupt, "", err)
+	}
+	if !countsChunks(h) {
+		return sequenceID(salt, h.Deterministic()), 0, nil
+	}
+
+	chunkSize, ok := h.ChunkSize()
+	if !ok || chunkSize <= 0 {
+		return nil, 0, errors.Newf(errors.CodeCorrupt, "", "header does not record the chunk size")
+	}
+	return sequenceID(salt, h.Deterministic()), chunkCount(h.GetOriginalSize(), chunkSize), nil
+}
+
+// countsChunks reports whether each chunk's additional data binds the chunk
+// count. Streamed files do not know it up front. Deterministic files with a
+// trailer leave it out, so appending to the input keeps the earlier chunks
+// identical; the trailer still catches truncation.
+func countsChunks(h *header.Header) bool {
+	if h.Streamed() {
+		return false
+	}
+	return !h.Deterministic() || !h.HasTrailer()
+}
+
+func sequenceID(salt []byte, deterministic bool) []byte {
+	if deterministic {
+		return make([]byte, len(salt))
+	}
+	return salt
+}
+
+func chunkCount(size int64, chunkSize int) uint64 {
+	if size <= 0 {
+		return 0
+	}
+	return uint64((size + int64(chunkSize) - 1) / int64(chunkSize))
+}
+
+func fileSuite(h *header.Header) cipher.Suite {
+	return cipher.Suite(h.CipherSuite())
+}
+
+func checkFreshness(h *header.Header, expectAfter time.Time) error {
+	if expectAfter.IsZero() {
+		return nil
+	}
+
+	created, ok := h.Time(header.TagCreated)
+	if !ok {
+		return errors.Newf(errors.CodeAuthentication, "", "%w: no creation time recorded", ErrRollback)
+	}
+	if !created.After(expectAfter) {
+		return errors.Newf(errors.CodeAuthentication, "", "%w: created %s, expected after %s",
+			ErrRollback, created.Format(time.RFC3339), expectAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func checkContentType(expected, path string, r reporter.Reporter) {
+	if expected == "" {
+		return
+	}
+
+	actual, err := file.SniffContentType(path)
+	if err != nil || actual == expected {
+		return
+	}
+	r.Warn(fmt.Sprintf("decrypted content looks like %s, but %s was recorded at encryption; the file may be damaged or not the one you expected", actual, expected))
+}
+
+func newPipeline(key []byte, mode types.Processing, opts Options) (*stream.Pipeline, error) {
+	pipeline, err := stream.NewPipeline(key, mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream pipeline: %w", err)
 	}
+	pipeline.SetReporter(opts.Reporter)
 
+	if opts.ChunkSize > 0 {
+		if err := pipeline.SetChunkSize(opts.ChunkSize); err != nil {
+			return nil, errors.New(errors.CodeInvalidInput, "", err)
+		}
+	}
+	if opts.Concurrency > 0 {
+		if err := pipeline.SetConcurrency(opts.Concurrency); err != nil {
+			return nil, errors.New(errors.CodeInvalidInput, "", err)
+		}
+	}
+	if opts.MaxOutstanding != 0 {
+		if err := pipeline.SetMaxOutstanding(opts.MaxOutstanding); err != nil {
+			return nil, errors.New(errors.CodeInvalidInput, "", err)
+		}
+	}
+	if opts.WorkerPool != nil {
+		if err := pipeline.SetWorkerPool(opts.WorkerPool); err != nil {
+			return nil, errors.New(errors.CodeInvalidInput, "", err)
+		}
+	}
+	if opts.Throttle == nil && opts.RateLimit > 0 {
+		var err error
+		if opts.Throttle, err = stream.NewThrottle(opts.RateLimit); err != nil {
+			return nil, errors.New(errors.CodeInvalidInput, "", err)
+		}
+	}
+	pipeline.SetThrottle(opts.Throttle)
+
+	return pipeline, nil
+}
+
+func autoChunkSize(pipeline *stream.Pipeline, size int64) error {
+	if err := pipeline.SetChunkSize(stream.AutoChunkSize(size)); err != nil {
+		return err
+	}
+	if available, ok := sysinfo.AvailableMemory(); ok {
+		pipeline.FitChunkSize(int64(min(available/4, math.MaxInt64)))
+	}
 	return nil
 }
+
+func limitMemory(pipeline *stream.Pipeline, limit int64) error {
+	if limit <= 0 {
+		return nil
+	}
+	if err := pipeline.SetMemoryLimit(limit); err != nil {
+		return errors.New(errors.CodeInvalidInput, "", err)
+	}
+	return nil
+}
+
+func wrapError(op, path string, err *error) {
+	if *err == nil {
+		return
+	}
+
+	code := errors.CodeUnknown
+	if errors.Is(*err, context.Canceled) {
+		code = errors.CodeCanceled
+	}
+	*err = errors.New(code, op, *err).WithPath(path)
+}
+
+func createOutput(path string, mode os.FileMode) (*tempfile.File, error) {
+	if file.IsDevice(path) {
+		return tempfile.OpenInPlace(path)
+	}
+
+	f, err := tempfile.CreateAtomic(path)
+	if err != nil || mode == 0 {
+		return f, err
+	}
+	if err := f.Chmod(mode); err != nil {
+		_ = f.Remove()
+		return nil, errors.New(errors.CodeIO, "chmod", err).WithPath(f.Path())
+	}
+	return f, nil
+}
+
+func closeOutput(f *tempfile.File, keepPartial bool, err *error) {
+	if *err == nil {
+		return
+	}
+
+	if keepPartial {
+		if commitErr := f.Commit(); commitErr != nil {
+			*err = fmt.Errorf("%w (partial output could not be kept: %v)", *err, commitErr)
+		}
+		return
+	}
+
+	if removeErr := f.Abort(); removeErr != nil {
+		*err = fmt.Errorf("%w (partial output %s could not be removed: %v)", *err, f.Path(), removeErr)
+	}
+}
+
+func storeTimes(h *header.Header, times file.Times) {
+	h.SetTime(header.TagModTime, times.Modified)
+	if !times.Accessed.IsZero() {
+		h.SetTime(header.TagAccessTime, times.Accessed)
+	}
+	if !times.Created.IsZero() {
+		h.SetTime(header.TagBirthTime, times.Created)
+	}
+}
+
+func loadTimes(h *header.Header) (file.Times, bool) {
+	modified, ok := h.Time(header.TagModTime)
+	if !ok {
+		return file.Times{}, false
+	}
+
+	accessed, _ := h.Time(header.TagAccessTime)
+	created, _ := h.Time(header.TagBirthTime)
+	return file.Times{Modified: modified, Accessed: accessed, Created: created}, true
+}
@@ -0,0 +1,369 @@
+package processor_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/stream"
+	"github.com/hambosto/sweetbyte/internal/stream/chunk"
+)
+
+const chunkSize = stream.MinChunkSize
+
+var (
+	password  = []byte("correct horse battery staple")
+	dedupSalt = []byte("test repository")
+)
+
+func options() processor.Options {
+	return processor.Options{KDFProfile: derive.ProfileLight, ChunkSize: chunkSize}
+}
+
+// plaintext returns n bytes that compress in some chunks and not others.
+func plaintext(n int, seed uint64) []byte {
+	data := make([]byte, n)
+	rng := rand.New(rand.NewPCG(seed, 0))
+	for i := range data {
+		if i/4096%2 == 0 {
+			data[i] = byte(rng.Uint32())
+		} else {
+			data[i] = "sweetbyte "[i%10]
+		}
+	}
+	return data
+}
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func encrypt(t *testing.T, data []byte, password []byte, opts processor.Options) []byte {
+	t.Helper()
+	src := writeFile(t, "plain", data)
+	dest := src + ".swx"
+	if err := processor.Encryption(context.Background(), src, dest, password, opts); err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encrypted
+}
+
+func decrypt(t *testing.T, encrypted []byte, opts processor.Options) ([]byte, error) {
+	t.Helper()
+	return decryptAs(t, encrypted, password, opts)
+}
+
+func decryptAs(t *testing.T, encrypted []byte, password []byte, opts processor.Options) ([]byte, error) {
+	t.Helper()
+	src := writeFile(t, "plain.swx", encrypted)
+	dest := filepath.Join(filepath.Dir(src), "decrypted")
+	if err := processor.Decryption(context.Background(), src, dest, password, opts); err != nil {
+		return nil, err
+	}
+	decrypted, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decrypted, nil
+}
+
+// chunkSpans locates the n length-prefixed chunks of an encrypted file by
+// walking back from its trailer, and returns them with the trailer offset.
+func chunkSpans(t *testing.T, encrypted []byte, n int) (spans [][2]int, trailer int) {
+	t.Helper()
+	trailer = len(encrypted) - chunk.TrailerSize
+	if binary.BigEndian.Uint32(encrypted[trailer:]) != chunk.TrailerMarker {
+		t.Fatal("file does not end with a trailer")
+	}
+	return spansBefore(t, encrypted, trailer, n), trailer
+}
+
+// spansBefore locates the n length-prefixed chunks of an encrypted file that
+// end at end.
+func spansBefore(t *testing.T, encrypted []byte, end, n int) (spans [][2]int) {
+	t.Helper()
+	for range n {
+		start := end - 4
+		for start >= 0 && int(binary.BigEndian.Uint32(encrypted[start:])) != end-start-4 {
+			start--
+		}
+		if start < 0 {
+			t.Fatalf("no chunk ends at %d", end)
+		}
+		spans = append([][2]int{{start + 4, end}}, spans...)
+		end = start
+	}
+	return spans
+}
+
+func TestRoundTrip(t *testing.T) {
+	sizes := []int{1, chunkSize - 1, chunkSize, chunkSize + 1, 3*chunkSize + 17}
+	for _, cipher := range []string{"cascade", "aes-gcm", "xchacha20"} {
+		for _, noECC := range []bool{false, true} {
+			for _, size := range sizes {
+				t.Run(fmt.Sprintf("%s/noecc=%t/%d", cipher, noECC, size), func(t *testing.T) {
+					opts := options()
+					opts.Cipher, opts.NoECC = cipher, noECC
+					data := plaintext(size, uint64(size))
+
+					decrypted, err := decrypt(t, encrypt(t, data, password, opts), options())
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(decrypted, data) {
+						t.Error("decrypted file differs from the plaintext")
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	for _, deterministic := range []bool{false, true} {
+		t.Run(fmt.Sprintf("deterministic=%t", deterministic), func(t *testing.T) {
+			opts := options()
+			opts.Deterministic, opts.DedupSalt = deterministic, dedupSalt
+			data := plaintext(3*chunkSize+17, 1)
+
+			var encrypted, decrypted bytes.Buffer
+			if err := processor.EncryptStream(context.Background(), bytes.NewReader(data), -1, &encrypted, password, opts); err != nil {
+				t.Fatal(err)
+			}
+			if err := processor.DecryptStream(context.Background(), &encrypted, &decrypted, password, options()); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decrypted.Bytes(), data) {
+				t.Error("decrypted stream differs from the plaintext")
+			}
+		})
+	}
+}
+
+func TestWrongPassword(t *testing.T) {
+	encrypted := encrypt(t, plaintext(100, 1), []byte("another password"), options())
+	if _, err := decrypt(t, encrypted, options()); !errors.Is(err, processor.ErrAuthentication) {
+		t.Fatalf("decrypting with the wrong password: %v, want ErrAuthentication", err)
+	}
+}
+
+// TestFailedVerify checks that output that fails verification never
+// replaces an existing destination.
+func TestFailedVerify(t *testing.T) {
+	processor.FailVerify(t)
+	src := writeFile(t, "plain", plaintext(2*chunkSize, 6))
+	dest := src + ".swx"
+	if err := os.WriteFile(dest, []byte("existing"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := options()
+	opts.Verify = true
+	if err := processor.Encryption(context.Background(), src, dest, password, opts); err == nil {
+		t.Fatal("encryption succeeded although verification failed")
+	}
+	existing, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(existing) != "existing" {
+		t.Error("destination was replaced by output that failed verification")
+	}
+	entries, err := os.ReadDir(filepath.Dir(dest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("%d files left next to the destination, want 2", len(entries))
+	}
+}
+
+func TestCorruption(t *testing.T) {
+	data := plaintext(3*chunkSize+17, 2)
+	const chunks = 4
+
+	tests := []struct {
+		name  string
+		noECC bool
+		// damage returns a damaged copy of encrypted.
+		damage func(encrypted []byte, spans [][2]int, trailer int) []byte
+		// repaired is whether decryption recovers the plaintext; if not,
+		// it must fail with target, or else with code.
+		repaired bool
+		target   error
+		code     errors.Code
+	}{
+		{
+			name: "one shard",
+			damage: func(encrypted []byte, spans [][2]int, _ int) []byte {
+				start := spans[1][0]
+				for i := start + 10; i < start+60; i++ {
+					encrypted[i] ^= 0xFF
+				}
+				return encrypted
+			},
+			repaired: true,
+		},
+		{
+			name: "parity only",
+			damage: func(encrypted []byte, spans [][2]int, _ int) []byte {
+				encrypted[spans[2][1]-1] ^= 0x01
+				return encrypted
+			},
+			repaired: true,
+		},
+		{
+			name: "beyond repair",
+			damage: func(encrypted []byte, spans [][2]int, _ int) []byte {
+				rng := rand.New(rand.NewPCG(5, 0))
+				for i := spans[1][0]; i < spans[1][1]; i++ {
+					encrypted[i] = byte(rng.Uint32())
+				}
+				return encrypted
+			},
+			code: errors.CodeAuthentication,
+		},
+		{
+			name:  "without parity",
+			noECC: true,
+			damage: func(encrypted []byte, spans [][2]int, _ int) []byte {
+				encrypted[spans[1][0]+10] ^= 0x01
+				return encrypted
+			},
+			code: errors.CodeAuthentication,
+		},
+		{
+			name: "last chunk missing",
+			damage: func(encrypted []byte, spans [][2]int, trailer int) []byte {
+				return append(encrypted[:spans[chunks-1][0]-4], encrypted[trailer:]...)
+			},
+			target: chunk.ErrTrailerMismatch,
+		},
+		{
+			name: "chunks swapped",
+			damage: func(encrypted []byte, spans [][2]int, _ int) []byte {
+				first := bytes.Clone(encrypted[spans[0][0]-4 : spans[0][1]])
+				second := bytes.Clone(encrypted[spans[1][0]-4 : spans[1][1]])
+				swapped := append(bytes.Clone(encrypted[:spans[0][0]-4]), second...)
+				swapped = append(swapped, first...)
+				return append(swapped, encrypted[spans[1][1]:]...)
+			},
+			code: errors.CodeAuthentication,
+		},
+		{
+			name: "oversized chunk size",
+			damage: func(encrypted []byte, spans [][2]int, _ int) []byte {
+				binary.BigEndian.PutUint32(encrypted[spans[1][0]-4:], 0x7FFFFFF0)
+				return encrypted
+			},
+			target: chunk.ErrOversizedChunk,
+		},
+		{
+			name: "trailer missing",
+			damage: func(encrypted []byte, _ [][2]int, trailer int) []byte {
+				return encrypted[:trailer]
+			},
+			target: chunk.ErrTruncated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := options()
+			opts.NoECC = tt.noECC
+			encrypted := encrypt(t, data, password, opts)
+			spans, trailer := chunkSpans(t, encrypted, chunks)
+
+			decrypted, err := decrypt(t, tt.damage(encrypted, spans, trailer), options())
+			switch {
+			case tt.repaired:
+				if err != nil {
+					t.Fatalf("damage was not repaired: %v", err)
+				}
+				if !bytes.Equal(decrypted, data) {
+					t.Error("repaired file differs from the plaintext")
+				}
+			case tt.target != nil:
+				if !errors.Is(err, tt.target) {
+					t.Fatalf("decrypting: %v, want %v", err, tt.target)
+				}
+			default:
+				if errors.CodeOf(err) != tt.code {
+					t.Fatalf("decrypting: %v (%s), want %s", err, errors.CodeOf(err), tt.code)
+				}
+			}
+		})
+	}
+}
+
+// TestDeterministicDedup checks that deterministic encryption gives the
+// same chunks for the same input, although each file has its own header,
+// and that appending to the plaintext leaves the chunks before the append
+// unchanged, so backups deduplicate them.
+func TestDeterministicDedup(t *testing.T) {
+	opts := options()
+	opts.Deterministic, opts.DedupSalt = true, dedupSalt
+	data := plaintext(3*chunkSize+17, 3)
+
+	first := encrypt(t, data, password, opts)
+	spans, trailer := chunkSpans(t, first, 4)
+	second := encrypt(t, data, password, opts)
+	secondSpans, secondTrailer := chunkSpans(t, second, 4)
+	if !bytes.Equal(first[spans[0][0]-4:], second[secondSpans[0][0]-4:]) || trailer-spans[0][0] != secondTrailer-secondSpans[0][0] {
+		t.Fatal("encrypting the same file twice gave different chunks")
+	}
+
+	appended := encrypt(t, append(bytes.Clone(data), plaintext(chunkSize, 4)...), password, opts)
+	// The last chunk was partial and grows with the append.
+	for i, span := range spans[:3] {
+		if !bytes.Contains(appended, first[span[0]:span[1]]) {
+			t.Errorf("chunk %d changed after appending to the plaintext", i)
+		}
+	}
+
+	other := encrypt(t, data, []byte("another password"), opts)
+	for i, span := range spans {
+		if bytes.Contains(other, first[span[0]:span[1]]) {
+			t.Errorf("chunk %d is the same under another password", i)
+		}
+	}
+
+	opts.DedupSalt = []byte("another repository")
+	other = encrypt(t, data, password, opts)
+	for i, span := range spans {
+		if bytes.Contains(other, first[span[0]:span[1]]) {
+			t.Errorf("chunk %d is the same under another dedup salt", i)
+		}
+	}
+
+	opts.DedupSalt = nil
+	src := writeFile(t, "plain", data)
+	if err := processor.Encryption(context.Background(), src, src+".swx", password, opts); errors.CodeOf(err) != errors.CodeInvalidInput {
+		t.Errorf("encrypting without a dedup salt: %v, want %s", err, errors.CodeInvalidInput)
+	}
+
+	decrypted, err := decrypt(t, appended, options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted[:len(data)], data) {
+		t.Error("decrypted file differs from the plaintext")
+	}
+}
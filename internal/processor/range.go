@@ -0,0 +1,93 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/reporter"
+	"github.com/hambosto/sweetbyte/internal/storage"
+)
+
+// rangeBufferSize is how much of a range is decrypted and written at a time.
+const rangeBufferSize = 1024 * 1024
+
+// DecryptRange writes length bytes of the decrypted content of srcPath,
+// starting at offset, to destPath, which may be StdioPath or an object
+// storage URL. A negative length reads to the end. Only the chunks that
+// overlap the range are decrypted.
+func DecryptRange(ctx context.Context, srcPath, destPath string, password []byte, opts Options, offset, length int64) (err error) {
+	defer wrapError("decrypt", srcPath, &err)
+
+	r, err := OpenReader(srcPath, password, opts)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return WriteSection(ctx, r, r.Size(), offset, length, destPath, opts)
+}
+
+// WriteSection writes length bytes of src, which holds size bytes, starting
+// at offset, to destPath. A negative length, or one that runs past the end,
+// writes up to the end.
+func WriteSection(ctx context.Context, src io.ReaderAt, size, offset, length int64, destPath string, opts Options) (err error) {
+	if offset < 0 || offset > size {
+		return errors.Newf(errors.CodeInvalidInput, "", "offset %d is outside the content, which is %d bytes", offset, size)
+	}
+	if length < 0 || length > size-offset {
+		length = size - offset
+	}
+	section := io.NewSectionReader(src, offset, length)
+
+	if IsStdio(destPath) {
+		return copySection(ctx, os.Stdout, section, opts.Reporter)
+	}
+	if storage.IsRemote(destPath) {
+		return writeObject(ctx, destPath, func(w io.Writer) error {
+			return copySection(ctx, w, section, opts.Reporter)
+		})
+	}
+
+	output, err := createOutput(destPath, opts.Mode)
+	if err != nil {
+		return err
+	}
+	defer closeOutput(output, opts.KeepPartial, &err)
+
+	if err := copySection(ctx, output, section, opts.Reporter); err != nil {
+		return err
+	}
+	if err := output.Sync(); err != nil {
+		return fmt.Errorf("failed to sync output: %w", err)
+	}
+	return output.Commit()
+}
+
+func copySection(ctx context.Context, dst io.Writer, section *io.SectionReader, r reporter.Reporter) error {
+	progress := reporter.OrNop(r).Progress(section.Size(), "Decrypting")
+	buffer := make([]byte, min(rangeBufferSize, max(section.Size(), 1)))
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n, err := section.Read(buffer)
+		if n > 0 {
+			if _, err := dst.Write(buffer[:n]); err != nil {
+				return errors.New(errors.CodeIO, "write", err)
+			}
+			if err := progress.Add(int64(n)); err != nil {
+				return fmt.Errorf("updating progress: %w", err)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
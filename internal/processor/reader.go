@@ -0,0 +1,287 @@
+package processor
+
+import (
+	"fmt"
+	"hash"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/header"
+	"github.com/hambosto/sweetbyte/internal/stream"
+	"github.com/hambosto/sweetbyte/internal/stream/chunk"
+	"github.com/hambosto/sweetbyte/internal/types"
+)
+
+// readerCacheSize bounds the memory a Reader spends on decrypted chunks.
+const readerCacheSize = 32 * 1024 * 1024
+
+var ErrNotSeekable = errors.Sentinel("file predates chunk sequence numbers and can only be decrypted from start to end")
+
+// Reader decrypts any part of an encrypted file on demand, one chunk at a
+// time. It is safe for concurrent use.
+type Reader struct {
+	path      string
+	src       *file.Source
+	header    *header.Header
+	key       []byte
+	opts      Options
+	pipeline  *stream.Pipeline
+	index     *chunk.Index
+	chunkSize int64
+	size      int64
+	name      string
+
+	mu       sync.Mutex
+	cached   map[uint64][]byte
+	order    []uint64
+	capacity int
+}
+
+// OpenReader unlocks path and locates its chunks from the chunk index of
+// files encrypted with --seekable. Other files are scanned for their chunk
+// length prefixes; those encrypted from a pipe, including archives, do not
+// record their chunk count, so they are read in full to check the trailer
+// before anything is decrypted.
+func OpenReader(path string, password []byte, opts Options) (r *Reader, err error) {
+	defer wrapError("open", path, &err)
+
+	src, err := file.OpenSource(path, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			src.Close()
+		}
+	}()
+
+	fileHeader, key, err := openHeader(src, password, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			releaseKey(key, opts)
+		}
+	}()
+
+	if !fileHeader.HasSequence() || !fileHeader.HasTrailer() {
+		return nil, errors.New(errors.CodeUnsupported, "", ErrNotSeekable)
+	}
+	chunkSize, ok := fileHeader.ChunkSize()
+	if !ok || chunkSize < chunk.MinChunkSize {
+		return nil, errors.Newf(errors.CodeCorrupt, "", "header does not record a valid chunk size")
+	}
+
+	pipeline, err := newPipeline(key, types.Decryption, Options{})
+	if err != nil {
+		return nil, err
+	}
+	pipeline.SetChunkFlags(fileHeader.Revision() >= header.RevisionChunkFlags)
+	pipeline.SetECC(fileHeader.HasECC())
+	pipeline.SetSuite(fileSuite(fileHeader))
+	if err := setDictionary(pipeline, fileHeader, key); err != nil {
+		return nil, err
+	}
+	if err := setSequence(pipeline, fileHeader); err != nil {
+		return nil, err
+	}
+	if err := setChunkSize(pipeline, fileHeader); err != nil {
+		return nil, err
+	}
+
+	r = &Reader{
+		path:      path,
+		src:       src,
+		header:    fileHeader,
+		key:       key,
+		opts:      opts,
+		pipeline:  pipeline,
+		chunkSize: int64(chunkSize),
+		cached:    make(map[uint64][]byte),
+		capacity:  max(2, readerCacheSize/chunkSize),
+	}
+	if err := r.locateChunks(src.Offset()); err != nil {
+		return nil, err
+	}
+	if r.name, err = r.storedName(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reader) locateChunks(start int64) error {
+	if r.header.HasChunkIndex() {
+		return r.loadIndex(start)
+	}
+
+	var mac hash.Hash
+	if r.header.Streamed() {
+		trailerKey, err := chunk.TrailerKey(r.key)
+		if err != nil {
+			return err
+		}
+		mac = chunk.NewTrailer(trailerKey)
+	}
+
+	coverage := r.pipeline.TrailerCoverage()
+	index, err := chunk.ScanIndex(r.src.File, start, r.pipeline.MaxStoredChunk(), mac, func(stored []byte) []byte {
+		r.pipeline.RepairChunk(stored)
+		return coverage(stored)
+	})
+	if err != nil {
+		return err
+	}
+	r.index = index
+	return r.measure()
+}
+
+func (r *Reader) loadIndex(start int64) error {
+	info, err := r.src.Stat()
+	if err != nil {
+		return errors.New(errors.CodeIO, "stat", err)
+	}
+	indexKey, err := chunk.IndexKey(r.key)
+	if err != nil {
+		return err
+	}
+	if r.index, err = chunk.LoadIndex(r.src.File, start, info.Size()-r.header.Padding(), indexKey); err != nil {
+		return err
+	}
+	return r.measure()
+}
+
+// measure sets the decrypted size, and checks that the chunks found agree
+// with the size recorded in the header.
+func (r *Reader) measure() error {
+	count := r.index.Len()
+	if !r.header.Streamed() {
+		r.size = r.header.GetOriginalSize()
+		if want := chunkCount(r.size, int(r.chunkSize)); uint64(count) != want {
+			return errors.Newf(errors.CodeCorrupt, "", "found %d chunks, the header records %d", count, want)
+		}
+		return nil
+	}
+	if count == 0 {
+		return nil
+	}
+	last, err := r.chunk(uint64(count - 1))
+	if err != nil {
+		return err
+	}
+	r.size = int64(count-1)*r.chunkSize + int64(len(last))
+	return nil
+}
+
+func (r *Reader) storedName() (string, error) {
+	if _, ok := r.header.SealedName(); ok {
+		return openName(r.header, r.key)
+	}
+	if name, ok := r.header.Name(); ok && validName(name) {
+		return name, nil
+	}
+	base := filepath.Base(r.path)
+	for _, ext := range []string{config.FileExtension, config.ArchiveExtension} {
+		if trimmed, ok := strings.CutSuffix(base, ext); ok && trimmed != "" {
+			return trimmed, nil
+		}
+	}
+	return base + ".decrypted", nil
+}
+
+// Size is the length of the decrypted content.
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+// Name is the file name recorded at encryption, or one derived from the
+// encrypted file's name.
+func (r *Reader) Name() string {
+	return r.name
+}
+
+func (r *Reader) ContentType() string {
+	return r.header.ContentType()
+}
+
+// ModTime is the modification time recorded with --preserve-times, or else
+// the time the file was encrypted.
+func (r *Reader) ModTime() time.Time {
+	if times, ok := loadTimes(r.header); ok {
+		return times.Modified
+	}
+	created, _ := r.header.Time(header.TagCreated)
+	return created
+}
+
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.Newf(errors.CodeInvalidInput, "read", "negative offset %d", off)
+	}
+
+	n := 0
+	for n < len(p) && off < r.size {
+		index := uint64(off / r.chunkSize)
+		data, err := r.chunk(index)
+		if err != nil {
+			return n, err
+		}
+		within := off - int64(index)*r.chunkSize
+		if within >= int64(len(data)) {
+			return n, errors.Newf(errors.CodeCorrupt, "read", "chunk is shorter than the recorded chunk size").WithChunk(index)
+		}
+		copied := copy(p[n:], data[within:])
+		n += copied
+		off += int64(copied)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (r *Reader) chunk(index uint64) ([]byte, error) {
+	r.mu.Lock()
+	data, ok := r.cached[index]
+	r.mu.Unlock()
+	if ok {
+		return data, nil
+	}
+
+	if index >= uint64(r.index.Len()) {
+		return nil, errors.Newf(errors.CodeCorrupt, "read", "chunk is missing").WithChunk(index)
+	}
+	offset, length := r.index.Offsets[index], r.index.Lengths[index]
+	sealed := make([]byte, length)
+	if _, err := r.src.ReadAt(sealed, offset); err != nil {
+		return nil, errors.New(errors.CodeIO, "failed to read chunk data", err).WithChunk(index).WithOffset(offset)
+	}
+	data, err := r.pipeline.DecryptChunk(index, sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.cached[index]; !ok {
+		if len(r.order) == r.capacity {
+			delete(r.cached, r.order[0])
+			r.order = slices.Delete(r.order, 0, 1)
+		}
+		r.cached[index] = data
+		r.order = append(r.order, index)
+	}
+	return data, nil
+}
+
+func (r *Reader) Close() error {
+	releaseKey(r.key, r.opts)
+	return r.src.Close()
+}
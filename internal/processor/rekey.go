@@ -0,0 +1,171 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/header"
+	"github.com/hambosto/sweetbyte/internal/reporter"
+	"github.com/hambosto/sweetbyte/internal/secret"
+	"github.com/hambosto/sweetbyte/internal/types"
+	"golang.org/x/sync/errgroup"
+)
+
+func Rekey(ctx context.Context, srcPath, destPath string, oldPassword, newPassword []byte, oldOpts, newOpts Options) (err error) {
+	defer wrapError("rekey", srcPath, &err)
+
+	srcFile, err := file.OpenSource(srcPath, oldOpts.DirectIO)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close()
+
+	oldHeader, oldKey, err := openHeader(srcFile, oldPassword, oldOpts)
+	if err != nil {
+		return err
+	}
+	defer releaseKey(oldKey, oldOpts)
+
+	size, err := decryptedSize(oldHeader)
+	if err != nil {
+		return err
+	}
+
+	var name string
+	if _, ok := oldHeader.SealedName(); ok {
+		if name, err = openName(oldHeader, oldKey); err != nil {
+			return err
+		}
+	}
+
+	if newOpts.KDFProfile == "" && newOpts.Recipient == nil {
+		newOpts.KDFProfile = oldHeader.Profile()
+	}
+	if newOpts.Cipher == "" {
+		newOpts.Cipher = fileSuite(oldHeader).String()
+	}
+	if newOpts.ChunkSize == 0 {
+		if chunkSize, ok := oldHeader.ChunkSize(); ok {
+			newOpts.ChunkSize = chunkSize
+		}
+	}
+	if newOpts.Labels == nil {
+		newOpts.Labels = oldHeader.Labels()
+	}
+	if newOpts.Comment == "" {
+		newOpts.Comment = oldHeader.Comment()
+	}
+	if newOpts.ContentType == "" {
+		newOpts.ContentType = oldHeader.ContentType()
+	}
+	if newOpts.Token == nil && newOpts.Recipient == nil {
+		if newOpts.Token, err = headerToken(oldHeader); err != nil {
+			return err
+		}
+	}
+	newOpts.Padding = oldHeader.Padding()
+	newOpts.Seekable = oldHeader.HasChunkIndex()
+	if newOpts.Dictionary, _, err = openDictionary(oldHeader, oldKey); err != nil {
+		return err
+	}
+	newOpts.HiddenPath = ""
+	if newOpts.Mode == 0 {
+		if info, err := file.GetFileInfo(srcPath); err == nil && info != nil {
+			newOpts.Mode = info.Mode().Perm()
+		}
+	}
+
+	newKey, encryption, headerBytes, err := prepareEncryption("", size, newPassword, newOpts, func(h *header.Header, key []byte) error {
+		return inheritMetadata(h, oldHeader, key, name)
+	})
+	if err != nil {
+		return err
+	}
+	defer secret.Wipe(newKey)
+
+	decryption, err := newPipeline(oldKey, types.Decryption, Options{Concurrency: oldOpts.Concurrency, MaxOutstanding: oldOpts.MaxOutstanding, Reporter: reporter.Nop()})
+	if err != nil {
+		return err
+	}
+	decryption.SetChunkFlags(oldHeader.Revision() >= header.RevisionChunkFlags)
+	decryption.SetECC(oldHeader.HasECC())
+	decryption.SetSuite(fileSuite(oldHeader))
+	if err := setDictionary(decryption, oldHeader, oldKey); err != nil {
+		return err
+	}
+	if err := setTrailer(decryption, oldHeader, oldKey); err != nil {
+		return err
+	}
+	if err := setSequence(decryption, oldHeader); err != nil {
+		return err
+	}
+	if err := setChunkSize(decryption, oldHeader); err != nil {
+		return err
+	}
+	if err := limitMemory(decryption, oldOpts.MaxMemory); err != nil {
+		return err
+	}
+
+	destFile, err := createOutput(destPath, newOpts.Mode)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer closeOutput(destFile, false, &err)
+
+	if _, err := destFile.Write(headerBytes); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	encryption.SetDescription("Re-encrypting...")
+
+	reader, writer := io.Pipe()
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		err := decryption.Process(ctx, srcFile, writer, size)
+		writer.CloseWithError(err)
+		return err
+	})
+	g.Go(func() error {
+		err := encryption.Process(ctx, reader, destFile, size)
+		reader.CloseWithError(err)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return fmt.Errorf("failed to process file: %w", err)
+	}
+	if err := copyPadding(srcPath, destFile, newOpts.Padding); err != nil {
+		return err
+	}
+
+	if err := destFile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync output: %w", err)
+	}
+	if err := destFile.Commit(); err != nil {
+		return fmt.Errorf("failed to finalize destination file: %w", err)
+	}
+	return nil
+}
+
+func inheritMetadata(h, old *header.Header, key []byte, name string) error {
+	if times, ok := loadTimes(old); ok {
+		storeTimes(h, times)
+	}
+
+	owner, ok, err := old.Owner()
+	if err != nil {
+		return err
+	}
+	if ok {
+		h.SetOwner(owner)
+	}
+
+	if name != "" {
+		return sealName(h, key, name)
+	}
+	if name, ok := old.Name(); ok {
+		h.SetName(name)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package processor
+
+import (
+	"github.com/hambosto/sweetbyte/internal/encoding"
+	"github.com/hambosto/sweetbyte/internal/stream"
+	"github.com/hambosto/sweetbyte/internal/types"
+)
+
+type RepairSeverity int
+
+const (
+	RepairMinor RepairSeverity = iota
+	RepairModerate
+	RepairSevere
+)
+
+var repairSeverityNames = map[RepairSeverity]string{
+	RepairMinor:    "minor",
+	RepairModerate: "moderate",
+	RepairSevere:   "severe",
+}
+
+func (s RepairSeverity) String() string {
+	return repairSeverityNames[s]
+}
+
+func (s RepairSeverity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+type RepairRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+type RepairedChunk struct {
+	Index         uint64         `json:"index"`
+	Offset        int64          `json:"offset"`
+	Length        int64          `json:"length"`
+	Shards        int            `json:"shards"`
+	DamagedShards []int          `json:"damaged_shards"`
+	Ranges        []RepairRange  `json:"ranges"`
+	RepairedBytes int64          `json:"repaired_bytes"`
+	Severity      RepairSeverity `json:"severity"`
+}
+
+type RepairReport struct {
+	Path          string          `json:"path"`
+	Chunks        []RepairedChunk `json:"chunks"`
+	RepairedBytes int64           `json:"repaired_bytes"`
+	Severity      RepairSeverity  `json:"severity"`
+}
+
+func (r RepairReport) Repaired() bool {
+	return len(r.Chunks) > 0
+}
+
+func NewRepairReport(path string, repairs []types.ChunkRepair) RepairReport {
+	report := RepairReport{Path: path, Chunks: make([]RepairedChunk, 0, len(repairs))}
+	for _, repair := range repairs {
+		chunk := repairedChunk(repair)
+		report.Chunks = append(report.Chunks, chunk)
+		report.RepairedBytes += chunk.RepairedBytes
+		report.Severity = max(report.Severity, chunk.Severity)
+	}
+	return report
+}
+
+func repairedChunk(repair types.ChunkRepair) RepairedChunk {
+	dataOffset := repair.Offset + framePrefixSize
+	shardSize := int64(repair.ShardSize)
+	chunk := RepairedChunk{
+		Index:         repair.Index,
+		Offset:        dataOffset,
+		Length:        int64(repair.Length),
+		Shards:        encoding.DataShards + encoding.ParityShards,
+		DamagedShards: repair.Shards,
+		RepairedBytes: int64(len(repair.Shards)) * shardSize,
+		Severity:      repairSeverity(len(repair.Shards)),
+	}
+
+	for _, shard := range repair.Shards {
+		start := dataOffset + int64(shard)*shardSize
+		if n := len(chunk.Ranges); n > 0 && chunk.Ranges[n-1].End == start {
+			chunk.Ranges[n-1].End = start + shardSize
+			continue
+		}
+		chunk.Ranges = append(chunk.Ranges, RepairRange{Start: start, End: start + shardSize})
+	}
+	return chunk
+}
+
+func repairSeverity(damaged int) RepairSeverity {
+	limit := encoding.ParityShards / 2
+	switch {
+	case damaged*3 <= limit:
+		return RepairMinor
+	case damaged*3 <= limit*2:
+		return RepairModerate
+	default:
+		return RepairSevere
+	}
+}
+
+func reportRepairs(pipeline *stream.Pipeline, srcPath string, opts Options) {
+	if opts.Repaired == nil {
+		return
+	}
+	if repairs := pipeline.Repairs(); len(repairs) > 0 {
+		opts.Repaired(NewRepairReport(srcPath, repairs))
+	}
+}
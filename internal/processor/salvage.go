@@ -0,0 +1,266 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/hambosto/sweetbyte/internal/encoding"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/header"
+	"github.com/hambosto/sweetbyte/internal/stream"
+	"github.com/hambosto/sweetbyte/internal/stream/chunk"
+	"github.com/hambosto/sweetbyte/internal/stream/processing"
+	"github.com/hambosto/sweetbyte/internal/types"
+	"github.com/hambosto/sweetbyte/internal/utils"
+)
+
+var ErrDataLost = errors.Sentinel("some data could not be recovered")
+
+type SalvageRange struct {
+	Start     int64 `json:"start"`
+	End       int64 `json:"end"`
+	Recovered bool  `json:"recovered"`
+}
+
+type SalvageReport struct {
+	OriginalSize   int64          `json:"original_size"`
+	RecoveredBytes int64          `json:"recovered_bytes"`
+	LostBytes      int64          `json:"lost_bytes"`
+	Chunks         int            `json:"chunks"`
+	RepairedChunks int            `json:"repaired_chunks"`
+	LostChunks     int            `json:"lost_chunks"`
+	FramingLost    bool           `json:"framing_lost,omitempty"`
+	Ranges         []SalvageRange `json:"ranges"`
+}
+
+func (r *SalvageReport) add(start, end int64, recovered bool) {
+	if end <= start {
+		return
+	}
+	if recovered {
+		r.RecoveredBytes += end - start
+	} else {
+		r.LostBytes += end - start
+	}
+
+	if n := len(r.Ranges); n > 0 && r.Ranges[n-1].Recovered == recovered && r.Ranges[n-1].End == start {
+		r.Ranges[n-1].End = end
+		return
+	}
+	r.Ranges = append(r.Ranges, SalvageRange{Start: start, End: end, Recovered: recovered})
+}
+
+func Salvage(ctx context.Context, srcPath, destPath string, password []byte, skipLost bool, opts Options) (report SalvageReport, err error) {
+	defer wrapError("salvage", srcPath, &err)
+
+	srcFile, err := file.OpenSource(srcPath, opts.DirectIO)
+	if err != nil {
+		return report, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close()
+
+	fileHeader, err := header.NewHeader()
+	if err != nil {
+		return report, fmt.Errorf("failed to create header: %w", err)
+	}
+	if err := fileHeader.Unmarshal(srcFile); err != nil {
+		return report, fmt.Errorf("failed to unmarshal header: %w", err)
+	}
+
+	key, err := unlockWith(fileHeader, password, opts)
+	if err != nil {
+		return report, err
+	}
+	defer releaseKey(key, opts)
+
+	dataProcessing, err := processing.NewDataProcessing(key, types.Decryption)
+	if err != nil {
+		return report, fmt.Errorf("data processing creation: %w", err)
+	}
+	dataProcessing.SetChunkFlags(fileHeader.Revision() >= header.RevisionChunkFlags)
+	dataProcessing.SetECC(fileHeader.HasECC())
+	dataProcessing.SetSuite(fileSuite(fileHeader))
+	if dict, ok, err := openDictionary(fileHeader, key); err != nil {
+		return report, err
+	} else if ok {
+		if err := dataProcessing.SetDictionary(dict); err != nil {
+			return report, errors.New(errors.CodeCorrupt, "", err)
+		}
+	}
+	fileID, count, err := fileSequence(fileHeader)
+	if err != nil {
+		return report, err
+	}
+	dataProcessing.SetSequence(fileID, count)
+
+	encoder, err := encoding.NewEncoding(encoding.DataShards, encoding.ParityShards)
+	if err != nil {
+		return report, err
+	}
+
+	chunkSize, ok := fileHeader.ChunkSize()
+	if !ok {
+		chunkSize = stream.DefaultChunkSize
+	}
+	if chunkSize < stream.MinChunkSize || chunkSize > stream.MaxChunkSize {
+		return report, errors.Newf(errors.CodeCorrupt, "", "invalid chunk size %d", chunkSize)
+	}
+	maxChunk := uint32(dataProcessing.MaxEncoded(chunkSize))
+
+	destFile, err := createOutput(destPath, opts.Mode)
+	if err != nil {
+		return report, fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer closeOutput(destFile, false, &err)
+
+	report.OriginalSize = fileHeader.GetOriginalSize()
+	buffers := processing.NewBuffers()
+
+	chunks := &salvageChunks{src: srcFile, offset: srcFile.Offset(), maxChunk: maxChunk, trailer: fileHeader.HasTrailer()}
+	if fileHeader.HasChunkIndex() {
+		chunks.index = salvageIndex(srcFile, fileHeader, key)
+	}
+
+	var position int64
+	streamed := fileHeader.Streamed()
+	for index := uint64(0); streamed || position < report.OriginalSize; index++ {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		data, offset, ok := chunks.next(index)
+		if !ok {
+			report.FramingLost = chunks.framingLost
+			break
+		}
+
+		report.Chunks++
+		intact := true
+		if fileHeader.HasECC() {
+			intact, _ = encoder.Verify(data)
+		}
+		result := dataProcessing.Process(ctx, types.Task{Data: data, Index: index, Offset: offset}, buffers)
+
+		if result.Err == nil {
+			if !intact {
+				report.RepairedChunks++
+			}
+			if _, err := destFile.Write(result.Data); err != nil {
+				return report, errors.New(errors.CodeIO, "write", err).WithPath(destPath)
+			}
+			report.add(position, position+int64(len(result.Data)), true)
+			position += int64(len(result.Data))
+			continue
+		}
+
+		report.LostChunks++
+		lost := int64(chunkSize)
+		if !streamed {
+			lost = min(lost, report.OriginalSize-position)
+		}
+		if !skipLost {
+			if err := writeZeros(destFile, lost); err != nil {
+				return report, errors.New(errors.CodeIO, "write", err).WithPath(destPath)
+			}
+		}
+		report.add(position, position+lost, false)
+		position += lost
+	}
+
+	if streamed {
+		report.OriginalSize = position
+	} else if position < report.OriginalSize {
+		if !skipLost {
+			if err := writeZeros(destFile, report.OriginalSize-position); err != nil {
+				return report, errors.New(errors.CodeIO, "write", err).WithPath(destPath)
+			}
+		}
+		report.add(position, report.OriginalSize, false)
+	}
+
+	if err := destFile.Commit(); err != nil {
+		return report, fmt.Errorf("failed to finalize destination file: %w", err)
+	}
+	return report, nil
+}
+
+// salvageChunks reads the stored chunks of a file in order. With a chunk
+// index it reads each chunk where the index puts it, so a damaged length
+// prefix costs only its own chunk; without one it follows the prefixes and
+// stops at the first that cannot be right.
+type salvageChunks struct {
+	src         *file.Source
+	index       *chunk.Index
+	offset      int64
+	maxChunk    uint32
+	trailer     bool
+	framingLost bool
+}
+
+// next returns chunk i and the offset of its length prefix, or false once
+// there are no more chunks that can be found.
+func (c *salvageChunks) next(i uint64) ([]byte, int64, bool) {
+	if c.index != nil {
+		if i >= uint64(c.index.Len()) || c.index.Lengths[i] > c.maxChunk {
+			return nil, 0, false
+		}
+		data := make([]byte, c.index.Lengths[i])
+		if _, err := c.src.ReadAt(data, c.index.Offsets[i]); err != nil {
+			return nil, 0, false
+		}
+		return data, c.index.Offsets[i] - 4, true
+	}
+
+	var sizeBuffer [4]byte
+	if _, err := io.ReadFull(c.src, sizeBuffer[:]); err != nil {
+		return nil, 0, false
+	}
+	chunkLen := utils.FromBytes[uint32](sizeBuffer[:])
+	if chunkLen == chunk.TrailerMarker && c.trailer {
+		return nil, 0, false
+	}
+	if chunkLen == 0 || chunkLen > c.maxChunk {
+		c.framingLost = true
+		return nil, 0, false
+	}
+
+	data := make([]byte, chunkLen)
+	if _, err := io.ReadFull(c.src, data); err != nil {
+		return nil, 0, false
+	}
+	offset := c.offset
+	c.offset += int64(len(sizeBuffer)) + int64(chunkLen)
+	return data, offset, true
+}
+
+// salvageIndex loads the chunk index of a seekable file. It returns nil if
+// the index is damaged, and chunks are then found by their length prefixes.
+func salvageIndex(src *file.Source, h *header.Header, key []byte) *chunk.Index {
+	info, err := src.Stat()
+	if err != nil {
+		return nil
+	}
+	indexKey, err := chunk.IndexKey(key)
+	if err != nil {
+		return nil
+	}
+	index, err := chunk.LoadIndex(src.File, src.Offset(), info.Size()-h.Padding(), indexKey)
+	if err != nil {
+		return nil
+	}
+	return index
+}
+
+func writeZeros(w io.Writer, n int64) error {
+	zeros := make([]byte, min(n, 1024*1024))
+	for n > 0 {
+		written, err := w.Write(zeros[:min(n, int64(len(zeros)))])
+		if err != nil {
+			return err
+		}
+		n -= int64(written)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package processor_test
+
+import (
+	"bytes"
+	"context"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/scrub"
+	"github.com/hambosto/sweetbyte/internal/stream"
+	"github.com/hambosto/sweetbyte/internal/stream/chunk"
+)
+
+// TestLargestChunkSize checks that scrub, repair and salvage accept the
+// stored chunks of the largest chunk size, which are longer than it once
+// parity is added.
+func TestLargestChunkSize(t *testing.T) {
+	if testing.Short() {
+		t.Skip("stores a chunk of more than 64 MiB")
+	}
+	ctx := context.Background()
+	data := make([]byte, 24*1024*1024)
+	_, _ = rand.NewChaCha8([32]byte{}).Read(data)
+	opts := options()
+	opts.ChunkSize = stream.MaxChunkSize
+	encrypted := encrypt(t, data, password, opts)
+	if len(encrypted) <= stream.MaxChunkSize {
+		t.Fatalf("stored chunk is only %d bytes", len(encrypted))
+	}
+	encrypted[len(encrypted)/2] ^= 0xff
+	src := writeFile(t, "plain.swx", encrypted)
+
+	report := scrub.File(ctx, src)
+	if report.Err != nil {
+		t.Fatalf("scrubbing: %v", report.Err)
+	}
+	if report.Chunks != 1 || report.RepairableChunks != 1 {
+		t.Fatalf("scrubbing found %d chunks, %d repairable, want 1 and 1", report.Chunks, report.RepairableChunks)
+	}
+
+	repaired := filepath.Join(t.TempDir(), "repaired.swx")
+	if _, err := scrub.Repair(ctx, src, repaired); err != nil {
+		t.Fatalf("repairing: %v", err)
+	}
+	if report := scrub.File(ctx, repaired); !report.OK() {
+		t.Fatalf("repaired file is still damaged: %v", report.Err)
+	}
+
+	salvaged := filepath.Join(t.TempDir(), "salvaged")
+	salvage, err := processor.Salvage(ctx, src, salvaged, password, false, opts)
+	if err != nil {
+		t.Fatalf("salvaging: %v", err)
+	}
+	if salvage.LostBytes != 0 || salvage.RepairedChunks != 1 {
+		t.Errorf("salvage lost %d bytes and repaired %d chunks, want 0 and 1", salvage.LostBytes, salvage.RepairedChunks)
+	}
+	recovered, err := os.ReadFile(salvaged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(recovered, data) {
+		t.Error("salvaged file differs from the plaintext")
+	}
+}
+
+// TestSalvageDamagedFraming checks that a chunk whose length prefix and data
+// are overwritten costs a seekable file only that chunk, while a file
+// without a chunk index loses everything after it.
+func TestSalvageDamagedFraming(t *testing.T) {
+	const chunks = 6
+	data := plaintext(chunks*chunkSize, 21)
+	for _, seekable := range []bool{true, false} {
+		opts := options()
+		opts.NoECC = true
+		opts.Seekable = seekable
+		encrypted := encrypt(t, data, password, opts)
+		end := len(encrypted) - chunk.TrailerSize
+		if seekable {
+			end -= int(chunk.IndexSize(chunks))
+		}
+		spans := spansBefore(t, encrypted, end, chunks)
+		for i := spans[2][0] - 4; i < spans[2][1]; i++ {
+			encrypted[i] = 0xee
+		}
+		src := writeFile(t, "plain.swx", encrypted)
+
+		dst := filepath.Join(t.TempDir(), "salvaged")
+		report, err := processor.Salvage(context.Background(), src, dst, password, false, opts)
+		if err != nil {
+			t.Fatalf("seekable %v: salvaging: %v", seekable, err)
+		}
+		recovered, err := os.ReadFile(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !seekable {
+			if !report.FramingLost || report.LostBytes != int64(len(data)-2*chunkSize) {
+				t.Errorf("without an index: framing lost %v, lost %d bytes, want true and %d", report.FramingLost, report.LostBytes, len(data)-2*chunkSize)
+			}
+			continue
+		}
+		if report.FramingLost || report.LostChunks != 1 || report.LostBytes != chunkSize {
+			t.Errorf("with an index: framing lost %v, lost %d chunks and %d bytes, want false, 1 and %d", report.FramingLost, report.LostChunks, report.LostBytes, chunkSize)
+		}
+		want := bytes.Clone(data)
+		clear(want[2*chunkSize : 3*chunkSize])
+		if !bytes.Equal(recovered, want) {
+			t.Error("salvaged file differs from the plaintext outside the lost chunk")
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package processor_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/escrow"
+	"github.com/hambosto/sweetbyte/internal/processor"
+)
+
+// encryptSplit encrypts data with its data key split threshold of total
+// ways, the way encrypt --split-key does, and returns the encrypted file's
+// path along with the shares read back from disk.
+func encryptSplit(t *testing.T, data []byte, threshold, total int) (string, []*escrow.Share) {
+	t.Helper()
+	opts := options()
+	opts.KeyShares = func(destPath string, fileID, dataKey []byte) error {
+		shares, err := escrow.SplitKey(fileID, dataKey, threshold, total)
+		if err != nil {
+			return err
+		}
+		for _, share := range shares {
+			if err := share.Write(escrow.SharePath(destPath, share.Index())); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	src := writeFile(t, "plain", data)
+	dest := src + ".swx"
+	if err := processor.Encryption(context.Background(), src, dest, password, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	shares := make([]*escrow.Share, total)
+	for i := range shares {
+		share, err := escrow.ReadShare(escrow.SharePath(dest, i+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		shares[i] = share
+	}
+	return dest, shares
+}
+
+// recoverWith decrypts path with the data key that shares rebuild, the way
+// recover does.
+func recoverWith(t *testing.T, path string, shares []*escrow.Share) ([]byte, error) {
+	t.Helper()
+	dataKey, err := escrow.CombineShares(shares)
+	if err != nil {
+		return nil, err
+	}
+	dest := filepath.Join(t.TempDir(), "recovered")
+	opts := options()
+	opts.DataKey = dataKey
+	if err := processor.Decryption(context.Background(), path, dest, nil, opts); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(dest)
+}
+
+func TestKeySharesRoundTrip(t *testing.T) {
+	data := plaintext(chunkSize+17, 25)
+	path, shares := encryptSplit(t, data, 3, 5)
+
+	for _, set := range [][]*escrow.Share{shares[:3], shares[2:], {shares[4], shares[0], shares[2]}} {
+		recovered, err := recoverWith(t, path, set)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(recovered, data) {
+			t.Error("recovered file differs from the plaintext")
+		}
+	}
+}
+
+func TestKeySharesFailures(t *testing.T) {
+	path, shares := encryptSplit(t, plaintext(100, 26), 3, 5)
+	otherPath, otherShares := encryptSplit(t, plaintext(100, 27), 3, 5)
+
+	if _, err := recoverWith(t, path, shares[:2]); !errors.Is(err, escrow.ErrTooFewShares) {
+		t.Errorf("recovering with two of three shares: %v, want %v", err, escrow.ErrTooFewShares)
+	}
+	if _, err := recoverWith(t, path, []*escrow.Share{shares[0], shares[1], otherShares[2]}); !errors.Is(err, escrow.ErrMixedShares) {
+		t.Errorf("recovering with shares of two files: %v, want %v", err, escrow.ErrMixedShares)
+	}
+	if _, err := recoverWith(t, path, otherShares[:3]); !errors.Is(err, processor.ErrAuthentication) {
+		t.Errorf("recovering with another file's shares: %v, want ErrAuthentication", err)
+	}
+
+	if err := os.WriteFile(otherPath+escrow.ShareExtension+"1", []byte("not a share"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := escrow.ReadShare(otherPath + escrow.ShareExtension + "1"); !errors.Is(err, escrow.ErrInvalidShare) {
+		t.Errorf("reading a damaged share: %v, want %v", err, escrow.ErrInvalidShare)
+	}
+}
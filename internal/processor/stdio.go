@@ -0,0 +1,175 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/header"
+	"github.com/hambosto/sweetbyte/internal/secret"
+	"github.com/hambosto/sweetbyte/internal/storage"
+	"github.com/hambosto/sweetbyte/internal/types"
+)
+
+const StdioPath = "-"
+
+func IsStdio(path string) bool {
+	return path == StdioPath
+}
+
+// Stream encrypts or decrypts srcPath into destPath in one pass. Either
+// may be StdioPath or an object storage URL.
+func Stream(ctx context.Context, mode types.ProcessorMode, srcPath, destPath string, password []byte, opts Options) (err error) {
+	defer wrapError(strings.ToLower(string(mode)), srcPath, &err)
+
+	src, size, err := openStream(ctx, srcPath, opts.DirectIO)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if IsStdio(destPath) {
+		return streamTo(ctx, mode, src, size, os.Stdout, password, opts)
+	}
+	return streamToFile(ctx, mode, src, size, destPath, password, opts)
+}
+
+// EncryptReader encrypts src into destPath, which may be StdioPath or an
+// object storage URL.
+func EncryptReader(ctx context.Context, src io.Reader, destPath string, password []byte, opts Options) (err error) {
+	defer wrapError("encrypt", destPath, &err)
+
+	if IsStdio(destPath) {
+		return streamTo(ctx, types.ModeEncrypt, src, -1, os.Stdout, password, opts)
+	}
+	return streamToFile(ctx, types.ModeEncrypt, src, -1, destPath, password, opts)
+}
+
+// DecryptFile decrypts srcPath, which may be StdioPath or a URL, into dst.
+func DecryptFile(ctx context.Context, srcPath string, dst io.Writer, password []byte, opts Options) (err error) {
+	defer wrapError("decrypt", srcPath, &err)
+
+	source, _, err := openStream(ctx, srcPath, opts.DirectIO)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	return decryptStream(ctx, source, dst, password, opts)
+}
+
+func streamToFile(ctx context.Context, mode types.ProcessorMode, src io.Reader, size int64, destPath string, password []byte, opts Options) (err error) {
+	if storage.IsRemote(destPath) {
+		return streamToObject(ctx, mode, src, size, destPath, password, opts)
+	}
+
+	output, err := createOutput(destPath, opts.Mode)
+	if err != nil {
+		return err
+	}
+	defer closeOutput(output, opts.KeepPartial, &err)
+
+	if err := streamTo(ctx, mode, src, size, output, password, opts); err != nil {
+		return err
+	}
+	if err := output.Sync(); err != nil {
+		return fmt.Errorf("failed to sync output: %w", err)
+	}
+	return output.Commit()
+}
+
+func streamTo(ctx context.Context, mode types.ProcessorMode, src io.Reader, size int64, dst io.Writer, password []byte, opts Options) error {
+	if mode == types.ModeEncrypt {
+		return encryptStream(ctx, src, size, dst, password, opts)
+	}
+	return decryptStream(ctx, src, dst, password, opts)
+}
+
+func EncryptStream(ctx context.Context, src io.Reader, size int64, dst io.Writer, password []byte, opts Options) (err error) {
+	defer wrapError("encrypt", StdioPath, &err)
+
+	return encryptStream(ctx, src, size, dst, password, opts)
+}
+
+func DecryptStream(ctx context.Context, src io.Reader, dst io.Writer, password []byte, opts Options) (err error) {
+	defer wrapError("decrypt", StdioPath, &err)
+
+	return decryptStream(ctx, src, dst, password, opts)
+}
+
+func encryptStream(ctx context.Context, src io.Reader, size int64, dst io.Writer, password []byte, opts Options) error {
+	pad, err := preparePadding(ctx, password, opts)
+	if err != nil {
+		return err
+	}
+	defer pad.release()
+	opts.Padding = pad.size
+
+	key, pipeline, headerBytes, err := prepareEncryption("", size, password, opts, nil)
+	if err != nil {
+		return err
+	}
+	secret.Wipe(key)
+
+	if _, err := dst.Write(headerBytes); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	if err := pipeline.Process(ctx, src, dst, size); err != nil {
+		return fmt.Errorf("failed to process stream: %w", err)
+	}
+	if err := pad.write(dst); err != nil {
+		return fmt.Errorf("failed to write padding: %w", err)
+	}
+	return nil
+}
+
+func decryptStream(ctx context.Context, src io.Reader, dst io.Writer, password []byte, opts Options) error {
+	fileHeader, key, err := openHeader(src, password, opts)
+	if err != nil {
+		return err
+	}
+	defer releaseKey(key, opts)
+
+	pipeline, err := newPipeline(key, types.Decryption, Options{Concurrency: opts.Concurrency, MaxOutstanding: opts.MaxOutstanding, RateLimit: opts.RateLimit, Throttle: opts.Throttle, Reporter: opts.Reporter})
+	if err != nil {
+		return err
+	}
+	pipeline.SetChunkFlags(fileHeader.Revision() >= header.RevisionChunkFlags)
+	pipeline.SetECC(fileHeader.HasECC())
+	pipeline.SetSuite(fileSuite(fileHeader))
+	if err := setDictionary(pipeline, fileHeader, key); err != nil {
+		return err
+	}
+	if err := setTrailer(pipeline, fileHeader, key); err != nil {
+		return err
+	}
+	if err := setSequence(pipeline, fileHeader); err != nil {
+		return err
+	}
+	if err := setChunkSize(pipeline, fileHeader); err != nil {
+		return err
+	}
+	if err := limitMemory(pipeline, opts.MaxMemory); err != nil {
+		return err
+	}
+
+	srcPath := StdioPath
+	if source, ok := src.(*file.Source); ok {
+		srcPath = source.Name()
+		pipeline.SetBaseOffset(source.Offset())
+	}
+
+	size, err := decryptedSize(fileHeader)
+	if err != nil {
+		return err
+	}
+	err = pipeline.Process(ctx, src, dst, size)
+	reportRepairs(pipeline, srcPath, opts)
+	if err != nil {
+		return fmt.Errorf("failed to process stream: %w", err)
+	}
+	return nil
+}
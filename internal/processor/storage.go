@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/storage"
+	"github.com/hambosto/sweetbyte/internal/types"
+)
+
+// openStream opens srcPath for reading from the start: stdin, an object
+// storage URL or a local file. size is -1 when it is not known in advance.
+func openStream(ctx context.Context, srcPath string, directIO bool) (io.ReadCloser, int64, error) {
+	if IsStdio(srcPath) {
+		return io.NopCloser(os.Stdin), -1, nil
+	}
+	if storage.IsRemote(srcPath) {
+		return storage.Open(ctx, srcPath)
+	}
+
+	source, err := file.OpenSource(srcPath, directIO)
+	if err != nil {
+		return nil, 0, err
+	}
+	size, err := file.Size(srcPath)
+	if err != nil {
+		source.Close()
+		return nil, 0, fmt.Errorf("failed to get file size: %w", err)
+	}
+	return source, size, nil
+}
+
+func streamToObject(ctx context.Context, mode types.ProcessorMode, src io.Reader, size int64, destPath string, password []byte, opts Options) error {
+	return writeObject(ctx, destPath, func(w io.Writer) error {
+		return streamTo(ctx, mode, src, size, w, password, opts)
+	})
+}
+
+// writeObject uploads what write produces to the object storage URL
+// destPath. The object only appears once write succeeds; otherwise the
+// upload is aborted, as partial objects cannot be resumed.
+func writeObject(ctx context.Context, destPath string, write func(io.Writer) error) (err error) {
+	obj, err := storage.Create(ctx, destPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if abortErr := obj.Abort(); abortErr != nil {
+			err = fmt.Errorf("%w (upload could not be aborted: %v)", err, abortErr)
+		}
+	}()
+
+	if err := write(obj); err != nil {
+		return err
+	}
+	return obj.Commit()
+}
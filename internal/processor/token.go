@@ -0,0 +1,37 @@
+package processor
+
+import (
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/header"
+	"github.com/hambosto/sweetbyte/internal/secret"
+)
+
+var ErrTokenFailed = errors.Sentinel("hardware token did not answer")
+
+// applyToken mixes the response of token to the challenge for salt into kek.
+// It consumes kek and returns the combined key.
+func applyToken(kek *secret.Buffer, token derive.KeyProvider, salt []byte) (*secret.Buffer, error) {
+	defer kek.Destroy()
+
+	response, err := token.Respond(derive.ProviderChallenge(salt))
+	if err != nil {
+		return nil, errors.Newf(errors.CodeAuthentication, "", "%w: %w", ErrTokenFailed, err)
+	}
+	defer secret.Wipe(response)
+
+	return secret.FromBytes(derive.MixProvider(kek.Bytes(), response)), nil
+}
+
+// headerToken returns the token the header's primary slot needs, if any.
+func headerToken(h *header.Header) (derive.KeyProvider, error) {
+	spec, ok := h.KeyProvider()
+	if !ok {
+		return nil, nil
+	}
+	token, err := derive.ParseProvider(spec)
+	if err != nil {
+		return nil, errors.New(errors.CodeUnsupported, "", err)
+	}
+	return token, nil
+}
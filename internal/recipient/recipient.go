@@ -0,0 +1,123 @@
+package recipient
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/hambosto/sweetbyte/internal/envelope"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+)
+
+const (
+	publicKeyType = "SWEETBYTE PUBLIC KEY"
+	identityType  = "SWEETBYTE IDENTITY"
+
+	keySize  = 32
+	wrapInfo = "sweetbyte recipient v1"
+)
+
+var (
+	ErrInvalidKey    = errors.Sentinel("not a sweetbyte public key or identity")
+	ErrInvalidStanza = errors.Sentinel("invalid recipient key in header")
+	ErrWrongIdentity = errors.Sentinel("file was not encrypted to this identity")
+)
+
+func Generate() (*ecdh.PrivateKey, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate identity: %w", err)
+	}
+	return key, nil
+}
+
+func Write(prefix string, key *ecdh.PrivateKey) (string, string, error) {
+	publicPath, identityPath := prefix+".pub", prefix+".key"
+	if err := file.WritePEM(identityPath, identityType, key.Bytes()); err != nil {
+		return "", "", err
+	}
+	if err := file.WritePEM(publicPath, publicKeyType, key.PublicKey().Bytes()); err != nil {
+		return "", "", err
+	}
+	return publicPath, identityPath, nil
+}
+
+func ReadPublicKey(path string) (*ecdh.PublicKey, error) {
+	data, err := file.ReadPEM(path, publicKeyType, ErrInvalidKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdh.X25519().NewPublicKey(data)
+	if err != nil {
+		return nil, errors.New(errors.CodeInvalidInput, "read public key", ErrInvalidKey).WithPath(path)
+	}
+	return key, nil
+}
+
+func ReadIdentity(path string) (*ecdh.PrivateKey, error) {
+	data, err := file.ReadPEM(path, identityType, ErrInvalidKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdh.X25519().NewPrivateKey(data)
+	if err != nil {
+		return nil, errors.New(errors.CodeInvalidInput, "read identity", ErrInvalidKey).WithPath(path)
+	}
+	return key, nil
+}
+
+func Wrap(recipient *ecdh.PublicKey, fileID, dataKey []byte) ([]byte, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	wrappingKey, err := deriveWrappingKey(ephemeral, recipient, ephemeral.PublicKey().Bytes(), fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := envelope.Wrap(wrappingKey, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return append(ephemeral.PublicKey().Bytes(), wrappedKey...), nil
+}
+
+func Unwrap(identity *ecdh.PrivateKey, fileID, stanza []byte) ([]byte, error) {
+	if len(stanza) <= keySize {
+		return nil, errors.New(errors.CodeCorrupt, "", ErrInvalidStanza)
+	}
+
+	ephemeral, err := ecdh.X25519().NewPublicKey(stanza[:keySize])
+	if err != nil {
+		return nil, errors.New(errors.CodeCorrupt, "", ErrInvalidStanza)
+	}
+
+	wrappingKey, err := deriveWrappingKey(identity, ephemeral, stanza[:keySize], fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := envelope.Unwrap(wrappingKey, stanza[keySize:])
+	if err != nil {
+		return nil, errors.New(errors.CodeAuthentication, "", ErrWrongIdentity)
+	}
+	return dataKey, nil
+}
+
+func deriveWrappingKey(private *ecdh.PrivateKey, public *ecdh.PublicKey, ephemeral, fileID []byte) ([]byte, error) {
+	shared, err := private.ECDH(public)
+	if err != nil {
+		return nil, fmt.Errorf("key agreement failed: %w", err)
+	}
+
+	salt := append(bytes.Clone(ephemeral), fileID...)
+	return hkdf.Key(sha256.New, shared, salt, wrapInfo, keySize)
+}
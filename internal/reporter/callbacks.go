@@ -0,0 +1,83 @@
+package reporter
+
+import (
+	"sync"
+	"time"
+)
+
+type Stats struct {
+	Description string
+	Done        int64
+	Total       int64
+	Chunks      int
+	Elapsed     time.Duration
+	Throughput  float64
+	ETA         time.Duration
+}
+
+func (s Stats) Percent() float64 {
+	if s.Total <= 0 {
+		return 0
+	}
+	return float64(s.Done) / float64(s.Total) * 100
+}
+
+func (s Stats) MBPerSecond() float64 {
+	return s.Throughput / (1024 * 1024)
+}
+
+type Callbacks struct {
+	OnProgress func(Stats)
+	OnInfo     func(message string)
+	OnWarn     func(message string)
+}
+
+func (c Callbacks) Progress(totalSize int64, description string) Progress {
+	if c.OnProgress == nil {
+		return nop{}
+	}
+	return &tracker{
+		fn:      c.OnProgress,
+		stats:   Stats{Description: description, Total: totalSize},
+		started: time.Now(),
+	}
+}
+
+func (c Callbacks) Info(message string) {
+	if c.OnInfo != nil {
+		c.OnInfo(message)
+	}
+}
+
+func (c Callbacks) Warn(message string) {
+	if c.OnWarn != nil {
+		c.OnWarn(message)
+	}
+}
+
+type tracker struct {
+	mu      sync.Mutex
+	fn      func(Stats)
+	stats   Stats
+	started time.Time
+}
+
+func (t *tracker) Add(size int64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stats.Done += size
+	t.stats.Chunks++
+	t.stats.Elapsed = time.Since(t.started)
+	if seconds := t.stats.Elapsed.Seconds(); seconds > 0 {
+		t.stats.Throughput = float64(t.stats.Done) / seconds
+	}
+
+	t.stats.ETA = 0
+	if t.stats.Total > t.stats.Done && t.stats.Throughput > 0 {
+		t.stats.ETA = time.Duration(float64(t.stats.Total-t.stats.Done) / t.stats.Throughput * float64(time.Second))
+	}
+
+	t.fn(t.stats)
+	return nil
+}
@@ -0,0 +1,42 @@
+package reporter
+
+type Progress interface {
+	Add(size int64) error
+}
+
+type Reporter interface {
+	Progress(totalSize int64, description string) Progress
+	Info(message string)
+	Warn(message string)
+}
+
+type nop struct{}
+
+func Nop() Reporter {
+	return nop{}
+}
+
+func OrNop(r Reporter) Reporter {
+	if r == nil {
+		return nop{}
+	}
+	return r
+}
+
+func (nop) Progress(int64, string) Progress { return nop{} }
+func (nop) Add(int64) error                 { return nil }
+func (nop) Info(string)                     {}
+func (nop) Warn(string)                     {}
+
+type shared struct {
+	Reporter
+	progress Progress
+}
+
+func Shared(r Reporter, progress Progress) Reporter {
+	return shared{Reporter: OrNop(r), progress: progress}
+}
+
+func (s shared) Progress(int64, string) Progress {
+	return s.progress
+}
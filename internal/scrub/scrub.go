@@ -0,0 +1,291 @@
+package scrub
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hambosto/sweetbyte/internal/cipher"
+	"github.com/hambosto/sweetbyte/internal/encoding"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/header"
+	"github.com/hambosto/sweetbyte/internal/stream"
+	"github.com/hambosto/sweetbyte/internal/stream/chunk"
+	"github.com/hambosto/sweetbyte/internal/stream/processing"
+	"github.com/hambosto/sweetbyte/internal/utils"
+)
+
+type Range struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+type ChunkReport struct {
+	Index         uint64          `json:"index"`
+	Offset        int64           `json:"offset"`
+	Length        int64           `json:"length"`
+	Status        encoding.Status `json:"status"`
+	Shards        int             `json:"shards"`
+	DamagedShards []int           `json:"damaged_shards,omitempty"`
+	Ranges        []Range         `json:"ranges"`
+}
+
+type Report struct {
+	Path                string        `json:"path"`
+	Chunks              uint64        `json:"chunks"`
+	NoParity            bool          `json:"no_parity,omitempty"`
+	HeaderShards        int           `json:"header_shards,omitempty"`
+	DamagedChunks       []uint64      `json:"damaged_chunks,omitempty"`
+	RepairableChunks    int           `json:"repairable_chunks"`
+	UnrecoverableChunks int           `json:"unrecoverable_chunks"`
+	Damage              []ChunkReport `json:"damage,omitempty"`
+	Err                 error         `json:"-"`
+	Error               string        `json:"error,omitempty"`
+	Duration            time.Duration `json:"duration"`
+}
+
+func (r Report) OK() bool {
+	return r.Err == nil && len(r.DamagedChunks) == 0
+}
+
+func (r Report) Recoverable() bool {
+	return r.Err == nil && r.UnrecoverableChunks == 0
+}
+
+type Summary struct {
+	Started             time.Time     `json:"started"`
+	Duration            time.Duration `json:"duration"`
+	Files               int           `json:"files"`
+	DamagedFiles        int           `json:"damaged_files"`
+	DamagedChunks       int           `json:"damaged_chunks"`
+	UnrecoverableChunks int           `json:"unrecoverable_chunks"`
+	Reports             []Report      `json:"reports"`
+}
+
+func (s Summary) OK() bool {
+	return s.DamagedFiles == 0
+}
+
+// Repairable reports whether parity can rebuild every damaged file.
+func (s Summary) Repairable() bool {
+	for _, r := range s.Reports {
+		if !r.Recoverable() {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Summary) add(r Report) {
+	s.Files++
+	s.DamagedChunks += len(r.DamagedChunks)
+	s.UnrecoverableChunks += r.UnrecoverableChunks
+	if !r.OK() {
+		s.DamagedFiles++
+	}
+	s.Reports = append(s.Reports, r)
+}
+
+func Paths(ctx context.Context, paths []string, report func(Report)) (Summary, error) {
+	summary := Summary{Started: time.Now()}
+	defer func() { summary.Duration = time.Since(summary.Started) }()
+
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if path == root {
+					return err
+				}
+				return nil
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if !d.Type().IsRegular() || !isSweetbyteFile(path) {
+				return nil
+			}
+
+			r := File(ctx, path)
+			summary.add(r)
+			if report != nil {
+				report(r)
+			}
+			return nil
+		})
+		if err != nil {
+			summary.Duration = time.Since(summary.Started)
+			return summary, fmt.Errorf("failed to scrub %s: %w", root, err)
+		}
+	}
+
+	summary.Duration = time.Since(summary.Started)
+	return summary, nil
+}
+
+func File(ctx context.Context, path string) Report {
+	start := time.Now()
+	report := Report{Path: path}
+	report.Err = scrubFile(ctx, path, &report)
+	if report.Err != nil {
+		report.Error = report.Err.Error()
+	}
+	report.Duration = time.Since(start)
+	return report
+}
+
+func scrubFile(ctx context.Context, path string, report *Report) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.New(errors.CodeIO, "open", err).WithPath(path)
+	}
+	defer f.Close()
+
+	fileHeader, err := header.NewHeader()
+	if err != nil {
+		return err
+	}
+	if err := fileHeader.UnmarshalLazy(f); err != nil {
+		return err
+	}
+	report.NoParity = !fileHeader.HasECC()
+	maxChunk, err := maxChunkLength(fileHeader)
+	if err != nil {
+		return err
+	}
+
+	encoder, err := encoding.NewEncoding(encoding.DataShards, encoding.ParityShards)
+	if err != nil {
+		return err
+	}
+
+	offset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return errors.New(errors.CodeIO, "seek", err).WithPath(path)
+	}
+
+	var sizeBuffer [4]byte
+	for index := uint64(0); ; index++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if _, err := io.ReadFull(f, sizeBuffer[:]); err != nil {
+			if err == io.EOF {
+				if fileHeader.HasTrailer() {
+					return errors.New(errors.CodeCorrupt, "read chunk size", chunk.ErrTruncated).WithChunk(index).WithOffset(offset)
+				}
+				return nil
+			}
+			return errors.New(errors.CodeCorrupt, "read chunk size", err).WithChunk(index).WithOffset(offset)
+		}
+
+		chunkLen := utils.FromBytes[uint32](sizeBuffer[:])
+		if chunkLen == chunk.TrailerMarker && fileHeader.HasTrailer() {
+			if _, _, err := chunk.ReadIndexedTrailer(f, indexSize(fileHeader, index), io.Discard, fileHeader.Padding()); err != nil {
+				return errors.New(errors.CodeCorrupt, "", err).WithOffset(offset)
+			}
+			return nil
+		}
+		if chunkLen == 0 || chunkLen > maxChunk {
+			return errors.Newf(errors.CodeCorrupt, "read chunk size", "invalid chunk length %d", chunkLen).WithChunk(index).WithOffset(offset)
+		}
+
+		data := make([]byte, chunkLen)
+		if _, err := io.ReadFull(f, data); err != nil {
+			return errors.New(errors.CodeCorrupt, "read chunk data", err).WithChunk(index).WithOffset(offset)
+		}
+
+		report.Chunks++
+		dataOffset := offset + int64(len(sizeBuffer))
+		offset = dataOffset + int64(chunkLen)
+
+		if report.NoParity {
+			continue
+		}
+		if ok, err := encoder.Verify(data); err == nil && ok {
+			continue
+		}
+
+		chunkReport := describe(encoder.Diagnose(data), index, dataOffset, int64(chunkLen))
+		report.DamagedChunks = append(report.DamagedChunks, index)
+		report.Damage = append(report.Damage, chunkReport)
+		if chunkReport.Status == encoding.StatusRepairable {
+			report.RepairableChunks++
+		} else {
+			report.UnrecoverableChunks++
+		}
+	}
+}
+
+// maxChunkLength is the largest stored chunk that the chunk size in the
+// header can produce, so a longer size prefix is known to be damage.
+func maxChunkLength(h *header.Header) (uint32, error) {
+	chunkSize, ok := h.ChunkSize()
+	if !ok {
+		chunkSize = stream.DefaultChunkSize
+	}
+	if chunkSize < stream.MinChunkSize || chunkSize > stream.MaxChunkSize {
+		return 0, errors.Newf(errors.CodeCorrupt, "read header", "invalid chunk size %d", chunkSize)
+	}
+	maxChunk := processing.MaxEncoded(chunkSize, cipher.Suite(h.CipherSuite()), h.HasECC(), h.Revision() >= header.RevisionChunkFlags)
+	return uint32(maxChunk), nil
+}
+
+// indexSize is the length of the chunk index that follows the trailer of a
+// file with chunks chunks, or zero if the header records none.
+func indexSize(h *header.Header, chunks uint64) int64 {
+	if !h.HasChunkIndex() {
+		return 0
+	}
+	return chunk.IndexSize(chunks)
+}
+
+func describe(diagnosis encoding.Diagnosis, index uint64, offset, length int64) ChunkReport {
+	report := ChunkReport{
+		Index:         index,
+		Offset:        offset,
+		Length:        length,
+		Status:        diagnosis.Status,
+		Shards:        diagnosis.Shards,
+		DamagedShards: diagnosis.DamagedShards,
+	}
+
+	if diagnosis.Status != encoding.StatusRepairable {
+		report.Ranges = []Range{{Start: offset, End: offset + length}}
+		return report
+	}
+
+	shardSize := int64(diagnosis.ShardSize)
+	for _, shard := range diagnosis.DamagedShards {
+		start := offset + int64(shard)*shardSize
+		if n := len(report.Ranges); n > 0 && report.Ranges[n-1].End == start {
+			report.Ranges[n-1].End = start + shardSize
+			continue
+		}
+		report.Ranges = append(report.Ranges, Range{Start: start, End: start + shardSize})
+	}
+	return report
+}
+
+func isSweetbyteFile(path string) bool {
+	if file.IsEncryptedFile(path) {
+		return true
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	fileHeader, err := header.NewHeader()
+	if err != nil {
+		return false
+	}
+	return fileHeader.UnmarshalLazy(f) == nil
+}
@@ -0,0 +1,13 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd
+
+package secret
+
+import "golang.org/x/sys/unix"
+
+func lock(data []byte) error {
+	return unix.Mlock(data)
+}
+
+func unlock(data []byte) error {
+	return unix.Munlock(data)
+}
@@ -0,0 +1,60 @@
+package secret
+
+import "runtime"
+
+type Buffer struct {
+	data   []byte
+	locked bool
+}
+
+func New(size int) *Buffer {
+	b := &Buffer{data: make([]byte, size)}
+	if size > 0 {
+		b.locked = lock(b.data) == nil
+	}
+	return b
+}
+
+func FromBytes(data []byte) *Buffer {
+	b := New(len(data))
+	copy(b.data, data)
+	Wipe(data)
+	return b
+}
+
+func FromString(s string) *Buffer {
+	b := New(len(s))
+	copy(b.data, s)
+	return b
+}
+
+func (b *Buffer) Bytes() []byte {
+	if b == nil {
+		return nil
+	}
+	return b.data
+}
+
+func (b *Buffer) Len() int {
+	return len(b.Bytes())
+}
+
+func (b *Buffer) Locked() bool {
+	return b != nil && b.locked
+}
+
+func (b *Buffer) Destroy() {
+	if b == nil || b.data == nil {
+		return
+	}
+	Wipe(b.data)
+	if b.locked {
+		_ = unlock(b.data)
+	}
+	b.data, b.locked = nil, false
+}
+
+func Wipe(data []byte) {
+	clear(data)
+	runtime.KeepAlive(data)
+}
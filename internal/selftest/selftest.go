@@ -0,0 +1,342 @@
+package selftest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/hambosto/sweetbyte/internal/cipher"
+	"github.com/hambosto/sweetbyte/internal/cipher/algorithm"
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/encoding"
+	"github.com/hambosto/sweetbyte/internal/processor"
+)
+
+const (
+	roundTripSize     = 3*1024*1024 + 4321
+	roundTripPassword = "sweetbyte selftest password"
+)
+
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+type Result struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+	Err      error         `json:"-"`
+	Error    string        `json:"error,omitempty"`
+}
+
+func (r Result) Passed() bool {
+	return r.Err == nil
+}
+
+func Checks() []Check {
+	checks := []Check{
+		{Name: "AES-256-GCM known answer", Run: checkAESGCM},
+		{Name: "XChaCha20-Poly1305 known answer", Run: checkXChaCha20},
+		{Name: "Argon2id known answer", Run: checkArgon2id},
+		{Name: "Reed-Solomon known answer", Run: checkReedSolomon},
+		{Name: "Reed-Solomon repair", Run: checkReedSolomonRepair},
+	}
+	for _, suite := range []cipher.Suite{cipher.SuiteCascade, cipher.SuiteAESGCM, cipher.SuiteXChaCha20} {
+		checks = append(checks, Check{
+			Name: fmt.Sprintf("Round trip (%s)", suite),
+			Run: func(ctx context.Context) error {
+				return checkRoundTrip(ctx, processor.Options{Cipher: suite.String(), KDFProfile: derive.ProfileLight})
+			},
+		})
+	}
+	checks = append(checks,
+		Check{Name: "Round trip (no ECC)", Run: func(ctx context.Context) error {
+			return checkRoundTrip(ctx, processor.Options{NoECC: true, KDFProfile: derive.ProfileLight})
+		}},
+		Check{Name: "Tampering detected", Run: checkTampering},
+	)
+	return checks
+}
+
+func Run(ctx context.Context, report func(Result)) []Result {
+	checks := Checks()
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		if ctx.Err() != nil {
+			break
+		}
+
+		started := time.Now()
+		result := Result{Name: check.Name, Err: check.Run(ctx)}
+		result.Duration = time.Since(started)
+		if result.Err != nil {
+			result.Error = result.Err.Error()
+		}
+		results = append(results, result)
+		if report != nil {
+			report(result)
+		}
+	}
+	return results
+}
+
+func Failed(results []Result) int {
+	var failed int
+	for _, result := range results {
+		if !result.Passed() {
+			failed++
+		}
+	}
+	return failed
+}
+
+func checkAESGCM(context.Context) error {
+	aes, err := algorithm.NewAESCipher(make([]byte, algorithm.AESKeySize))
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, algorithm.AESNonceSize)
+	plaintext := make([]byte, 16)
+	expected := decodeHex("000000000000000000000000" +
+		"cea7403d4d606b6e074ec5d3baf39d18" +
+		"d0d1c8a799996bf0265b98b5d48ab919")
+
+	sealed, err := aes.EncryptWithNonceTo(nil, nonce, plaintext, nil)
+	if err != nil {
+		return err
+	}
+	if err := compare("ciphertext", sealed, expected); err != nil {
+		return err
+	}
+
+	opened, err := aes.DecryptTo(nil, sealed, nil)
+	if err != nil {
+		return fmt.Errorf("decrypting the reference ciphertext failed: %w", err)
+	}
+	if err := compare("plaintext", opened, plaintext); err != nil {
+		return err
+	}
+	return expectRejected(func(tampered []byte) error {
+		_, err := aes.DecryptTo(nil, tampered, nil)
+		return err
+	}, sealed)
+}
+
+func checkXChaCha20(context.Context) error {
+	key := decodeHex("808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f")
+	nonce := decodeHex("404142434445464748494a4b4c4d4e4f5051525354555657")
+	additionalData := decodeHex("50515253c0c1c2c3c4c5c6c7")
+	plaintext := []byte("Ladies and Gentlemen of the class of '99: If I could offer you only one tip for the future, sunscreen would be it.")
+	expected := decodeHex("404142434445464748494a4b4c4d4e4f5051525354555657" +
+		"bd6d179d3e83d43b9576579493c0e939572a1700252bfaccbed2902c21396cbb" +
+		"731c7f1b0b4aa6440bf3a82f4eda7e39ae64c6708c54c216cb96b72e1213b452" +
+		"2f8c9ba40db5d945b11b69b982c1bb9e3f3fac2bc369488f76b2383565d3fff9" +
+		"21f9664c97637da9768812f615c68b13b52e" +
+		"c0875924c1c7987947deafd8780acf49")
+
+	chacha, err := algorithm.NewChaCha20Cipher(key)
+	if err != nil {
+		return err
+	}
+
+	sealed, err := chacha.EncryptWithNonceTo(nil, nonce, plaintext, additionalData)
+	if err != nil {
+		return err
+	}
+	if err := compare("ciphertext", sealed, expected); err != nil {
+		return err
+	}
+
+	opened, err := chacha.DecryptTo(nil, sealed, additionalData)
+	if err != nil {
+		return fmt.Errorf("decrypting the reference ciphertext failed: %w", err)
+	}
+	if err := compare("plaintext", opened, plaintext); err != nil {
+		return err
+	}
+	return expectRejected(func(tampered []byte) error {
+		_, err := chacha.DecryptTo(nil, tampered, additionalData)
+		return err
+	}, sealed)
+}
+
+func checkArgon2id(context.Context) error {
+	salt := []byte("sweetbyte selftest salt 32 bytes")
+	params := derive.Params{Time: 1, Memory: 8 * 1024, Threads: 1}
+	expected := decodeHex("d663609894acf3c56fcf03b8ca9c2b2c54f084908273a7c0f52b04feb766883f" +
+		"6d511c09a39cae42b434796b9d87618cace8a79501cd7c1f0779072d14b9626d")
+
+	key, err := derive.HashWithParams([]byte("password"), salt, params)
+	if err != nil {
+		return err
+	}
+	return compare("derived key", key, expected)
+}
+
+func checkReedSolomon(context.Context) error {
+	encoder, err := encoding.NewEncoding(encoding.DataShards, encoding.ParityShards)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := encoder.Encode(sequence(1000))
+	if err != nil {
+		return err
+	}
+	if len(encoded) != 3500 {
+		return fmt.Errorf("encoded length is %d, expected 3500", len(encoded))
+	}
+
+	digest := sha256.Sum256(encoded)
+	return compare("SHA-256 of the encoded shards", digest[:], decodeHex("81cf9323a10e506261dae86170820e45710c773a2018f0ab762af5ea5d78246e"))
+}
+
+func checkReedSolomonRepair(context.Context) error {
+	encoder, err := encoding.NewEncoding(encoding.DataShards, encoding.ParityShards)
+	if err != nil {
+		return err
+	}
+
+	data := sequence(4096)
+	encoded, err := encoder.Encode(data)
+	if err != nil {
+		return err
+	}
+	if diagnosis := encoder.Diagnose(encoded); diagnosis.Status != encoding.StatusIntact {
+		return fmt.Errorf("freshly encoded data diagnosed as %s", diagnosis.Status)
+	}
+
+	shardSize := len(encoded) / (encoding.DataShards + encoding.ParityShards)
+	damaged := []int{0, 5}
+	for _, shard := range damaged {
+		encoded[shard*shardSize+7] ^= 0xA5
+	}
+	diagnosis := encoder.Diagnose(encoded)
+	if diagnosis.Status != encoding.StatusRepairable || !slices.Equal(diagnosis.DamagedShards, damaged) {
+		return fmt.Errorf("damaged shards %v diagnosed as %s %v", damaged, diagnosis.Status, diagnosis.DamagedShards)
+	}
+
+	return nil
+}
+
+func checkRoundTrip(ctx context.Context, opts processor.Options) error {
+	dir, err := os.MkdirTemp("", "sweetbyte-selftest-")
+	if err != nil {
+		return fmt.Errorf("failed to create a temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := sample(roundTripSize)
+	src := filepath.Join(dir, "plain.bin")
+	if err := os.WriteFile(src, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write the test file: %w", err)
+	}
+
+	encrypted := src + ".swx"
+	if err := processor.Encryption(ctx, src, encrypted, []byte(roundTripPassword), opts); err != nil {
+		return fmt.Errorf("encryption failed: %w", err)
+	}
+	decrypted := filepath.Join(dir, "decrypted.bin")
+	if err := processor.Decryption(ctx, encrypted, decrypted, []byte(roundTripPassword), opts); err != nil {
+		return fmt.Errorf("decryption failed: %w", err)
+	}
+
+	restored, err := os.ReadFile(decrypted)
+	if err != nil {
+		return fmt.Errorf("failed to read the decrypted file: %w", err)
+	}
+	if !bytes.Equal(restored, data) {
+		return fmt.Errorf("decrypted file differs from the original (%d bytes, expected %d)", len(restored), len(data))
+	}
+
+	if err := processor.Decryption(ctx, encrypted, filepath.Join(dir, "wrong.bin"), []byte(roundTripPassword+"!"), opts); err == nil {
+		return fmt.Errorf("decryption with a wrong password succeeded")
+	}
+	return nil
+}
+
+func checkTampering(ctx context.Context) error {
+	dir, err := os.MkdirTemp("", "sweetbyte-selftest-")
+	if err != nil {
+		return fmt.Errorf("failed to create a temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "plain.bin")
+	if err := os.WriteFile(src, sample(roundTripSize), 0o600); err != nil {
+		return fmt.Errorf("failed to write the test file: %w", err)
+	}
+
+	opts := processor.Options{NoECC: true, KDFProfile: derive.ProfileLight}
+	encrypted := src + ".swx"
+	if err := processor.Encryption(ctx, src, encrypted, []byte(roundTripPassword), opts); err != nil {
+		return fmt.Errorf("encryption failed: %w", err)
+	}
+
+	ciphertext, err := os.ReadFile(encrypted)
+	if err != nil {
+		return fmt.Errorf("failed to read the encrypted file: %w", err)
+	}
+	ciphertext[len(ciphertext)/2] ^= 0x01
+	if err := os.WriteFile(encrypted, ciphertext, 0o600); err != nil {
+		return fmt.Errorf("failed to write the tampered file: %w", err)
+	}
+
+	if err := processor.Decryption(ctx, encrypted, filepath.Join(dir, "decrypted.bin"), []byte(roundTripPassword), opts); err == nil {
+		return fmt.Errorf("a file with a flipped bit decrypted without error")
+	}
+	return nil
+}
+
+func expectRejected(open func([]byte) error, sealed []byte) error {
+	tampered := slices.Clone(sealed)
+	tampered[len(tampered)-1] ^= 0x80
+	if err := open(tampered); err == nil {
+		return fmt.Errorf("a ciphertext with a flipped tag bit was accepted")
+	}
+	return nil
+}
+
+func compare(what string, got, expected []byte) error {
+	if bytes.Equal(got, expected) {
+		return nil
+	}
+	return fmt.Errorf("%s mismatch:\n  got      %s\n  expected %s", what, hex.EncodeToString(got), hex.EncodeToString(expected))
+}
+
+func decodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func sequence(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	return data
+}
+
+func sample(size int) []byte {
+	data := make([]byte, size)
+	random := rand.New(rand.NewPCG(1, 2))
+	for i := range data {
+		if i%4096 < 2048 {
+			data[i] = byte(random.Uint32())
+		} else {
+			data[i] = "sweetbyte"[i%9]
+		}
+	}
+	return data
+}
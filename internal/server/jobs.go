@@ -0,0 +1,285 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/reporter"
+)
+
+type Operation string
+
+const (
+	OpEncrypt Operation = "encrypt"
+	OpDecrypt Operation = "decrypt"
+	OpVerify  Operation = "verify"
+)
+
+type State string
+
+const (
+	StateQueued   State = "queued"
+	StateRunning  State = "running"
+	StateDone     State = "done"
+	StateFailed   State = "failed"
+	StateCanceled State = "canceled"
+)
+
+// maxFinished bounds how many finished jobs are remembered for status
+// queries; the oldest are forgotten first.
+const maxFinished = 1000
+
+var (
+	ErrJobNotFound  = errors.Sentinel("no such job")
+	ErrJobFinished  = errors.Sentinel("job has already finished")
+	ErrShuttingDown = errors.Sentinel("server is shutting down")
+)
+
+// Request describes a job. Paths are resolved by the server, so relative
+// paths are relative to its working directory.
+type Request struct {
+	Operation         Operation `json:"operation"`
+	Input             string    `json:"input"`
+	Output            string    `json:"output,omitempty"`
+	Password          string    `json:"password,omitempty"`
+	Keyfile           string    `json:"keyfile,omitempty"`
+	KeyfilePassphrase string    `json:"keyfile_passphrase,omitempty"`
+	Identity          string    `json:"identity,omitempty"`
+	Force             bool      `json:"force,omitempty"`
+}
+
+// Job is the state of a submitted request as reported to clients. Done and
+// Total count bytes of the input; Total is 0 until the job starts, and
+// stays 0 for input of unknown size.
+type Job struct {
+	ID        string    `json:"id"`
+	Operation Operation `json:"operation"`
+	Input     string    `json:"input"`
+	Output    string    `json:"output,omitempty"`
+	State     State     `json:"state"`
+	Done      int64     `json:"done"`
+	Total     int64     `json:"total"`
+	Error     string    `json:"error,omitempty"`
+	ErrorCode string    `json:"error_code,omitempty"`
+	Created   time.Time `json:"created"`
+	Started   time.Time `json:"started,omitzero"`
+	Finished  time.Time `json:"finished,omitzero"`
+}
+
+func (j Job) finished() bool {
+	return j.State == StateDone || j.State == StateFailed || j.State == StateCanceled
+}
+
+// Runner carries out a request, reporting progress through r. It returns
+// the path that was written, if any.
+type Runner func(ctx context.Context, req Request, r reporter.Reporter) (output string, err error)
+
+// Manager queues jobs and runs at most a fixed number of them at once.
+type Manager struct {
+	run   Runner
+	slots chan struct{}
+
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+
+	mu    sync.Mutex
+	jobs  map[string]*entry
+	order []string
+}
+
+type entry struct {
+	job    Job
+	cancel context.CancelFunc
+}
+
+func NewManager(concurrency int, run Runner) *Manager {
+	ctx, stop := context.WithCancel(context.Background())
+	return &Manager{
+		run:   run,
+		slots: make(chan struct{}, max(concurrency, 1)),
+		ctx:   ctx,
+		stop:  stop,
+		jobs:  make(map[string]*entry),
+	}
+}
+
+// Submit queues req and returns the new job.
+func (m *Manager) Submit(req Request) (Job, error) {
+	if err := req.validate(); err != nil {
+		return Job{}, err
+	}
+	if m.ctx.Err() != nil {
+		return Job{}, errors.New(errors.CodeCanceled, "submit", ErrShuttingDown)
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	e := &entry{
+		job: Job{
+			ID:        strings.ToLower(rand.Text()[:12]),
+			Operation: req.Operation,
+			Input:     req.Input,
+			Output:    req.Output,
+			State:     StateQueued,
+			Created:   time.Now(),
+		},
+		cancel: cancel,
+	}
+
+	m.mu.Lock()
+	m.jobs[e.job.ID] = e
+	m.order = append(m.order, e.job.ID)
+	m.prune()
+	job := e.job
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go m.execute(ctx, e, req)
+	return job, nil
+}
+
+func (m *Manager) execute(ctx context.Context, e *entry, req Request) {
+	defer m.wg.Done()
+	defer e.cancel()
+
+	release, err := m.acquire(ctx)
+	if err != nil {
+		m.finish(e, "", err)
+		return
+	}
+	defer release()
+
+	m.update(e, func(job *Job) {
+		job.State = StateRunning
+		job.Started = time.Now()
+	})
+	progress := reporter.Callbacks{OnProgress: func(s reporter.Stats) {
+		m.update(e, func(job *Job) {
+			job.Done, job.Total = s.Done, s.Total
+		})
+	}}
+	output, err := m.run(ctx, req, progress)
+	m.finish(e, output, err)
+}
+
+// acquire waits for one of the concurrency slots, which streaming requests
+// share with jobs.
+func (m *Manager) acquire(ctx context.Context) (release func(), err error) {
+	select {
+	case m.slots <- struct{}{}:
+		return func() { <-m.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-m.ctx.Done():
+		return nil, errors.New(errors.CodeCanceled, "", ErrShuttingDown)
+	}
+}
+
+func (m *Manager) finish(e *entry, output string, err error) {
+	m.update(e, func(job *Job) {
+		job.Finished = time.Now()
+		if output != "" {
+			job.Output = output
+		}
+		switch {
+		case err == nil:
+			job.State = StateDone
+		case errors.Is(err, context.Canceled):
+			job.State = StateCanceled
+		default:
+			job.State = StateFailed
+			job.Error = err.Error()
+			job.ErrorCode = errors.CodeOf(err).String()
+		}
+	})
+}
+
+func (m *Manager) update(e *entry, fn func(*Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(&e.job)
+}
+
+// prune forgets the oldest finished jobs beyond maxFinished. m.mu must be
+// held.
+func (m *Manager) prune() {
+	finished := 0
+	for _, id := range m.order {
+		if m.jobs[id].job.finished() {
+			finished++
+		}
+	}
+	m.order = slices.DeleteFunc(m.order, func(id string) bool {
+		if finished <= maxFinished || !m.jobs[id].job.finished() {
+			return false
+		}
+		finished--
+		delete(m.jobs, id)
+		return true
+	})
+}
+
+func (m *Manager) Get(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.jobs[id]
+	if !ok {
+		return Job{}, errors.New(errors.CodeNotFound, "", ErrJobNotFound)
+	}
+	return e.job, nil
+}
+
+// List returns every remembered job, oldest first.
+func (m *Manager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]Job, 0, len(m.order))
+	for _, id := range m.order {
+		jobs = append(jobs, m.jobs[id].job)
+	}
+	return jobs
+}
+
+// Cancel stops a queued or running job. The job reports StateCanceled once
+// its work has stopped.
+func (m *Manager) Cancel(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.jobs[id]
+	if !ok {
+		return Job{}, errors.New(errors.CodeNotFound, "", ErrJobNotFound)
+	}
+	if e.job.finished() {
+		return e.job, errors.New(errors.CodeInvalidInput, "", ErrJobFinished)
+	}
+	e.cancel()
+	return e.job, nil
+}
+
+// Close cancels every job and waits for them to stop.
+func (m *Manager) Close() {
+	m.stop()
+	m.wg.Wait()
+}
+
+func (r Request) validate() error {
+	switch r.Operation {
+	case OpEncrypt, OpDecrypt, OpVerify:
+	case "":
+		return errors.Newf(errors.CodeInvalidInput, "operation", "an operation is required (encrypt, decrypt or verify)")
+	default:
+		return errors.Newf(errors.CodeInvalidInput, "operation", "unknown operation %q (use encrypt, decrypt or verify)", r.Operation)
+	}
+	if r.Input == "" {
+		return errors.Newf(errors.CodeInvalidInput, "input", "an input path is required")
+	}
+	if r.Operation == OpVerify && r.Output != "" {
+		return errors.Newf(errors.CodeInvalidInput, "output", "verify writes no output")
+	}
+	return nil
+}
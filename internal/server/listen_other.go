@@ -0,0 +1,9 @@
+//go:build !unix
+
+package server
+
+import "net"
+
+func listenPrivate(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/keyfile"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/recipient"
+	"github.com/hambosto/sweetbyte/internal/reporter"
+	"github.com/hambosto/sweetbyte/internal/secret"
+	"github.com/hambosto/sweetbyte/internal/storage"
+	"github.com/hambosto/sweetbyte/internal/types"
+)
+
+var ErrNoCredentials = errors.Sentinel("a password or identity is required")
+
+// Run carries out req with the processor, following the same rules as the
+// encrypt, decrypt and verify commands.
+func Run(ctx context.Context, req Request, r reporter.Reporter) (string, error) {
+	opts, err := req.options(r)
+	if err != nil {
+		return "", err
+	}
+	if req.Password == "" && opts.Identity == nil {
+		return "", errors.New(errors.CodeInvalidInput, "password", ErrNoCredentials)
+	}
+	password := []byte(req.Password)
+	defer secret.Wipe(password)
+
+	if req.Operation == OpVerify {
+		return "", processor.Authenticate(ctx, req.Input, password, opts)
+	}
+
+	mode := types.ModeEncrypt
+	if req.Operation == OpDecrypt {
+		mode = types.ModeDecrypt
+	}
+	output, err := outputPath(req, mode)
+	if err != nil {
+		return "", err
+	}
+	if !req.Force {
+		if err := checkOutput(ctx, output); err != nil {
+			return "", err
+		}
+	}
+
+	switch {
+	case storage.IsRemote(req.Input) || storage.IsRemote(output):
+		err = processor.Stream(ctx, mode, req.Input, output, password, opts)
+	case mode == types.ModeEncrypt:
+		err = processor.Encryption(ctx, req.Input, output, password, opts)
+	default:
+		err = processor.Decryption(ctx, req.Input, output, password, opts)
+	}
+	return output, err
+}
+
+func (req Request) options(r reporter.Reporter) (processor.Options, error) {
+	opts := processor.Options{Reporter: r}
+	if req.Keyfile != "" {
+		var passphrase keyfile.PassphraseFunc
+		if req.KeyfilePassphrase != "" {
+			passphrase = func() (string, error) { return req.KeyfilePassphrase, nil }
+		}
+		key, err := keyfile.Load(req.Keyfile, passphrase)
+		if err != nil {
+			return opts, err
+		}
+		opts.Keyfile = key
+	}
+	if req.Identity != "" {
+		if req.Operation == OpEncrypt {
+			return opts, errors.Newf(errors.CodeInvalidInput, "identity", "an identity only decrypts")
+		}
+		identity, err := recipient.ReadIdentity(req.Identity)
+		if err != nil {
+			return opts, err
+		}
+		opts.Identity = identity
+	}
+	return opts.WithTuning(config.LoadTuning()), nil
+}
+
+func outputPath(req Request, mode types.ProcessorMode) (string, error) {
+	if req.Output != "" {
+		return req.Output, nil
+	}
+	if storage.IsRemote(req.Input) {
+		return "", errors.Newf(errors.CodeInvalidInput, "output", "an output is required for URL inputs")
+	}
+	output := file.GetOutputPath(req.Input, mode)
+	if output != req.Input {
+		return output, nil
+	}
+	if stored, ok := processor.StoredOutputPath(req.Input); ok {
+		return stored, nil
+	}
+	return "", errors.Newf(errors.CodeInvalidInput, "output", "cannot determine the output name of %s", req.Input)
+}
+
+func checkOutput(ctx context.Context, output string) error {
+	if !storage.IsRemote(output) {
+		if err := file.ValidatePath(output, false); err != nil {
+			return fmt.Errorf("output file validation failed: %w", err)
+		}
+		return nil
+	}
+
+	_, err := storage.Stat(ctx, output)
+	switch {
+	case err == nil:
+		return errors.New(errors.CodeExists, "", file.ErrOutputExists).WithPath(output)
+	case errors.CodeOf(err) == errors.CodeNotFound:
+		return nil
+	}
+	return fmt.Errorf("output validation failed: %w", err)
+}
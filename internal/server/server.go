@@ -0,0 +1,238 @@
+// Package server exposes encrypt, decrypt and verify jobs to other programs
+// through an HTTP/JSON API on a local Unix socket.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+)
+
+const (
+	SocketName = "sweetbyte.sock"
+
+	maxRequestSize  = 1 << 20
+	shutdownTimeout = 10 * time.Second
+)
+
+var (
+	ErrAlreadyRunning  = errors.Sentinel("another server is listening on the socket")
+	ErrUnsafeSocketDir = errors.Sentinel("socket directory is not private")
+)
+
+type Server struct {
+	jobs   *Manager
+	logger *slog.Logger
+}
+
+func New(jobs *Manager, logger *slog.Logger) *Server {
+	return &Server{jobs: jobs, logger: logger}
+}
+
+// Handler routes the job API and the streaming endpoints of
+// PublicHandler for the Unix socket:
+//
+//	GET  /v1/version           server version
+//	GET  /v1/jobs              all remembered jobs
+//	POST /v1/jobs              submit a Request
+//	GET  /v1/jobs/{id}         one job
+//	POST /v1/jobs/{id}/cancel  cancel a job
+//	POST /encrypt              encrypt the request body into the response body
+//	POST /decrypt              decrypt the request body into the response body
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/version", s.version)
+	mux.HandleFunc("GET /v1/jobs", s.list)
+	mux.HandleFunc("POST /v1/jobs", s.submit)
+	mux.HandleFunc("GET /v1/jobs/{id}", s.get)
+	mux.HandleFunc("POST /v1/jobs/{id}/cancel", s.cancel)
+	mux.HandleFunc("POST /encrypt", s.encrypt)
+	mux.HandleFunc("POST /decrypt", s.decrypt)
+	return mux
+}
+
+// Serve answers requests on l with handler until ctx is canceled, then lets
+// in-flight requests finish. Jobs keep running; stop them with
+// Manager.Close.
+func (s *Server) Serve(ctx context.Context, l net.Listener, handler http.Handler) error {
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          slog.NewLogLogger(s.logger.Handler(), slog.LevelWarn),
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(l) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		// Cut off transfers that are still running.
+		return srv.Close()
+	}
+	return nil
+}
+
+func (s *Server) version(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"version": config.AppVersion})
+}
+
+func (s *Server) list(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.jobs.List())
+}
+
+func (s *Server) submit(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, errors.Newf(errors.CodeInvalidInput, "decode request", "%w", err))
+		return
+	}
+
+	job, err := s.jobs.Submit(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.logger.Info("job submitted", "id", job.ID, "operation", job.Operation, "input", job.Input)
+	w.Header().Set("Location", "/v1/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	job, err := s.jobs.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (s *Server) cancel(w http.ResponseWriter, r *http.Request) {
+	job, err := s.jobs.Cancel(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.logger.Info("job canceled", "id", job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusOf(err))
+	w.Write(append(errors.JSON(err), '\n'))
+}
+
+func statusOf(err error) int {
+	switch errors.CodeOf(err) {
+	case errors.CodeInvalidInput:
+		return http.StatusBadRequest
+	case errors.CodeNotFound:
+		return http.StatusNotFound
+	case errors.CodeExists:
+		return http.StatusConflict
+	case errors.CodeAuthentication:
+		return http.StatusUnauthorized
+	case errors.CodeCorrupt:
+		return http.StatusUnprocessableEntity
+	case errors.CodeCanceled:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// DefaultSocketPath is $XDG_RUNTIME_DIR/sweetbyte.sock, or a socket in a
+// private per-user directory under the system temp directory.
+func DefaultSocketPath() (string, error) {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, SocketName), nil
+	}
+	dir := filepath.Join(os.TempDir(), "sweetbyte-"+strconv.Itoa(os.Getuid()))
+	if err := privateDir(dir); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, SocketName), nil
+}
+
+// privateDir creates dir if needed and checks that it is a directory, not a
+// link, that only the current user can enter. The temp directory is shared,
+// so another user could have created it first to swap out the socket.
+func privateDir(dir string) error {
+	if err := os.Mkdir(dir, 0o700); err != nil && !errors.Is(err, fs.ErrExist) {
+		return errors.New(errors.CodeIO, "create socket directory", err).WithPath(dir)
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return errors.New(errors.CodeIO, "create socket directory", err).WithPath(dir)
+	}
+	if !info.IsDir() {
+		return errors.Newf(errors.CodeExists, "create socket directory", "%w: it is not a directory", ErrUnsafeSocketDir).WithPath(dir)
+	}
+	if uid, _, ok := file.InfoOwner(info); ok {
+		if int(uid) != os.Getuid() {
+			return errors.Newf(errors.CodeExists, "create socket directory", "%w: it is owned by user %d", ErrUnsafeSocketDir, uid).WithPath(dir)
+		}
+		if perm := info.Mode().Perm(); perm != 0o700 {
+			return errors.Newf(errors.CodeExists, "create socket directory", "%w: it has mode %#o instead of 0700", ErrUnsafeSocketDir, perm).WithPath(dir)
+		}
+	}
+	return nil
+}
+
+// ListenUnix listens on the socket at path, replacing a socket left behind
+// by a server that is no longer running. Only the owner may connect.
+func ListenUnix(path string) (net.Listener, error) {
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return nil, errors.New(errors.CodeExists, "listen", ErrAlreadyRunning).WithPath(path)
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != os.ModeSocket {
+			return nil, errors.Newf(errors.CodeExists, "listen", "%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, errors.New(errors.CodeIO, "remove stale socket", err).WithPath(path)
+		}
+	}
+
+	l, err := listenPrivate(path)
+	if err != nil {
+		return nil, errors.New(errors.CodeIO, "listen", err).WithPath(path)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		l.Close()
+		return nil, errors.New(errors.CodeIO, "listen", fmt.Errorf("restrict socket permissions: %w", err)).WithPath(path)
+	}
+	return l, nil
+}
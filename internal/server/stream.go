@@ -0,0 +1,171 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/hambosto/sweetbyte/internal/cipher"
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/reporter"
+	"github.com/hambosto/sweetbyte/internal/secret"
+)
+
+// PasswordHeader carries the password for the streaming endpoints, so it
+// stays out of URLs and access logs.
+const PasswordHeader = "X-Sweetbyte-Password"
+
+var (
+	ErrNoToken      = errors.Sentinel("an API token is required to listen on a network address")
+	ErrUnauthorized = errors.Sentinel("missing or invalid API token")
+	ErrPlainHTTP    = errors.Sentinel("plain HTTP is only served on a loopback address")
+)
+
+// PublicHandler routes what is offered on a network address, where every
+// request must present token as a bearer token:
+//
+//	GET  /v1/version  server version
+//	POST /encrypt     encrypt the request body into the response body
+//	POST /decrypt     decrypt the request body into the response body
+//
+// The job API is left out, since jobs name paths on the server's disk.
+func (s *Server) PublicHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/version", s.version)
+	mux.HandleFunc("POST /encrypt", s.encrypt)
+	mux.HandleFunc("POST /decrypt", s.decrypt)
+	return requireToken(token, mux)
+}
+
+func (s *Server) encrypt(w http.ResponseWriter, r *http.Request) {
+	opts := processor.Options{}
+	query := r.URL.Query()
+	opts.KDFProfile = query.Get("kdf")
+	if _, err := derive.ProfileParams(opts.KDFProfile); err != nil {
+		writeError(w, errors.New(errors.CodeInvalidInput, "kdf", err))
+		return
+	}
+	opts.Cipher = query.Get("cipher")
+	if _, err := cipher.ParseSuite(opts.Cipher); err != nil {
+		writeError(w, errors.New(errors.CodeInvalidInput, "cipher", err))
+		return
+	}
+	s.stream(w, r, opts, func(src io.Reader, dst io.Writer, password []byte, opts processor.Options) error {
+		return processor.EncryptStream(r.Context(), src, r.ContentLength, dst, password, opts)
+	})
+}
+
+func (s *Server) decrypt(w http.ResponseWriter, r *http.Request) {
+	s.stream(w, r, processor.Options{}, func(src io.Reader, dst io.Writer, password []byte, opts processor.Options) error {
+		return processor.DecryptStream(r.Context(), src, dst, password, opts)
+	})
+}
+
+// stream runs one transfer once a job slot is free. Errors found before
+// any output is written get a JSON error response. Later ones can no
+// longer change the status, so the connection is cut off instead of
+// ending the chunked body, which clients see as a truncated transfer.
+func (s *Server) stream(w http.ResponseWriter, r *http.Request, opts processor.Options, run func(src io.Reader, dst io.Writer, password []byte, opts processor.Options) error) {
+	password := []byte(r.Header.Get(PasswordHeader))
+	if len(password) == 0 {
+		writeError(w, errors.Newf(errors.CodeInvalidInput, "password", "the %s header is required", PasswordHeader))
+		return
+	}
+	defer secret.Wipe(password)
+
+	release, err := s.jobs.acquire(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer release()
+
+	// Without full duplex, HTTP/1.1 stops reading the upload once the
+	// response starts. Reading first also answers "Expect: 100-continue",
+	// which writing the header before the body would refuse.
+	_ = http.NewResponseController(w).EnableFullDuplex()
+	src := bufio.NewReader(r.Body)
+	_, _ = src.Peek(1)
+
+	opts.Reporter = reporter.Nop()
+	out := &responseStream{w: w}
+	err = run(src, out, password, opts.WithTuning(config.LoadTuning()))
+	switch {
+	case err == nil:
+		out.start()
+	case !out.started:
+		writeError(w, err)
+	default:
+		s.logger.Warn("stream failed", "path", r.URL.Path, "remote", r.RemoteAddr, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// responseStream sends the success status with the first byte of output.
+type responseStream struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (s *responseStream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.w.Header().Set("Content-Type", "application/octet-stream")
+	s.w.WriteHeader(http.StatusOK)
+}
+
+func (s *responseStream) Write(p []byte) (int, error) {
+	s.start()
+	return s.w.Write(p)
+}
+
+func requireToken(token string, next http.Handler) http.Handler {
+	want := sha256.Sum256([]byte(token))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		sum := sha256.Sum256([]byte(got))
+		if !ok || subtle.ConstantTimeCompare(sum[:], want[:]) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sweetbyte"`)
+			writeError(w, errors.New(errors.CodeAuthentication, "", ErrUnauthorized))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ListenTCP listens on addr, with TLS when certFile and keyFile are set.
+// Without them it only listens on loopback addresses, since the token and
+// passwords would otherwise cross the network in plain text.
+func ListenTCP(addr, certFile, keyFile string) (net.Listener, error) {
+	var tlsConfig *tls.Config
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, errors.New(errors.CodeInvalidInput, "load TLS certificate", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.New(errors.CodeIO, "listen", err).WithPath(addr)
+	}
+	if tlsConfig != nil {
+		return tls.NewListener(l, tlsConfig), nil
+	}
+	if tcpAddr, ok := l.Addr().(*net.TCPAddr); !ok || !tcpAddr.IP.IsLoopback() {
+		l.Close()
+		return nil, errors.New(errors.CodeInvalidInput, "listen", ErrPlainHTTP).WithPath(addr)
+	}
+	return l, nil
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+)
+
+const (
+	Name  = "sweetbyte"
+	Label = "io.github.hambosto.sweetbyte"
+)
+
+var (
+	ErrUnsupported  = errors.Sentinel("service installation is not supported on this platform")
+	ErrNotInstalled = errors.Sentinel("service is not installed")
+)
+
+type Spec struct {
+	Executable string
+	Args       []string
+}
+
+func NewSpec(args ...string) (Spec, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return Spec{}, fmt.Errorf("failed to locate the sweetbyte executable: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+	return Spec{Executable: executable, Args: args}, nil
+}
+
+func (s Spec) Command() []string {
+	return append([]string{s.Executable}, s.Args...)
+}
+
+func run(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	output := strings.TrimSpace(string(out))
+	if err != nil {
+		if output == "" {
+			return "", fmt.Errorf("%s %s: %w", name, strings.Join(args, " "), err)
+		}
+		return output, fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, output)
+	}
+	return output, nil
+}
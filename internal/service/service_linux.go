@@ -0,0 +1,100 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const unitName = Name + ".service"
+
+func Render(spec Spec) string {
+	quoted := make([]string, 0, len(spec.Args)+1)
+	for _, arg := range spec.Command() {
+		quoted = append(quoted, systemdQuote(arg))
+	}
+
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=SweetByte background daemon\n\n")
+	b.WriteString("[Service]\n")
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(quoted, " "))
+	b.WriteString("Restart=on-failure\n\n")
+	b.WriteString("[Install]\n")
+	b.WriteString("WantedBy=default.target\n")
+	return b.String()
+}
+
+func Install(spec Spec) (string, error) {
+	path, err := unitPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(Render(spec)), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write unit file: %w", err)
+	}
+
+	if _, err := run("systemctl", "--user", "daemon-reload"); err != nil {
+		return path, err
+	}
+	if _, err := run("systemctl", "--user", "enable", "--now", unitName); err != nil {
+		return path, err
+	}
+	return path, nil
+}
+
+func Uninstall() error {
+	path, err := unitPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return ErrNotInstalled
+	}
+
+	if _, err := run("systemctl", "--user", "disable", "--now", unitName); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove unit file: %w", err)
+	}
+	_, err = run("systemctl", "--user", "daemon-reload")
+	return err
+}
+
+func Status() (string, error) {
+	path, err := unitPath()
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", ErrNotInstalled
+	}
+
+	state, err := run("systemctl", "--user", "is-active", unitName)
+	if state == "" && err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s (%s)", state, path), nil
+}
+
+func unitPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(dir, "systemd", "user", unitName), nil
+}
+
+func systemdQuote(arg string) string {
+	arg = strings.ReplaceAll(arg, "%", "%%")
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\;$") {
+		return arg
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", "$$")
+	return `"` + replacer.Replace(arg) + `"`
+}
@@ -0,0 +1,19 @@
+//go:build !linux && !darwin && !windows
+
+package service
+
+func Render(spec Spec) string {
+	return ""
+}
+
+func Install(spec Spec) (string, error) {
+	return "", ErrUnsupported
+}
+
+func Uninstall() error {
+	return ErrUnsupported
+}
+
+func Status() (string, error) {
+	return "", ErrUnsupported
+}
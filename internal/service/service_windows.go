@@ -0,0 +1,53 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+const taskName = "SweetByte"
+
+func Render(spec Spec) string {
+	args := spec.Command()
+	for i, arg := range args {
+		args[i] = syscall.EscapeArg(arg)
+	}
+	return strings.Join(args, " ")
+}
+
+func Install(spec Spec) (string, error) {
+	location := "scheduled task " + taskName
+	if _, err := run("schtasks", "/Create", "/F", "/SC", "ONLOGON", "/RL", "LIMITED", "/TN", taskName, "/TR", Render(spec)); err != nil {
+		return "", err
+	}
+	if _, err := run("schtasks", "/Run", "/TN", taskName); err != nil {
+		return location, err
+	}
+	return location, nil
+}
+
+func Uninstall() error {
+	if _, err := run("schtasks", "/Query", "/TN", taskName); err != nil {
+		return ErrNotInstalled
+	}
+	_, _ = run("schtasks", "/End", "/TN", taskName)
+	_, err := run("schtasks", "/Delete", "/F", "/TN", taskName)
+	return err
+}
+
+func Status() (string, error) {
+	out, err := run("schtasks", "/Query", "/TN", taskName, "/FO", "LIST")
+	if err != nil {
+		return "", ErrNotInstalled
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		if state, ok := strings.CutPrefix(scanner.Text(), "Status:"); ok {
+			return fmt.Sprintf("%s (scheduled task %s)", strings.TrimSpace(state), taskName), nil
+		}
+	}
+	return "installed (scheduled task " + taskName + ")", nil
+}
@@ -0,0 +1,129 @@
+package shamir
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+const MaxShares = 255
+
+var (
+	expTable [255]byte
+	logTable [256]byte
+)
+
+func init() {
+	x := byte(1)
+	for i := range expTable {
+		expTable[i] = x
+		logTable[x] = byte(i)
+		x = mul(x, 3)
+	}
+}
+
+// mul multiplies in GF(2^8) with the AES polynomial, without tables so it can
+// build them.
+func mul(a, b byte) byte {
+	var product byte
+	for b > 0 {
+		if b&1 != 0 {
+			product ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return product
+}
+
+func div(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])-int(logTable[b])+255)%255]
+}
+
+// Split divides secret into n shares so that any threshold of them rebuild it
+// and fewer reveal nothing. Each share is its x coordinate followed by one
+// byte per secret byte.
+func Split(secret []byte, threshold, n int) ([][]byte, error) {
+	if threshold < 2 || threshold > n || n > MaxShares {
+		return nil, fmt.Errorf("need 2 <= threshold <= shares <= %d, got %d of %d", MaxShares, threshold, n)
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("secret cannot be empty")
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, 1, 1+len(secret))
+		shares[i][0] = byte(i + 1)
+	}
+
+	coefficients := make([]byte, threshold)
+	defer clear(coefficients)
+	for _, b := range secret {
+		coefficients[0] = b
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, fmt.Errorf("failed to generate coefficients: %w", err)
+		}
+		for i := range shares {
+			shares[i] = append(shares[i], evaluate(coefficients, shares[i][0]))
+		}
+	}
+	return shares, nil
+}
+
+// Combine rebuilds the secret from at least threshold distinct shares. With
+// too few shares it returns a wrong secret rather than an error, so callers
+// must check the result.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, fmt.Errorf("need at least 2 shares, got %d", len(shares))
+	}
+
+	size := len(shares[0])
+	seen := make(map[byte]bool, len(shares))
+	for _, share := range shares {
+		if len(share) != size || size < 2 {
+			return nil, fmt.Errorf("shares have different lengths")
+		}
+		if share[0] == 0 || seen[share[0]] {
+			return nil, fmt.Errorf("duplicate or invalid share %d", share[0])
+		}
+		seen[share[0]] = true
+	}
+
+	secret := make([]byte, size-1)
+	for i, share := range shares {
+		// Lagrange basis polynomial for share i, evaluated at x = 0.
+		basis := byte(1)
+		for j, other := range shares {
+			if i != j {
+				basis = mulTable(basis, div(other[0], other[0]^share[0]))
+			}
+		}
+		for k := range secret {
+			secret[k] ^= mulTable(share[k+1], basis)
+		}
+	}
+	return secret, nil
+}
+
+func evaluate(coefficients []byte, x byte) byte {
+	var y byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		y = mulTable(y, x) ^ coefficients[i]
+	}
+	return y
+}
+
+func mulTable(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])+int(logTable[b]))%255]
+}
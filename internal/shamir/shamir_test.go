@@ -0,0 +1,71 @@
+package shamir_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hambosto/sweetbyte/internal/shamir"
+)
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("a 32-byte data key, more or less")
+	shares, err := shamir.Split(secret, 3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every set of three shares, in any order, rebuilds the secret.
+	for i := range shares {
+		for j := i + 1; j < len(shares); j++ {
+			for k := j + 1; k < len(shares); k++ {
+				combined, err := shamir.Combine([][]byte{shares[k], shares[i], shares[j]})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(combined, secret) {
+					t.Errorf("shares %d, %d and %d do not rebuild the secret", i+1, j+1, k+1)
+				}
+			}
+		}
+	}
+
+	if combined, err := shamir.Combine(shares); err != nil || !bytes.Equal(combined, secret) {
+		t.Errorf("all shares do not rebuild the secret: %v", err)
+	}
+	if combined, err := shamir.Combine(shares[:2]); err == nil && bytes.Equal(combined, secret) {
+		t.Error("two shares of a 3-of-5 split rebuild the secret")
+	}
+}
+
+func TestSplitInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		threshold, n int
+		secret       []byte
+	}{
+		{1, 3, []byte("secret")},
+		{4, 3, []byte("secret")},
+		{2, shamir.MaxShares + 1, []byte("secret")},
+		{2, 3, nil},
+	} {
+		if _, err := shamir.Split(tt.secret, tt.threshold, tt.n); err == nil {
+			t.Errorf("splitting %d bytes %d of %d succeeded", len(tt.secret), tt.threshold, tt.n)
+		}
+	}
+}
+
+func TestCombineInvalid(t *testing.T) {
+	shares, err := shamir.Split([]byte("secret"), 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, set := range map[string][][]byte{
+		"one share":       shares[:1],
+		"duplicate share": {shares[0], shares[0]},
+		"uneven shares":   {shares[0], shares[1][:3]},
+		"share zero":      {append([]byte{0}, shares[0][1:]...), shares[1]},
+	} {
+		if _, err := shamir.Combine(set); err == nil {
+			t.Errorf("combining %s succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/netclient"
+)
+
+const (
+	SchemeAzure = "azblob"
+
+	azureVersion   = "2021-08-06"
+	maxAzureBlocks = 50000
+)
+
+var ErrNoAzureCredentials = errors.Sentinel("no Azure credentials: set AZURE_STORAGE_SAS_TOKEN or AZURE_STORAGE_KEY")
+
+func init() {
+	Register(SchemeAzure, azureBackend{})
+}
+
+// azureBackend reads and writes block blobs. Its URLs name the storage
+// account first: azblob://ACCOUNT/CONTAINER/BLOB.
+type azureBackend struct{}
+
+type azureClient struct {
+	http     *http.Client
+	account  string
+	endpoint *url.URL
+	sas      url.Values
+	key      []byte
+}
+
+// azureFromEnv authenticates with a SAS token or the account key.
+// AZURE_STORAGE_BLOB_ENDPOINT replaces the account's public endpoint, e.g.
+// for Azurite.
+func azureFromEnv(account string) (*azureClient, error) {
+	client := &azureClient{http: netclient.NewStreaming(), account: account}
+
+	raw := "https://" + account + ".blob.core.windows.net"
+	if custom := os.Getenv("AZURE_STORAGE_BLOB_ENDPOINT"); custom != "" {
+		raw = custom
+	}
+	endpoint, err := parseEndpoint(raw)
+	if err != nil {
+		return nil, err
+	}
+	client.endpoint = endpoint
+
+	if token := os.Getenv("AZURE_STORAGE_SAS_TOKEN"); token != "" {
+		if client.sas, err = url.ParseQuery(strings.TrimPrefix(token, "?")); err != nil {
+			return nil, errors.Newf(errors.CodeInvalidInput, "azblob", "invalid AZURE_STORAGE_SAS_TOKEN: %w", err)
+		}
+		return client, nil
+	}
+	if key := os.Getenv("AZURE_STORAGE_KEY"); key != "" {
+		if client.key, err = base64.StdEncoding.DecodeString(key); err != nil {
+			return nil, errors.Newf(errors.CodeInvalidInput, "azblob", "invalid AZURE_STORAGE_KEY: %w", err)
+		}
+		return client, nil
+	}
+	return nil, errors.New(errors.CodeInvalidInput, "azblob", ErrNoAzureCredentials)
+}
+
+func (azureBackend) client(location *Location) (*azureClient, string, error) {
+	container, blob, _ := strings.Cut(location.Key, "/")
+	if container == "" || blob == "" {
+		return nil, "", errors.Newf(errors.CodeInvalidInput, "", "%w %q: expected %s://ACCOUNT/CONTAINER/BLOB", ErrInvalidURL, location, SchemeAzure)
+	}
+	client, err := azureFromEnv(location.Bucket)
+	if err != nil {
+		return nil, "", err
+	}
+	return client, location.Key, nil
+}
+
+func (b azureBackend) Stat(ctx context.Context, location *Location) (int64, error) {
+	client, path, err := b.client(location)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.do(ctx, "stat", http.MethodHead, path, nil, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	drain(resp)
+	return resp.ContentLength, nil
+}
+
+func (b azureBackend) Open(ctx context.Context, location *Location) (io.ReadCloser, int64, error) {
+	client, path, err := b.client(location)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := client.do(ctx, "download", http.MethodGet, path, nil, nil, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+func (b azureBackend) Create(ctx context.Context, location *Location) (Object, error) {
+	client, path, err := b.client(location)
+	if err != nil {
+		return nil, err
+	}
+	return &azureUpload{ctx: ctx, client: client, path: path, partSize: PartSize}, nil
+}
+
+func (c *azureClient) do(ctx context.Context, op, method, path string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(c.endpoint.Path, "/") + "/" + path
+	u.RawPath = strings.TrimSuffix(c.endpoint.EscapedPath(), "/") + "/" + uriEncode(path, false)
+
+	values := url.Values{}
+	maps.Copy(values, query)
+	maps.Copy(values, c.sas)
+	u.RawQuery = values.Encode()
+
+	return send(ctx, c.http, op, true, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for name, v := range header {
+			req.Header[name] = v
+		}
+		req.Header.Set("x-ms-version", azureVersion)
+		req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+		if c.key != nil {
+			c.signSharedKey(req, query, len(body))
+		}
+		return req, nil
+	})
+}
+
+// signSharedKey signs req with the account key, as described in "Authorize
+// with Shared Key" for service version 2015-02-21 and later.
+func (c *azureClient) signSharedKey(req *http.Request, query url.Values, length int) {
+	contentLength := ""
+	if length > 0 {
+		contentLength = strconv.Itoa(length)
+	}
+
+	var msHeaders []string
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-ms-") {
+			msHeaders = append(msHeaders, lower)
+		}
+	}
+	slices.Sort(msHeaders)
+
+	var b strings.Builder
+	for _, field := range []string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date: x-ms-date is used instead
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+	} {
+		b.WriteString(field + "\n")
+	}
+	for _, name := range msHeaders {
+		b.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+
+	b.WriteString("/" + c.account + req.URL.EscapedPath())
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, strings.ToLower(name))
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		vals := slices.Clone(query[name])
+		slices.Sort(vals)
+		b.WriteString("\n" + name + ":" + strings.Join(vals, ","))
+	}
+
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(b.String()))
+	req.Header.Set("Authorization", "SharedKey "+c.account+":"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+// azureUpload stages blocks of one part each and commits them with a block
+// list. Blocks that are never committed are discarded by the service, so
+// Abort has nothing to clean up.
+type azureUpload struct {
+	ctx      context.Context
+	client   *azureClient
+	path     string
+	partSize int
+	buf      []byte
+	blocks   []string
+	err      error
+}
+
+func (u *azureUpload) Write(p []byte) (int, error) {
+	if u.err != nil {
+		return 0, u.err
+	}
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), u.partSize-len(u.buf))
+		u.buf = append(u.buf, p[:n]...)
+		p = p[n:]
+		written += n
+		if len(u.buf) == u.partSize {
+			if u.err = u.putBlock(); u.err != nil {
+				return written, u.err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (u *azureUpload) Commit() error {
+	if u.err != nil {
+		return u.err
+	}
+	if len(u.blocks) == 0 {
+		header := http.Header{"X-Ms-Blob-Type": {"BlockBlob"}}
+		resp, err := u.client.do(u.ctx, "upload", http.MethodPut, u.path, nil, header, u.buf)
+		if err != nil {
+			return err
+		}
+		drain(resp)
+		return nil
+	}
+
+	if len(u.buf) > 0 {
+		if err := u.putBlock(); err != nil {
+			return err
+		}
+	}
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"BlockList"`
+		Latest  []string `xml:"Latest"`
+	}{Latest: u.blocks})
+	if err != nil {
+		return err
+	}
+	body = append([]byte(xml.Header), body...)
+	resp, err := u.client.do(u.ctx, "complete upload", http.MethodPut, u.path, url.Values{"comp": {"blocklist"}}, nil, body)
+	if err != nil {
+		return err
+	}
+	drain(resp)
+	return nil
+}
+
+func (u *azureUpload) Abort() error {
+	u.buf = nil
+	return nil
+}
+
+func (u *azureUpload) putBlock() error {
+	number := len(u.blocks) + 1
+	if number > maxAzureBlocks {
+		return errors.Newf(errors.CodeUnsupported, "upload", "%w (%d)", ErrTooManyParts, maxAzureBlocks)
+	}
+	// Block IDs must all have the same length within a blob.
+	id := base64.StdEncoding.EncodeToString(fmt.Appendf(nil, "block-%08d", number))
+
+	query := url.Values{"comp": {"block"}, "blockid": {id}}
+	resp, err := u.client.do(u.ctx, fmt.Sprintf("upload block %d", number), http.MethodPut, u.path, query, nil, u.buf)
+	if err != nil {
+		return err
+	}
+	drain(resp)
+	u.blocks = append(u.blocks, id)
+
+	u.buf = u.buf[:0]
+	if number%partGrowth == 0 {
+		u.partSize *= 2
+	}
+	return nil
+}
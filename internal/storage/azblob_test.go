@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeAzure keeps block blobs in memory, staging blocks until a block list
+// commits them.
+type fakeAzure struct {
+	mu       sync.Mutex
+	blobs    map[string][]byte
+	staged   map[string][]byte
+	requests []string
+}
+
+func newFakeAzure(t *testing.T) *fakeAzure {
+	t.Helper()
+	f := &fakeAzure{blobs: make(map[string][]byte), staged: make(map[string][]byte)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	t.Setenv("AZURE_STORAGE_BLOB_ENDPOINT", srv.URL)
+	t.Setenv("AZURE_STORAGE_KEY", "c2VjcmV0")
+	t.Setenv("AZURE_STORAGE_SAS_TOKEN", "")
+	return f
+}
+
+func (f *fakeAzure) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil || !strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey account:") || r.Header.Get("X-Ms-Version") != azureVersion {
+		writeS3Error(w, http.StatusForbidden, "AuthenticationFailed")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	query := r.URL.Query()
+	f.requests = append(f.requests, r.Method+" "+query.Get("comp"))
+	switch {
+	case r.Method == http.MethodPut && query.Get("comp") == "block":
+		f.staged[query.Get("blockid")] = body
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && query.Get("comp") == "blocklist":
+		var list struct {
+			Latest []string `xml:"Latest"`
+		}
+		if err := xml.Unmarshal(body, &list); err != nil {
+			writeS3Error(w, http.StatusBadRequest, "InvalidXmlDocument")
+			return
+		}
+		var blob []byte
+		for _, id := range list.Latest {
+			block, ok := f.staged[id]
+			if !ok {
+				writeS3Error(w, http.StatusBadRequest, "InvalidBlockList")
+				return
+			}
+			blob = append(blob, block...)
+		}
+		f.blobs[r.URL.Path] = blob
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && r.Header.Get("X-Ms-Blob-Type") == "BlockBlob":
+		f.blobs[r.URL.Path] = body
+		w.WriteHeader(http.StatusCreated)
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "UnsupportedHttpVerb")
+	}
+}
+
+var azureLocation = &Location{Scheme: SchemeAzure, Bucket: "account", Key: "container/dir/blob.bin"}
+
+func createAzureUpload(t *testing.T, data []byte) Object {
+	t.Helper()
+	object, err := azureBackend{}.Create(context.Background(), azureLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	object.(*azureUpload).partSize = 1024
+	for piece := range slices.Chunk(data, 700) {
+		if _, err := object.Write(piece); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return object
+}
+
+func TestAzureUpload(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		requests []string
+	}{
+		{"single", 500, []string{"PUT "}},
+		{"blocks", 2500, []string{"PUT block", "PUT block", "PUT block", "PUT blocklist"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeAzure(t)
+			data := testData(tt.size)
+
+			if err := createAzureUpload(t, data).Commit(); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(f.requests, tt.requests) {
+				t.Errorf("requests = %q, want %q", f.requests, tt.requests)
+			}
+			if !bytes.Equal(f.blobs["/container/dir/blob.bin"], data) {
+				t.Error("stored blob differs from what was written")
+			}
+		})
+	}
+}
+
+// TestAzureAbort checks that aborting leaves only uncommitted blocks, which
+// the service discards.
+func TestAzureAbort(t *testing.T) {
+	f := newFakeAzure(t)
+
+	if err := createAzureUpload(t, testData(2500)).Abort(); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.blobs) != 0 {
+		t.Error("a blob was committed by an aborted upload")
+	}
+}
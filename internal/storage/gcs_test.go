@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+)
+
+func TestGCSFromEnv(t *testing.T) {
+	t.Setenv("STORAGE_EMULATOR_HOST", "localhost:4443")
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "")
+	t.Setenv("GCS_ACCESS_KEY_ID", "")
+	t.Setenv("GCS_SECRET_ACCESS_KEY", "")
+
+	if _, err := gcsFromEnv(); !errors.Is(err, ErrNoGCSCredentials) {
+		t.Fatalf("without credentials: %v, want ErrNoGCSCredentials", err)
+	}
+
+	t.Setenv("GCS_ACCESS_KEY_ID", "GOOG1EXAMPLE")
+	t.Setenv("GCS_SECRET_ACCESS_KEY", "secret")
+	client, err := gcsFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.endpoint.String() != "https://localhost:4443" || !client.pathStyle {
+		t.Errorf("endpoint = %s, path style %t; want the emulator, path style", client.endpoint, client.pathStyle)
+	}
+	if signer, ok := client.signer.(*sigV4); !ok || signer.region != "auto" || signer.service != "s3" {
+		t.Errorf("signer = %#v, want SigV4 for region auto", client.signer)
+	}
+
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "ya29.token")
+	if client, err = gcsFromEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if client.signer != bearerToken("ya29.token") {
+		t.Errorf("signer = %#v, want the access token, which takes precedence", client.signer)
+	}
+}
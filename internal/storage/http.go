@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/netclient"
+)
+
+const (
+	SchemeHTTP  = "http"
+	SchemeHTTPS = "https"
+
+	// StallTimeout is how long a download may go without receiving any
+	// data before the connection is dropped and the transfer resumed.
+	StallTimeout = netclient.DefaultTimeout
+)
+
+var ErrChanged = errors.Sentinel("the file changed on the server during the download")
+
+func init() {
+	Register(SchemeHTTP, httpBackend{})
+	Register(SchemeHTTPS, httpBackend{})
+}
+
+// httpBackend downloads from web servers. A transfer that breaks off is
+// picked up where it stopped with a range request, as long as the server
+// identifies the content with an ETag or Last-Modified date.
+type httpBackend struct{}
+
+func (httpBackend) Open(ctx context.Context, location *Location) (io.ReadCloser, int64, error) {
+	d := &download{ctx: ctx, client: netclient.NewStreaming(), url: location.String(), size: -1}
+	if err := d.request(); err != nil {
+		return nil, 0, err
+	}
+	return d, d.size, nil
+}
+
+type download struct {
+	ctx       context.Context
+	client    *http.Client
+	url       string
+	validator string
+	size      int64
+	offset    int64
+
+	body   io.ReadCloser
+	cancel context.CancelFunc
+	stall  *time.Timer
+	failed int
+	err    error
+}
+
+// request starts the transfer at d.offset, with up to maxAttempts tries.
+func (d *download) request() error {
+	op := "download"
+	if d.offset > 0 {
+		op = fmt.Sprintf("resume download at byte %d", d.offset)
+	}
+
+	ctx, cancel := context.WithCancel(d.ctx)
+	resp, err := send(ctx, d.client, op, true, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url, nil)
+		if err != nil {
+			return nil, err
+		}
+		// Decompressing on the fly would make byte offsets meaningless for
+		// resuming.
+		req.Header.Set("Accept-Encoding", "identity")
+		if d.offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", d.offset))
+			req.Header.Set("If-Range", d.validator)
+		}
+		return req, nil
+	})
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	if d.offset == 0 {
+		d.size = resp.ContentLength
+		d.validator = resumeValidator(resp)
+	} else if resp.StatusCode != http.StatusPartialContent || !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", d.offset)) {
+		resp.Body.Close()
+		cancel()
+		return errors.New(errors.CodeIO, op, ErrChanged)
+	}
+
+	d.body, d.cancel = resp.Body, cancel
+	d.stall = time.AfterFunc(StallTimeout, cancel)
+	return nil
+}
+
+func (d *download) Read(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	for {
+		n, err := d.body.Read(p)
+		d.offset += int64(n)
+		if n > 0 {
+			d.stall.Reset(StallTimeout)
+			d.failed = 0
+		}
+		if err == nil || err == io.EOF || n > 0 {
+			if err != nil && err != io.EOF {
+				// Report the data first; the error recurs on the next Read.
+				err = nil
+			}
+			return n, err
+		}
+
+		if !d.resumable() {
+			d.err = errors.Newf(errors.CodeIO, "download", "connection lost at byte %d: %w", d.offset, err)
+			return 0, d.err
+		}
+		d.failed++
+		d.close()
+		if d.err = sleep(d.ctx, time.Duration(d.failed)*time.Second); d.err != nil {
+			return 0, d.err
+		}
+		if d.err = d.request(); d.err != nil {
+			return 0, d.err
+		}
+	}
+}
+
+func (d *download) resumable() bool {
+	return d.ctx.Err() == nil && d.validator != "" && d.failed < maxAttempts
+}
+
+func (d *download) Close() error {
+	d.close()
+	if d.err == nil {
+		d.err = io.ErrClosedPipe
+	}
+	return nil
+}
+
+func (d *download) close() {
+	if d.body == nil {
+		return
+	}
+	d.stall.Stop()
+	d.body.Close()
+	d.cancel()
+	d.body = nil
+}
+
+// resumeValidator returns the value for If-Range that makes a later range
+// request fail over to the full content if it has changed in between, or
+// "" when the server does not support resuming.
+func resumeValidator(resp *http.Response) string {
+	if resp.Header.Get("Accept-Ranges") != "bytes" {
+		return ""
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
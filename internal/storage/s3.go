@@ -0,0 +1,318 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/netclient"
+)
+
+const (
+	SchemeS3 = "s3"
+
+	// PartSize is the size of each multipart upload part. It doubles every
+	// partGrowth parts so an upload of unknown size stays within the part
+	// limit.
+	PartSize   = 16 << 20
+	partGrowth = 1000
+	maxParts   = 10000
+)
+
+var ErrTooManyParts = errors.Sentinel("upload has more parts than the service allows")
+
+func init() {
+	Register(SchemeS3, &s3Backend{config: s3FromEnv})
+}
+
+// s3Client talks to the S3 REST API, or to a service that mimics it.
+type s3Client struct {
+	http      *http.Client
+	endpoint  *url.URL
+	pathStyle bool
+	signer    signer
+}
+
+// s3Backend builds a client from the environment on every use, so a
+// credential refreshed between operations is picked up.
+type s3Backend struct {
+	config func() (*s3Client, error)
+}
+
+// s3FromEnv reads the variables the AWS CLI uses. A custom endpoint, such
+// as a MinIO server, is addressed path-style.
+func s3FromEnv() (*s3Client, error) {
+	region := firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	client := &s3Client{http: netclient.NewStreaming()}
+	if raw := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"); raw != "" {
+		endpoint, err := parseEndpoint(raw)
+		if err != nil {
+			return nil, err
+		}
+		client.endpoint = endpoint
+		client.pathStyle = true
+	} else {
+		client.endpoint = &url.URL{Scheme: "https", Host: "s3." + region + ".amazonaws.com"}
+	}
+
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey != "" || secretKey != "" {
+		if accessKey == "" || secretKey == "" {
+			return nil, errors.Newf(errors.CodeInvalidInput, "s3", "set both AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+		client.signer = &sigV4{
+			accessKey:    accessKey,
+			secretKey:    secretKey,
+			sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+			region:       region,
+			service:      "s3",
+		}
+	}
+	return client, nil
+}
+
+func (b *s3Backend) Stat(ctx context.Context, location *Location) (int64, error) {
+	client, err := b.config()
+	if err != nil {
+		return 0, err
+	}
+	return client.stat(ctx, location)
+}
+
+func (b *s3Backend) Open(ctx context.Context, location *Location) (io.ReadCloser, int64, error) {
+	client, err := b.config()
+	if err != nil {
+		return nil, 0, err
+	}
+	return client.open(ctx, location)
+}
+
+func (b *s3Backend) Create(ctx context.Context, location *Location) (Object, error) {
+	client, err := b.config()
+	if err != nil {
+		return nil, err
+	}
+	return &s3Upload{ctx: ctx, client: client, location: location, partSize: PartSize}, nil
+}
+
+func (c *s3Client) objectURL(location *Location, query url.Values) *url.URL {
+	u := *c.endpoint
+	path := "/" + location.Key
+	// Bucket names with dots do not match the wildcard certificate of the
+	// virtual-hosted endpoint.
+	if c.pathStyle || strings.Contains(location.Bucket, ".") {
+		path = "/" + location.Bucket + path
+	} else {
+		u.Host = location.Bucket + "." + u.Host
+	}
+	u.Path = strings.TrimSuffix(c.endpoint.Path, "/") + path
+	u.RawPath = strings.TrimSuffix(c.endpoint.EscapedPath(), "/") + uriEncode(path, false)
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+// do sends one request. Requests other than POST are retried, since their
+// bodies are held in memory and repeating them is harmless.
+func (c *s3Client) do(ctx context.Context, op, method string, u *url.URL, body []byte) (*http.Response, error) {
+	payloadHash := emptyPayloadSHA
+	if len(body) > 0 {
+		payloadHash = sha256Hex(body)
+	}
+	return send(ctx, c.http, op, method != http.MethodPost, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+		if err == nil && c.signer != nil {
+			c.signer.sign(req, payloadHash)
+		}
+		return req, err
+	})
+}
+
+func (c *s3Client) stat(ctx context.Context, location *Location) (int64, error) {
+	resp, err := c.do(ctx, "stat", http.MethodHead, c.objectURL(location, nil), nil)
+	if err != nil {
+		return 0, err
+	}
+	drain(resp)
+	return resp.ContentLength, nil
+}
+
+func (c *s3Client) open(ctx context.Context, location *Location) (io.ReadCloser, int64, error) {
+	resp, err := c.do(ctx, "download", http.MethodGet, c.objectURL(location, nil), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// s3Upload buffers one part at a time and sends it with a multipart upload.
+// An object smaller than one part is sent with a single PUT instead.
+type s3Upload struct {
+	ctx      context.Context
+	client   *s3Client
+	location *Location
+	partSize int
+	buf      []byte
+	uploadID string
+	parts    []s3Part
+	err      error
+}
+
+type s3Part struct {
+	Number int    `xml:"PartNumber"`
+	ETag   string `xml:"ETag"`
+}
+
+func (u *s3Upload) Write(p []byte) (int, error) {
+	if u.err != nil {
+		return 0, u.err
+	}
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), u.partSize-len(u.buf))
+		u.buf = append(u.buf, p[:n]...)
+		p = p[n:]
+		written += n
+		if len(u.buf) == u.partSize {
+			if u.err = u.uploadPart(); u.err != nil {
+				return written, u.err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (u *s3Upload) Commit() error {
+	if u.err != nil {
+		return u.err
+	}
+	if u.uploadID == "" {
+		resp, err := u.client.do(u.ctx, "upload", http.MethodPut, u.client.objectURL(u.location, nil), u.buf)
+		if err != nil {
+			return err
+		}
+		drain(resp)
+		return nil
+	}
+
+	if len(u.buf) > 0 {
+		if err := u.uploadPart(); err != nil {
+			return err
+		}
+	}
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}{Parts: u.parts})
+	if err != nil {
+		return err
+	}
+	resp, err := u.client.do(u.ctx, "complete upload", http.MethodPost, u.client.objectURL(u.location, url.Values{"uploadId": {u.uploadID}}), body)
+	if err != nil {
+		return err
+	}
+	// A completion that fails after the 200 status line reports the error
+	// in the body instead.
+	result, err := readBody(resp)
+	if err != nil {
+		return errors.New(errors.CodeIO, "complete upload", err)
+	}
+	if bytes.Contains(result, []byte("<Error>")) {
+		return errors.Newf(errors.CodeIO, "complete upload", "%s", xmlErrorMessage(result))
+	}
+	return nil
+}
+
+func (u *s3Upload) Abort() error {
+	u.buf = nil
+	if u.uploadID == "" {
+		return nil
+	}
+	// The caller's context may be what failed the upload; aborting must
+	// still reach the server so the parts are not billed.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(u.ctx), netclient.DefaultTimeout)
+	defer cancel()
+	resp, err := u.client.do(ctx, "abort upload", http.MethodDelete, u.client.objectURL(u.location, url.Values{"uploadId": {u.uploadID}}), nil)
+	if err != nil {
+		return err
+	}
+	drain(resp)
+	return nil
+}
+
+func (u *s3Upload) uploadPart() error {
+	if u.uploadID == "" {
+		if err := u.initiate(); err != nil {
+			return err
+		}
+	}
+	number := len(u.parts) + 1
+	if number > maxParts {
+		return errors.Newf(errors.CodeUnsupported, "upload", "%w (%d)", ErrTooManyParts, maxParts)
+	}
+
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {u.uploadID}}
+	resp, err := u.client.do(u.ctx, fmt.Sprintf("upload part %d", number), http.MethodPut, u.client.objectURL(u.location, query), u.buf)
+	if err != nil {
+		return err
+	}
+	drain(resp)
+	u.parts = append(u.parts, s3Part{Number: number, ETag: resp.Header.Get("ETag")})
+
+	u.buf = u.buf[:0]
+	if number%partGrowth == 0 {
+		u.partSize *= 2
+	}
+	return nil
+}
+
+func (u *s3Upload) initiate() error {
+	resp, err := u.client.do(u.ctx, "start upload", http.MethodPost, u.client.objectURL(u.location, url.Values{"uploads": {""}}), nil)
+	if err != nil {
+		return err
+	}
+	body, err := readBody(resp)
+	if err != nil {
+		return errors.New(errors.CodeIO, "start upload", err)
+	}
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(body, &result); err != nil || result.UploadID == "" {
+		return errors.Newf(errors.CodeIO, "start upload", "unexpected response from the server")
+	}
+	u.uploadID = result.UploadID
+	return nil
+}
+
+func parseEndpoint(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, errors.Newf(errors.CodeInvalidInput, "endpoint", "invalid endpoint %q", raw)
+	}
+	u.RawQuery, u.Fragment = "", ""
+	return u, nil
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,309 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+)
+
+// fakeS3 keeps objects and multipart uploads in memory and answers the
+// requests s3Client makes, path-style. It rejects a request whose
+// X-Amz-Content-Sha256 does not match its body.
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	uploads  map[string]map[int][]byte
+	requests []string
+	nextID   int
+
+	// completeError makes completing an upload fail after its 200 status
+	// line, as S3 does.
+	completeError bool
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *s3Backend) {
+	t.Helper()
+	f := &fakeS3{objects: make(map[string][]byte), uploads: make(map[string]map[int][]byte)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	endpoint, err := parseEndpoint(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &s3Client{
+		http:      srv.Client(),
+		endpoint:  endpoint,
+		pathStyle: true,
+		signer:    &sigV4{accessKey: "AKIDEXAMPLE", secretKey: "secret", region: "us-east-1", service: "s3"},
+	}
+	return f, &s3Backend{config: func() (*s3Client, error) { return client, nil }}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("X-Amz-Content-Sha256") != sha256Hex(body) || r.Header.Get("Authorization") == "" {
+		writeS3Error(w, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	query := r.URL.Query()
+	uploadID := query.Get("uploadId")
+	key := r.URL.Path
+	f.requests = append(f.requests, r.Method+" "+s3Operation(query))
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.nextID++
+		id := strconv.Itoa(f.nextID)
+		f.uploads[id] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPut && uploadID != "":
+		parts, ok := f.uploads[uploadID]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		parts[number] = body
+		w.Header().Set("ETag", fmt.Sprintf("%q", "part"+strconv.Itoa(number)))
+	case r.Method == http.MethodPost && uploadID != "":
+		f.complete(w, key, uploadID, body)
+	case r.Method == http.MethodDelete && uploadID != "":
+		delete(f.uploads, uploadID)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		object, ok := f.objects[key]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(object)))
+		_, _ = w.Write(object)
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func (f *fakeS3) complete(w http.ResponseWriter, key, uploadID string, body []byte) {
+	parts, ok := f.uploads[uploadID]
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+	if f.completeError {
+		fmt.Fprint(w, "<Error><Code>InternalError</Code><Message>We encountered an internal error.</Message></Error>")
+		return
+	}
+
+	var list struct {
+		Parts []s3Part `xml:"Part"`
+	}
+	if err := xml.Unmarshal(body, &list); err != nil {
+		writeS3Error(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+	var object []byte
+	for i, part := range list.Parts {
+		data, ok := parts[part.Number]
+		if part.Number != i+1 || !ok || part.ETag != fmt.Sprintf("%q", "part"+strconv.Itoa(part.Number)) {
+			writeS3Error(w, http.StatusBadRequest, "InvalidPart")
+			return
+		}
+		object = append(object, data...)
+	}
+	f.objects[key] = object
+	delete(f.uploads, uploadID)
+	fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+}
+
+// s3Operation names a request by the query parameters that select what it
+// does.
+func s3Operation(query url.Values) string {
+	switch {
+	case query.Has("uploads"):
+		return "uploads"
+	case query.Has("partNumber"):
+		return "part " + query.Get("partNumber")
+	case query.Has("uploadId"):
+		return "upload"
+	default:
+		return "object"
+	}
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code></Error>", code)
+}
+
+var testLocation = &Location{Scheme: SchemeS3, Bucket: "bucket", Key: "dir/file name.bin"}
+
+const testKey = "/bucket/dir/file name.bin"
+
+// createUpload starts an upload with parts of partSize bytes and writes
+// data to it in uneven pieces.
+func createUpload(t *testing.T, ctx context.Context, backend *s3Backend, partSize int, data []byte) Object {
+	t.Helper()
+	object, err := backend.Create(ctx, testLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	object.(*s3Upload).partSize = partSize
+	for piece := range slices.Chunk(data, 700) {
+		if _, err := object.Write(piece); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return object
+}
+
+func testData(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data
+}
+
+func TestS3SmallUpload(t *testing.T) {
+	f, backend := newFakeS3(t)
+	data := testData(500)
+
+	object := createUpload(t, context.Background(), backend, 1024, data)
+	if err := object.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"PUT object"}; !slices.Equal(f.requests, want) {
+		t.Errorf("requests = %q, want %q", f.requests, want)
+	}
+	if !bytes.Equal(f.objects[testKey], data) {
+		t.Error("stored object differs from what was written")
+	}
+
+	size, err := backend.Stat(context.Background(), testLocation)
+	if err != nil || size != int64(len(data)) {
+		t.Errorf("Stat = %d, %v, want %d", size, err, len(data))
+	}
+	rc, _, err := backend.Open(context.Background(), testLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if got, err := io.ReadAll(rc); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Open read %d bytes, %v; want the object back", len(got), err)
+	}
+}
+
+func TestS3MultipartUpload(t *testing.T) {
+	f, backend := newFakeS3(t)
+	data := testData(2500)
+
+	object := createUpload(t, context.Background(), backend, 1024, data)
+	if err := object.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"POST uploads", "PUT part 1", "PUT part 2", "PUT part 3", "POST upload"}
+	if !slices.Equal(f.requests, want) {
+		t.Errorf("requests = %q, want %q", f.requests, want)
+	}
+	if !bytes.Equal(f.objects[testKey], data) {
+		t.Error("assembled object differs from what was written")
+	}
+	if len(f.uploads) != 0 {
+		t.Errorf("%d uploads left open", len(f.uploads))
+	}
+}
+
+func TestS3Abort(t *testing.T) {
+	f, backend := newFakeS3(t)
+
+	object := createUpload(t, context.Background(), backend, 1024, testData(2500))
+	if err := object.Abort(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"POST uploads", "PUT part 1", "PUT part 2", "DELETE upload"}
+	if !slices.Equal(f.requests, want) {
+		t.Errorf("requests = %q, want %q", f.requests, want)
+	}
+	if len(f.uploads) != 0 {
+		t.Errorf("%d uploads left open", len(f.uploads))
+	}
+	if _, err := backend.Stat(context.Background(), testLocation); errors.CodeOf(err) != errors.CodeNotFound {
+		t.Errorf("Stat after Abort = %v, want not found", err)
+	}
+}
+
+// TestS3AbortCanceled checks that an upload whose context was canceled,
+// which is how a failed encryption ends, still aborts on the server.
+func TestS3AbortCanceled(t *testing.T) {
+	f, backend := newFakeS3(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	object := createUpload(t, ctx, backend, 1024, testData(2500))
+	cancel()
+	if err := object.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.uploads) != 0 {
+		t.Errorf("%d uploads left open", len(f.uploads))
+	}
+}
+
+func TestS3AbortBeforeUpload(t *testing.T) {
+	f, backend := newFakeS3(t)
+
+	object := createUpload(t, context.Background(), backend, 1024, testData(500))
+	if err := object.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.requests) != 0 {
+		t.Errorf("requests = %q, want none", f.requests)
+	}
+}
+
+func TestS3CompleteError(t *testing.T) {
+	f, backend := newFakeS3(t)
+	f.completeError = true
+
+	object := createUpload(t, context.Background(), backend, 1024, testData(2500))
+	err := object.Commit()
+	if errors.CodeOf(err) != errors.CodeIO {
+		t.Fatalf("Commit = %v, want an I/O error", err)
+	}
+	if _, ok := f.objects[testKey]; ok {
+		t.Error("object was stored although completing the upload failed")
+	}
+}
+
+func TestS3OpenMissing(t *testing.T) {
+	_, backend := newFakeS3(t)
+
+	_, _, err := backend.Open(context.Background(), testLocation)
+	if errors.CodeOf(err) != errors.CodeNotFound {
+		t.Fatalf("Open = %v, want not found", err)
+	}
+}
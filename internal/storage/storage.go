@@ -0,0 +1,297 @@
+// Package storage opens and creates the objects behind scheme://bucket/key
+// locations, so ciphertext can stream to and from object storage or web
+// servers without touching the local disk. Plain paths are not handled here;
+// callers keep using local files for them.
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+)
+
+var (
+	ErrUnknownScheme = errors.Sentinel("unknown storage scheme")
+	ErrInvalidURL    = errors.Sentinel("invalid storage URL")
+	ErrReadOnly      = errors.Sentinel("this storage can only be read")
+)
+
+// Object is a destination being written. Nothing is visible at its location
+// until Commit succeeds, and Abort discards everything written so far.
+type Object interface {
+	io.Writer
+	Commit() error
+	Abort() error
+}
+
+// Opener reads an existing object. size is -1 when the backend does not
+// report it.
+type Opener interface {
+	Open(ctx context.Context, location *Location) (rc io.ReadCloser, size int64, err error)
+}
+
+// Creator starts writing a new object, replacing any object at location
+// once committed.
+type Creator interface {
+	Create(ctx context.Context, location *Location) (Object, error)
+}
+
+// Stater reports the size of an existing object, failing with
+// errors.CodeNotFound when there is none.
+type Stater interface {
+	Stat(ctx context.Context, location *Location) (int64, error)
+}
+
+// Location is a parsed scheme://bucket/key URL. For web URLs, Bucket is the
+// host and Key the path with any query string.
+type Location struct {
+	Scheme string
+	Bucket string
+	Key    string
+}
+
+func (l *Location) String() string {
+	return l.Scheme + "://" + l.Bucket + "/" + l.Key
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Opener)
+)
+
+// Register makes backend handle URLs with scheme. Every backend can be read;
+// those that also implement Creator and Stater can be written. Registering a
+// scheme twice replaces the earlier backend.
+func Register(scheme string, backend Opener) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[scheme] = backend
+}
+
+// Schemes lists the registered schemes, sorted.
+func Schemes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return schemesLocked()
+}
+
+// IsRemote reports whether path is a URL for a registered backend rather
+// than a local path.
+func IsRemote(path string) bool {
+	scheme, _, ok := strings.Cut(path, "://")
+	if !ok {
+		return false
+	}
+	_, err := lookup(scheme)
+	return err == nil
+}
+
+func Parse(raw string) (*Location, error) {
+	scheme, rest, ok := strings.Cut(raw, "://")
+	if !ok {
+		return nil, errors.Newf(errors.CodeInvalidInput, "", "%w %q", ErrInvalidURL, raw)
+	}
+	bucket, key, _ := strings.Cut(rest, "/")
+	if bucket == "" || key == "" || strings.HasSuffix(key, "/") {
+		return nil, errors.Newf(errors.CodeInvalidInput, "", "%w %q: expected %s://BUCKET/KEY", ErrInvalidURL, raw, scheme)
+	}
+	return &Location{Scheme: strings.ToLower(scheme), Bucket: bucket, Key: key}, nil
+}
+
+// Open opens the object at raw for reading.
+func Open(ctx context.Context, raw string) (io.ReadCloser, int64, error) {
+	location, backend, err := resolve(raw)
+	if err != nil {
+		return nil, 0, err
+	}
+	return backend.Open(ctx, location)
+}
+
+// Stat returns the size of the object at raw.
+func Stat(ctx context.Context, raw string) (int64, error) {
+	location, backend, err := resolve(raw)
+	if err != nil {
+		return 0, err
+	}
+	stater, ok := backend.(Stater)
+	if !ok {
+		return 0, errors.New(errors.CodeUnsupported, "stat", ErrReadOnly)
+	}
+	return stater.Stat(ctx, location)
+}
+
+// Create starts writing the object at raw.
+func Create(ctx context.Context, raw string) (Object, error) {
+	location, backend, err := resolve(raw)
+	if err != nil {
+		return nil, err
+	}
+	creator, ok := backend.(Creator)
+	if !ok {
+		return nil, errors.New(errors.CodeUnsupported, "upload", ErrReadOnly)
+	}
+	return creator.Create(ctx, location)
+}
+
+// Writable reports whether raw names a backend that can create objects.
+func Writable(raw string) bool {
+	_, backend, err := resolve(raw)
+	if err != nil {
+		return false
+	}
+	_, ok := backend.(Creator)
+	return ok
+}
+
+// BaseName returns the last element of the key in raw, without a query
+// string, for naming a local copy of the object.
+func BaseName(raw string) string {
+	location, err := Parse(raw)
+	if err != nil {
+		return ""
+	}
+	key, _, _ := strings.Cut(location.Key, "?")
+	key, _, _ = strings.Cut(key, "#")
+	if unescaped, err := url.PathUnescape(key); err == nil {
+		key = unescaped
+	}
+	switch name := path.Base(key); name {
+	case ".", "..", "/":
+		return ""
+	default:
+		return name
+	}
+}
+
+func resolve(raw string) (*Location, Opener, error) {
+	location, err := Parse(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	backend, err := lookup(location.Scheme)
+	if err != nil {
+		return nil, nil, err
+	}
+	return location, backend, nil
+}
+
+func lookup(scheme string) (Opener, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	backend, ok := registry[strings.ToLower(scheme)]
+	if !ok {
+		return nil, errors.Newf(errors.CodeUnsupported, "", "%w %q (known: %s)", ErrUnknownScheme, scheme, strings.Join(schemesLocked(), ", "))
+	}
+	return backend, nil
+}
+
+func schemesLocked() []string {
+	schemes := make([]string, 0, len(registry))
+	for scheme := range registry {
+		schemes = append(schemes, scheme)
+	}
+	slices.Sort(schemes)
+	return schemes
+}
+
+const maxAttempts = 3
+
+// send issues the request made by newRequest. With retry, network errors
+// and 5xx responses are tried again after a pause, up to maxAttempts times.
+// Any other failure is turned into an error carrying the service's message.
+func send(ctx context.Context, client *http.Client, op string, retry bool, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, errors.New(errors.CodeInvalidInput, op, err)
+		}
+
+		resp, err := client.Do(req)
+		if err == nil && resp.StatusCode < 300 {
+			return resp, nil
+		}
+		if retry && attempt < maxAttempts && ctx.Err() == nil && (err != nil || resp.StatusCode >= 500) {
+			if resp != nil {
+				drain(resp)
+			}
+			if err := sleep(ctx, time.Duration(attempt)*time.Second); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, errors.New(errors.CodeIO, op, err)
+		}
+		body, _ := readBody(resp)
+		return nil, statusError(op, resp, xmlErrorMessage(body))
+	}
+}
+
+// xmlErrorMessage extracts the code and message of the XML error document
+// that S3, GCS and Azure all send with a failed response.
+func xmlErrorMessage(body []byte) string {
+	var doc struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(body, &doc) != nil || doc.Code == "" {
+		return ""
+	}
+	if doc.Message == "" {
+		return doc.Code
+	}
+	return doc.Code + ": " + doc.Message
+}
+
+// statusError turns a failed response into an error, using the message
+// from the body when the service sent one.
+func statusError(op string, resp *http.Response, message string) error {
+	code := errors.CodeIO
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		code = errors.CodeNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		code = errors.CodeAuthentication
+	}
+	if message == "" {
+		message = resp.Status
+	} else {
+		message = resp.Status + ": " + message
+	}
+	return errors.Newf(code, op, "%s", message)
+}
+
+func drain(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+}
+
+func readBody(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return body, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
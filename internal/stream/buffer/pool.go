@@ -0,0 +1,46 @@
+package buffer
+
+import "sync"
+
+// Pool recycles chunk buffers between the stages of a pipeline, so a steady
+// stream of chunks stops allocating once the pipeline is full. A nil Pool
+// allocates every buffer.
+type Pool struct {
+	buffers sync.Pool
+	// headers keeps the slice headers that buffers are pooled in, so that
+	// releasing a buffer does not allocate one.
+	headers sync.Pool
+}
+
+func NewPool() *Pool {
+	return &Pool{}
+}
+
+// Get returns a buffer of length size, reusing a released one that is large
+// enough.
+func (p *Pool) Get(size int) []byte {
+	if p != nil {
+		if b, ok := p.buffers.Get().(*[]byte); ok {
+			buf := *b
+			*b = nil
+			p.headers.Put(b)
+			if cap(buf) >= size {
+				return buf[:size]
+			}
+		}
+	}
+	return make([]byte, size)
+}
+
+// Put releases b for reuse. The caller must not touch b afterwards.
+func (p *Pool) Put(b []byte) {
+	if p == nil || cap(b) == 0 {
+		return
+	}
+	header, ok := p.headers.Get().(*[]byte)
+	if !ok {
+		header = new([]byte)
+	}
+	*header = b[:0]
+	p.buffers.Put(header)
+}
@@ -11,6 +11,7 @@ import (
 type SequentialBuffer struct {
 	mu      sync.Mutex
 	buffer  map[uint64]types.TaskResult
+	ready   []types.TaskResult
 	nextIdx uint64
 }
 
@@ -25,13 +26,15 @@ func NewSequentialBuffer(start uint64) (*SequentialBuffer, error) {
 	}, nil
 }
 
+// Add stores result and returns the results that are now in order. The
+// returned slice is reused by the next call.
 func (b *SequentialBuffer) Add(result types.TaskResult) []types.TaskResult {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
 	b.buffer[result.Index] = result
 
-	var ready []types.TaskResult
+	ready := b.ready[:0]
 	for {
 		result, exists := b.buffer[b.nextIdx]
 		if !exists {
@@ -42,6 +45,7 @@ func (b *SequentialBuffer) Add(result types.TaskResult) []types.TaskResult {
 		b.nextIdx++
 	}
 
+	b.ready = ready
 	return ready
 }
 
@@ -5,29 +5,92 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/stream/buffer"
 	"github.com/hambosto/sweetbyte/internal/types"
 	"github.com/hambosto/sweetbyte/internal/utils"
 )
 
-const MinChunkSize = 256 * 1024 // 256 KB
+const MinChunkSize = 64 * 1024 // 64 KB
+
+var (
+	ErrMissingChunks  = errors.Sentinel("file is truncated: chunks are missing")
+	ErrOversizedChunk = errors.Sentinel("chunk is larger than the chunk size allows")
+)
 
 type ChunkReader struct {
-	processing types.Processing
-	chunkSize  int
+	processing    types.Processing
+	chunkSize     int
+	prefetchDepth int
+	window        *Window
+	buffers       *buffer.Pool
+	baseOffset    int64
+	trailer       bool
+	chunkCount    uint64
+	maxChunk      int
+	padding       int64
+	indexKey      []byte
+	digest        []byte
+	digestOffset  int64
 }
 
-func NewChunkReader(processing types.Processing, chunkSize int) (*ChunkReader, error) {
+func NewChunkReader(processing types.Processing, chunkSize, prefetchDepth int, window *Window) (*ChunkReader, error) {
 	if chunkSize < MinChunkSize {
-		return nil, fmt.Errorf("chunk size must be at least %d bytes (256 KB), got %d", MinChunkSize, chunkSize)
+		return nil, fmt.Errorf("chunk size must be at least %d bytes (64 KB), got %d", MinChunkSize, chunkSize)
+	}
+	if prefetchDepth < 0 {
+		return nil, fmt.Errorf("prefetch depth cannot be negative, got %d", prefetchDepth)
 	}
 	return &ChunkReader{
-		processing: processing,
-		chunkSize:  chunkSize,
+		processing:    processing,
+		chunkSize:     chunkSize,
+		prefetchDepth: prefetchDepth,
+		window:        window,
 	}, nil
 }
 
+func (r *ChunkReader) SetBaseOffset(offset int64) {
+	r.baseOffset = offset
+}
+
+// SetBufferPool makes the reader take the buffers of its tasks from pool.
+// Whoever consumes a task releases its data there.
+func (r *ChunkReader) SetBufferPool(pool *buffer.Pool) {
+	r.buffers = pool
+}
+
+func (r *ChunkReader) SetTrailer(enabled bool) {
+	r.trailer = enabled
+}
+
+// SetChunkCount makes decryption fail when the input ends before count
+// chunks, for files whose chunks are bound to the count.
+func (r *ChunkReader) SetChunkCount(count uint64) {
+	r.chunkCount = count
+}
+
+// SetMaxChunk makes decryption reject a size prefix above size before
+// allocating for it.
+func (r *ChunkReader) SetMaxChunk(size int) {
+	r.maxChunk = size
+}
+
+func (r *ChunkReader) SetPadding(size int64) {
+	r.padding = size
+}
+
+// SetIndex makes decryption expect a chunk index authenticated under key
+// between the trailer and the padding.
+func (r *ChunkReader) SetIndex(key []byte) {
+	r.indexKey = key
+}
+
+func (r *ChunkReader) Trailer() ([]byte, int64) {
+	return r.digest, r.digestOffset
+}
+
 func (r *ChunkReader) Read(ctx context.Context, input io.Reader) (<-chan types.Task, <-chan error) {
-	tasks := make(chan types.Task)
+	tasks := make(chan types.Task, r.prefetchDepth)
 	errCh := make(chan error, 1)
 
 	go func() {
@@ -56,80 +119,132 @@ func (r *ChunkReader) Read(ctx context.Context, input io.Reader) (<-chan types.T
 }
 
 func (r *ChunkReader) readForEncryption(ctx context.Context, reader io.Reader, tasks chan<- types.Task) error {
-	buffer := make([]byte, r.chunkSize)
 	var index uint64
+	offset := r.baseOffset
 
 	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+		if err := r.window.Acquire(ctx); err != nil {
+			return err
 		}
 
-		n, err := reader.Read(buffer)
+		data := r.buffers.Get(r.chunkSize)
+		n, err := io.ReadFull(reader, data)
+		if n == 0 {
+			r.buffers.Put(data)
+			r.window.Release()
+		}
 		if n > 0 {
 			task := types.Task{
-				Data:  make([]byte, n),
-				Index: index,
+				Data:   data[:n],
+				Index:  index,
+				Offset: offset,
 			}
-			copy(task.Data, buffer[:n])
 
 			select {
 			case tasks <- task:
 				index++
+				offset += int64(n)
 			case <-ctx.Done():
 				return ctx.Err()
 			}
 		}
 
-		if err == io.EOF {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
 			return nil
 		}
 		if err != nil {
-			return fmt.Errorf("failed to read input: %w", err)
+			return errors.New(errors.CodeIO, "failed to read input", err).WithChunk(index).WithOffset(offset)
 		}
 	}
 }
 
 func (r *ChunkReader) readForDecryption(ctx context.Context, reader io.Reader, tasks chan<- types.Task) error {
 	var index uint64
+	offset := r.baseOffset
 
 	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+		if err := r.window.Acquire(ctx); err != nil {
+			return err
 		}
 
 		var sizeBuffer [4]byte
 		_, err := io.ReadFull(reader, sizeBuffer[:])
 		if err == io.EOF {
+			r.window.Release()
+			if r.trailer {
+				return errors.New(errors.CodeCorrupt, "", ErrTruncated).WithChunk(index).WithOffset(offset)
+			}
+			if index < r.chunkCount {
+				return errors.New(errors.CodeCorrupt, "", ErrMissingChunks).WithChunk(index).WithOffset(offset)
+			}
 			return nil
 		}
 		if err != nil {
-			return fmt.Errorf("failed to read chunk size: %w", err)
+			return errors.New(readErrorCode(err), "failed to read chunk size", err).WithChunk(index).WithOffset(offset)
 		}
 
 		chunkLen := utils.FromBytes[uint32](sizeBuffer[:])
+		if r.trailer && chunkLen == TrailerMarker {
+			r.window.Release()
+			return r.readTrailer(reader, index, offset)
+		}
 		if chunkLen == 0 {
+			r.window.Release()
+			if r.trailer {
+				return errors.New(errors.CodeAuthentication, "", ErrTrailerMismatch).WithChunk(index).WithOffset(offset)
+			}
+			offset += int64(len(sizeBuffer))
 			continue
 		}
 
-		data := make([]byte, chunkLen)
+		if r.maxChunk > 0 && int64(chunkLen) > int64(r.maxChunk) {
+			r.window.Release()
+			return errors.New(errors.CodeCorrupt, fmt.Sprintf("chunk size %d, at most %d", chunkLen, r.maxChunk), ErrOversizedChunk).WithChunk(index).WithOffset(offset)
+		}
+
+		data := r.buffers.Get(int(chunkLen))
 		if _, err := io.ReadFull(reader, data); err != nil {
-			return fmt.Errorf("failed to read chunk data (length: %d): %w", chunkLen, err)
+			return errors.New(readErrorCode(err), fmt.Sprintf("failed to read chunk data (length: %d)", chunkLen), err).WithChunk(index).WithOffset(offset)
 		}
 
 		task := types.Task{
-			Data:  data,
-			Index: index,
+			Data:   data,
+			Index:  index,
+			Offset: offset,
 		}
 
 		select {
 		case tasks <- task:
 			index++
+			offset += int64(len(sizeBuffer)) + int64(chunkLen)
 		case <-ctx.Done():
 			return ctx.Err()
 		}
 	}
 }
+
+func (r *ChunkReader) readTrailer(reader io.Reader, chunks uint64, offset int64) error {
+	var indexSize int64
+	if r.indexKey != nil {
+		indexSize = IndexSize(chunks)
+	}
+	digest, index, err := ReadIndexedTrailer(reader, indexSize, io.Discard, r.padding)
+	if err != nil {
+		return errors.New(errors.CodeUnknown, "", err).WithOffset(offset)
+	}
+	if r.indexKey != nil {
+		if err := CheckIndex(index, r.indexKey); err != nil {
+			return errors.New(errors.CodeUnknown, "", err).WithOffset(offset + TrailerSize)
+		}
+	}
+	r.digest = digest
+	r.digestOffset = offset
+	return nil
+}
+
+func readErrorCode(err error) errors.Code {
+	if err == io.ErrUnexpectedEOF {
+		return errors.CodeCorrupt
+	}
+	return errors.CodeIO
+}
@@ -1,77 +1,288 @@
 package chunk
 
 import (
+	"bufio"
+	"cmp"
 	"context"
+	"crypto/hmac"
+	"encoding/binary"
 	"fmt"
+	"hash"
 	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
 
+	"github.com/ccoveille/go-safecast/v2"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/reporter"
 	"github.com/hambosto/sweetbyte/internal/stream/buffer"
 	"github.com/hambosto/sweetbyte/internal/types"
-	"github.com/hambosto/sweetbyte/internal/ui/bar"
-	"github.com/hambosto/sweetbyte/internal/utils"
+	"golang.org/x/sync/errgroup"
 )
 
+// CoalesceSize is the buffer that sequential writes of chunks smaller than it
+// are gathered in, so each write call carries several chunks.
+const CoalesceSize = 1024 * 1024 // 1 MB
+
 type ChunkWriter struct {
 	mode             types.Processing
-	progressBar      *bar.ProgressBar
+	progress         reporter.Progress
 	sequentialBuffer *buffer.SequentialBuffer
+	window           *Window
+	buffers          *buffer.Pool
+	sources          *buffer.Pool
+	written          atomic.Int64
+	trailer          hash.Hash
+	coverage         Coverage
+	indexKey         []byte
+	offsets          []uint64
+	position         uint64
+	sizePrefix       [4]byte
+	coalesce         int
+	mu               sync.Mutex
+	repairs          []types.ChunkRepair
 }
 
-func NewChunkWriter(mode types.Processing, progressBar *bar.ProgressBar) (*ChunkWriter, error) {
+func NewChunkWriter(mode types.Processing, progress reporter.Progress, window *Window) (*ChunkWriter, error) {
+	if progress == nil {
+		progress = reporter.Nop().Progress(0, "")
+	}
+
 	seqBuf, err := buffer.NewSequentialBuffer(0)
 	if err != nil {
 		return nil, fmt.Errorf("creating sequential buffer: %w", err)
 	}
 	return &ChunkWriter{
 		mode:             mode,
-		progressBar:      progressBar,
+		progress:         progress,
 		sequentialBuffer: seqBuf,
+		window:           window,
 	}, nil
 }
 
-func (w *ChunkWriter) Write(ctx context.Context, output io.Writer, results <-chan types.TaskResult) error {
+// SetBufferPools makes the writer release the data of each result to
+// buffers once written, and its source chunk to sources once authenticated.
+func (w *ChunkWriter) SetBufferPools(buffers, sources *buffer.Pool) {
+	w.buffers = buffers
+	w.sources = sources
+}
+
+// SetTrailer makes the writer authenticate the part of each chunk that
+// coverage picks with mac.
+func (w *ChunkWriter) SetTrailer(mac hash.Hash, coverage Coverage) {
+	w.trailer = mac
+	w.coverage = coverage
+}
+
+// SetIndex makes encryption follow the trailer with a chunk index
+// authenticated under key.
+func (w *ChunkWriter) SetIndex(key []byte) {
+	w.indexKey = key
+}
+
+// SetCoalescing makes sequential writes gather in a buffer of size bytes,
+// flushed when it fills, whenever the writer would wait for the next chunk,
+// and at the end. Zero writes every chunk straight through.
+func (w *ChunkWriter) SetCoalescing(size int) {
+	w.coalesce = size
+}
+
+func (w *ChunkWriter) Written() int64 {
+	return w.written.Load()
+}
+
+func (w *ChunkWriter) Repairs() []types.ChunkRepair {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	repairs := slices.Clone(w.repairs)
+	slices.SortFunc(repairs, func(a, b types.ChunkRepair) int { return cmp.Compare(a.Index, b.Index) })
+	return repairs
+}
+
+func (w *ChunkWriter) CheckTrailer(digest []byte, offset int64) error {
+	if w.trailer == nil || w.mode != types.Decryption {
+		return nil
+	}
+	if !hmac.Equal(digest, w.trailer.Sum(nil)) {
+		return errors.New(errors.CodeAuthentication, "", ErrTrailerMismatch).WithOffset(offset)
+	}
+	return nil
+}
+
+func (w *ChunkWriter) Write(ctx context.Context, output io.Writer, results <-chan types.TaskResult) (err error) {
+	var coalesced *bufio.Writer
+	if w.coalesce > 0 {
+		coalesced = bufio.NewWriterSize(output, w.coalesce)
+		output = coalesced
+		// Chunks already counted as written reach the output even when a
+		// later one fails.
+		defer func() {
+			if flushErr := flush(coalesced); err == nil {
+				err = flushErr
+			}
+		}()
+	}
+
 	for {
+		var result types.TaskResult
+		var ok bool
 		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case result, ok := <-results:
-			if !ok {
-				return w.writeOrdered(output, w.sequentialBuffer.Flush())
+		case result, ok = <-results:
+		default:
+			if err := flush(coalesced); err != nil {
+				return err
 			}
-
-			if result.Err != nil {
-				return fmt.Errorf("task %d failed: %w", result.Index, result.Err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case result, ok = <-results:
 			}
+		}
+		if !ok {
+			return w.finish(output)
+		}
 
-			ready := w.sequentialBuffer.Add(result)
-			if err := w.writeOrdered(output, ready); err != nil {
-				return err
+		if result.Err != nil {
+			return result.Err
+		}
+
+		ready := w.sequentialBuffer.Add(result)
+		if err := w.writeOrdered(output, ready); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *ChunkWriter) finish(output io.Writer) error {
+	if err := w.writeOrdered(output, w.sequentialBuffer.Flush()); err != nil {
+		return err
+	}
+	if w.trailer != nil && w.mode == types.Encryption {
+		if err := writeTrailer(output, w.trailer); err != nil {
+			return err
+		}
+		if w.indexKey != nil {
+			return writeIndex(output, w.indexKey, w.offsets)
+		}
+	}
+	return nil
+}
+
+func flush(coalesced *bufio.Writer) error {
+	if coalesced == nil || coalesced.Buffered() == 0 {
+		return nil
+	}
+	if err := coalesced.Flush(); err != nil {
+		return errors.New(errors.CodeIO, "writing chunk data", err)
+	}
+	return nil
+}
+
+func (w *ChunkWriter) WriteAt(ctx context.Context, output io.WriterAt, chunkSize, concurrency int, results <-chan types.TaskResult) error {
+	if w.mode != types.Decryption {
+		return fmt.Errorf("positional writes are only supported for decryption")
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	for range concurrency {
+		g.Go(func() error {
+			for {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case result, ok := <-results:
+					if !ok {
+						return nil
+					}
+					if err := w.writeAt(output, chunkSize, result); err != nil {
+						return err
+					}
+				}
 			}
+		})
+	}
+
+	return g.Wait()
+}
+
+func (w *ChunkWriter) writeAt(output io.WriterAt, chunkSize int, result types.TaskResult) error {
+	if result.Err != nil {
+		return result.Err
+	}
+	if len(result.Data) > chunkSize {
+		return errors.Newf(errors.CodeCorrupt, "writing chunk data", "chunk exceeds recorded chunk size (%d > %d)", len(result.Data), chunkSize).WithChunk(result.Index)
+	}
+
+	offset, err := safecast.Convert[int64](result.Index * uint64(chunkSize))
+	if err != nil {
+		return errors.New(errors.CodeCorrupt, "computing chunk offset", err).WithChunk(result.Index)
+	}
+
+	if _, err := output.WriteAt(result.Data, offset); err != nil {
+		return errors.New(errors.CodeIO, "writing chunk data", err).WithChunk(result.Index)
+	}
+	if w.trailer != nil {
+		w.mu.Lock()
+		for _, ready := range w.sequentialBuffer.Add(types.TaskResult{Index: result.Index, Source: result.Source}) {
+			authenticate(w.trailer, w.coverage, ready.Source, w.sizePrefix[:])
+			w.sources.Put(ready.Source)
 		}
+		w.mu.Unlock()
+	} else {
+		w.sources.Put(result.Source)
 	}
+	w.recordRepair(result)
+	w.written.Add(int64(len(result.Data)))
+	w.buffers.Put(result.Data)
+	w.window.Release()
+	if err := w.progress.Add(int64(result.Size)); err != nil {
+		return fmt.Errorf("updating progress: %w", err)
+	}
+	return nil
 }
 
 func (w *ChunkWriter) writeOrdered(output io.Writer, results []types.TaskResult) error {
 	switch w.mode {
 	case types.Encryption:
 		for _, res := range results {
-			sizePrefix := utils.ToBytes[uint32](len(res.Data))
+			sizePrefix := w.prefix(len(res.Data))
 			if _, err := output.Write(sizePrefix); err != nil {
-				return fmt.Errorf("writing chunk size prefix: %w", err)
+				return errors.New(errors.CodeIO, "writing chunk size prefix", err).WithChunk(res.Index)
 			}
 			if _, err := output.Write(res.Data); err != nil {
-				return fmt.Errorf("writing chunk data: %w", err)
+				return errors.New(errors.CodeIO, "writing chunk data", err).WithChunk(res.Index)
 			}
-			if err := w.progressBar.Add(int64(res.Size)); err != nil {
+			if w.trailer != nil {
+				authenticate(w.trailer, w.coverage, res.Data, w.sizePrefix[:])
+			}
+			if w.indexKey != nil {
+				w.offsets = append(w.offsets, w.position)
+				w.position += uint64(len(sizePrefix) + len(res.Data))
+			}
+			w.buffers.Put(res.Data)
+			w.written.Add(int64(res.Size))
+			w.window.Release()
+			if err := w.progress.Add(int64(res.Size)); err != nil {
 				return fmt.Errorf("updating progress: %w", err)
 			}
 		}
 	case types.Decryption:
 		for _, res := range results {
 			if _, err := output.Write(res.Data); err != nil {
-				return fmt.Errorf("writing chunk data: %w", err)
+				return errors.New(errors.CodeIO, "writing chunk data", err).WithChunk(res.Index)
+			}
+			if w.trailer != nil {
+				authenticate(w.trailer, w.coverage, res.Source, w.sizePrefix[:])
 			}
-			if err := w.progressBar.Add(int64(res.Size)); err != nil {
+			w.sources.Put(res.Source)
+			w.buffers.Put(res.Data)
+			w.recordRepair(res)
+			w.written.Add(int64(res.Size))
+			w.window.Release()
+			if err := w.progress.Add(int64(res.Size)); err != nil {
 				return fmt.Errorf("updating progress: %w", err)
 			}
 		}
@@ -81,3 +292,20 @@ func (w *ChunkWriter) writeOrdered(output io.Writer, results []types.TaskResult)
 
 	return nil
 }
+
+// prefix encodes the length prefix of a chunk of n bytes into a buffer that
+// the next call overwrites.
+func (w *ChunkWriter) prefix(n int) []byte {
+	binary.BigEndian.PutUint32(w.sizePrefix[:], safecast.MustConvert[uint32](n))
+	return w.sizePrefix[:]
+}
+
+func (w *ChunkWriter) recordRepair(result types.TaskResult) {
+	if result.Repair == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.repairs = append(w.repairs, *result.Repair)
+}
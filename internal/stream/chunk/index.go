@@ -0,0 +1,213 @@
+package chunk
+
+import (
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"slices"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/utils"
+)
+
+const (
+	// indexFooterSize is the chunk count and MAC that end a chunk index.
+	indexFooterSize = 8 + sha256.Size
+
+	indexInfo = "sweetbyte chunk index"
+)
+
+var ErrIndexMismatch = errors.Sentinel("chunk index does not match: it was altered or belongs to another file")
+
+// Index locates every chunk of an encrypted stream, so any chunk can be
+// read and decrypted without the ones before it.
+type Index struct {
+	// Offsets holds the position of each chunk's data, after its length
+	// prefix.
+	Offsets []int64
+	Lengths []uint32
+}
+
+func (x *Index) Len() int {
+	return len(x.Offsets)
+}
+
+func IndexKey(dataKey []byte) ([]byte, error) {
+	key, err := hkdf.Key(sha256.New, dataKey, nil, indexInfo, sha256.Size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive chunk index key: %w", err)
+	}
+	return key, nil
+}
+
+// IndexSize is the length of the chunk index written after the trailer of
+// a stream of chunks chunks.
+func IndexSize(chunks uint64) int64 {
+	return int64(chunks)*8 + indexFooterSize
+}
+
+// writeIndex writes the offset of each chunk's length prefix, relative to
+// the first chunk, then the chunk count and an HMAC over both.
+func writeIndex(output io.Writer, key []byte, offsets []uint64) error {
+	index := make([]byte, 0, IndexSize(uint64(len(offsets))))
+	for _, offset := range offsets {
+		index = binary.BigEndian.AppendUint64(index, offset)
+	}
+	index = binary.BigEndian.AppendUint64(index, uint64(len(offsets)))
+	mac := hmac.New(sha256.New, key)
+	mac.Write(index)
+	index = mac.Sum(index)
+
+	if _, err := output.Write(index); err != nil {
+		return errors.New(errors.CodeIO, "writing chunk index", err)
+	}
+	return nil
+}
+
+// CheckIndex verifies the MAC of a chunk index as read after the trailer.
+func CheckIndex(index, key []byte) error {
+	if len(index) < indexFooterSize {
+		return errors.New(errors.CodeCorrupt, "", ErrIndexMismatch)
+	}
+	body, digest := index[:len(index)-sha256.Size], index[len(index)-sha256.Size:]
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	if !hmac.Equal(digest, mac.Sum(nil)) {
+		return errors.New(errors.CodeAuthentication, "", ErrIndexMismatch)
+	}
+	return nil
+}
+
+// LoadIndex reads the chunk index that ends at end, the end of the file
+// less its padding, for chunks that begin at start. It checks the index
+// MAC, so the chunk count cannot be changed, but not the trailer digest.
+func LoadIndex(r io.ReaderAt, start, end int64, key []byte) (*Index, error) {
+	var footer [indexFooterSize]byte
+	if end-start < TrailerSize+indexFooterSize {
+		return nil, errors.New(errors.CodeCorrupt, "", ErrTruncated)
+	}
+	if _, err := r.ReadAt(footer[:], end-indexFooterSize); err != nil {
+		return nil, errors.New(readAtErrorCode(err), "reading chunk index", err).WithOffset(end - indexFooterSize)
+	}
+
+	count := binary.BigEndian.Uint64(footer[:8])
+	if count > uint64(end-start)/8 {
+		return nil, errors.New(errors.CodeCorrupt, "", ErrIndexMismatch).WithOffset(end - indexFooterSize)
+	}
+	indexStart := end - IndexSize(count)
+	trailerStart := indexStart - TrailerSize
+	if trailerStart < start {
+		return nil, errors.New(errors.CodeCorrupt, "", ErrIndexMismatch).WithOffset(end - indexFooterSize)
+	}
+
+	raw := make([]byte, IndexSize(count))
+	if _, err := r.ReadAt(raw, indexStart); err != nil {
+		return nil, errors.New(readAtErrorCode(err), "reading chunk index", err).WithOffset(indexStart)
+	}
+	if err := CheckIndex(raw, key); err != nil {
+		return nil, errors.New(errors.CodeUnknown, "", err).WithOffset(indexStart)
+	}
+
+	var marker [4]byte
+	if _, err := r.ReadAt(marker[:], trailerStart); err != nil {
+		return nil, errors.New(readAtErrorCode(err), "reading trailer", err).WithOffset(trailerStart)
+	}
+	if utils.FromBytes[uint32](marker[:]) != TrailerMarker {
+		return nil, errors.New(errors.CodeCorrupt, "", ErrIndexMismatch).WithOffset(trailerStart)
+	}
+
+	index := &Index{Offsets: make([]int64, count), Lengths: make([]uint32, count)}
+	next := trailerStart
+	for i := int64(count) - 1; i >= 0; i-- {
+		prefix := start + int64(binary.BigEndian.Uint64(raw[i*8:]))
+		length := next - prefix - 4
+		if prefix < start || length <= 0 || length > math.MaxUint32 || (i == 0 && prefix != start) {
+			return nil, errors.New(errors.CodeCorrupt, "", ErrIndexMismatch).WithChunk(uint64(i))
+		}
+		index.Offsets[i] = prefix + 4
+		index.Lengths[i] = uint32(length)
+		next = prefix
+	}
+	return index, nil
+}
+
+// ScanIndex walks the length prefixes of the chunks in r from start up to
+// the trailer, seeking over the chunk data. With mac set, the data is read
+// and checked against the trailer, covering what coverage picks, instead,
+// which catches chunks that were removed from the end of a stream whose
+// chunk count is not recorded. Prefixes above maxChunk are rejected.
+func ScanIndex(r io.ReaderAt, start int64, maxChunk int, mac hash.Hash, coverage Coverage) (*Index, error) {
+	src := io.NewSectionReader(r, 0, math.MaxInt64)
+	if _, err := src.Seek(start, io.SeekStart); err != nil {
+		return nil, errors.New(errors.CodeIO, "seeking to the first chunk", err)
+	}
+
+	index := &Index{}
+	offset := start
+	var data []byte
+	for {
+		var sizeBuffer [4]byte
+		if _, err := io.ReadFull(src, sizeBuffer[:]); err != nil {
+			if err == io.EOF {
+				return nil, errors.New(errors.CodeCorrupt, "", ErrTruncated).WithChunk(uint64(index.Len())).WithOffset(offset)
+			}
+			return nil, errors.New(readErrorCode(err), "failed to read chunk size", err).WithChunk(uint64(index.Len())).WithOffset(offset)
+		}
+
+		chunkLen := utils.FromBytes[uint32](sizeBuffer[:])
+		if chunkLen == TrailerMarker {
+			return index, checkIndexTrailer(src, mac, offset)
+		}
+		if chunkLen == 0 {
+			return nil, errors.Newf(errors.CodeCorrupt, "", "empty chunk").WithChunk(uint64(index.Len())).WithOffset(offset)
+		}
+		if int64(chunkLen) > int64(maxChunk) {
+			return nil, errors.New(errors.CodeCorrupt, fmt.Sprintf("chunk size %d, at most %d", chunkLen, maxChunk), ErrOversizedChunk).WithChunk(uint64(index.Len())).WithOffset(offset)
+		}
+
+		index.Offsets = append(index.Offsets, offset+int64(len(sizeBuffer)))
+		index.Lengths = append(index.Lengths, chunkLen)
+
+		if mac != nil {
+			data = slices.Grow(data[:0], int(chunkLen))[:chunkLen]
+			if _, err := io.ReadFull(src, data); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return nil, errors.New(readErrorCode(err), "failed to read chunk data", err).WithChunk(uint64(index.Len() - 1)).WithOffset(offset)
+			}
+			authenticate(mac, coverage, data, sizeBuffer[:])
+		} else if _, err := src.Seek(int64(chunkLen), io.SeekCurrent); err != nil {
+			return nil, errors.New(errors.CodeIO, "seeking over chunk data", err).WithChunk(uint64(index.Len() - 1)).WithOffset(offset)
+		}
+		offset += int64(len(sizeBuffer)) + int64(chunkLen)
+	}
+}
+
+func checkIndexTrailer(r io.Reader, mac hash.Hash, offset int64) error {
+	if mac == nil {
+		return nil
+	}
+	digest := make([]byte, mac.Size())
+	if _, err := io.ReadFull(r, digest); err != nil {
+		return errors.New(readErrorCode(err), "reading trailer", err).WithOffset(offset)
+	}
+	if !hmac.Equal(digest, mac.Sum(nil)) {
+		return errors.New(errors.CodeAuthentication, "", ErrTrailerMismatch).WithOffset(offset)
+	}
+	return nil
+}
+
+// readAtErrorCode maps a short ReadAt, which reports io.EOF, to a corrupt
+// file rather than an I/O failure.
+func readAtErrorCode(err error) errors.Code {
+	if err == io.EOF {
+		return errors.CodeCorrupt
+	}
+	return readErrorCode(err)
+}
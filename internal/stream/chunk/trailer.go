@@ -0,0 +1,107 @@
+package chunk
+
+import (
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/utils"
+)
+
+const (
+	TrailerMarker uint32 = 0xFFFFFFFF
+	TrailerSize          = 4 + sha256.Size
+
+	trailerInfo = "sweetbyte trailer"
+)
+
+var (
+	ErrTruncated       = errors.Sentinel("file is truncated: the trailer is missing")
+	ErrTrailerMismatch = errors.Sentinel("trailer does not match: chunks are missing, reordered or altered")
+)
+
+func TrailerKey(dataKey []byte) ([]byte, error) {
+	key, err := hkdf.Key(sha256.New, dataKey, nil, trailerInfo, sha256.Size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive trailer key: %w", err)
+	}
+	return key, nil
+}
+
+func NewTrailer(key []byte) hash.Hash {
+	return hmac.New(sha256.New, key)
+}
+
+// Coverage picks the part of a stored chunk that the trailer covers, such
+// as the sealed ciphertext without its parity. Nil covers all of it.
+type Coverage func(stored []byte) []byte
+
+// authenticate adds a stored chunk to the trailer mac: the length of the
+// part that coverage picks, encoded into prefix, then that part.
+func authenticate(mac hash.Hash, coverage Coverage, stored, prefix []byte) {
+	if coverage != nil {
+		stored = coverage(stored)
+	}
+	binary.BigEndian.PutUint32(prefix, uint32(len(stored)))
+	mac.Write(prefix[:4])
+	mac.Write(stored)
+}
+
+func writeTrailer(output io.Writer, mac hash.Hash) error {
+	trailer := append(utils.ToBytes[uint32](TrailerMarker), mac.Sum(nil)...)
+	if _, err := output.Write(trailer); err != nil {
+		return errors.New(errors.CodeIO, "writing trailer", err)
+	}
+	return nil
+}
+
+func ReadTrailer(input io.Reader) ([]byte, error) {
+	return ReadPaddedTrailer(input, io.Discard, 0)
+}
+
+func ReadPaddedTrailer(input io.Reader, padding io.Writer, size int64) ([]byte, error) {
+	digest, _, err := ReadIndexedTrailer(input, 0, padding, size)
+	return digest, err
+}
+
+// ReadIndexedTrailer reads the digest that follows the trailer marker, then
+// indexSize bytes of chunk index (see IndexSize), then size bytes of
+// padding, which it copies to padding. The index is checked to list as
+// many chunks as indexSize implies, but its MAC is left to CheckIndex.
+func ReadIndexedTrailer(input io.Reader, indexSize int64, padding io.Writer, size int64) (digest, index []byte, err error) {
+	digest = make([]byte, sha256.Size)
+	if _, err := io.ReadFull(input, digest); err != nil {
+		return nil, nil, errors.New(readErrorCode(err), "reading trailer", err)
+	}
+
+	if indexSize > 0 {
+		index = make([]byte, indexSize)
+		if _, err := io.ReadFull(input, index); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, nil, errors.New(readErrorCode(err), "reading chunk index", err)
+		}
+		if count := utils.FromBytes[uint64](index[len(index)-indexFooterSize:]); IndexSize(count) != indexSize {
+			return nil, nil, errors.New(errors.CodeCorrupt, "", ErrIndexMismatch)
+		}
+	}
+
+	if n, err := io.CopyN(padding, input, size); n < size {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, nil, errors.New(readErrorCode(err), "reading padding", err)
+	}
+
+	var extra [1]byte
+	if n, _ := input.Read(extra[:]); n > 0 {
+		return nil, nil, errors.Newf(errors.CodeCorrupt, "reading trailer", "unexpected data after the trailer")
+	}
+	return digest, index, nil
+}
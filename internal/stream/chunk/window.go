@@ -0,0 +1,41 @@
+package chunk
+
+import "context"
+
+type Window struct {
+	slots chan struct{}
+}
+
+func NewWindow(size int) *Window {
+	if size <= 0 {
+		return nil
+	}
+	return &Window{slots: make(chan struct{}, size)}
+}
+
+func (w *Window) Size() int {
+	if w == nil {
+		return 0
+	}
+	return cap(w.slots)
+}
+
+func (w *Window) Acquire(ctx context.Context) error {
+	if w == nil {
+		return nil
+	}
+
+	select {
+	case w.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *Window) Release() {
+	if w == nil {
+		return
+	}
+	<-w.slots
+}
@@ -11,6 +11,7 @@ import (
 type ConcurrentExecutor struct {
 	dataProcessing *processing.DataProcessing
 	concurrency    int
+	pool           *Pool
 }
 
 func NewConcurrentExecutor(dataProcessing *processing.DataProcessing, concurrency int) *ConcurrentExecutor {
@@ -20,6 +21,10 @@ func NewConcurrentExecutor(dataProcessing *processing.DataProcessing, concurrenc
 	}
 }
 
+func (e *ConcurrentExecutor) SetPool(pool *Pool) {
+	e.pool = pool
+}
+
 func (e *ConcurrentExecutor) Process(ctx context.Context, tasks <-chan types.Task, mode types.Processing) <-chan types.TaskResult {
 	results := make(chan types.TaskResult, e.concurrency)
 
@@ -40,6 +45,7 @@ func (e *ConcurrentExecutor) Process(ctx context.Context, tasks <-chan types.Tas
 func (e *ConcurrentExecutor) worker(ctx context.Context, wg *sync.WaitGroup, tasks <-chan types.Task, results chan<- types.TaskResult) {
 	defer wg.Done()
 
+	buffers := processing.NewBuffers()
 	for {
 		select {
 		case <-ctx.Done():
@@ -48,7 +54,10 @@ func (e *ConcurrentExecutor) worker(ctx context.Context, wg *sync.WaitGroup, tas
 			if !ok {
 				return
 			}
-			result := e.dataProcessing.Process(ctx, task)
+			result, ok := e.process(ctx, task, buffers)
+			if !ok {
+				return
+			}
 			select {
 			case results <- result:
 			case <-ctx.Done():
@@ -57,3 +66,14 @@ func (e *ConcurrentExecutor) worker(ctx context.Context, wg *sync.WaitGroup, tas
 		}
 	}
 }
+
+func (e *ConcurrentExecutor) process(ctx context.Context, task types.Task, buffers *processing.Buffers) (types.TaskResult, bool) {
+	if e.pool == nil {
+		return e.dataProcessing.Process(ctx, task, buffers), true
+	}
+	if err := e.pool.Acquire(ctx); err != nil {
+		return types.TaskResult{}, false
+	}
+	defer e.pool.Release()
+	return e.dataProcessing.Process(ctx, task, buffers), true
+}
@@ -0,0 +1,34 @@
+package concurrent
+
+import (
+	"context"
+	"fmt"
+)
+
+type Pool struct {
+	slots chan struct{}
+}
+
+func NewPool(workers int) (*Pool, error) {
+	if workers < 1 {
+		return nil, fmt.Errorf("worker budget must be at least 1, got %d", workers)
+	}
+	return &Pool{slots: make(chan struct{}, workers)}, nil
+}
+
+func (p *Pool) Size() int {
+	return cap(p.slots)
+}
+
+func (p *Pool) Acquire(ctx context.Context) error {
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) Release() {
+	<-p.slots
+}
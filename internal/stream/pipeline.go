@@ -6,24 +6,82 @@ import (
 	"io"
 	"runtime"
 
+	"github.com/hambosto/sweetbyte/internal/cipher"
+	"github.com/hambosto/sweetbyte/internal/compression"
 	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/reporter"
+	"github.com/hambosto/sweetbyte/internal/stream/buffer"
 	"github.com/hambosto/sweetbyte/internal/stream/chunk"
 	"github.com/hambosto/sweetbyte/internal/stream/concurrent"
 	"github.com/hambosto/sweetbyte/internal/stream/processing"
+	"github.com/hambosto/sweetbyte/internal/stream/throttle"
 	"github.com/hambosto/sweetbyte/internal/types"
-	"github.com/hambosto/sweetbyte/internal/ui/bar"
+	"github.com/hambosto/sweetbyte/internal/utils"
 	"golang.org/x/sync/errgroup"
 )
 
-const DefaultChunkSize = 256 * 1024
+const (
+	DefaultChunkSize     = 256 * 1024
+	MinChunkSize         = chunk.MinChunkSize
+	MaxChunkSize         = 64 * 1024 * 1024
+	DefaultPrefetchDepth = 4
+	StageCopies          = 6
+	MaxAutoChunkSize     = 8 * 1024 * 1024
+
+	autoChunkTarget = 256
+)
+
+func AutoChunkSize(size int64) int {
+	if size <= 0 {
+		return DefaultChunkSize
+	}
+
+	chunkSize := chunk.MinChunkSize
+	for chunkSize < MaxAutoChunkSize && int64(chunkSize)*autoChunkTarget < size {
+		chunkSize *= 2
+	}
+	return chunkSize
+}
 
 type Pipeline struct {
-	key            []byte
 	chunkSize      int
+	positional     bool
 	concurrency    int
+	prefetchDepth  int
+	maxOutstanding int
+	description    string
+	reporter       reporter.Reporter
+	baseOffset     int64
+	trailerKey     []byte
+	indexKey       []byte
+	padding        int64
+	chunkCount     uint64
 	dataProcessing *processing.DataProcessing
+	buffers        *buffer.Pool
+	sources        *buffer.Pool
 	executor       *concurrent.ConcurrentExecutor
+	workerPool     *concurrent.Pool
+	throttle       *throttle.Throttle
 	processing     types.Processing
+	repairs        []types.ChunkRepair
+}
+
+type WorkerPool = concurrent.Pool
+
+// Throttle caps the bytes per second that the pipelines sharing it read
+// and, separately, write.
+type Throttle = throttle.Throttle
+
+func NewThrottle(rate int64) (*Throttle, error) {
+	return throttle.New(rate)
+}
+
+func NewWorkerPool(workers int) (*WorkerPool, error) {
+	if workers == 0 {
+		workers = runtime.NumCPU()
+	}
+	return concurrent.NewPool(workers)
 }
 
 func NewPipeline(key []byte, processMode types.Processing) (*Pipeline, error) {
@@ -36,37 +94,321 @@ func NewPipeline(key []byte, processMode types.Processing) (*Pipeline, error) {
 		return nil, fmt.Errorf("data processing creation: %w", err)
 	}
 
+	// sources holds the chunks the reader produces and buffers the
+	// processed ones, which differ in size by the parity.
+	buffers, sources := buffer.NewPool(), buffer.NewPool()
+	dataProcessing.SetBufferPools(buffers, sources)
+
 	concurrency := runtime.NumCPU()
 	executor := concurrent.NewConcurrentExecutor(dataProcessing, concurrency)
 
 	return &Pipeline{
-		key:            key,
 		chunkSize:      DefaultChunkSize,
 		concurrency:    concurrency,
+		prefetchDepth:  min(DefaultPrefetchDepth, concurrency),
 		dataProcessing: dataProcessing,
+		buffers:        buffers,
+		sources:        sources,
 		executor:       executor,
 		processing:     processMode,
+		reporter:       reporter.Nop(),
 	}, nil
 }
 
+func (p *Pipeline) ChunkSize() int {
+	return p.chunkSize
+}
+
+func (p *Pipeline) Concurrency() int {
+	return p.concurrency
+}
+
+func (p *Pipeline) SetChunkSize(chunkSize int) error {
+	if chunkSize < chunk.MinChunkSize {
+		return fmt.Errorf("chunk size must be at least %d bytes, got %d", chunk.MinChunkSize, chunkSize)
+	}
+	if chunkSize > MaxChunkSize {
+		return fmt.Errorf("chunk size must be at most %d bytes, got %d", MaxChunkSize, chunkSize)
+	}
+
+	p.chunkSize = chunkSize
+	return nil
+}
+
+func (p *Pipeline) FitChunkSize(limit int64) {
+	for p.EstimatedMemory() > limit && p.chunkSize/2 >= chunk.MinChunkSize {
+		p.chunkSize /= 2
+	}
+}
+
+func (p *Pipeline) SetConcurrency(concurrency int) error {
+	if concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1, got %d", concurrency)
+	}
+
+	p.concurrency = concurrency
+	p.prefetchDepth = min(p.prefetchDepth, concurrency)
+	p.executor = concurrent.NewConcurrentExecutor(p.dataProcessing, concurrency)
+	p.executor.SetPool(p.workerPool)
+	return nil
+}
+
+func (p *Pipeline) SetWorkerPool(pool *WorkerPool) error {
+	p.workerPool = pool
+	if pool == nil {
+		p.executor.SetPool(nil)
+		return nil
+	}
+	return p.SetConcurrency(min(p.concurrency, pool.Size()))
+}
+
+// SetThrottle limits how fast the pipeline reads its input and writes its
+// output; nil removes the limit.
+func (p *Pipeline) SetThrottle(t *Throttle) {
+	p.throttle = t
+}
+
+func (p *Pipeline) SetMaxOutstanding(chunks int) error {
+	if chunks < 1 {
+		return fmt.Errorf("outstanding chunk limit must be at least 1, got %d", chunks)
+	}
+
+	p.maxOutstanding = chunks
+	return nil
+}
+
+func (p *Pipeline) SetMemoryLimit(limit int64) error {
+	if limit <= 0 {
+		return fmt.Errorf("memory limit must be positive, got %d", limit)
+	}
+
+	for p.EstimatedMemory() > limit {
+		switch {
+		case p.maxOutstanding > 1:
+			p.maxOutstanding--
+		case p.prefetchDepth > 0:
+			p.prefetchDepth--
+		case p.concurrency > 1:
+			if err := p.SetConcurrency(p.concurrency - 1); err != nil {
+				return err
+			}
+		case p.processing == types.Encryption && p.chunkSize/2 >= chunk.MinChunkSize:
+			p.chunkSize /= 2
+		default:
+			return fmt.Errorf("memory limit of %s is too low: at least %s is needed", utils.FormatBytes(limit), utils.FormatBytes(p.EstimatedMemory()))
+		}
+	}
+
+	return nil
+}
+
+func (p *Pipeline) EstimatedMemory() int64 {
+	return int64(p.windowSize())*int64(p.chunkSize)*StageCopies + int64(p.coalescing())
+}
+
+func (p *Pipeline) windowSize() int {
+	if p.maxOutstanding > 0 {
+		return p.maxOutstanding
+	}
+	return p.prefetchDepth + 2*p.concurrency
+}
+
+func (p *Pipeline) SetChunkFlags(enabled bool) {
+	p.dataProcessing.SetChunkFlags(enabled)
+}
+
+func (p *Pipeline) SetECC(enabled bool) {
+	p.dataProcessing.SetECC(enabled)
+}
+
+func (p *Pipeline) SetSuite(suite cipher.Suite) {
+	p.dataProcessing.SetSuite(suite)
+}
+
+func (p *Pipeline) SetTrailer(dataKey []byte) error {
+	if dataKey == nil {
+		p.trailerKey = nil
+		return nil
+	}
+
+	key, err := chunk.TrailerKey(dataKey)
+	if err != nil {
+		return err
+	}
+	p.trailerKey = key
+	return nil
+}
+
+// TrailerCoverage picks the part of each stored chunk that the trailer
+// covers: the sealed ciphertext, without its Reed-Solomon parity.
+func (p *Pipeline) TrailerCoverage() chunk.Coverage {
+	return p.dataProcessing.Sealed
+}
+
+// RepairChunk corrects damaged shards of a stored chunk in place, as
+// decryption does before authenticating it.
+func (p *Pipeline) RepairChunk(stored []byte) *types.ChunkRepair {
+	return p.dataProcessing.Repair(stored)
+}
+
+// MaxStoredChunk is the largest stored chunk that the chunk size can
+// produce, which bounds the size prefixes decryption accepts.
+func (p *Pipeline) MaxStoredChunk() int {
+	return p.dataProcessing.MaxEncoded(p.chunkSize)
+}
+
+// SetIndex adds a chunk index after the trailer when encrypting, and
+// expects one when decrypting. It needs a trailer.
+func (p *Pipeline) SetIndex(dataKey []byte) error {
+	if dataKey == nil {
+		p.indexKey = nil
+		return nil
+	}
+
+	key, err := chunk.IndexKey(dataKey)
+	if err != nil {
+		return err
+	}
+	p.indexKey = key
+	return nil
+}
+
+func (p *Pipeline) SetPadding(size int64) {
+	p.padding = size
+}
+
+func (p *Pipeline) SetSequence(fileID []byte, chunkCount uint64) {
+	p.dataProcessing.SetSequence(fileID, chunkCount)
+	p.chunkCount = chunkCount
+}
+
+func (p *Pipeline) SetFirstChunk(index uint64) {
+	p.dataProcessing.SetFirstChunk(index)
+}
+
+func (p *Pipeline) SetDeterministic(enabled bool) {
+	p.dataProcessing.SetDeterministic(enabled)
+}
+
+func (p *Pipeline) SetCompressionLevel(level compression.Level) error {
+	return p.dataProcessing.SetCompressionLevel(level)
+}
+
+func (p *Pipeline) SetDictionary(dict []byte) error {
+	return p.dataProcessing.SetDictionary(dict)
+}
+
+func (p *Pipeline) SetDescription(description string) {
+	p.description = description
+}
+
+func (p *Pipeline) SetReporter(r reporter.Reporter) {
+	p.reporter = reporter.OrNop(r)
+}
+
+func (p *Pipeline) SetBaseOffset(offset int64) {
+	p.baseOffset = offset
+}
+
+func (p *Pipeline) SetPrefetchDepth(depth int) {
+	p.prefetchDepth = max(0, min(depth, p.concurrency))
+}
+
+func (p *Pipeline) Repairs() []types.ChunkRepair {
+	return p.repairs
+}
+
+func (p *Pipeline) EnablePositionalWrites(chunkSize int) error {
+	if p.processing != types.Decryption {
+		return fmt.Errorf("positional writes are only supported for decryption")
+	}
+	if err := p.SetChunkSize(chunkSize); err != nil {
+		return err
+	}
+
+	p.positional = true
+	return nil
+}
+
+// coalescing is the size of the buffer that sequential writes are gathered
+// in, used when chunks are small enough that writing each one on its own
+// would cost a call per chunk.
+func (p *Pipeline) coalescing() int {
+	if p.positional || p.chunkSize >= chunk.CoalesceSize {
+		return 0
+	}
+	return chunk.CoalesceSize
+}
+
 func (p *Pipeline) Process(ctx context.Context, input io.Reader, output io.Writer, totalSize int64) error {
 	if input == nil || output == nil {
 		return fmt.Errorf("input and output must not be nil")
 	}
 
-	bar := bar.NewProgressBar(totalSize, p.processing.String())
+	description := p.description
+	if description == "" {
+		description = p.processing.String()
+	}
+	progress := p.reporter.Progress(totalSize, description)
 
-	reader, err := chunk.NewChunkReader(p.processing, DefaultChunkSize)
+	window := chunk.NewWindow(p.windowSize())
+	reader, err := chunk.NewChunkReader(p.processing, p.chunkSize, p.prefetchDepth, window)
 	if err != nil {
 		return fmt.Errorf("reader creation: %w", err)
 	}
+	reader.SetBaseOffset(p.baseOffset)
+	reader.SetBufferPool(p.sources)
+	if p.processing == types.Decryption {
+		reader.SetChunkCount(p.chunkCount)
+		reader.SetMaxChunk(p.MaxStoredChunk())
+	}
 
-	writer, err := chunk.NewChunkWriter(p.processing, bar)
+	writer, err := chunk.NewChunkWriter(p.processing, progress, window)
 	if err != nil {
 		return fmt.Errorf("writer creation: %w", err)
 	}
+	writer.SetBufferPools(p.buffers, p.sources)
+	writer.SetCoalescing(p.coalescing())
+
+	if p.trailerKey != nil {
+		writer.SetTrailer(chunk.NewTrailer(p.trailerKey), p.TrailerCoverage())
+		reader.SetTrailer(p.processing == types.Decryption)
+		reader.SetPadding(p.padding)
+		if p.indexKey != nil {
+			writer.SetIndex(p.indexKey)
+			reader.SetIndex(p.indexKey)
+		}
+	}
+
+	if p.throttle != nil {
+		input = p.throttle.Reader(ctx, input)
+		output = p.throttle.Writer(ctx, output)
+	}
 
-	return p.run(ctx, input, output, reader, writer, p.processing)
+	err = p.run(ctx, input, output, reader, writer, p.processing)
+	p.repairs = writer.Repairs()
+	if err == nil {
+		err = writer.CheckTrailer(reader.Trailer())
+	}
+	if err == nil && p.processing == types.Decryption && totalSize >= 0 && writer.Written() != totalSize {
+		// Files without a trailer, such as those encrypted in place, end
+		// at any chunk boundary; the size in the header is authenticated.
+		err = errors.Newf(errors.CodeCorrupt, "", "decrypted %d bytes but the header records %d", writer.Written(), totalSize)
+	}
+	if err != nil && p.processing == types.Decryption && !errors.Is(err, context.Canceled) {
+		return errors.New(errors.CodeUnknown, "", err).WithRecovered(writer.Written())
+	}
+	return err
+}
+
+// DecryptChunk decrypts one chunk on its own, for random access. Damage
+// that Reed-Solomon parity can correct is repaired silently.
+func (p *Pipeline) DecryptChunk(index uint64, data []byte) ([]byte, error) {
+	if p.processing != types.Decryption {
+		return nil, fmt.Errorf("chunks can only be decrypted by a decryption pipeline")
+	}
+	result := p.dataProcessing.Process(context.Background(), types.Task{Data: data, Index: index}, nil)
+	return result.Data, result.Err
 }
 
 func (p *Pipeline) run(ctx context.Context, input io.Reader, output io.Writer, reader *chunk.ChunkReader, writer *chunk.ChunkWriter, mode types.Processing) error {
@@ -76,6 +418,9 @@ func (p *Pipeline) run(ctx context.Context, input io.Reader, output io.Writer, r
 	results := p.executor.Process(ctx, tasks, mode)
 
 	g.Go(func() error {
+		if outputAt, ok := output.(io.WriterAt); ok && p.positional {
+			return writer.WriteAt(ctx, outputAt, p.chunkSize, p.concurrency, results)
+		}
 		return writer.Write(ctx, output, results)
 	})
 
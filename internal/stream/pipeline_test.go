@@ -0,0 +1,103 @@
+package stream_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/hambosto/sweetbyte/internal/benchmark"
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/stream"
+	"github.com/hambosto/sweetbyte/internal/types"
+)
+
+const workloadSize = 16 << 20
+
+var chunkSizes = []int{stream.DefaultChunkSize, 1 << 20, 4 << 20}
+
+func newPipeline(b *testing.B, key []byte, mode types.Processing, chunkSize int) *stream.Pipeline {
+	b.Helper()
+	pipeline, err := stream.NewPipeline(key, mode)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := pipeline.SetChunkSize(chunkSize); err != nil {
+		b.Fatal(err)
+	}
+	return pipeline
+}
+
+func newKey(b *testing.B) []byte {
+	b.Helper()
+	key, err := derive.GetRandomBytes(derive.ArgonKeyLen)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return key
+}
+
+// reportPerChunk adds the allocations of each chunk next to those of each
+// run, which cover the whole workload.
+func reportPerChunk(b *testing.B, chunkSize int, run func()) {
+	chunks := float64((workloadSize + chunkSize - 1) / chunkSize)
+	allocs := testing.AllocsPerRun(1, run)
+	b.ReportMetric(allocs/chunks, "allocs/chunk")
+}
+
+// BenchmarkEncrypt runs the encryption pipeline over the mixed workload of
+// the bench command. The pipeline is reused, so its buffer pools are warm
+// after the first run.
+func BenchmarkEncrypt(b *testing.B) {
+	workload := benchmark.Workload(workloadSize)
+	for _, chunkSize := range chunkSizes {
+		b.Run(strconv.Itoa(chunkSize>>10)+"KB", func(b *testing.B) {
+			pipeline := newPipeline(b, newKey(b), types.Encryption, chunkSize)
+			var encrypted bytes.Buffer
+			encrypted.Grow(2 * workloadSize)
+			run := func() {
+				encrypted.Reset()
+				if err := pipeline.Process(context.Background(), bytes.NewReader(workload), &encrypted, workloadSize); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.SetBytes(workloadSize)
+			b.ReportAllocs()
+			for b.Loop() {
+				run()
+			}
+			reportPerChunk(b, chunkSize, run)
+		})
+	}
+}
+
+// BenchmarkDecrypt runs the decryption pipeline over the workload encrypted
+// once up front.
+func BenchmarkDecrypt(b *testing.B) {
+	workload := benchmark.Workload(workloadSize)
+	for _, chunkSize := range chunkSizes {
+		b.Run(strconv.Itoa(chunkSize>>10)+"KB", func(b *testing.B) {
+			key := newKey(b)
+			var encrypted bytes.Buffer
+			if err := newPipeline(b, key, types.Encryption, chunkSize).Process(context.Background(), bytes.NewReader(workload), &encrypted, workloadSize); err != nil {
+				b.Fatal(err)
+			}
+
+			pipeline := newPipeline(b, key, types.Decryption, chunkSize)
+			run := func() {
+				if err := pipeline.Process(context.Background(), bytes.NewReader(encrypted.Bytes()), io.Discard, workloadSize); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.SetBytes(workloadSize)
+			b.ReportAllocs()
+			for b.Loop() {
+				run()
+			}
+			reportPerChunk(b, chunkSize, run)
+		})
+	}
+}
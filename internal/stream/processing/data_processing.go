@@ -1,23 +1,44 @@
 package processing
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
 	"fmt"
+	"slices"
 
 	"github.com/hambosto/sweetbyte/internal/cipher"
+	"github.com/hambosto/sweetbyte/internal/cipher/algorithm"
 	"github.com/hambosto/sweetbyte/internal/compression"
 	"github.com/hambosto/sweetbyte/internal/derive"
 	"github.com/hambosto/sweetbyte/internal/encoding"
+	"github.com/hambosto/sweetbyte/internal/errors"
 	"github.com/hambosto/sweetbyte/internal/padding"
+	"github.com/hambosto/sweetbyte/internal/stream/buffer"
 	"github.com/hambosto/sweetbyte/internal/types"
 )
 
+const CompressionLevel = compression.LevelBestSpeed
+
+const (
+	chunkRaw        byte = 0
+	chunkCompressed byte = 1
+)
+
 type DataProcessing struct {
 	cipher     *cipher.Cipher
 	encoder    *encoding.Encoding
 	compressor *compression.Compression
 	padder     *padding.Padding
+	buffers    *buffer.Pool
+	sources    *buffer.Pool
 	processing types.Processing
+	suite      cipher.Suite
+	fileID     []byte
+	chunkCount uint64
+	firstChunk uint64
+	chunkFlags bool
+	ecc        bool
 }
 
 func NewDataProcessing(key []byte, processing types.Processing) (*DataProcessing, error) {
@@ -35,7 +56,7 @@ func NewDataProcessing(key []byte, processing types.Processing) (*DataProcessing
 		return nil, fmt.Errorf("Reed-Solomon encoder initialization: %w", err)
 	}
 
-	compressor, err := compression.NewCompression(compression.LevelBestSpeed)
+	compressor, err := compression.NewCompression(CompressionLevel)
 	if err != nil {
 		return nil, fmt.Errorf("compressor initialization: %w", err)
 	}
@@ -51,93 +72,351 @@ func NewDataProcessing(key []byte, processing types.Processing) (*DataProcessing
 		compressor: compressor,
 		padder:     padder,
 		processing: processing,
+		chunkFlags: true,
+		ecc:        true,
 	}, nil
 }
 
-func (p *DataProcessing) Process(ctx context.Context, task types.Task) types.TaskResult {
+// SetBufferPools makes processing take the buffers of its results from
+// buffers, and release the chunks it encrypts to sources.
+func (p *DataProcessing) SetBufferPools(buffers, sources *buffer.Pool) {
+	p.buffers = buffers
+	p.sources = sources
+}
+
+func (p *DataProcessing) SetChunkFlags(enabled bool) {
+	p.chunkFlags = enabled
+}
+
+func (p *DataProcessing) SetECC(enabled bool) {
+	p.ecc = enabled
+}
+
+func (p *DataProcessing) SetSuite(suite cipher.Suite) {
+	p.suite = suite
+}
+
+func (p *DataProcessing) SetSequence(fileID []byte, chunkCount uint64) {
+	p.fileID = bytes.Clone(fileID)
+	p.chunkCount = chunkCount
+}
+
+func (p *DataProcessing) SetFirstChunk(index uint64) {
+	p.firstChunk = index
+}
+
+func (p *DataProcessing) SetDeterministic(enabled bool) {
+	p.cipher.SetDeterministic(enabled)
+}
+
+func (p *DataProcessing) SetCompressionLevel(level compression.Level) error {
+	compressor, err := compression.NewCompression(level)
+	if err != nil {
+		return fmt.Errorf("compressor initialization: %w", err)
+	}
+	p.compressor = compressor
+	return nil
+}
+
+// SetDictionary switches compression to zstd primed with dict.
+func (p *DataProcessing) SetDictionary(dict []byte) error {
+	compressor, err := compression.NewDictCompression(CompressionLevel, dict)
+	if err != nil {
+		return fmt.Errorf("compressor initialization: %w", err)
+	}
+	p.compressor = compressor
+	return nil
+}
+
+// Sealed returns the sealed ciphertext in a chunk as stored, leaving out
+// its Reed-Solomon parity.
+func (p *DataProcessing) Sealed(stored []byte) []byte {
+	if !p.ecc {
+		return stored
+	}
+	return p.encoder.Data(stored)
+}
+
+// MaxEncoded is the largest stored chunk that encrypting chunkSize bytes
+// can produce. With chunk flags, data that does not compress is stored raw.
+func (p *DataProcessing) MaxEncoded(chunkSize int) int {
+	return MaxEncoded(chunkSize, p.suite, p.ecc, p.chunkFlags)
+}
+
+// MaxEncoded is the largest stored chunk that encrypting chunkSize bytes
+// with suite can produce, for tools that read a file without its key.
+func MaxEncoded(chunkSize int, suite cipher.Suite, ecc, chunkFlags bool) int {
+	compressed := compression.Bound(chunkSize)
+	if chunkFlags {
+		compressed = chunkSize + 1
+	}
+	return EncryptedLayout(compressed, suite, ecc).Encoded
+}
+
+type ChunkLayout struct {
+	Payload int
+	Padded  int
+	Sealed  int
+	Encoded int
+}
+
+func EncryptedLayout(compressed int, suite cipher.Suite, ecc bool) ChunkLayout {
+	layout := ChunkLayout{Payload: compressed + 1}
+	layout.Padded = layout.Payload + padding.BlockSize - layout.Payload%padding.BlockSize
+	layout.Sealed = layout.Padded + suite.Overhead()
+	layout.Encoded = layout.Sealed
+	if ecc {
+		shardSize := (layout.Sealed + encoding.DataShards - 1) / encoding.DataShards
+		layout.Encoded = shardSize * (encoding.DataShards + encoding.ParityShards)
+	}
+	return layout
+}
+
+type Buffers struct {
+	primary   []byte
+	secondary []byte
+	aad       []byte
+}
+
+func NewBuffers() *Buffers {
+	return &Buffers{}
+}
+
+func (p *DataProcessing) Process(ctx context.Context, task types.Task, buffers *Buffers) types.TaskResult {
 	if err := ctx.Err(); err != nil {
-		return types.TaskResult{Index: task.Index, Err: err}
+		return types.TaskResult{Index: task.Index, Offset: task.Offset, Err: errors.New(errors.CodeCanceled, "", err).WithChunk(task.Index)}
+	}
+
+	if buffers == nil {
+		buffers = NewBuffers()
 	}
 
 	var output []byte
+	var repair *types.ChunkRepair
 	var err error
 
 	switch p.processing {
 	case types.Encryption:
-		output, err = p.encryptPipeline(task.Data)
+		output, err = p.encryptPipeline(task.Data, p.additionalData(task.Index, buffers))
+		p.sources.Put(task.Data)
 	case types.Decryption:
-		output, err = p.decryptPipeline(task.Data)
+		output, repair, err = p.decryptPipeline(task.Data, p.additionalData(task.Index, buffers), buffers)
 	default:
 		err = fmt.Errorf("unknown processing type: %d", p.processing)
 	}
 
-	size := len(task.Data)
-	if p.processing == types.Decryption && output != nil {
-		size = len(output)
+	if err != nil {
+		err = errors.New(errors.CodeUnknown, "", err).WithChunk(task.Index).WithOffset(task.Offset)
 	}
 
-	return types.TaskResult{
-		Index: task.Index,
-		Data:  output,
-		Size:  size,
-		Err:   err,
+	result := types.TaskResult{
+		Index:  task.Index,
+		Offset: task.Offset,
+		Data:   output,
+		Size:   len(task.Data),
+		Err:    err,
 	}
+	if p.processing == types.Decryption {
+		result.Source = task.Data
+		if output != nil {
+			result.Size = len(output)
+		}
+		if repair != nil {
+			repair.Index = task.Index
+			repair.Offset = task.Offset
+			result.Repair = repair
+		}
+	}
+	return result
 }
 
-func (p *DataProcessing) encryptPipeline(data []byte) ([]byte, error) {
-	compressed, err := p.compressor.Compress(data)
-	if err != nil {
-		return nil, fmt.Errorf("compression: %w", err)
+func (p *DataProcessing) additionalData(index uint64, buffers *Buffers) []byte {
+	if p.fileID == nil {
+		return nil
 	}
 
-	padded, err := p.padder.Pad(compressed)
+	aad := append(buffers.aad[:0], p.fileID...)
+	aad = binary.BigEndian.AppendUint64(aad, p.firstChunk+index)
+	aad = binary.BigEndian.AppendUint64(aad, p.chunkCount)
+	buffers.aad = aad
+	return aad
+}
+
+// encryptPipeline builds the chunk in one pooled buffer sized up front by
+// EncryptedLayout: data is compressed after room for the nonces, padded,
+// sealed over itself and then extended with its parity shards.
+func (p *DataProcessing) encryptPipeline(data, additionalData []byte) ([]byte, error) {
+	nonceSize := p.suite.NonceSize()
+	layout := EncryptedLayout(len(data)+1, p.suite, p.ecc)
+	chunk := p.buffers.Get(layout.Encoded)[:nonceSize]
+
+	chunk, err := p.compressor.AppendCompressed(chunk, data)
 	if err != nil {
-		return nil, fmt.Errorf("padding: %w", err)
+		return nil, errors.New(errors.CodeUnknown, "compression", err)
 	}
 
-	aesEncrypted, err := p.cipher.EncryptAES(padded)
+	if p.chunkFlags {
+		if len(chunk)-nonceSize < len(data) {
+			chunk = append(chunk, chunkCompressed)
+		} else {
+			chunk = append(append(chunk[:nonceSize], data...), chunkRaw)
+		}
+	}
+
+	// The buffer only grows here if compression expanded the data.
+	layout = EncryptedLayout(len(chunk)-nonceSize, p.suite, p.ecc)
+	chunk = slices.Grow(chunk, layout.Encoded-len(chunk))
+	padded, err := p.padder.Pad(chunk[nonceSize:])
 	if err != nil {
-		return nil, fmt.Errorf("AES-256-GCM encryption: %w", err)
+		return nil, errors.New(errors.CodeUnknown, "padding", err)
 	}
 
-	chachaEncrypted, err := p.cipher.EncryptChaCha20(aesEncrypted)
+	sealed, err := p.seal(chunk[:nonceSize+len(padded)], additionalData)
 	if err != nil {
-		return nil, fmt.Errorf("XChaCha20-Poly1305 encryption: %w", err)
+		return nil, err
 	}
 
-	encoded, err := p.encoder.Encode(chachaEncrypted)
+	if !p.ecc {
+		return sealed, nil
+	}
+
+	encoded, err := p.encoder.EncodeTo(sealed, sealed)
 	if err != nil {
-		return nil, fmt.Errorf("Reed-Solomon encoding: %w", err)
+		return nil, errors.New(errors.CodeUnknown, "Reed-Solomon encoding", err)
 	}
 
 	return encoded, nil
 }
 
-func (p *DataProcessing) decryptPipeline(data []byte) ([]byte, error) {
-	decoded, err := p.encoder.Decode(data)
+func (p *DataProcessing) decryptPipeline(data, additionalData []byte, buffers *Buffers) ([]byte, *types.ChunkRepair, error) {
+	// Parity is checked before authenticating, so damage confined to the
+	// parity shards is repaired and reported as well.
+	repair := p.Repair(data)
+	decrypted, err := p.openEncoded(data, additionalData, buffers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	unpadded, err := p.padder.Unpad(decrypted)
+	if err != nil {
+		return nil, nil, errors.New(errors.CodeCorrupt, "padding validation (tampering detected)", err)
+	}
+
+	if p.chunkFlags {
+		if len(unpadded) == 0 {
+			return nil, nil, errors.Newf(errors.CodeCorrupt, "chunk flag", "missing chunk flag")
+		}
+
+		flag := unpadded[len(unpadded)-1]
+		unpadded = unpadded[:len(unpadded)-1]
+		switch flag {
+		case chunkRaw:
+			return append(p.buffers.Get(len(unpadded))[:0], unpadded...), repair, nil
+		case chunkCompressed:
+		default:
+			return nil, nil, errors.Newf(errors.CodeCorrupt, "chunk flag", "unknown chunk flag %d", flag)
+		}
+	}
+
+	decompressed, err := p.compressor.DecompressTo(p.buffers.Get(0), unpadded)
 	if err != nil {
-		return nil, fmt.Errorf("Reed-Solomon decoding (data corrupted): %w", err)
+		return nil, nil, errors.New(errors.CodeCorrupt, "decompression (data corrupted)", err)
+	}
+
+	return decompressed, repair, nil
+}
+
+func (p *DataProcessing) openEncoded(data, additionalData []byte, buffers *Buffers) ([]byte, error) {
+	decoded := data
+	if p.ecc {
+		var err error
+		if decoded, err = p.encoder.Decode(data); err != nil {
+			return nil, errors.New(errors.CodeCorrupt, "Reed-Solomon decoding (data corrupted)", err)
+		}
+	}
+	return p.open(decoded, additionalData, buffers)
+}
+
+// Repair corrects damaged shards of a stored chunk in place, and describes
+// what it corrected. It returns nil for an intact or unrecoverable chunk,
+// and without Reed-Solomon parity.
+func (p *DataProcessing) Repair(data []byte) *types.ChunkRepair {
+	if !p.ecc {
+		return nil
+	}
+	diagnosis := p.encoder.Repair(data)
+	if diagnosis.Status != encoding.StatusRepairable {
+		return nil
+	}
+	return &types.ChunkRepair{Length: len(data), Shards: diagnosis.DamagedShards, ShardSize: diagnosis.ShardSize}
+}
+
+// seal encrypts the padded chunk that follows the suite's nonce size of
+// free bytes in chunk, in place.
+func (p *DataProcessing) seal(chunk, additionalData []byte) ([]byte, error) {
+	switch p.suite {
+	case cipher.SuiteAESGCM:
+		aesEncrypted, err := p.cipher.EncryptAESInPlace(chunk, additionalData)
+		if err != nil {
+			return nil, errors.New(errors.CodeUnknown, "AES-256-GCM encryption", err)
+		}
+		return aesEncrypted, nil
+	case cipher.SuiteXChaCha20:
+		chachaEncrypted, err := p.cipher.EncryptChaCha20InPlace(chunk, additionalData)
+		if err != nil {
+			return nil, errors.New(errors.CodeUnknown, "XChaCha20-Poly1305 encryption", err)
+		}
+		return chachaEncrypted, nil
+	case cipher.SuiteCascade:
+	default:
+		return nil, errors.Newf(errors.CodeUnsupported, "encryption", "unsupported cipher suite %s", p.suite)
 	}
 
-	chachaDecrypted, err := p.cipher.DecryptChaCha20(decoded)
+	aesEncrypted, err := p.cipher.EncryptAESInPlace(chunk[algorithm.ChaChaNonceSizeX:], additionalData)
 	if err != nil {
-		return nil, fmt.Errorf("XChaCha20-Poly1305 decryption (tampering detected): %w", err)
+		return nil, errors.New(errors.CodeUnknown, "AES-256-GCM encryption", err)
 	}
 
-	aesDecrypted, err := p.cipher.DecryptAES(chachaDecrypted)
+	chachaEncrypted, err := p.cipher.EncryptChaCha20InPlace(chunk[:algorithm.ChaChaNonceSizeX+len(aesEncrypted)], additionalData)
 	if err != nil {
-		return nil, fmt.Errorf("AES-256-GCM decryption (tampering detected): %w", err)
+		return nil, errors.New(errors.CodeUnknown, "XChaCha20-Poly1305 encryption", err)
+	}
+
+	return chachaEncrypted, nil
+}
+
+func (p *DataProcessing) open(ciphertext, additionalData []byte, buffers *Buffers) ([]byte, error) {
+	switch p.suite {
+	case cipher.SuiteAESGCM:
+		aesDecrypted, err := p.cipher.DecryptAESTo(buffers.secondary, ciphertext, additionalData)
+		if err != nil {
+			return nil, errors.New(errors.CodeAuthentication, "AES-256-GCM decryption (tampering detected)", err)
+		}
+		buffers.secondary = aesDecrypted
+		return aesDecrypted, nil
+	case cipher.SuiteXChaCha20:
+		chachaDecrypted, err := p.cipher.DecryptChaCha20To(buffers.secondary, ciphertext, additionalData)
+		if err != nil {
+			return nil, errors.New(errors.CodeAuthentication, "XChaCha20-Poly1305 decryption (tampering detected)", err)
+		}
+		buffers.secondary = chachaDecrypted
+		return chachaDecrypted, nil
+	case cipher.SuiteCascade:
+	default:
+		return nil, errors.Newf(errors.CodeUnsupported, "decryption", "unsupported cipher suite %s", p.suite)
 	}
 
-	unpadded, err := p.padder.Unpad(aesDecrypted)
+	chachaDecrypted, err := p.cipher.DecryptChaCha20To(buffers.primary, ciphertext, additionalData)
 	if err != nil {
-		return nil, fmt.Errorf("padding validation (tampering detected): %w", err)
+		return nil, errors.New(errors.CodeAuthentication, "XChaCha20-Poly1305 decryption (tampering detected)", err)
 	}
+	buffers.primary = chachaDecrypted
 
-	decompressed, err := p.compressor.Decompress(unpadded)
+	aesDecrypted, err := p.cipher.DecryptAESTo(buffers.secondary, chachaDecrypted, additionalData)
 	if err != nil {
-		return nil, fmt.Errorf("decompression (data corrupted): %w", err)
+		return nil, errors.New(errors.CodeAuthentication, "AES-256-GCM decryption (tampering detected)", err)
 	}
+	buffers.secondary = aesDecrypted
 
-	return decompressed, nil
+	return aesDecrypted, nil
 }
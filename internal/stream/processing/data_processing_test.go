@@ -0,0 +1,103 @@
+package processing_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/hambosto/sweetbyte/internal/benchmark"
+	"github.com/hambosto/sweetbyte/internal/cipher"
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/stream/buffer"
+	"github.com/hambosto/sweetbyte/internal/stream/processing"
+	"github.com/hambosto/sweetbyte/internal/types"
+)
+
+const chunkSize = 256 << 10
+
+var suites = []cipher.Suite{cipher.SuiteCascade, cipher.SuiteAESGCM, cipher.SuiteXChaCha20}
+
+func newProcessing(b *testing.B, key []byte, mode types.Processing, suite cipher.Suite) (*processing.DataProcessing, *buffer.Pool, *buffer.Pool) {
+	b.Helper()
+	p, err := processing.NewDataProcessing(key, mode)
+	if err != nil {
+		b.Fatal(err)
+	}
+	p.SetSuite(suite)
+	p.SetSequence(bytes.Repeat([]byte{1}, 16), 1)
+	buffers, sources := buffer.NewPool(), buffer.NewPool()
+	p.SetBufferPools(buffers, sources)
+	return p, buffers, sources
+}
+
+// BenchmarkEncryptChunk measures one chunk going through compression,
+// padding, sealing and Reed-Solomon encoding in its single buffer, with
+// the buffers released to the pools the way the pipeline does.
+func BenchmarkEncryptChunk(b *testing.B) {
+	data := benchmark.Workload(chunkSize)
+	key, err := derive.GetRandomBytes(derive.ArgonKeyLen)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, suite := range suites {
+		b.Run(suite.String(), func(b *testing.B) {
+			p, buffers, sources := newProcessing(b, key, types.Encryption, suite)
+			buffers.Put(make([]byte, 0, 2*chunkSize))
+			scratch := processing.NewBuffers()
+
+			b.SetBytes(chunkSize)
+			b.ReportAllocs()
+			for b.Loop() {
+				source := sources.Get(len(data))
+				copy(source, data)
+				result := p.Process(context.Background(), types.Task{Data: source}, scratch)
+				if result.Err != nil {
+					b.Fatal(result.Err)
+				}
+				buffers.Put(result.Data)
+			}
+		})
+	}
+}
+
+// BenchmarkDecryptChunk measures one chunk being checked against its
+// parity, opened, unpadded and decompressed.
+func BenchmarkDecryptChunk(b *testing.B) {
+	data := benchmark.Workload(chunkSize)
+	key, err := derive.GetRandomBytes(derive.ArgonKeyLen)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, suite := range suites {
+		b.Run(suite.String(), func(b *testing.B) {
+			encryption, _, _ := newProcessing(b, key, types.Encryption, suite)
+			encrypted := encryption.Process(context.Background(), types.Task{Data: bytes.Clone(data)}, processing.NewBuffers())
+			if encrypted.Err != nil {
+				b.Fatal(encrypted.Err)
+			}
+
+			p, buffers, _ := newProcessing(b, key, types.Decryption, suite)
+			scratch := processing.NewBuffers()
+			result := p.Process(context.Background(), types.Task{Data: encrypted.Data}, scratch)
+			if result.Err != nil {
+				b.Fatal(result.Err)
+			}
+			if !bytes.Equal(result.Data, data) {
+				b.Fatal("decrypted chunk differs from the plaintext")
+			}
+			buffers.Put(result.Data)
+
+			b.SetBytes(chunkSize)
+			b.ReportAllocs()
+			for b.Loop() {
+				result := p.Process(context.Background(), types.Task{Data: encrypted.Data}, scratch)
+				if result.Err != nil {
+					b.Fatal(result.Err)
+				}
+				buffers.Put(result.Data)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket that refills at rate bytes per second and holds
+// at most one second of them. It is safe for concurrent use, so pipelines
+// that share one share its rate.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func NewLimiter(rate int64) (*Limiter, error) {
+	if rate <= 0 {
+		return nil, fmt.Errorf("rate must be positive, got %d", rate)
+	}
+	return &Limiter{rate: float64(rate), tokens: float64(rate), last: time.Now()}, nil
+}
+
+// Burst is the most that Wait lets through at once without waiting.
+func (l *Limiter) Burst() int {
+	return int(l.rate)
+}
+
+// Wait takes n tokens, going into debt for more than the bucket holds, and
+// blocks until the balance is no longer negative or ctx is canceled.
+func (l *Limiter) Wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Throttle caps reading and writing separately, each at the same rate.
+type Throttle struct {
+	read  *Limiter
+	write *Limiter
+}
+
+func New(rate int64) (*Throttle, error) {
+	read, err := NewLimiter(rate)
+	if err != nil {
+		return nil, err
+	}
+	write, err := NewLimiter(rate)
+	if err != nil {
+		return nil, err
+	}
+	return &Throttle{read: read, write: write}, nil
+}
+
+// Reader returns r limited to the read rate of t.
+func (t *Throttle) Reader(ctx context.Context, r io.Reader) io.Reader {
+	return &reader{ctx: ctx, r: r, limiter: t.read}
+}
+
+// Writer returns w limited to the write rate of t. It keeps positional
+// writes available when w supports them.
+func (t *Throttle) Writer(ctx context.Context, w io.Writer) io.Writer {
+	limited := &writer{ctx: ctx, w: w, limiter: t.write}
+	if at, ok := w.(io.WriterAt); ok {
+		return &writerAt{writer: limited, at: at}
+	}
+	return limited
+}
+
+type reader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *Limiter
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if burst := r.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.Wait(r.ctx, n); waitErr != nil && err == nil {
+			err = waitErr
+		}
+	}
+	return n, err
+}
+
+type writer struct {
+	ctx     context.Context
+	w       io.Writer
+	limiter *Limiter
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if err := w.limiter.Wait(w.ctx, len(p)); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
+
+type writerAt struct {
+	*writer
+	at io.WriterAt
+}
+
+func (w *writerAt) WriteAt(p []byte, off int64) (int, error) {
+	if err := w.limiter.Wait(w.ctx, len(p)); err != nil {
+		return 0, err
+	}
+	return w.at.WriteAt(p, off)
+}
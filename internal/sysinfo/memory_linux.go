@@ -0,0 +1,36 @@
+package sysinfo
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func systemMemory() (Memory, bool) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return Memory{}, false
+	}
+	defer f.Close()
+
+	var memory Memory
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			memory.Total = kb * 1024
+		case "MemAvailable:":
+			memory.Available = kb * 1024
+		}
+	}
+	return memory, memory.Total > 0
+}
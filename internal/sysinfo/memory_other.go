@@ -0,0 +1,7 @@
+//go:build !linux
+
+package sysinfo
+
+func systemMemory() (Memory, bool) {
+	return Memory{}, false
+}
@@ -0,0 +1,182 @@
+package sysinfo
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/hambosto/sweetbyte/internal/cipher"
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/header"
+	"golang.org/x/sys/cpu"
+)
+
+type Memory struct {
+	Total     uint64 `json:"total"`
+	Available uint64 `json:"available"`
+}
+
+type Backends struct {
+	AutoCipher string `json:"auto_cipher"`
+	AES        string `json:"aes_gcm"`
+	ChaCha20   string `json:"chacha20_poly1305"`
+	Argon2     string `json:"argon2"`
+}
+
+type Formats struct {
+	MinVersion     uint16 `json:"min_version"`
+	CurrentVersion uint16 `json:"current_version"`
+	Revision       uint64 `json:"revision"`
+}
+
+type KDF struct {
+	Profile string `json:"profile"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory_kib"`
+	Threads uint8  `json:"threads"`
+}
+
+type Config struct {
+	SettingsFile   string `json:"settings_file"`
+	SettingsExists bool   `json:"settings_exists"`
+	SettingsError  string `json:"settings_error,omitempty"`
+	TempDir        string `json:"temp_dir"`
+	RequireTmpfs   bool   `json:"require_tmpfs,omitempty"`
+}
+
+type Report struct {
+	Version     string        `json:"version"`
+	GoVersion   string        `json:"go_version"`
+	OS          string        `json:"os"`
+	Arch        string        `json:"arch"`
+	CPUs        int           `json:"cpus"`
+	GOMAXPROCS  int           `json:"gomaxprocs"`
+	CPUFeatures []string      `json:"cpu_features"`
+	Backends    Backends      `json:"backends"`
+	Memory      *Memory       `json:"memory,omitempty"`
+	KDF         KDF           `json:"kdf"`
+	Tuning      config.Tuning `json:"tuning"`
+	ChunkSize   int           `json:"chunk_size,omitempty"`
+	Concurrency int           `json:"concurrency"`
+	Config      Config        `json:"config"`
+	Formats     Formats       `json:"formats"`
+}
+
+func Collect() Report {
+	report := Report{
+		Version:     config.AppVersion,
+		GoVersion:   runtime.Version(),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		CPUs:        runtime.NumCPU(),
+		GOMAXPROCS:  runtime.GOMAXPROCS(0),
+		CPUFeatures: cpuFeatures(),
+		Backends:    backends(),
+		KDF:         defaultKDF(),
+		Tuning:      config.LoadTuning(),
+		Config:      configLocations(),
+		Formats: Formats{
+			MinVersion:     header.VersionLegacy,
+			CurrentVersion: header.CurrentVersion,
+			Revision:       header.FormatRevision,
+		},
+	}
+
+	report.ChunkSize = report.Tuning.ChunkSize
+	report.Concurrency = runtime.NumCPU()
+	if report.Tuning.Concurrency > 0 {
+		report.Concurrency = report.Tuning.Concurrency
+	}
+
+	if memory, ok := systemMemory(); ok {
+		report.Memory = &memory
+	}
+	return report
+}
+
+func AvailableMemory() (uint64, bool) {
+	memory, ok := systemMemory()
+	if !ok || memory.Available == 0 {
+		return 0, false
+	}
+	return memory.Available, true
+}
+
+func defaultKDF() KDF {
+	params := derive.DefaultParams()
+	return KDF{Profile: derive.ProfileDefault, Time: params.Time, Memory: params.Memory, Threads: params.Threads}
+}
+
+func cpuFeatures() []string {
+	var features []string
+	add := func(name string, present bool) {
+		if present {
+			features = append(features, name)
+		}
+	}
+
+	switch runtime.GOARCH {
+	case "amd64", "386":
+		add("aes-ni", cpu.X86.HasAES)
+		add("pclmulqdq", cpu.X86.HasPCLMULQDQ)
+		add("ssse3", cpu.X86.HasSSSE3)
+		add("sse4.1", cpu.X86.HasSSE41)
+		add("avx", cpu.X86.HasAVX)
+		add("avx2", cpu.X86.HasAVX2)
+		add("avx512f", cpu.X86.HasAVX512F)
+		add("bmi2", cpu.X86.HasBMI2)
+		add("adx", cpu.X86.HasADX)
+	case "arm64":
+		add("aes", cpu.ARM64.HasAES)
+		add("pmull", cpu.ARM64.HasPMULL)
+		add("sha2", cpu.ARM64.HasSHA2)
+		add("asimd", cpu.ARM64.HasASIMD)
+	}
+	return features
+}
+
+func backends() Backends {
+	b := Backends{AutoCipher: cipher.AutoSuite().String(), AES: "software (constant-time)", ChaCha20: "generic", Argon2: "generic"}
+
+	switch runtime.GOARCH {
+	case "amd64":
+		if cipher.HardwareAES() {
+			b.AES = "hardware (AES-NI + CLMUL)"
+		}
+		switch {
+		case cpu.X86.HasAVX2 && cpu.X86.HasBMI2:
+			b.ChaCha20 = "AVX2"
+		default:
+			b.ChaCha20 = "SSSE3"
+		}
+		if cpu.X86.HasSSE41 {
+			b.Argon2 = "SSE4.1"
+		}
+	case "arm64":
+		if cipher.HardwareAES() {
+			b.AES = "hardware (ARMv8 crypto)"
+		}
+		b.ChaCha20 = "NEON"
+	}
+	return b
+}
+
+func configLocations() Config {
+	var c Config
+	if path, err := config.SettingsPath(); err != nil {
+		c.SettingsError = err.Error()
+	} else {
+		c.SettingsFile = path
+		if _, err := os.Stat(path); err == nil {
+			c.SettingsExists = true
+		}
+	}
+
+	temp := config.LoadTemp()
+	c.TempDir = temp.Dir
+	if c.TempDir == "" {
+		c.TempDir = os.TempDir()
+	}
+	c.RequireTmpfs = temp.RequireTmpfs
+	return c
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package tempfile
+
+import "os"
+
+// SyncDir flushes the entries of dir, so a file created or renamed in it
+// survives a crash.
+func SyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
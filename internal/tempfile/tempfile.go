@@ -0,0 +1,178 @@
+package tempfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hambosto/sweetbyte/internal/errors"
+)
+
+var ErrNotTmpfs = errors.Sentinel("temporary directory is not on tmpfs")
+
+type Options struct {
+	Dir          string
+	RequireTmpfs bool
+}
+
+var (
+	tempMu      sync.RWMutex
+	tempOptions Options
+)
+
+func SetOptions(opts Options) {
+	tempMu.Lock()
+	defer tempMu.Unlock()
+	tempOptions = opts
+}
+
+func Dir() string {
+	tempMu.RLock()
+	defer tempMu.RUnlock()
+	if tempOptions.Dir != "" {
+		return tempOptions.Dir
+	}
+	return os.TempDir()
+}
+
+type File struct {
+	*os.File
+	path      string
+	dest      string
+	inPlace   bool
+	committed bool
+	removed   bool
+}
+
+func Create(pattern string) (*File, error) {
+	tempMu.RLock()
+	requireTmpfs := tempOptions.RequireTmpfs
+	tempMu.RUnlock()
+
+	dir := Dir()
+	if requireTmpfs {
+		ok, err := isTmpfs(dir)
+		if err != nil {
+			return nil, errors.New(errors.CodeIO, "check temporary directory", err).WithPath(dir)
+		}
+		if !ok {
+			return nil, errors.New(errors.CodeInvalidInput, "", ErrNotTmpfs).WithPath(dir)
+		}
+	}
+
+	return createTemp(dir, pattern, "")
+}
+
+func CreateAtomic(dest string) (*File, error) {
+	cleanPath := filepath.Clean(dest)
+	if err := os.MkdirAll(filepath.Dir(cleanPath), 0o755); err != nil {
+		return nil, errors.New(errors.CodeIO, "create parent directory", err).WithPath(cleanPath)
+	}
+
+	return createTemp(filepath.Dir(cleanPath), "."+filepath.Base(cleanPath)+".*.tmp", cleanPath)
+}
+
+func OpenInPlace(dest string) (*File, error) {
+	cleanPath := filepath.Clean(dest)
+	f, err := os.OpenFile(cleanPath, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, errors.New(errors.CodeIO, "open", err).WithPath(cleanPath)
+	}
+
+	return &File{File: f, path: cleanPath, dest: cleanPath, inPlace: true}, nil
+}
+
+func createTemp(dir, pattern, dest string) (*File, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, errors.New(errors.CodeIO, "create temporary file", err).WithPath(dir)
+	}
+	if err := f.Chmod(0o600); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, errors.New(errors.CodeIO, "create temporary file", err).WithPath(f.Name())
+	}
+
+	return &File{File: f, path: f.Name(), dest: dest}, nil
+}
+
+func (t *File) Path() string {
+	return t.path
+}
+
+func (t *File) Commit() error {
+	if t.committed {
+		return nil
+	}
+	if t.dest == "" {
+		return fmt.Errorf("temporary file %s has no destination", t.path)
+	}
+
+	if err := t.Sync(); err != nil {
+		return errors.New(errors.CodeIO, "sync", err).WithPath(t.path)
+	}
+	if err := t.File.Close(); err != nil {
+		return errors.New(errors.CodeIO, "close", err).WithPath(t.path)
+	}
+	if t.inPlace {
+		t.committed = true
+		return nil
+	}
+	if err := os.Rename(t.path, t.dest); err != nil {
+		_ = os.Remove(t.path)
+		return errors.New(errors.CodeIO, "rename", err).WithPath(t.dest)
+	}
+	t.committed = true
+
+	if err := SyncDir(filepath.Dir(t.dest)); err != nil {
+		return errors.New(errors.CodeIO, "sync directory", err).WithPath(t.dest)
+	}
+	return nil
+}
+
+func (t *File) Abort() error {
+	if t.inPlace {
+		return t.Remove()
+	}
+	if t.committed {
+		if err := os.Remove(t.dest); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return t.Remove()
+}
+
+func (t *File) Remove() error {
+	if t.removed || t.committed {
+		return nil
+	}
+	t.removed = true
+	if t.inPlace {
+		return t.File.Close()
+	}
+
+	wipe(t.File)
+	_ = t.File.Close()
+	if err := os.Remove(t.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func wipe(f *os.File) {
+	info, err := f.Stat()
+	if err != nil {
+		return
+	}
+
+	zeros := make([]byte, 1024*1024)
+	for offset := int64(0); offset < info.Size(); offset += int64(len(zeros)) {
+		n := min(int64(len(zeros)), info.Size()-offset)
+		if _, err := f.WriteAt(zeros[:n], offset); err != nil {
+			return
+		}
+	}
+	_ = f.Sync()
+}
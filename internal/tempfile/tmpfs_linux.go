@@ -0,0 +1,13 @@
+package tempfile
+
+import "syscall"
+
+const tmpfsMagic = 0x01021994
+
+func isTmpfs(dir string) (bool, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return false, err
+	}
+	return stat.Type == tmpfsMagic, nil
+}
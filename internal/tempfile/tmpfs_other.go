@@ -0,0 +1,9 @@
+//go:build !linux
+
+package tempfile
+
+import "fmt"
+
+func isTmpfs(string) (bool, error) {
+	return false, fmt.Errorf("tmpfs detection is not supported on this platform")
+}
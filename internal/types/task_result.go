@@ -1,13 +1,25 @@
 package types
 
 type Task struct {
-	Data  []byte
-	Index uint64
+	Data   []byte
+	Index  uint64
+	Offset int64
 }
 
 type TaskResult struct {
-	Index uint64
-	Data  []byte
-	Size  int
-	Err   error
+	Index  uint64
+	Offset int64
+	Data   []byte
+	Source []byte
+	Size   int
+	Repair *ChunkRepair
+	Err    error
+}
+
+type ChunkRepair struct {
+	Index     uint64
+	Offset    int64
+	Length    int
+	Shards    []int
+	ShardSize int
 }
@@ -9,6 +9,7 @@ import (
 type ProgressBar struct {
 	bar         *progressbar.ProgressBar
 	description string
+	batch       *Batch
 }
 
 func NewProgressBar(totalSize int64, description string) *ProgressBar {
@@ -34,6 +35,24 @@ func NewProgressBar(totalSize int64, description string) *ProgressBar {
 	}
 }
 
+func NewBatchProgressBar(totalSize int64, description string, batch *Batch) *ProgressBar {
+	if batch == nil {
+		return NewProgressBar(totalSize, description)
+	}
+
+	p := NewProgressBar(totalSize, batch.describe(description))
+	p.description = description
+	p.batch = batch
+	return p
+}
+
 func (p *ProgressBar) Add(size int64) error {
+	if p == nil {
+		return nil
+	}
+	if p.batch != nil {
+		p.batch.add(size)
+		p.bar.Describe(p.batch.describe(p.description))
+	}
 	return p.bar.Add64(size)
 }
@@ -0,0 +1,60 @@
+package bar
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hambosto/sweetbyte/internal/utils"
+)
+
+type Batch struct {
+	mu        sync.Mutex
+	totalSize int64
+	done      int64
+	files     int
+	current   int
+	started   time.Time
+}
+
+func NewBatch(totalSize int64, files int) *Batch {
+	return &Batch{totalSize: totalSize, files: files, started: time.Now()}
+}
+
+func (b *Batch) NextFile() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.current++
+}
+
+func (b *Batch) Done() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.done
+}
+
+func (b *Batch) add(size int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done += size
+}
+
+func (b *Batch) describe(description string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	percent := 0.0
+	if b.totalSize > 0 {
+		percent = float64(b.done) / float64(b.totalSize) * 100
+	}
+
+	summary := fmt.Sprintf("[%d/%d · %.0f%% of %s", max(b.current, 1), b.files, percent, utils.FormatBytes(b.totalSize))
+	if elapsed := time.Since(b.started); b.done > 0 && b.done < b.totalSize {
+		remaining := time.Duration(float64(elapsed) / float64(b.done) * float64(b.totalSize-b.done))
+		summary += fmt.Sprintf(" · ~%s left", remaining.Round(time.Second))
+	}
+	return summary + "] " + description
+}
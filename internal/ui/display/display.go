@@ -2,19 +2,45 @@ package display
 
 import (
 	"fmt"
+	"os"
 	"strconv"
+	"strings"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/reporter"
 	"github.com/hambosto/sweetbyte/internal/types"
+	"github.com/hambosto/sweetbyte/internal/ui/bar"
 	"github.com/hambosto/sweetbyte/internal/utils"
 )
 
 var (
 	successStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	errorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	hintStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
 	boldStyle    = lipgloss.NewStyle().Bold(true)
 )
 
+const (
+	fileTableLimit  = 50
+	repairListLimit = 20
+)
+
+var (
+	quiet      bool
+	noProgress bool
+)
+
+func SetQuiet(enabled bool) {
+	quiet = enabled
+}
+
+func SetProgress(enabled bool) {
+	noProgress = !enabled
+}
+
 func ShowFileInfo(filePaths []string, fileSizes []int64, fileEncrypted []bool) error {
 	if len(filePaths) == 0 {
 		return fmt.Errorf("no files found")
@@ -29,7 +55,7 @@ func ShowFileInfo(filePaths []string, fileSizes []int64, fileEncrypted []bool) e
 	fmt.Println()
 
 	tableInfo := table.New().Headers("No", "Name", "Size", "Status").Border(lipgloss.NormalBorder()).BorderStyle(boldStyle)
-	for i := range filePaths {
+	for i := range min(len(filePaths), fileTableLimit) {
 		fileStatus := "unencrypted"
 		if fileEncrypted[i] {
 			fileStatus = "encrypted"
@@ -49,12 +75,19 @@ func ShowFileInfo(filePaths []string, fileSizes []int64, fileEncrypted []bool) e
 	}
 
 	fmt.Println(tableInfo)
+	if hidden := len(filePaths) - fileTableLimit; hidden > 0 {
+		fmt.Println(boldStyle.Render(fmt.Sprintf("... and %d more; the list below is split into pages", hidden)))
+	}
 	fmt.Println()
 
 	return nil
 }
 
 func ShowSuccessInfo(mode types.ProcessorMode, destPath string) {
+	if quiet {
+		return
+	}
+
 	action := "encrypted"
 	if mode == types.ModeDecrypt {
 		action = "decrypted"
@@ -65,7 +98,221 @@ func ShowSuccessInfo(mode types.ProcessorMode, destPath string) {
 	fmt.Println()
 }
 
+func ShowRekeyed(destPath string) {
+	if quiet {
+		return
+	}
+	fmt.Println()
+	fmt.Printf("%s %s ", successStyle.Render("✓"), boldStyle.Render(fmt.Sprintf("File re-encrypted with a new data key: %s", destPath)))
+	fmt.Println()
+}
+
 func ShowSourceDeleted(inputPath string) {
+	if quiet {
+		return
+	}
 	fmt.Printf("%s %s ", successStyle.Render("✓"), boldStyle.Render(fmt.Sprintf("Source file deleted: %s", inputPath)))
 	fmt.Println()
 }
+
+func ShowError(err error) {
+	fmt.Fprintf(os.Stderr, "%s %s\n", errorStyle.Render("✗"), boldStyle.Render(err.Error()))
+	if hint := hintFor(err); hint != "" {
+		fmt.Fprintf(os.Stderr, "  %s %s\n", hintStyle.Render("hint:"), hint)
+	}
+	if recovered := errors.RecoveredOf(err); recovered > 0 {
+		fmt.Fprintf(os.Stderr, "  %s %s of plaintext was recovered; re-run with --keep-partial to keep it.\n", hintStyle.Render("hint:"), utils.FormatBytes(recovered))
+	}
+}
+
+func ShowWarning(message string) {
+	fmt.Fprintf(os.Stderr, "%s %s\n", hintStyle.Render("!"), boldStyle.Render(message))
+}
+
+func ShowRepairReport(report processor.RepairReport) {
+	if !report.Repaired() {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "%s %s\n", hintStyle.Render("!"), boldStyle.Render(fmt.Sprintf("Repaired %d damaged chunk(s) in %s from Reed-Solomon parity (%s rebuilt, severity: %s)",
+		len(report.Chunks), report.Path, utils.FormatBytes(report.RepairedBytes), report.Severity)))
+	for _, chunk := range report.Chunks[:min(len(report.Chunks), repairListLimit)] {
+		ranges := make([]string, len(chunk.Ranges))
+		for i, r := range chunk.Ranges {
+			ranges[i] = fmt.Sprintf("%d-%d", r.Start, r.End-1)
+		}
+		fmt.Fprintf(os.Stderr, "  chunk %d at byte %d: %d of %d shards rebuilt, bytes %s (%s)\n",
+			chunk.Index, chunk.Offset, len(chunk.DamagedShards), chunk.Shards, strings.Join(ranges, ", "), chunk.Severity)
+	}
+	if hidden := len(report.Chunks) - repairListLimit; hidden > 0 {
+		fmt.Fprintf(os.Stderr, "  ... and %d more\n", hidden)
+	}
+	fmt.Fprintf(os.Stderr, "  %s the decrypted output is intact, but the encrypted file is degrading; replace it with a fresh copy.\n", hintStyle.Render("hint:"))
+}
+
+func ShowCancelHint() {
+	fmt.Println(hintStyle.Render("Press q or Esc to cancel."))
+}
+
+func ShowCanceled() {
+	fmt.Println()
+	fmt.Printf("%s %s ", hintStyle.Render("!"), boldStyle.Render("Operation canceled, returning to the menu."))
+	fmt.Println()
+	fmt.Println()
+}
+
+func ShowBookmarkSaved(name string) {
+	fmt.Printf("%s %s ", successStyle.Render("✓"), boldStyle.Render(fmt.Sprintf("Bookmark saved: %s", name)))
+	fmt.Println()
+}
+
+func ShowEstimate(mode types.ProcessorMode, inputSize, outputSize int64) {
+	if quiet {
+		return
+	}
+
+	label := "Estimated encrypted size"
+	if mode == types.ModeDecrypt {
+		label = "Expected decrypted size"
+	}
+
+	fmt.Printf("%s %s ", hintStyle.Render("→"), boldStyle.Render(fmt.Sprintf("%s: %s (input: %s)", label, utils.FormatBytes(outputSize), utils.FormatBytes(inputSize))))
+	fmt.Println()
+}
+
+func ShowEstimateDetails(estimate processor.Estimate, files int) {
+	parity := "none (--no-ecc)"
+	if estimate.ECC {
+		parity = utils.FormatBytes(estimate.ParitySize)
+	}
+
+	tableInfo := table.New().Border(lipgloss.NormalBorder()).BorderStyle(boldStyle).
+		Row("Input", fmt.Sprintf("%s in %d file(s)", utils.FormatBytes(estimate.InputSize), files)).
+		Row("Compressed data", fmt.Sprintf("%s (%.0f%% of input, sampled)", utils.FormatBytes(estimate.DataSize), estimate.CompressionRatio()*100)).
+		Row("Padding", utils.FormatBytes(estimate.PaddingSize)).
+		Row("Nonces and tags", fmt.Sprintf("%s (%s)", utils.FormatBytes(estimate.CipherSize), estimate.Suite.Description())).
+		Row("Reed-Solomon parity", parity).
+		Row("Header, framing, trailer", utils.FormatBytes(estimate.FramingSize)).
+		Row(boldStyle.Render("Estimated output"), boldStyle.Render(utils.FormatBytes(estimate.OutputSize)))
+	if estimate.ChunkSize > 0 {
+		tableInfo = tableInfo.Row("Chunks", fmt.Sprintf("%d of up to %s", estimate.Chunks, utils.FormatBytes(int64(estimate.ChunkSize))))
+	}
+
+	fmt.Println(tableInfo.Render())
+}
+
+type QueueEntry struct {
+	Mode    types.ProcessorMode
+	Input   string
+	Output  string
+	Done    bool
+	Skipped bool
+	Err     error
+}
+
+func ShowQueue(entries []QueueEntry) {
+	tableInfo := table.New().Headers("No", "Operation", "File", "Output", "Status").Border(lipgloss.NormalBorder()).BorderStyle(boldStyle)
+	for i, entry := range entries {
+		status := "queued"
+		switch {
+		case entry.Err != nil:
+			status = errorStyle.Render("failed: " + entry.Err.Error())
+		case entry.Skipped:
+			status = hintStyle.Render("skipped")
+		case entry.Done:
+			status = successStyle.Render("done")
+		}
+
+		tableInfo = tableInfo.Row(
+			boldStyle.Render(strconv.Itoa(i+1)),
+			boldStyle.Render(string(entry.Mode)),
+			successStyle.Render(entry.Input),
+			entry.Output,
+			status,
+		)
+	}
+
+	fmt.Println()
+	fmt.Println(tableInfo)
+	fmt.Println()
+}
+
+func ShowQueueSummary(entries []QueueEntry) {
+	var done, failed, skipped int
+	for _, entry := range entries {
+		switch {
+		case entry.Err != nil:
+			failed++
+		case entry.Skipped:
+			skipped++
+		case entry.Done:
+			done++
+		}
+	}
+
+	ShowQueue(entries)
+	style := successStyle
+	if failed > 0 {
+		style = errorStyle
+	}
+	fmt.Printf("%s %s\n", style.Render("■"), boldStyle.Render(fmt.Sprintf("Queue finished: %d succeeded, %d failed, %d skipped", done, failed, skipped)))
+}
+
+func ShowTreeSummary(entries []QueueEntry) {
+	var done, failed, skipped int
+	for _, entry := range entries {
+		switch {
+		case entry.Err != nil:
+			failed++
+			fmt.Printf("%s %s: %s\n", errorStyle.Render("✗"), entry.Input, entry.Err)
+		case entry.Skipped:
+			skipped++
+		case entry.Done:
+			done++
+		}
+	}
+	if quiet && failed == 0 {
+		return
+	}
+
+	style := successStyle
+	if failed > 0 {
+		style = errorStyle
+	}
+	fmt.Printf("%s %s\n", style.Render("■"), boldStyle.Render(fmt.Sprintf("Processed %d files: %d succeeded, %d failed, %d skipped", len(entries), done, failed, skipped)))
+}
+
+func ShowInfo(message string) {
+	if quiet {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s %s\n", successStyle.Render("i"), message)
+}
+
+type Reporter struct {
+	batch *bar.Batch
+	quiet bool
+}
+
+func NewReporter(batch *bar.Batch) *Reporter {
+	return &Reporter{batch: batch}
+}
+
+func NewQuietReporter() *Reporter {
+	return &Reporter{quiet: true}
+}
+
+func (r *Reporter) Progress(totalSize int64, description string) reporter.Progress {
+	if r.quiet || quiet || noProgress {
+		return reporter.Nop().Progress(totalSize, description)
+	}
+	return bar.NewBatchProgressBar(totalSize, description, r.batch)
+}
+
+func (r *Reporter) Info(message string) {
+	ShowInfo(message)
+}
+
+func (r *Reporter) Warn(message string) {
+	ShowWarning(message)
+}
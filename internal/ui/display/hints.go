@@ -0,0 +1,67 @@
+package display
+
+import (
+	"github.com/hambosto/sweetbyte/internal/archive"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/header"
+	"github.com/hambosto/sweetbyte/internal/keyfile"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/recipient"
+	"github.com/hambosto/sweetbyte/internal/scrub"
+	"github.com/hambosto/sweetbyte/internal/server"
+	"github.com/hambosto/sweetbyte/internal/service"
+	"github.com/hambosto/sweetbyte/internal/stream/chunk"
+	"github.com/hambosto/sweetbyte/internal/ui/prompt"
+)
+
+type hint struct {
+	target  error
+	message string
+}
+
+var hints = []hint{
+	{file.ErrOutputExists, "Choose another path with -o, or pass --force to overwrite it."},
+	{file.ErrNotFound, "Check the spelling of the input path and that it is relative to the current directory."},
+	{file.ErrIsDirectory, "SweetByte processes single files; pass a file path with -i, or use --recursive or --archive for a directory."},
+	{file.ErrEmptyFile, "Empty files have nothing to protect; check that the file was written completely."},
+	{file.ErrNoEligibleFiles, "Run SweetByte from the directory containing your files and check that they are not matched by the exclusion patterns."},
+	{processor.ErrAuthentication, "Wrong password or wrong keyfile. If the credentials are correct, the header may be damaged beyond repair."},
+	{processor.ErrMissingFactor, "This file was encrypted with --require-both; supply the password and the keyfile with --keyfile."},
+	{processor.ErrNeedsKeyfile, "Supply the keyfile that was used to encrypt this file with --keyfile, along with the password."},
+	{processor.ErrNeedsIdentity, "This file was encrypted with --recipient; decrypt it with --identity and the private key generated alongside that public key."},
+	{recipient.ErrWrongIdentity, "The file was encrypted to a different public key; use the identity whose .pub file was given to --recipient."},
+	{archive.ErrNotArchive, "This file holds a single encrypted file rather than an archive; decrypt it with sweetbyte decrypt."},
+	{archive.ErrUnsafePath, "The archive was not made by encrypt --archive or was crafted to write elsewhere; nothing was written outside the destination."},
+	{chunk.ErrTruncated, "The end of the file is missing, usually from an interrupted copy or download; copy it again, or recover what is left with sweetbyte salvage."},
+	{chunk.ErrTrailerMismatch, "Every chunk decrypted but the set of chunks is not the one that was written; restore the file from another copy."},
+	{header.ErrNewerFormat, "The file was written by a newer release of SweetByte; upgrade to decrypt it."},
+	{processor.ErrMismatch, "The encrypted file decrypted cleanly but holds different content; the plaintext has changed since it was encrypted, or the two files are not a pair."},
+	{processor.ErrRollback, "A newer version of this file was expected; it may have been restored from an old backup or swapped."},
+	{processor.ErrDataLost, "The recovered output was still written; lost ranges are zero-filled unless --skip-lost was given."},
+	{scrub.ErrRepairable, "Run sweetbyte repair on each damaged file to rewrite it from parity, and replace the storage if damage keeps appearing."},
+	{file.ErrPunchUnsupported, "In-place encryption needs Linux and a filesystem that can free blocks inside a file (ext4, XFS, Btrfs, tmpfs); encrypt normally instead."},
+	{processor.ErrSourceChanged, "Another process was writing to the source, so it was not deleted. Retry once the writer has finished."},
+	{server.ErrPlainHTTP, "Pass --tls-cert and --tls-key, or listen on 127.0.0.1 behind a TLS-terminating proxy."},
+	{server.ErrUnsafeSocketDir, "Another user could swap the socket in it. Remove the directory if you created it yourself, or pass --socket with a path in a directory only you can access."},
+	{service.ErrNotInstalled, "Install it first with sweetbyte service install."},
+	{service.ErrUnsupported, "Run sweetbyte daemon from your own init system or scheduler instead."},
+	{keyfile.ErrWrongPassphrase, "The keyfile is protected by its own passphrase, which may differ from the file password."},
+	{prompt.ErrPasswordTooShort, "Use a longer passphrase; several random words are easier to remember than symbols."},
+	{prompt.ErrPasswordMismatch, "Both entries must match exactly; re-run and type the password again."},
+}
+
+var codeHints = map[errors.Code]string{
+	errors.CodeCorrupt:        "The file is damaged beyond what Reed-Solomon can repair; restore it from a backup copy.",
+	errors.CodeAuthentication: "The data failed authentication: it was modified, or the credentials are wrong.",
+	errors.CodeCanceled:       "The operation was canceled before it completed.",
+}
+
+func hintFor(err error) string {
+	for _, h := range hints {
+		if errors.Is(err, h.target) {
+			return h.message
+		}
+	}
+	return codeHints[errors.CodeOf(err)]
+}
@@ -1,15 +1,72 @@
 package prompt
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/charmbracelet/huh"
+	"github.com/hambosto/sweetbyte/internal/config"
 	"github.com/hambosto/sweetbyte/internal/types"
+	"github.com/hambosto/sweetbyte/internal/utils"
 )
 
 const passwordMinLength = 8
 
+const (
+	LocationCurrent      = "."
+	LocationBrowse       = "\x00browse"
+	LocationSaveBookmark = "\x00save-bookmark"
+	SelectAll            = "\x00all"
+)
+
+const filePageSize = 50
+
+const (
+	pageNext     = "\x00next-page"
+	pagePrevious = "\x00previous-page"
+	browseHere   = "\x00here"
+	browseParent = "\x00parent"
+	listHeight   = 15
+)
+
+const (
+	depthAll      = 0
+	depthCurrent  = 1
+	depthTwoLevel = 2
+)
+
+const ModeQueue types.ProcessorMode = "Batch queue"
+
+const (
+	QueueAdd     = "add"
+	QueueRun     = "run"
+	QueueDiscard = "discard"
+)
+
+type JobOptions struct {
+	PreserveTimes bool
+	Verify        bool
+	DeleteSource  bool
+}
+
+const (
+	jobPreserveTimes = "preserve-times"
+	jobVerify        = "verify"
+	jobDeleteSource  = "delete-source"
+)
+
+var (
+	ErrNoFilesSelected  = errors.New("no files selected")
+	ErrPasswordTooShort = fmt.Errorf("password must be at least %d characters", passwordMinLength)
+	ErrPasswordEmpty    = errors.New("password cannot be empty")
+	ErrPasswordMismatch = errors.New("password mismatch")
+)
+
 func ConfirmFileOverwrite(path string) (bool, error) {
 	var confirm bool
 	if err := huh.NewConfirm().
@@ -22,7 +79,39 @@ func ConfirmFileOverwrite(path string) (bool, error) {
 	return confirm, nil
 }
 
-func GetEncryptionPassword() (string, error) {
+func ConfirmEstimate(outputSize int64) (bool, error) {
+	confirm := true
+	if err := huh.NewConfirm().
+		Title(fmt.Sprintf("The encrypted output will take about %s. Continue?", utils.FormatBytes(outputSize))).
+		Value(&confirm).
+		WithTheme(huh.ThemeCatppuccin()).
+		Run(); err != nil {
+		return false, fmt.Errorf("confirmation failed: %w", err)
+	}
+	return confirm, nil
+}
+
+// GetEncryptionPassword returns the password as bytes the caller can wipe.
+// The prompt's own copy of the typed string cannot be.
+func GetEncryptionPassword() ([]byte, error) {
+	return getEncryptionPassword(true)
+}
+
+func GetEncryptionPasswordOnce() ([]byte, error) {
+	return getEncryptionPassword(false)
+}
+
+func ValidateEncryptionPassword(password []byte) error {
+	if len(password) < passwordMinLength {
+		return ErrPasswordTooShort
+	}
+	if len(bytes.TrimSpace(password)) == 0 {
+		return ErrPasswordEmpty
+	}
+	return nil
+}
+
+func getEncryptionPassword(confirm bool) ([]byte, error) {
 	var password string
 	if err := huh.NewInput().
 		Title("Enter encryption password:").
@@ -30,34 +119,37 @@ func GetEncryptionPassword() (string, error) {
 		Value(&password).
 		WithTheme(huh.ThemeCatppuccin()).
 		Run(); err != nil {
-		return "", fmt.Errorf("password prompt failed: %w", err)
+		return nil, fmt.Errorf("password prompt failed: %w", err)
 	}
 
 	if len(password) < passwordMinLength {
-		return "", fmt.Errorf("password must be at least %d characters", passwordMinLength)
+		return nil, ErrPasswordTooShort
 	}
 	if strings.TrimSpace(password) == "" {
-		return "", fmt.Errorf("password cannot be empty")
+		return nil, ErrPasswordEmpty
+	}
+	if !confirm {
+		return []byte(password), nil
 	}
 
-	var confirm string
+	var confirmation string
 	if err := huh.NewInput().
 		Title("Confirm password:").
 		EchoMode(huh.EchoModePassword).
-		Value(&confirm).
+		Value(&confirmation).
 		WithTheme(huh.ThemeCatppuccin()).
 		Run(); err != nil {
-		return "", fmt.Errorf("password prompt failed: %w", err)
+		return nil, fmt.Errorf("password prompt failed: %w", err)
 	}
 
-	if password != confirm {
-		return "", fmt.Errorf("password mismatch")
+	if password != confirmation {
+		return nil, ErrPasswordMismatch
 	}
 
-	return password, nil
+	return []byte(password), nil
 }
 
-func GetDecryptionPassword() (string, error) {
+func GetDecryptionPassword() ([]byte, error) {
 	var password string
 	if err := huh.NewInput().
 		Title("Enter decryption password:").
@@ -65,14 +157,64 @@ func GetDecryptionPassword() (string, error) {
 		Value(&password).
 		WithTheme(huh.ThemeCatppuccin()).
 		Run(); err != nil {
-		return "", fmt.Errorf("password prompt failed: %w", err)
+		return nil, fmt.Errorf("password prompt failed: %w", err)
 	}
 
 	if strings.TrimSpace(password) == "" {
-		return "", fmt.Errorf("password cannot be empty")
+		return nil, ErrPasswordEmpty
 	}
 
-	return password, nil
+	return []byte(password), nil
+}
+
+func GetKeyfilePassphrase() (string, error) {
+	var passphrase string
+	if err := huh.NewInput().
+		Title("Enter keyfile passphrase:").
+		EchoMode(huh.EchoModePassword).
+		Value(&passphrase).
+		WithTheme(huh.ThemeCatppuccin()).
+		Run(); err != nil {
+		return "", fmt.Errorf("passphrase prompt failed: %w", err)
+	}
+
+	if len(passphrase) < passwordMinLength {
+		return "", ErrPasswordTooShort
+	}
+
+	var confirm string
+	if err := huh.NewInput().
+		Title("Confirm keyfile passphrase:").
+		EchoMode(huh.EchoModePassword).
+		Value(&confirm).
+		WithTheme(huh.ThemeCatppuccin()).
+		Run(); err != nil {
+		return "", fmt.Errorf("passphrase prompt failed: %w", err)
+	}
+
+	if passphrase != confirm {
+		return "", ErrPasswordMismatch
+	}
+
+	return passphrase, nil
+}
+
+func GetKeyfileUnlockPassphrase(path string) (string, error) {
+	var passphrase string
+	if err := huh.NewInput().
+		Title(fmt.Sprintf("Enter passphrase for keyfile %s:", path)).
+		EchoMode(huh.EchoModePassword).
+		Value(&passphrase).
+		WithTheme(huh.ThemeCatppuccin()).
+		Run(); err != nil {
+		return "", fmt.Errorf("passphrase prompt failed: %w", err)
+	}
+
+	if strings.TrimSpace(passphrase) == "" {
+		return "", ErrPasswordEmpty
+	}
+
+	return passphrase, nil
 }
 
 func ConfirmFileRemoval(path, fileType string) (bool, error) {
@@ -93,10 +235,21 @@ func ConfirmFileRemoval(path, fileType string) (bool, error) {
 }
 
 func GetProcessingMode() (types.ProcessorMode, error) {
+	return chooseProcessingMode(true)
+}
+
+func GetJobMode() (types.ProcessorMode, error) {
+	return chooseProcessingMode(false)
+}
+
+func chooseProcessingMode(withQueue bool) (types.ProcessorMode, error) {
 	options := []huh.Option[string]{
 		huh.NewOption(string(types.ModeEncrypt), string(types.ModeEncrypt)),
 		huh.NewOption(string(types.ModeDecrypt), string(types.ModeDecrypt)),
 	}
+	if withQueue {
+		options = append(options, huh.NewOption("Batch queue (mix encrypt and decrypt jobs)", string(ModeQueue)))
+	}
 
 	var selected string
 	if err := huh.NewSelect[string]().
@@ -111,25 +264,282 @@ func GetProcessingMode() (types.ProcessorMode, error) {
 	return types.ProcessorMode(selected), nil
 }
 
+func GetJobOptions(mode types.ProcessorMode) (JobOptions, error) {
+	options := []huh.Option[string]{
+		huh.NewOption("Preserve timestamps", jobPreserveTimes).Selected(true),
+	}
+	if mode == types.ModeEncrypt {
+		options = append(options, huh.NewOption("Verify after encrypting", jobVerify))
+	}
+	options = append(options, huh.NewOption("Delete source when done", jobDeleteSource))
+
+	var selected []string
+	if err := huh.NewMultiSelect[string]().
+		Title("Job options:").
+		Options(options...).
+		Value(&selected).
+		WithTheme(huh.ThemeCatppuccin()).
+		Run(); err != nil {
+		return JobOptions{}, fmt.Errorf("option selection failed: %w", err)
+	}
+
+	return JobOptions{
+		PreserveTimes: slices.Contains(selected, jobPreserveTimes),
+		Verify:        slices.Contains(selected, jobVerify),
+		DeleteSource:  slices.Contains(selected, jobDeleteSource),
+	}, nil
+}
+
+func ConfirmReusePassword(mode types.ProcessorMode) (bool, error) {
+	var confirm bool
+	if err := huh.NewConfirm().
+		Title(fmt.Sprintf("Reuse the password of the previous %s job?", strings.ToLower(string(mode)))).
+		Value(&confirm).
+		WithTheme(huh.ThemeCatppuccin()).
+		Run(); err != nil {
+		return false, fmt.Errorf("confirmation failed: %w", err)
+	}
+	return confirm, nil
+}
+
+func ChooseQueueAction(jobs int) (string, error) {
+	options := []huh.Option[string]{
+		huh.NewOption("Add another job", QueueAdd),
+		huh.NewOption(fmt.Sprintf("Run %d job(s)", jobs), QueueRun),
+		huh.NewOption("Discard the queue", QueueDiscard),
+	}
+
+	var selected string
+	if err := huh.NewSelect[string]().
+		Title("Queue:").
+		Options(options...).
+		Value(&selected).
+		WithTheme(huh.ThemeCatppuccin()).
+		Run(); err != nil {
+		return "", fmt.Errorf("queue action selection failed: %w", err)
+	}
+
+	return selected, nil
+}
+
 func ChooseFile(fileList []string) (string, error) {
+	return chooseFile(fileList)
+}
+
+func chooseFile(fileList []string) (string, error) {
 	if len(fileList) == 0 {
 		return "", fmt.Errorf("no options available for selection")
 	}
 
-	options := make([]huh.Option[string], len(fileList))
-	for i, file := range fileList {
-		options[i] = huh.NewOption(file, file)
+	pages := (len(fileList) + filePageSize - 1) / filePageSize
+	for page := 0; ; {
+		start := page * filePageSize
+		end := min(start+filePageSize, len(fileList))
+
+		options := make([]huh.Option[string], 0, end-start+2)
+		if page > 0 {
+			options = append(options, huh.NewOption("Previous page", pagePrevious))
+		}
+		for _, file := range fileList[start:end] {
+			options = append(options, huh.NewOption(file, file))
+		}
+		if page < pages-1 {
+			options = append(options, huh.NewOption(fmt.Sprintf("Next page (%d more files)", len(fileList)-end), pageNext))
+		}
+
+		title := "Select file:"
+		if pages > 1 {
+			title = fmt.Sprintf("Select file (%d-%d of %d, page %d of %d):", start+1, end, len(fileList), page+1, pages)
+		}
+
+		var selected string
+		if err := huh.NewSelect[string]().
+			Title(title).
+			Options(options...).
+			Value(&selected).
+			Height(listHeight).
+			Filtering(true).
+			WithTheme(huh.ThemeCatppuccin()).
+			Run(); err != nil {
+			return "", fmt.Errorf("selection failed: %w", err)
+		}
+
+		switch selected {
+		case pageNext:
+			page++
+		case pagePrevious:
+			page--
+		default:
+			return selected, nil
+		}
+	}
+}
+
+func ChooseFiles(fileList []string) ([]string, error) {
+	if len(fileList) == 0 {
+		return nil, fmt.Errorf("no options available for selection")
+	}
+
+	chosen := make(map[string]bool)
+	pages := (len(fileList) + filePageSize - 1) / filePageSize
+	for page := 0; ; {
+		start := page * filePageSize
+		end := min(start+filePageSize, len(fileList))
+
+		options := make([]huh.Option[string], 0, end-start+3)
+		if len(fileList) > 1 {
+			options = append(options, huh.NewOption(fmt.Sprintf("All %d files in this directory tree", len(fileList)), SelectAll))
+		}
+		if page > 0 {
+			options = append(options, huh.NewOption("Previous page", pagePrevious))
+		}
+		for _, file := range fileList[start:end] {
+			options = append(options, huh.NewOption(file, file).Selected(chosen[file]))
+		}
+		if page < pages-1 {
+			options = append(options, huh.NewOption(fmt.Sprintf("Next page (%d more files)", len(fileList)-end), pageNext))
+		}
+
+		title := "Select files:"
+		if pages > 1 {
+			title = fmt.Sprintf("Select files (%d-%d of %d, page %d of %d):", start+1, end, len(fileList), page+1, pages)
+		}
+
+		var selected []string
+		if err := huh.NewMultiSelect[string]().
+			Title(title).
+			Description("Space to select, Enter to confirm; tick a page entry to keep browsing").
+			Options(options...).
+			Value(&selected).
+			Height(listHeight).
+			Filterable(true).
+			WithTheme(huh.ThemeCatppuccin()).
+			Run(); err != nil {
+			return nil, fmt.Errorf("selection failed: %w", err)
+		}
+
+		if slices.Contains(selected, SelectAll) {
+			return []string{SelectAll}, nil
+		}
+		for _, file := range fileList[start:end] {
+			chosen[file] = slices.Contains(selected, file)
+		}
+
+		switch {
+		case slices.Contains(selected, pageNext):
+			page++
+		case slices.Contains(selected, pagePrevious):
+			page--
+		default:
+			files := slices.DeleteFunc(slices.Clone(fileList), func(file string) bool { return !chosen[file] })
+			if len(files) == 0 {
+				return nil, ErrNoFilesSelected
+			}
+			return files, nil
+		}
+	}
+}
+
+func ChooseScanDepth() (int, error) {
+	options := []huh.Option[int]{
+		huh.NewOption("Whole directory tree", depthAll),
+		huh.NewOption("This directory only", depthCurrent),
+		huh.NewOption("This directory and its subdirectories", depthTwoLevel),
+	}
+
+	var selected int
+	if err := huh.NewSelect[int]().
+		Title("Scan depth:").
+		Options(options...).
+		Value(&selected).
+		WithTheme(huh.ThemeCatppuccin()).
+		Run(); err != nil {
+		return 0, fmt.Errorf("depth selection failed: %w", err)
 	}
 
+	return selected, nil
+}
+
+func ChooseDirectory(start string) (string, error) {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", start, err)
+	}
+
+	for {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", dir, err)
+		}
+
+		options := []huh.Option[string]{huh.NewOption("Use this directory", browseHere)}
+		if parent := filepath.Dir(dir); parent != dir {
+			options = append(options, huh.NewOption(".. (parent directory)", browseParent))
+		}
+		for _, entry := range entries {
+			if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+				options = append(options, huh.NewOption(entry.Name()+string(filepath.Separator), entry.Name()))
+			}
+		}
+
+		var selected string
+		if err := huh.NewSelect[string]().
+			Title(dir).
+			Options(options...).
+			Value(&selected).
+			Height(listHeight).
+			Filtering(true).
+			WithTheme(huh.ThemeCatppuccin()).
+			Run(); err != nil {
+			return "", fmt.Errorf("directory selection failed: %w", err)
+		}
+
+		switch selected {
+		case browseHere:
+			return dir, nil
+		case browseParent:
+			dir = filepath.Dir(dir)
+		default:
+			dir = filepath.Join(dir, selected)
+		}
+	}
+}
+
+func ChooseLocation(bookmarks []config.Bookmark) (string, error) {
+	options := make([]huh.Option[string], 0, len(bookmarks)+3)
+	options = append(options, huh.NewOption("Current directory", LocationCurrent))
+	for _, bookmark := range bookmarks {
+		options = append(options, huh.NewOption(fmt.Sprintf("%s (%s)", bookmark.Name, bookmark.Path), bookmark.Path))
+	}
+	options = append(options, huh.NewOption("Browse for a directory...", LocationBrowse))
+	options = append(options, huh.NewOption("Bookmark current directory...", LocationSaveBookmark))
+
 	var selected string
 	if err := huh.NewSelect[string]().
-		Title("Select file:").
+		Title("Select location:").
 		Options(options...).
 		Value(&selected).
 		WithTheme(huh.ThemeCatppuccin()).
 		Run(); err != nil {
-		return "", fmt.Errorf("selection failed: %w", err)
+		return "", fmt.Errorf("location selection failed: %w", err)
 	}
 
 	return selected, nil
 }
+
+func GetBookmarkName() (string, error) {
+	var name string
+	if err := huh.NewInput().
+		Title("Bookmark name:").
+		Value(&name).
+		WithTheme(huh.ThemeCatppuccin()).
+		Run(); err != nil {
+		return "", fmt.Errorf("bookmark prompt failed: %w", err)
+	}
+
+	if strings.TrimSpace(name) == "" {
+		return "", fmt.Errorf("bookmark name cannot be empty")
+	}
+
+	return strings.TrimSpace(name), nil
+}
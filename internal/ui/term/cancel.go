@@ -0,0 +1,68 @@
+package term
+
+import (
+	"context"
+	"os"
+
+	"github.com/muesli/cancelreader"
+	xterm "golang.org/x/term"
+)
+
+const (
+	keyCtrlC  = 0x03
+	keyEscape = 0x1b
+)
+
+func WatchCancelKeys(cancel context.CancelFunc) (stop func()) {
+	fd := int(os.Stdin.Fd())
+	if !xterm.IsTerminal(fd) {
+		return func() {}
+	}
+
+	state, err := xterm.MakeRaw(fd)
+	if err != nil {
+		return func() {}
+	}
+
+	reader, err := cancelreader.NewReader(os.Stdin)
+	if err != nil {
+		_ = xterm.Restore(fd, state)
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		buffer := make([]byte, 8)
+		for {
+			n, err := reader.Read(buffer)
+			if err != nil {
+				return
+			}
+			if isCancelKey(buffer[:n]) {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	return func() {
+		reader.Cancel()
+		<-done
+		_ = reader.Close()
+		_ = xterm.Restore(fd, state)
+	}
+}
+
+func isCancelKey(input []byte) bool {
+	if len(input) == 1 && input[0] == keyEscape {
+		return true
+	}
+	for _, b := range input {
+		if b == 'q' || b == 'Q' || b == keyCtrlC {
+			return true
+		}
+	}
+	return false
+}
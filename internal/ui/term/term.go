@@ -7,8 +7,13 @@ import (
 	"runtime"
 
 	"github.com/charmbracelet/lipgloss"
+	xterm "golang.org/x/term"
 )
 
+func IsInteractive() bool {
+	return xterm.IsTerminal(int(os.Stdin.Fd()))
+}
+
 func Clear() error {
 	var cmd *exec.Cmd
 	switch runtime.GOOS {
@@ -0,0 +1,193 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hambosto/sweetbyte/internal/file"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/types"
+)
+
+type Rule struct {
+	Path         string
+	Output       string
+	Password     []byte
+	DeleteSource bool
+	Options      processor.Options
+}
+
+func (r Rule) destination(name string) string {
+	if r.Output == "" {
+		return file.GetOutputPath(filepath.Join(r.Path, name), types.ModeEncrypt)
+	}
+	return file.GetOutputPath(filepath.Join(r.Output, name), types.ModeEncrypt)
+}
+
+type observation struct {
+	size    int64
+	modTime time.Time
+	since   time.Time
+	failed  bool
+}
+
+type Watcher struct {
+	rules    []Rule
+	interval time.Duration
+	debounce time.Duration
+	logger   *slog.Logger
+	seen     map[string]*observation
+}
+
+func New(rules []Rule, interval, debounce time.Duration, logger *slog.Logger) (*Watcher, error) {
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no watch rules configured")
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("poll interval must be positive, got %s", interval)
+	}
+	if debounce < 0 {
+		return nil, fmt.Errorf("debounce cannot be negative, got %s", debounce)
+	}
+
+	for _, rule := range rules {
+		info, err := os.Stat(rule.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to access %s: %w", rule.Path, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("watch path is not a directory: %s", rule.Path)
+		}
+	}
+
+	return &Watcher{
+		rules:    rules,
+		interval: interval,
+		debounce: debounce,
+		logger:   logger,
+		seen:     make(map[string]*observation),
+	}, nil
+}
+
+func (w *Watcher) Run(ctx context.Context) error {
+	paths := make([]string, len(w.rules))
+	for i, rule := range w.rules {
+		paths[i] = rule.Path
+	}
+	w.logger.Info("watch started", "paths", strings.Join(paths, ","), "interval", w.interval, "debounce", w.debounce)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.Scan(ctx)
+
+		select {
+		case <-ctx.Done():
+			w.logger.Info("watch stopped")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Watcher) Scan(ctx context.Context) {
+	now := time.Now()
+	present := make(map[string]bool)
+
+	for _, rule := range w.rules {
+		entries, err := os.ReadDir(rule.Path)
+		if err != nil {
+			w.logger.Error("failed to read directory", "path", rule.Path, "error", err)
+			continue
+		}
+
+		for _, entry := range entries {
+			if ctx.Err() != nil {
+				return
+			}
+			if !entry.Type().IsRegular() || !eligible(entry.Name()) {
+				continue
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			path := filepath.Join(rule.Path, entry.Name())
+			present[path] = true
+
+			if !w.settled(path, info, now) {
+				continue
+			}
+			dest := rule.destination(entry.Name())
+			if upToDate(info, dest) {
+				continue
+			}
+			w.encrypt(ctx, rule, path, dest)
+		}
+	}
+
+	for path := range w.seen {
+		if !present[path] {
+			delete(w.seen, path)
+		}
+	}
+}
+
+func (w *Watcher) settled(path string, info os.FileInfo, now time.Time) bool {
+	seen, ok := w.seen[path]
+	if !ok || seen.size != info.Size() || !seen.modTime.Equal(info.ModTime()) {
+		w.seen[path] = &observation{size: info.Size(), modTime: info.ModTime(), since: now}
+		return false
+	}
+	return !seen.failed && now.Sub(seen.since) >= w.debounce
+}
+
+func (w *Watcher) encrypt(ctx context.Context, rule Rule, path, dest string) {
+	opts := rule.Options
+	if rule.DeleteSource {
+		opts.Paranoid = true
+	}
+
+	started := time.Now()
+	if err := processor.Encryption(ctx, path, dest, rule.Password, opts); err != nil {
+		if ctx.Err() != nil {
+			w.logger.Info("encryption interrupted", "path", path)
+			return
+		}
+		w.logger.Error("encryption failed", "path", path, "error", err)
+		if seen, ok := w.seen[path]; ok {
+			seen.failed = true
+		}
+		return
+	}
+	w.logger.Info("file encrypted", "path", path, "output", dest, "duration", time.Since(started).Round(time.Millisecond))
+
+	if !rule.DeleteSource {
+		return
+	}
+	if err := file.Remove(path); err != nil {
+		w.logger.Error("failed to delete source", "path", path, "error", err)
+		return
+	}
+	delete(w.seen, path)
+	w.logger.Debug("source deleted", "path", path)
+}
+
+func eligible(name string) bool {
+	return !strings.HasPrefix(name, ".") && !file.IsEncryptedFile(name) && !file.IsArchive(name)
+}
+
+func upToDate(source os.FileInfo, dest string) bool {
+	info, err := os.Stat(dest)
+	if err != nil {
+		return false
+	}
+	return !source.ModTime().After(info.ModTime())
+}
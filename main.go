@@ -5,15 +5,20 @@ import (
 
 	"github.com/hambosto/sweetbyte/cmd/cli"
 	"github.com/hambosto/sweetbyte/cmd/interactive"
+	"github.com/hambosto/sweetbyte/internal/config"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/tempfile"
 )
 
 func main() {
 	if len(os.Args) > 1 {
 		cliApp := cli.NewCLI()
 		if err := cliApp.Execute(); err != nil {
-			os.Exit(1)
+			os.Exit(errors.ExitCode(err))
 		}
 	} else {
+		temp := config.LoadTemp()
+		tempfile.SetOptions(tempfile.Options{Dir: temp.Dir, RequireTmpfs: temp.RequireTmpfs})
 		interactive.Run()
 	}
 }
@@ -0,0 +1,93 @@
+package sweetbyte
+
+import (
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/recipient"
+)
+
+// Code classifies what went wrong, independently of the operation that
+// failed. The CLI exits with a distinct status for each.
+type Code int
+
+const (
+	CodeUnknown Code = iota
+	CodeInvalidInput
+	CodeNotFound
+	CodeExists
+	CodeIO
+	CodeAuthentication
+	CodeCorrupt
+	CodeCanceled
+	CodeUnsupported
+)
+
+func (c Code) String() string {
+	return errors.Code(c).String()
+}
+
+// Error is the type of the errors returned by this package. It wraps the
+// underlying error, so errors.Is still matches the Err variables below.
+type Error struct {
+	Code Code
+	// Path is the file the error concerns, if any.
+	Path string
+	// Chunk and Offset locate damage in an encrypted file. They are -1
+	// when the error is not tied to a place in the file.
+	Chunk  int64
+	Offset int64
+
+	err error
+}
+
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// ErrNoKey is returned when encrypting without a password, keyfile or
+// recipient.
+var ErrNoKey = errors.Sentinel("no password, keyfile or recipient given")
+
+var (
+	// ErrAuthentication means the password, keyfile or identity is wrong,
+	// or the header was tampered with.
+	ErrAuthentication = processor.ErrAuthentication
+	// ErrMissingFactor means the file needs both a password and a keyfile
+	// and only one was given.
+	ErrMissingFactor = processor.ErrMissingFactor
+	// ErrNeedsKeyfile means the file was encrypted with a keyfile and none
+	// was given.
+	ErrNeedsKeyfile = processor.ErrNeedsKeyfile
+	// ErrNeedsIdentity means the file was encrypted to a recipient and no
+	// identity was given.
+	ErrNeedsIdentity = processor.ErrNeedsIdentity
+	// ErrWrongIdentity means the identity does not match the file's
+	// recipient.
+	ErrWrongIdentity = recipient.ErrWrongIdentity
+	// ErrRollback means the file was not created after
+	// Options.ExpectAfter, or records no creation time.
+	ErrRollback = processor.ErrRollback
+)
+
+// CodeOf returns the code of err, or CodeUnknown if it has none.
+func CodeOf(err error) Code {
+	return Code(errors.CodeOf(err))
+}
+
+// wrap turns an error from the internal packages into an *Error.
+func wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{
+		Code:   CodeOf(err),
+		Path:   errors.PathOf(err),
+		Chunk:  errors.ChunkOf(err),
+		Offset: errors.OffsetOf(err),
+		err:    err,
+	}
+}
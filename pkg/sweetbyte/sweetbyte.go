@@ -0,0 +1,236 @@
+// Package sweetbyte encrypts and decrypts files and streams in the format
+// of the sweetbyte command, for Go programs that would otherwise shell out
+// to it.
+package sweetbyte
+
+import (
+	"context"
+	"crypto/ecdh"
+	"io"
+	"time"
+
+	"github.com/hambosto/sweetbyte/internal/derive"
+	"github.com/hambosto/sweetbyte/internal/errors"
+	"github.com/hambosto/sweetbyte/internal/processor"
+	"github.com/hambosto/sweetbyte/internal/recipient"
+	"github.com/hambosto/sweetbyte/internal/reporter"
+)
+
+// Argon2id profiles for Options.KDFProfile. The profile is recorded in the
+// file, so decrypting needs no profile.
+const (
+	KDFLight    = derive.ProfileLight
+	KDFDefault  = derive.ProfileDefault
+	KDFParanoid = derive.ProfileParanoid
+)
+
+// Reporter receives progress and messages while a file is processed.
+type Reporter interface {
+	// Progress starts tracking totalSize bytes of work, or an unknown
+	// amount when totalSize is -1.
+	Progress(totalSize int64, description string) Progress
+	Info(message string)
+	Warn(message string)
+}
+
+// Progress is told about each chunk processed, with its size in bytes.
+type Progress interface {
+	Add(size int64) error
+}
+
+// Stats describes the progress of an operation after a chunk.
+type Stats struct {
+	Description string
+	Done        int64
+	Total       int64
+	Chunks      int
+	Elapsed     time.Duration
+	// Throughput is in bytes per second.
+	Throughput float64
+	ETA        time.Duration
+}
+
+// Percent returns how much of Total is done, or 0 when Total is unknown.
+func (s Stats) Percent() float64 {
+	return reporter.Stats(s).Percent()
+}
+
+func (s Stats) MBPerSecond() float64 {
+	return reporter.Stats(s).MBPerSecond()
+}
+
+// Callbacks is a Reporter that calls the functions that are set.
+type Callbacks struct {
+	OnProgress func(Stats)
+	OnInfo     func(message string)
+	OnWarn     func(message string)
+}
+
+func (c Callbacks) Progress(totalSize int64, description string) Progress {
+	return c.reporter().Progress(totalSize, description)
+}
+
+func (c Callbacks) Info(message string) {
+	c.reporter().Info(message)
+}
+
+func (c Callbacks) Warn(message string) {
+	c.reporter().Warn(message)
+}
+
+func (c Callbacks) reporter() reporter.Callbacks {
+	callbacks := reporter.Callbacks{OnInfo: c.OnInfo, OnWarn: c.OnWarn}
+	if c.OnProgress != nil {
+		callbacks.OnProgress = func(s reporter.Stats) { c.OnProgress(Stats(s)) }
+	}
+	return callbacks
+}
+
+// Options configures encryption and decryption. The zero value needs only
+// a key: a Password, a Keyfile or, to encrypt, a Recipient.
+type Options struct {
+	// Password is read but not kept; the caller can wipe it once the call
+	// returns.
+	Password []byte
+	Keyfile  []byte
+	// RequireBoth makes the file need both the password and the keyfile.
+	RequireBoth bool
+	// Recipient encrypts to a public key instead of a password, and
+	// Identity is the private key that decrypts such a file.
+	Recipient *ecdh.PublicKey
+	Identity  *ecdh.PrivateKey
+	// KDFProfile is one of the KDF constants; empty means KDFDefault.
+	KDFProfile string
+	// Cipher is "cascade", "aes-gcm", "xchacha20" or "auto"; empty means
+	// "cascade".
+	Cipher string
+	// Deterministic makes the same plaintext and key always produce the
+	// same file, so unchanged chunks deduplicate in backups. It needs a
+	// password or keyfile, and a DedupSalt.
+	Deterministic bool
+	// DedupSalt is a secret that salts the key of Deterministic files, such
+	// as 32 random bytes kept with a backup repository's configuration.
+	// Only files encrypted with the same salt deduplicate.
+	DedupSalt []byte
+	// Seekable adds an index so chunks can be read without decrypting
+	// the file from the start.
+	Seekable bool
+	// NoECC leaves out the Reed-Solomon parity that lets damaged chunks
+	// be repaired.
+	NoECC  bool
+	Labels []string
+	// ChunkSize is picked from the file size when zero.
+	ChunkSize int
+	// Concurrency is the number of chunks processed at once; zero means
+	// the default.
+	Concurrency int
+	// MaxMemory bounds the memory the pipeline uses, shrinking the chunk
+	// size if needed; zero means no limit.
+	MaxMemory int64
+	// ExpectAfter makes decryption fail with ErrRollback for a file not
+	// created after it, against replay of an older version.
+	ExpectAfter time.Time
+	// Reporter receives progress; nothing is reported when it is nil.
+	Reporter Reporter
+}
+
+// Encrypt reads plaintext from src until EOF and writes the encrypted
+// stream to dst.
+func Encrypt(ctx context.Context, src io.Reader, dst io.Writer, opts Options) error {
+	if err := opts.checkEncryption(); err != nil {
+		return wrap(err)
+	}
+	return wrap(processor.EncryptStream(ctx, src, -1, dst, opts.Password, opts.processor()))
+}
+
+// Decrypt reads a file or stream written by Encrypt, EncryptFile or the
+// sweetbyte command from src and writes the plaintext to dst. Each chunk is
+// authenticated before it is written, so on error dst holds the plaintext
+// that came before the failure.
+func Decrypt(ctx context.Context, src io.Reader, dst io.Writer, opts Options) error {
+	return wrap(processor.DecryptStream(ctx, src, dst, opts.Password, opts.processor()))
+}
+
+// EncryptFile encrypts srcPath to destPath, which appears only once it is
+// complete.
+func EncryptFile(ctx context.Context, srcPath, destPath string, opts Options) error {
+	if err := opts.checkEncryption(); err != nil {
+		return wrap(err)
+	}
+	return wrap(processor.Encryption(ctx, srcPath, destPath, opts.Password, opts.processor()))
+}
+
+// DecryptFile decrypts srcPath to destPath, which appears only once the
+// whole file has been authenticated.
+func DecryptFile(ctx context.Context, srcPath, destPath string, opts Options) error {
+	return wrap(processor.Decryption(ctx, srcPath, destPath, opts.Password, opts.processor()))
+}
+
+// GenerateIdentity returns a new X25519 private key for Options.Identity.
+// Its public key is the Recipient to encrypt to.
+func GenerateIdentity() (*ecdh.PrivateKey, error) {
+	identity, err := recipient.Generate()
+	return identity, wrap(err)
+}
+
+// WriteIdentity saves identity as prefix.pub and prefix.key, the way the
+// keygen command does. It fails if either file exists.
+func WriteIdentity(prefix string, identity *ecdh.PrivateKey) (publicPath, identityPath string, err error) {
+	publicPath, identityPath, err = recipient.Write(prefix, identity)
+	return publicPath, identityPath, wrap(err)
+}
+
+// ReadPublicKey reads a public key written by WriteIdentity.
+func ReadPublicKey(path string) (*ecdh.PublicKey, error) {
+	key, err := recipient.ReadPublicKey(path)
+	return key, wrap(err)
+}
+
+// ReadIdentity reads a private key written by WriteIdentity.
+func ReadIdentity(path string) (*ecdh.PrivateKey, error) {
+	identity, err := recipient.ReadIdentity(path)
+	return identity, wrap(err)
+}
+
+func (o Options) checkEncryption() error {
+	if o.Recipient == nil && len(o.Password) == 0 && len(o.Keyfile) == 0 {
+		return errors.New(errors.CodeInvalidInput, "encrypt", ErrNoKey)
+	}
+	if o.Recipient != nil && (len(o.Password) > 0 || len(o.Keyfile) > 0) {
+		return errors.Newf(errors.CodeInvalidInput, "encrypt", "a recipient cannot be combined with a password or keyfile")
+	}
+	return nil
+}
+
+func (o Options) processor() processor.Options {
+	opts := processor.Options{
+		Keyfile:       o.Keyfile,
+		RequireBoth:   o.RequireBoth,
+		Recipient:     o.Recipient,
+		Identity:      o.Identity,
+		KDFProfile:    o.KDFProfile,
+		Cipher:        o.Cipher,
+		Deterministic: o.Deterministic,
+		DedupSalt:     o.DedupSalt,
+		Seekable:      o.Seekable,
+		NoECC:         o.NoECC,
+		Labels:        o.Labels,
+		ChunkSize:     o.ChunkSize,
+		Concurrency:   o.Concurrency,
+		MaxMemory:     o.MaxMemory,
+		ExpectAfter:   o.ExpectAfter,
+	}
+	if o.Reporter != nil {
+		opts.Reporter = internalReporter{o.Reporter}
+	}
+	return opts
+}
+
+// internalReporter adapts a Reporter to the one the pipeline takes.
+type internalReporter struct {
+	Reporter
+}
+
+func (r internalReporter) Progress(totalSize int64, description string) reporter.Progress {
+	return r.Reporter.Progress(totalSize, description)
+}
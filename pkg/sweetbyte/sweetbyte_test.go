@@ -0,0 +1,59 @@
+package sweetbyte_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hambosto/sweetbyte/pkg/sweetbyte"
+)
+
+func TestRoundTrip(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("sweetbyte "), 100_000)
+	var last sweetbyte.Stats
+	opts := sweetbyte.Options{
+		Password:   []byte("correct horse battery staple"),
+		KDFProfile: sweetbyte.KDFLight,
+		Reporter:   sweetbyte.Callbacks{OnProgress: func(s sweetbyte.Stats) { last = s }},
+	}
+
+	var encrypted bytes.Buffer
+	if err := sweetbyte.Encrypt(context.Background(), bytes.NewReader(plaintext), &encrypted, opts); err != nil {
+		t.Fatal(err)
+	}
+	if last.Done != int64(len(plaintext)) || last.Chunks == 0 {
+		t.Errorf("last progress reported %d bytes in %d chunks, want %d bytes", last.Done, last.Chunks, len(plaintext))
+	}
+
+	var decrypted bytes.Buffer
+	if err := sweetbyte.Decrypt(context.Background(), &encrypted, &decrypted, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted.Bytes(), plaintext) {
+		t.Error("decrypted plaintext differs")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	err := sweetbyte.Encrypt(context.Background(), bytes.NewReader(nil), &bytes.Buffer{}, sweetbyte.Options{})
+	if !errors.Is(err, sweetbyte.ErrNoKey) || sweetbyte.CodeOf(err) != sweetbyte.CodeInvalidInput {
+		t.Errorf("encrypting without a key: %v, want ErrNoKey", err)
+	}
+
+	var encrypted bytes.Buffer
+	opts := sweetbyte.Options{Password: []byte("right"), KDFProfile: sweetbyte.KDFLight}
+	if err := sweetbyte.Encrypt(context.Background(), bytes.NewReader([]byte("secret")), &encrypted, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	opts.Password = []byte("wrong")
+	err = sweetbyte.Decrypt(context.Background(), &encrypted, &bytes.Buffer{}, opts)
+	if !errors.Is(err, sweetbyte.ErrAuthentication) {
+		t.Errorf("decrypting with the wrong password: %v, want ErrAuthentication", err)
+	}
+	var sbErr *sweetbyte.Error
+	if !errors.As(err, &sbErr) || sbErr.Code != sweetbyte.CodeAuthentication {
+		t.Errorf("decrypting with the wrong password: %#v, want a *sweetbyte.Error with CodeAuthentication", err)
+	}
+}